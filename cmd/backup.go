@@ -0,0 +1,227 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+// default backup configuration values.
+const (
+	DefaultBackupDir  = "backups"
+	DefaultBackupKeep = 10
+
+	BackupDirKey    = "backup.dir"
+	BackupKeepKey   = "backup.keep"
+	BackupMaxAgeKey = "backup.max_age"
+)
+
+const backupCommandLongDesc = "The backup command takes a consistent snapshot of the datastore while it is in use and\n" +
+	"stores it with a timestamped name in the configured backup directory.\n\n" +
+	"Old snapshots are pruned according to the 'backup.keep' (number of snapshots) and\n" +
+	"'backup.max_age' (e.g. '720h') configuration values. Snapshots are also taken automatically\n" +
+	"before destructive commands such as delete and restore."
+
+var snapshotColumnConfigs = []table.ColumnConfig{
+	{
+		Name:  "Label",
+		Align: text.AlignCenter,
+	},
+	{
+		Name:  "Size",
+		Align: text.AlignRight,
+	},
+}
+
+func init() {
+	backupCmd := NewBackupCmd()
+	backupCmd.AddCommand(NewBackupListCmd())
+
+	rootCmd.AddCommand(backupCmd)
+}
+
+// NewBackupCmd creates a backup command.
+func NewBackupCmd() *cobra.Command {
+	// cmd represents the backup command
+	cmd := &cobra.Command{
+		Use:     "backup",
+		Short:   "Snapshot the datastore",
+		Long:    backupCommandLongDesc,
+		Example: "ogma backup",
+		Args:    cobra.NoArgs,
+		Run:     RunBackupCmd,
+	}
+
+	return cmd
+}
+
+// NewBackupListCmd creates a backup list subcommand.
+func NewBackupListCmd() *cobra.Command {
+	// cmd represents the backup list command
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List datastore snapshots",
+		Args:  cobra.NoArgs,
+		Run:   RunBackupListCmd,
+	}
+
+	return cmd
+}
+
+// RunBackupCmd performs action associated with backup command.
+func RunBackupCmd(cmd *cobra.Command, args []string) {
+	s, err := takeSnapshot("")
+	if err != nil {
+		log.Error("error creating backup: ", err)
+		cmd.PrintErrln("error creating backup: ", err)
+		return
+	}
+
+	cmd.Printf("Created backup: %s\n", s.Name)
+
+	removed, err := pruneSnapshots()
+	if err != nil {
+		log.Error("error pruning backups: ", err)
+		cmd.PrintErrln("error pruning backups: ", err)
+		return
+	}
+
+	if len(removed) > 0 {
+		cmd.Printf("Pruned %d old backup(s).\n", len(removed))
+	}
+}
+
+// RunBackupListCmd performs action associated with backup list command.
+func RunBackupListCmd(cmd *cobra.Command, args []string) {
+	// backups are never shown in color
+	r, err := outputRenderer(cmd, false)
+	if err != nil {
+		log.Error("invalid output format: ", err)
+		cmd.PrintErrln("invalid output format: ", err)
+		return
+	}
+
+	ss, err := datastore.Snapshots(backupDir())
+	if err != nil {
+		log.Error("error listing backups: ", err)
+		cmd.PrintErrln("error listing backups: ", err)
+		return
+	}
+
+	printReport(cmd, r, SnapshotsReport(ss))
+}
+
+// SnapshotsReport returns snapshot information as a report.
+func SnapshotsReport(ss []datastore.Snapshot) render.Report {
+	rep := render.Report{
+		Title: "Datastore Backups:",
+		Header: table.Row{
+			"Name",
+			"Created",
+			"Label",
+			"Size",
+		},
+		Columns: snapshotColumnConfigs,
+		Records: ss,
+		Empty:   "No backups found.",
+	}
+
+	for _, s := range ss {
+		rep.Rows = append(rep.Rows, table.Row{
+			s.Name,
+			s.Created.Format("2006-01-02 15:04:05"),
+			s.Label,
+			s.Size,
+		})
+	}
+
+	return rep
+}
+
+// takeSnapshot backs up the configured datastore. The datastore must exist. It is copied as it is stored, without
+// applying pending migrations, so an older datastore can always be restored.
+func takeSnapshot(label string) (datastore.Snapshot, error) {
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey), datastore.WithoutMigrations())
+	if err != nil {
+		return datastore.Snapshot{}, fmt.Errorf("error opening datastore: %w", err)
+	}
+	defer dsManager.Stop()
+
+	return dsManager.Backup(backupDir(), label)
+}
+
+// snapshotBeforeChange backs up the datastore ahead of a destructive change and prunes old backups.
+func snapshotBeforeChange(label string) (datastore.Snapshot, error) {
+	s, err := takeSnapshotIfExists(label)
+	if err != nil {
+		return datastore.Snapshot{}, err
+	}
+
+	if _, err = pruneSnapshots(); err != nil {
+		log.Warn("failed to prune backups: ", err)
+	}
+
+	return s, nil
+}
+
+// takeSnapshotIfExists backs up the datastore if there is one. A zero snapshot is returned when there is no
+// datastore to back up.
+func takeSnapshotIfExists(label string) (datastore.Snapshot, error) {
+	if _, err := os.Stat(viper.GetString(DatastoreFilenameKey)); errors.Is(err, os.ErrNotExist) {
+		log.WithField("label", label).Debug("no datastore to snapshot")
+		return datastore.Snapshot{}, nil
+	}
+
+	return takeSnapshot(label)
+}
+
+func pruneSnapshots() ([]datastore.Snapshot, error) {
+	keep := DefaultBackupKeep
+	if viper.IsSet(BackupKeepKey) {
+		keep = viper.GetInt(BackupKeepKey)
+	}
+
+	return datastore.Prune(backupDir(), datastore.Retention{
+		Keep:   keep,
+		MaxAge: viper.GetDuration(BackupMaxAgeKey),
+	})
+}
+
+func backupDir() string {
+	if d := viper.GetString(BackupDirKey); d != "" {
+		return d
+	}
+
+	return DefaultBackupDir
+}
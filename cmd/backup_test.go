@@ -0,0 +1,173 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+)
+
+func TestNewBackupCmd(t *testing.T) {
+	got := cmd.NewBackupCmd()
+
+	assert.Equal(t, "backup", got.Name())
+	assert.True(t, got.Runnable())
+}
+
+func TestRunBackupCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+		viper.Set(cmd.BackupKeepKey, cmd.DefaultBackupKeep)
+	}()
+
+	viper.Set(cmd.BackupDirKey, "test/backups")
+	viper.Set(cmd.BackupKeepKey, 2)
+
+	tests := []struct {
+		name      string
+		datastore string
+		want      string
+		wantCount int
+	}{
+		{
+			name:      "no datastore",
+			datastore: "test/foo.db",
+			want:      "error creating backup:  error opening datastore:",
+			wantCount: 0,
+		},
+		{
+			name:      "first backup",
+			datastore: dsFile,
+			want:      "Created backup: test_",
+			wantCount: 1,
+		},
+		{
+			name:      "second backup",
+			datastore: dsFile,
+			want:      "Created backup: test_",
+			wantCount: 2,
+		},
+		{
+			name:      "pruned backup",
+			datastore: dsFile,
+			want:      "Pruned 1 old backup(s).",
+			wantCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("datastore.filename", tt.datastore)
+
+			c := cmd.NewBackupCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs([]string{})
+
+			require.NoError(t, c.Execute())
+			assert.Contains(t, b.String(), tt.want)
+
+			ss, err := datastore.Snapshots("test/backups")
+			require.NoError(t, err)
+			assert.Len(t, ss, tt.wantCount)
+		})
+	}
+}
+
+func TestRunBackupCmdLegacy(t *testing.T) {
+	require.NoError(t, os.MkdirAll("test", 0o755))
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	legacy, err := datastore.New("test/legacy.db", datastore.WithoutMigrations())
+	require.NoError(t, err)
+	legacy.Stop()
+
+	viper.Set(cmd.BackupDirKey, "test/backups")
+	viper.Set("datastore.filename", "test/legacy.db")
+
+	c := cmd.NewBackupCmd()
+	b := bytes.NewBufferString("")
+	c.SetOut(b)
+	c.SetErr(b)
+	c.SetArgs([]string{})
+
+	require.NoError(t, c.Execute())
+	assert.Contains(t, b.String(), "Created backup: legacy-")
+
+	// neither the datastore nor its snapshot is migrated
+	ss, err := datastore.Snapshots("test/backups")
+	require.NoError(t, err)
+	require.Len(t, ss, 1)
+
+	for _, fp := range []string{"test/legacy.db", ss[0].Path} {
+		m, err := datastore.Open(fp, datastore.WithoutMigrations())
+		require.NoError(t, err)
+
+		v, err := m.SchemaVersion()
+		require.NoError(t, err)
+		assert.Equal(t, 0, v, fp)
+		m.Stop()
+	}
+}
+
+func TestRunBackupListCmd(t *testing.T) {
+	m, _ := initDatastoreManager(t)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.BackupDirKey, "test/backups")
+
+	c := cmd.NewBackupListCmd()
+	b := bytes.NewBufferString("")
+	c.SetOut(b)
+	c.SetArgs([]string{})
+
+	require.NoError(t, c.Execute())
+	assert.Equal(t, "No backups found.\n", b.String())
+
+	s, err := m.Backup("test/backups", "manual")
+	require.NoError(t, err)
+	m.Stop()
+
+	b.Reset()
+	require.NoError(t, c.Execute())
+	assert.Contains(t, b.String(), s.Name)
+	assert.Contains(t, b.String(), "manual")
+}
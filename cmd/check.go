@@ -0,0 +1,242 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/asdine/storm/v3"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+// ErrDanglingLink is returned when a link points to a listing or mail that isn't stored.
+var ErrDanglingLink = errors.New("dangling link")
+
+const checkCommandLongDesc = "The check command looks through stored records for problems that need fixing by hand."
+
+const checkLinksCommandLongDesc = "The check links command lists mail with links that can't be followed: links to a\n" +
+	"listing or correspondence that isn't stored, and text from older versions that isn't a link at all.\n\n" +
+	"New mail links are checked when they are added or imported, so these come from data stored before\n" +
+	"links were checked, or from records deleted since."
+
+var danglingLinkColumnConfigs = []table.ColumnConfig{
+	{
+		Name:  "Reference",
+		Align: text.AlignCenter,
+	},
+	{
+		Name:  "Date",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Link",
+		Align: text.AlignCenter,
+	},
+}
+
+// A DanglingLink is stored mail with a link that can't be followed.
+type DanglingLink struct {
+	Mail    Mail   `json:"mail"`
+	Problem string `json:"problem"`
+}
+
+func init() {
+	checkCmd := NewCheckCmd()
+	checkCmd.AddCommand(NewCheckLinksCmd())
+	checkCmd.AddCommand(NewCheckRefsCmd())
+
+	rootCmd.AddCommand(checkCmd)
+}
+
+// NewCheckCmd creates a check command.
+func NewCheckCmd() *cobra.Command {
+	// cmd represents the check command
+	cmd := &cobra.Command{
+		Use:     "check",
+		Short:   "Check stored records for problems",
+		Long:    checkCommandLongDesc,
+		Example: "ogma check links",
+	}
+
+	return cmd
+}
+
+// NewCheckLinksCmd creates a check links subcommand.
+func NewCheckLinksCmd() *cobra.Command {
+	// cmd represents the check links command
+	cmd := &cobra.Command{
+		Use:   "links",
+		Short: "List mail links that can't be followed",
+		Long:  checkLinksCommandLongDesc,
+		Args:  cobra.NoArgs,
+		Run:   RunCheckLinksCmd,
+	}
+
+	cmd.Flags().BoolP("pretty", "p", false, "Show prettier results.")
+
+	return cmd
+}
+
+// RunCheckLinksCmd performs action associated with check links command.
+func RunCheckLinksCmd(cmd *cobra.Command, args []string) {
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	dd, err := FindDanglingLinks(dsManager.Store)
+	if err != nil {
+		log.Error("failed to check links: ", err)
+		cmd.PrintErrln("failed to check links: ", err)
+		return
+	}
+
+	printReport(cmd, r, DanglingLinksReport(dd))
+}
+
+// FindDanglingLinks returns stored mail with links that can't be followed, in date order.
+func FindDanglingLinks(ds storm.Finder) ([]DanglingLink, error) {
+	mails := []Mail{}
+	if err := ds.All(&mails); err != nil {
+		return nil, fmt.Errorf("failure to read mail: %w", err)
+	}
+
+	sort.SliceStable(mails, func(i, j int) bool {
+		if mails[i].Date != mails[j].Date {
+			return mails[i].Date < mails[j].Date
+		}
+
+		return mails[i].Ref < mails[j].Ref
+	})
+
+	dd := []DanglingLink{}
+
+	for _, m := range mails {
+		p, err := linkProblem(ds, m.Link)
+		if err != nil {
+			return nil, err
+		}
+
+		if p != "" {
+			dd = append(dd, DanglingLink{Mail: m, Problem: p})
+		}
+	}
+
+	return dd, nil
+}
+
+// checkLink returns an error if a link can't be followed to a stored listing or mail.
+func checkLink(ds storm.Finder, l link.Link) error {
+	if l.Kind() == link.Invalid {
+		_, err := link.Parse(l.Text)
+
+		return err
+	}
+
+	p, err := linkProblem(ds, l)
+	if err != nil {
+		return err
+	}
+
+	if p != "" {
+		return fmt.Errorf("%w: %s: %s", ErrDanglingLink, l, p)
+	}
+
+	return nil
+}
+
+// linkProblem returns why a link can't be followed, or nothing if it points to a stored record or there is no
+// link.
+func linkProblem(ds storm.Finder, l link.Link) (string, error) {
+	switch l.Kind() {
+	case link.Listing:
+		err := ds.One("ID", l.Listing, &lstg.Listing{})
+		if errors.Is(err, storm.ErrNotFound) {
+			return "listing not found", nil
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("error reading listing %d: %w", l.Listing, err)
+		}
+	case link.Mail:
+		_, err := findMail(ds, l.Mail)
+		if errors.Is(err, ErrMailNotFound) {
+			return "mail not found", nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	case link.Invalid:
+		return "not a link", nil
+	case link.None:
+	}
+
+	return "", nil
+}
+
+// DanglingLinksReport returns mail with links that can't be followed as a report.
+func DanglingLinksReport(dd []DanglingLink) render.Report {
+	rep := render.Report{
+		Title: "Dangling Links:",
+		Header: table.Row{
+			"Reference",
+			"Date",
+			"Link",
+			"Problem",
+		},
+		Columns: danglingLinkColumnConfigs,
+		Records: dd,
+		Empty:   "No dangling links found.",
+	}
+
+	for _, d := range dd {
+		rep.Rows = append(rep.Rows, table.Row{
+			d.Mail.Ref,
+			d.Mail.Date,
+			d.Mail.Link.String(),
+			d.Problem,
+		})
+	}
+
+	return rep
+}
@@ -0,0 +1,279 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/asdine/storm/v3"
+	"github.com/jedib0t/go-pretty/v6/table"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+const checkRefsCommandLongDesc = "The check refs command lists mail that shares a reference with other mail. References\n" +
+	"are unique for new mail, but earlier versions could give two letters to the same member on the same day\n" +
+	"the same reference.\n\n" +
+	"Use '--repair' to give each duplicate its own reference. The oldest mail keeps the reference, and links to it\n" +
+	"are unchanged. The new references are listed so they can be written on the letters. A backup is taken first."
+
+// A RefChange is mail that was given a new reference.
+type RefChange struct {
+	Mail   Mail   `json:"mail"`
+	OldRef string `json:"old_reference"`
+}
+
+// NewCheckRefsCmd creates a check refs subcommand.
+func NewCheckRefsCmd() *cobra.Command {
+	// cmd represents the check refs command
+	cmd := &cobra.Command{
+		Use:   "refs",
+		Short: "List mail sharing a reference",
+		Long:  checkRefsCommandLongDesc,
+		Example: `ogma check refs
+ogma check refs --repair`,
+		Args: cobra.NoArgs,
+		Run:  RunCheckRefsCmd,
+	}
+
+	cmd.Flags().BoolP("repair", "r", false, "Give each duplicate its own reference.")
+	cmd.Flags().BoolP("pretty", "p", false, "Show prettier results.")
+
+	return cmd
+}
+
+// RunCheckRefsCmd performs action associated with check refs command.
+func RunCheckRefsCmd(cmd *cobra.Command, args []string) {
+	repair, _ := cmd.Flags().GetBool("repair")
+
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	if repair {
+		if _, err := takeSnapshotIfExists("pre-repair"); err != nil {
+			log.Error("error backing up datastore: ", err)
+			cmd.PrintErrln("error backing up datastore: ", err)
+			return
+		}
+	}
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	if !repair {
+		groups, err := FindDuplicateRefs(dsManager.Store)
+		if err != nil {
+			log.Error("failed to check references: ", err)
+			cmd.PrintErrln("failed to check references: ", err)
+			return
+		}
+
+		printReport(cmd, r, DuplicateRefsReport(groups))
+
+		return
+	}
+
+	changes, err := RepairDuplicateRefs(dsManager.Store)
+	if err != nil {
+		log.Error("failed to repair references: ", err)
+		cmd.PrintErrln("failed to repair references: ", err)
+		return
+	}
+
+	printReport(cmd, r, RefChangesReport(changes))
+}
+
+// FindDuplicateRefs returns groups of stored mail that share a reference, oldest first.
+func FindDuplicateRefs(ds storm.Finder) ([][]Mail, error) {
+	mails := []Mail{}
+	if err := ds.All(&mails); err != nil {
+		return nil, fmt.Errorf("failure to read mail: %w", err)
+	}
+
+	byRef := map[string][]Mail{}
+	order := []string{}
+
+	// mail is read in ID order, so the oldest mail with a reference comes first
+	for _, m := range mails {
+		if m.Ref == "" {
+			continue
+		}
+
+		ref := strings.ToLower(m.Ref)
+		if _, ok := byRef[ref]; !ok {
+			order = append(order, ref)
+		}
+
+		byRef[ref] = append(byRef[ref], m)
+	}
+
+	groups := [][]Mail{}
+
+	for _, ref := range order {
+		if len(byRef[ref]) > 1 {
+			groups = append(groups, byRef[ref])
+		}
+	}
+
+	return groups, nil
+}
+
+// RepairDuplicateRefs gives all but the oldest mail in each group sharing a reference a new reference of the
+// same length.
+func RepairDuplicateRefs(n storm.Node) ([]RefChange, error) {
+	tx, err := n.Begin(true)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning datastore transaction: %w", err)
+	}
+	defer func() {
+		if errRollback := tx.Rollback(); errRollback != nil && !errors.Is(errRollback, storm.ErrNotInTransaction) {
+			log.Error("failed to rollback datastore transaction: ", errRollback)
+		}
+	}()
+
+	groups, err := FindDuplicateRefs(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []RefChange{}
+
+	for _, g := range groups {
+		for _, m := range g[1:] {
+			c := RefChange{Mail: m, OldRef: m.Ref}
+
+			if c.Mail.Ref, err = NewMailRef(tx, m, len(m.Ref)); err != nil {
+				return nil, fmt.Errorf("error creating reference for mail id=%d: %w", m.ID, err)
+			}
+
+			if err = tx.Save(&c.Mail); err != nil {
+				return nil, fmt.Errorf("error saving mail id=%d: %w", m.ID, err)
+			}
+
+			log.WithFields(log.Fields{
+				"id":  m.ID,
+				"old": c.OldRef,
+				"new": c.Mail.Ref,
+			}).Info("repaired duplicate reference")
+
+			changes = append(changes, c)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing references to datastore: %w", err)
+	}
+
+	return changes, nil
+}
+
+// DuplicateRefsReport returns groups of mail sharing a reference as a report, one group after another.
+func DuplicateRefsReport(groups [][]Mail) render.Report {
+	rep := render.Report{
+		Title: "Duplicate References:",
+		Header: table.Row{
+			"Reference",
+			"Sender",
+			"Receiver",
+			"Date",
+			"Link",
+			"Status",
+		},
+		Columns: mailColumnConfigs,
+		Empty:   "No duplicate references found.",
+	}
+
+	mm := []Mail{}
+
+	for _, g := range groups {
+		for _, m := range g {
+			mm = append(mm, m)
+			rep.Rows = append(rep.Rows, table.Row{
+				m.Ref,
+				m.Sender.String(),
+				m.Receiver.String(),
+				m.Date,
+				m.Link.String(),
+				m.Status,
+			})
+		}
+	}
+
+	rep.Records = mm
+
+	if len(mm) > 0 {
+		rep.Note = "Use '--repair' to give each duplicate its own reference."
+	}
+
+	return rep
+}
+
+// RefChangesReport returns mail given new references as a report, by date.
+func RefChangesReport(changes []RefChange) render.Report {
+	changes = append([]RefChange{}, changes...)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Mail.Date < changes[j].Mail.Date })
+
+	rep := render.Report{
+		Title: "Repaired References:",
+		Header: table.Row{
+			"Old Reference",
+			"Reference",
+			"Sender",
+			"Receiver",
+			"Date",
+		},
+		Columns: mailColumnConfigs,
+		Records: changes,
+		Empty:   "No duplicate references found.",
+	}
+
+	for _, c := range changes {
+		rep.Rows = append(rep.Rows, table.Row{
+			c.OldRef,
+			c.Mail.Ref,
+			c.Mail.Sender.String(),
+			c.Mail.Receiver.String(),
+			c.Mail.Date,
+		})
+	}
+
+	if len(changes) > 0 {
+		rep.Note = "Write the new references on these letters."
+	}
+
+	return rep
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+)
+
+func TestRunCheckRefsCmd(t *testing.T) {
+	require.NoError(t, os.MkdirAll("test", 0o755))
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	dsFile := fmt.Sprintf("test/refs_%d.db", time.Now().Unix())
+	legacy, err := datastore.New(dsFile, datastore.WithoutMigrations())
+	require.NoError(t, err)
+
+	// mail record as stored before references were unique
+	type Mail struct {
+		ID       int    `storm:"id,increment"`
+		Ref      string `json:"reference"`
+		Sender   string `json:"sender"`
+		Receiver string `json:"receiver"`
+		Date     string `json:"date"`
+	}
+
+	require.NoError(t, legacy.Save(&Mail{Ref: "f2165e", Sender: "1234", Receiver: "5678", Date: "2021-11-15"}))
+	require.NoError(t, legacy.Save(&Mail{Ref: "f2165e", Sender: "1234", Receiver: "5678", Date: "2021-11-15"}))
+	require.NoError(t, legacy.Save(&Mail{Ref: "650e0a", Sender: "123", Receiver: "45678", Date: "2021-11-15"}))
+	_, err = legacy.Migrate(false)
+	require.NoError(t, err)
+	legacy.Stop()
+
+	viper.Set(cmd.BackupDirKey, "test/backups")
+	viper.Set(cmd.DatastoreFilenameKey, dsFile)
+
+	run := func(args ...string) string {
+		c := cmd.NewCheckRefsCmd()
+		b := bytes.NewBufferString("")
+		c.SetOut(b)
+		c.SetErr(b)
+		c.SetArgs(args)
+		require.NoError(t, c.Execute())
+
+		return b.String()
+	}
+
+	got := run()
+	assert.Contains(t, got, "| f2165e    |   1234 |     5678 | 2021-11-15 |      |        |\n"+
+		"| f2165e    |   1234 |     5678 | 2021-11-15 |      |        |\n")
+	assert.Contains(t, got, "Use '--repair' to give each duplicate its own reference.\n")
+	assert.NotContains(t, got, "650e0a")
+
+	viper.Set(cmd.OutputKey, "jsonl")
+	got = run()
+	viper.Set(cmd.OutputKey, "")
+	assert.Equal(t, `{"ID":1,"reference":"f2165e","sender":"1234","receiver":"5678","date":"2021-11-15","link":null}`+"\n"+
+		`{"ID":2,"reference":"f2165e","sender":"1234","receiver":"5678","date":"2021-11-15","link":null}`+"\n", got)
+
+	got = run("--repair")
+	assert.Contains(t, got, "| f2165e        | 31502b    |   1234 |     5678 | 2021-11-15 |\n")
+	assert.Contains(t, got, "Write the new references on these letters.\n")
+
+	assert.Equal(t, "No duplicate references found.\n", run())
+
+	// a backup is taken before references are changed
+	snapshots, err := os.ReadDir("test/backups")
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+}
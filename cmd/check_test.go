@@ -0,0 +1,94 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+func TestNewCheckLinksCmd(t *testing.T) {
+	got := cmd.NewCheckLinksCmd()
+
+	assert.Equal(t, "links", got.Name())
+	assert.Equal(t, "List mail links that can't be followed", got.Short)
+	assert.True(t, got.Runnable())
+}
+
+func TestRunCheckLinksCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.DatastoreFilenameKey, dsFile)
+
+	run := func(args ...string) string {
+		c := cmd.NewCheckLinksCmd()
+		b := bytes.NewBufferString("")
+		c.SetOut(b)
+		c.SetErr(b)
+		c.SetArgs(args)
+		require.NoError(t, c.Execute())
+
+		return b.String()
+	}
+
+	assert.Equal(t, "No dangling links found.\n", run())
+
+	m, err := datastore.Open(dsFile)
+	require.NoError(t, err)
+
+	id := func(n int) member.ID { return member.ID{Number: n} }
+
+	for _, mail := range []cmd.Mail{
+		{Ref: "a9a9a9", Sender: id(1234), Receiver: id(999), Date: "1999-01-01", Link: link.ToListing(99)},
+		{Ref: "d3d3d3", Sender: id(777), Receiver: id(1234), Date: "1990-01-01", Link: link.ToMail("deadbe")},
+		{Ref: "e4e4e4", Sender: id(1234), Receiver: id(777), Date: "1990-02-01", Link: link.Link{Text: "see letter"}},
+		{Ref: "f5f5f5", Sender: id(1234), Receiver: id(777), Date: "1990-03-01", Link: link.ToMail("d3d3d3")},
+	} {
+		r := mail
+		require.NoError(t, m.Save(&r))
+	}
+
+	m.Stop()
+
+	got := run()
+	assert.Contains(t, got, "|   d3d3d3  | 1990-01-01 |   Mdeadbe  | mail not found    |\n"+
+		"|   e4e4e4  | 1990-02-01 | see letter | not a link        |\n"+
+		"|   a9a9a9  | 1999-01-01 |     L99    | listing not found |\n")
+	assert.NotContains(t, got, "f5f5f5")
+	assert.NotContains(t, got, "123d5f")
+}
@@ -31,7 +31,8 @@ import (
 	"github.com/spf13/viper"
 )
 
-const deleteCommandLongDesc = "The delete command removes the datastore file. This action cannot be undone!"
+const deleteCommandLongDesc = "The delete command removes the datastore file. A backup snapshot is taken first and can be\n" +
+	"brought back with the restore command."
 
 // NewDeleteCmd creates a delete command.
 func NewDeleteCmd() *cobra.Command {
@@ -60,6 +61,17 @@ func RunDeleteCmd(cmd *cobra.Command, args []string) {
 	}
 
 	if isAll {
+		s, err := snapshotBeforeChange("pre-delete")
+		if err != nil {
+			cmd.Println("error backing up data before delete: ", err)
+			log.Error("error backing up data before delete: ", err)
+			return
+		}
+
+		if s.Name != "" {
+			cmd.Println("created backup: ", s.Name)
+		}
+
 		if err := clearData(); err != nil {
 			cmd.Println("error deleting all data: ", err)
 			log.Error("error deleting all data: ", err)
@@ -32,6 +32,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
 )
 
 func TestNewDeleteCmd(t *testing.T) {
@@ -49,6 +50,8 @@ func TestRunDeleteCmd(t *testing.T) {
 		require.NoError(t, os.RemoveAll("test/"))
 	}()
 
+	viper.Set(cmd.BackupDirKey, "test/backups")
+
 	tests := []struct {
 		name      string
 		args      []string
@@ -88,4 +91,9 @@ func TestRunDeleteCmd(t *testing.T) {
 			assert.Contains(t, b.String(), tt.want)
 		})
 	}
+
+	ss, err := datastore.Snapshots("test/backups")
+	require.NoError(t, err)
+	require.Len(t, ss, 1, "deleting should take exactly one backup")
+	assert.Equal(t, "pre-delete", ss[0].Label)
 }
@@ -0,0 +1,379 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+// default due configuration values.
+const (
+	DefaultDueReplyDays = 30
+	DefaultDueQuietDays = 90
+
+	DueReplyDaysKey = "due.reply_days"
+	DueQuietDaysKey = "due.quiet_days"
+	DueMembersKey   = "due.members"
+)
+
+// A DueKind is why a penpal is listed as due.
+type DueKind string
+
+// Due kinds.
+const (
+	// DueReply is a penpal whose last letter hasn't been answered.
+	DueReply DueKind = "reply owed"
+
+	// DueQuiet is a penpal who hasn't answered your last letter.
+	DueQuiet DueKind = "gone quiet"
+)
+
+const dueCommandLongDesc = "The due command lists penpals who are owed a reply, because their last letter came after\n" +
+	"the last one sent to them, and penpals who have gone quiet, because they haven't answered a letter for longer\n" +
+	"than expected. Mail is grouped by the other member in each letter to or from the configured 'member' number.\n" +
+	"Drafted, returned, and lost mail doesn't count as a letter.\n\n" +
+	"Penpals are listed by how overdue they are. A reply is due 'due.reply_days' days after a letter arrives, and a\n" +
+	"penpal has gone quiet 'due.quiet_days' days after a letter to them. Set 'due.members.<number>.reply_days' and\n" +
+	"'due.members.<number>.quiet_days' to change these for one member in the config, or use 'ogma member edit'\n" +
+	"with '--reply-days' and '--quiet-days' to store them on the member."
+
+var dueColumnConfigs = []table.ColumnConfig{
+	{
+		Name:  "Member",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Last Letter",
+		Align: text.AlignCenter,
+	},
+	{
+		Name:  "Days",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Overdue",
+		Align: text.AlignRight,
+	},
+}
+
+// DueThresholds are the days allowed before a reply is overdue and before a penpal has gone quiet.
+type DueThresholds struct {
+	ReplyDays int `mapstructure:"reply_days"`
+	QuietDays int `mapstructure:"quiet_days"`
+}
+
+// A Due is a penpal who is owed a reply or has gone quiet.
+type Due struct {
+	Member    member.ID `json:"member"`
+	Name      string    `json:"name"`
+	Kind      DueKind   `json:"status"`
+	Last      Mail      `json:"last"`
+	Days      int       `json:"days"`
+	Threshold int       `json:"threshold"`
+}
+
+// Overdue returns how many days past its threshold a due is. It is negative while there is still time.
+func (d Due) Overdue() int {
+	return d.Days - d.Threshold
+}
+
+func init() {
+	rootCmd.AddCommand(NewDueCmd())
+}
+
+// NewDueCmd creates a due command.
+func NewDueCmd() *cobra.Command {
+	// cmd represents the due command
+	cmd := &cobra.Command{
+		Use:     "due",
+		Short:   "List penpals owed a reply or gone quiet",
+		Long:    dueCommandLongDesc,
+		Example: "ogma due --overdue",
+		Args:    cobra.NoArgs,
+		Run:     RunDueCmd,
+	}
+
+	cmd.Flags().StringP("date", "d", time.Now().Format(DateFormat), "Date to count from.")
+	cmd.Flags().BoolP("overdue", "o", false, "Only list replies that are overdue.")
+	cmd.Flags().BoolP("pretty", "p", false, "Show prettier results.")
+
+	return cmd
+}
+
+// RunDueCmd implements functionality of a due command.
+func RunDueCmd(cmd *cobra.Command, args []string) {
+	self, err := member.Parse(viper.GetString("member"))
+	if err != nil {
+		log.Error("invalid member configuration: ", err)
+		cmd.PrintErrln("invalid 'member' configuration: ", err)
+		return
+	}
+
+	date, _ := cmd.Flags().GetString("date")
+
+	asOf, err := time.Parse(DateFormat, date)
+	if err != nil {
+		log.WithField("date", date).Error("invalid date argument: ", err)
+		cmd.PrintErrln("invalid input: ", fmt.Errorf("date format must be 'yyyy-mm-dd': %w", err))
+		return
+	}
+
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	dd, err := FindDue(dsManager.Store, self, asOf)
+	if err != nil {
+		log.Error("failed to find due correspondence: ", err)
+		cmd.PrintErrln("failed to find due correspondence: ", err)
+		return
+	}
+
+	if overdue, _ := cmd.Flags().GetBool("overdue"); overdue {
+		kept := []Due{}
+
+		for _, d := range dd {
+			if d.Overdue() > 0 {
+				kept = append(kept, d)
+			}
+		}
+
+		dd = kept
+	}
+
+	printReport(cmd, r, DueReport(dd))
+}
+
+// FindDue returns penpals of self who are owed a reply or have gone quiet on a date, most overdue first. Mail after
+// the date is left out. Every owed reply is listed, even before it is overdue; penpals are only listed as gone
+// quiet once they are overdue.
+func FindDue(ds storm.Finder, self member.ID, asOf time.Time) ([]Due, error) {
+	mails := []Mail{}
+	if err := ds.All(&mails); err != nil {
+		return nil, fmt.Errorf("failure to read mail: %w", err)
+	}
+
+	members := []Member{}
+	if err := ds.All(&members); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, fmt.Errorf("failure to read members: %w", err)
+	}
+
+	byNumber := map[member.ID]Member{}
+	for _, m := range members {
+		byNumber[m.Number] = m
+	}
+
+	// the latest letter each way between self and each penpal
+	type exchange struct {
+		in, out *Mail
+	}
+
+	exchanges := map[member.ID]*exchange{}
+	day := asOf.Format(DateFormat)
+
+	for i := range mails {
+		m := &mails[i]
+		if !countsAsLetter(*m) || m.Date > day {
+			continue
+		}
+
+		var penpal member.ID
+
+		isIn := self.Matches(m.Receiver) && !self.Matches(m.Sender)
+
+		switch {
+		case isIn:
+			penpal = m.Sender
+		case self.Matches(m.Sender) && !self.Matches(m.Receiver):
+			penpal = m.Receiver
+		default:
+			continue
+		}
+
+		e, ok := exchanges[penpal]
+		if !ok {
+			e = &exchange{}
+			exchanges[penpal] = e
+		}
+
+		last := &e.out
+		if isIn {
+			last = &e.in
+		}
+
+		if *last == nil || (*last).Date < m.Date {
+			*last = m
+		}
+	}
+
+	dd := []Due{}
+
+	for penpal, e := range exchanges {
+		th := dueThresholds(penpal, byNumber[penpal])
+		d := Due{Member: penpal, Name: byNumber[penpal].Name}
+
+		switch {
+		case e.in != nil && (e.out == nil || e.out.Date < e.in.Date):
+			d.Kind, d.Last, d.Threshold = DueReply, *e.in, th.ReplyDays
+		case e.out != nil:
+			d.Kind, d.Last, d.Threshold = DueQuiet, *e.out, th.QuietDays
+		default:
+			continue
+		}
+
+		sent, err := time.Parse(DateFormat, d.Last.Date)
+		if err != nil {
+			log.WithField("ref", d.Last.Ref).Warn("skipping mail with invalid date: ", err)
+			continue
+		}
+
+		d.Days = int(asOf.Sub(sent).Hours() / 24)
+
+		if d.Kind == DueQuiet && d.Overdue() <= 0 {
+			continue
+		}
+
+		dd = append(dd, d)
+	}
+
+	sort.Slice(dd, func(i, j int) bool {
+		if dd[i].Overdue() != dd[j].Overdue() {
+			return dd[i].Overdue() > dd[j].Overdue()
+		}
+
+		if dd[i].Member.Number != dd[j].Member.Number {
+			return dd[i].Member.Number < dd[j].Member.Number
+		}
+
+		return dd[i].Member.Extension < dd[j].Member.Extension
+	})
+
+	return dd, nil
+}
+
+// countsAsLetter reports whether mail was sent. Drafts haven't been, and returned or lost mail never arrived.
+func countsAsLetter(m Mail) bool {
+	switch m.Status { //nolint:exhaustive // every other status is a letter that was sent
+	case StatusDrafted, StatusReturned, StatusLost:
+		return false
+	default:
+		return true
+	}
+}
+
+// dueThresholds returns the thresholds for a penpal. Thresholds stored on the member come first, then those
+// configured for the member number, then the configured defaults. Configuration for a member number without an
+// extension applies to every extension of it.
+func dueThresholds(id member.ID, m Member) DueThresholds {
+	th := DueThresholds{
+		ReplyDays: viper.GetInt(DueReplyDaysKey),
+		QuietDays: viper.GetInt(DueQuietDaysKey),
+	}
+
+	for _, key := range []string{member.ID{Number: id.Number}.String(), id.String()} {
+		var configured DueThresholds
+		if err := viper.UnmarshalKey(DueMembersKey+"."+strings.ToLower(key), &configured); err != nil {
+			log.WithField("member", key).Warn("invalid due configuration: ", err)
+			continue
+		}
+
+		th = th.override(configured)
+	}
+
+	return th.override(DueThresholds{ReplyDays: m.ReplyDays, QuietDays: m.QuietDays})
+}
+
+// override returns the thresholds with any set in o replacing them.
+func (th DueThresholds) override(o DueThresholds) DueThresholds {
+	if o.ReplyDays > 0 {
+		th.ReplyDays = o.ReplyDays
+	}
+
+	if o.QuietDays > 0 {
+		th.QuietDays = o.QuietDays
+	}
+
+	return th
+}
+
+// DueReport returns penpals who are owed a reply or have gone quiet as a report.
+func DueReport(dd []Due) render.Report {
+	rep := render.Report{
+		Title: "Correspondence Due:",
+		Header: table.Row{
+			"Member",
+			"Name",
+			"Status",
+			"Last Letter",
+			"Date",
+			"Days",
+			"Overdue",
+		},
+		Columns: dueColumnConfigs,
+		Records: dd,
+		Empty:   "No correspondence due.",
+	}
+
+	for _, d := range dd {
+		overdue := ""
+		if d.Overdue() > 0 {
+			overdue = fmt.Sprintf("%d", d.Overdue())
+		}
+
+		rep.Rows = append(rep.Rows, table.Row{
+			d.Member.String(),
+			d.Name,
+			d.Kind,
+			d.Last.Ref,
+			d.Last.Date,
+			d.Days,
+			overdue,
+		})
+	}
+
+	return rep
+}
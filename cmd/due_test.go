@@ -0,0 +1,129 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+func TestNewDueCmd(t *testing.T) {
+	got := cmd.NewDueCmd()
+
+	assert.Equal(t, "due", got.Name())
+	assert.Equal(t, "List penpals owed a reply or gone quiet", got.Short)
+	assert.True(t, got.Runnable())
+}
+
+func TestRunDueCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+
+	id := func(n int) member.ID { return member.ID{Number: n} }
+
+	for _, mail := range []cmd.Mail{
+		// owed a reply, with a reply threshold configured for the member
+		{Ref: "a1a1a1", Sender: id(777), Receiver: id(1234), Date: "2021-01-10"},
+		// gone quiet, with a quiet threshold stored on the member
+		{Ref: "b2b2b2", Sender: id(888), Receiver: id(1234), Date: "2020-09-01"},
+		{Ref: "c3c3c3", Sender: id(1234), Receiver: id(888), Date: "2020-10-01"},
+		// owed a reply, but not overdue yet
+		{Ref: "d4d4d4", Sender: id(999), Receiver: id(1234), Date: "2021-02-20"},
+		// answered recently
+		{Ref: "e5e5e5", Sender: id(1234), Receiver: id(444), Date: "2021-02-01"},
+		// a draft isn't a reply
+		{Ref: "f6f6f6", Sender: id(1234), Receiver: id(999), Date: "2021-02-25", Status: cmd.StatusDrafted},
+	} {
+		r := mail
+		require.NoError(t, m.Save(&r))
+	}
+
+	require.NoError(t, m.Save(&cmd.Member{Number: id(888), Name: "Quiet Penpal", QuietDays: 120}))
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.DatastoreFilenameKey, dsFile)
+	viper.Set("member", "1234")
+	viper.Set(cmd.DueReplyDaysKey, cmd.DefaultDueReplyDays)
+	viper.Set(cmd.DueQuietDaysKey, cmd.DefaultDueQuietDays)
+	viper.Set(cmd.DueMembersKey+".777.reply_days", 40)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		notWant string
+	}{
+		{
+			name: "as of date",
+			args: []string{"-d2021-03-01"},
+			want: "|     55 |              | gone quiet |    b12cd3   | 1986-05-16 | 12708 |   12618 |\n" +
+				"|    888 | Quiet Penpal | gone quiet |    c3c3c3   | 2020-10-01 |   151 |      31 |\n" +
+				"|    777 |              | reply owed |    a1a1a1   | 2021-01-10 |    50 |      10 |\n" +
+				"|    999 |              | reply owed |    d4d4d4   | 2021-02-20 |     9 |         |\n",
+			notWant: "444",
+		},
+		{
+			name:    "overdue",
+			args:    []string{"-d2021-03-01", "--overdue"},
+			want:    "|    777 |              | reply owed |    a1a1a1   | 2021-01-10 |    50 |      10 |\n",
+			notWant: "999",
+		},
+		{
+			name: "nothing due",
+			args: []string{"-d1986-05-20"},
+			want: "No correspondence due.\n",
+		},
+		{
+			name: "invalid date",
+			args: []string{"-dMarch"},
+			want: "invalid input:  date format must be 'yyyy-mm-dd'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewDueCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			require.NoError(t, c.Execute())
+			assert.Contains(t, b.String(), tt.want)
+			if tt.notWant != "" {
+				assert.NotContains(t, b.String(), tt.notWant)
+			}
+		})
+	}
+}
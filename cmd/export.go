@@ -23,9 +23,11 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -35,7 +37,43 @@ import (
 	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
 )
 
-const exportCommandLongDesc = "The export command exports records from the datastore to json format. These files can be reimported."
+const exportCommandLongDesc = "The export command exports records from the datastore to json format. These files can be reimported.\n\n" +
+	"Exporting 'all' records writes a single archive containing issues, listings, mail, and members that can be\n" +
+	"read back with 'ogma import archive'.\n\n" +
+	"Listings and mail can also be written as csv or tsv with '--format', with a header naming the field in each\n" +
+	"column. Mail addresses and status histories aren't written to csv or tsv files."
+
+// ArchiveFormatVersion is the version of the archive document layout. Version 2 writes member numbers as
+// strings so they can carry an extension, version 3 writes member addresses as structured addresses,
+// version 4 writes each member's address history, version 5 writes mail links as the record they point to,
+// and version 6 adds the issue catalogue. Earlier archives can still be imported.
+const ArchiveFormatVersion = 6
+
+// An Archive holds every record type from a datastore in a single document.
+type Archive struct {
+	Metadata ArchiveMetadata `json:"metadata"`
+	Issues   []Issue         `json:"issues"`
+	Listings []lstg.Listing  `json:"listings"`
+	Mails    []Mail          `json:"mails"`
+	Members  []Member        `json:"members"`
+}
+
+// ArchiveMetadata describes where and when an archive was created.
+type ArchiveMetadata struct {
+	FormatVersion int           `json:"format_version"`
+	OgmaVersion   string        `json:"ogma_version"`
+	ExportedAt    time.Time     `json:"exported_at"`
+	SchemaVersion int           `json:"schema_version"`
+	Counts        ArchiveCounts `json:"counts"`
+}
+
+// ArchiveCounts records how many of each record type an archive holds.
+type ArchiveCounts struct {
+	Issues   int `json:"issues"`
+	Listings int `json:"listings"`
+	Mails    int `json:"mails"`
+	Members  int `json:"members"`
+}
 
 var (
 	exportType string
@@ -56,27 +94,43 @@ func NewExportCmd() *cobra.Command {
 		Run:   RunExportCmd,
 	}
 
-	cmd.Flags().StringVarP(&exportType, "record", "r", "all", "type of record to export ('mail', 'listing', or 'all')")
+	cmd.Flags().StringVarP(&exportType, "record", "r", "all", "type of record to export ('mail', 'listing', or 'all' for an archive)")
 	cmd.Flags().StringVarP(&exportFile, "outfile", "o", "export.json", "file to export records to")
+	addFormatFlags(cmd)
 
 	return cmd
 }
 
 // RunExportCmd performs action associated with export command.
 func RunExportCmd(cmd *cobra.Command, args []string) {
+	format, err := fileFormatFlag(cmd, exportFile)
+	if err != nil {
+		cmd.PrintErrln("invalid option: ", err)
+		return
+	}
+
+	if format.isDelimited() && !cmd.Flags().Changed("outfile") {
+		exportFile = "export." + format.Name
+	}
+
 	switch exportType {
 	case "all":
+		if format.isDelimited() {
+			cmd.PrintErrln("invalid option: archives are only written as json: ", format.Name)
+			return
+		}
+
 		if err := exportAll(); err != nil {
 			cmd.Println("error exporting all data: ", err)
 			log.Error("error exporting all data: ", err)
 		}
 	case "listing":
-		if err := exportListing(); err != nil {
+		if err := exportListing(format); err != nil {
 			cmd.Println("error exporting listing data: ", err)
 			log.Error("error exporting listing data: ", err)
 		}
 	case "mail":
-		if err := exportMail(); err != nil {
+		if err := exportMail(format); err != nil {
 			cmd.Println("error exporting mail data: ", err)
 			log.Error("error exporting mail data: ", err)
 		}
@@ -88,7 +142,7 @@ func RunExportCmd(cmd *cobra.Command, args []string) {
 	cmd.Println("successfully exported data")
 }
 
-func exportMail() error {
+func exportMail(format FileFormat) error {
 	ds, err := datastore.New(viper.GetString("datastore.filename"))
 	if err != nil {
 		return fmt.Errorf("error accessing datastore: %w", err)
@@ -101,12 +155,12 @@ func exportMail() error {
 		return fmt.Errorf("error getting mail records: %w", err)
 	}
 
-	mailData, err := json.Marshal(mailRecords)
-	if err != nil {
-		return fmt.Errorf("error marshaling mail records: %w", err)
+	var mailData bytes.Buffer
+	if err = format.encode(&mailData, mailRecords, mailRecords.Mails); err != nil {
+		return fmt.Errorf("error encoding mail records: %w", err)
 	}
 
-	err = os.WriteFile(exportFile, mailData, 0o600)
+	err = os.WriteFile(exportFile, mailData.Bytes(), 0o600)
 	if err != nil {
 		return fmt.Errorf("error writing mail data export: %w", err)
 	}
@@ -114,7 +168,7 @@ func exportMail() error {
 	return nil
 }
 
-func exportListing() error {
+func exportListing(format FileFormat) error {
 	ds, err := datastore.New(viper.GetString("datastore.filename"))
 	if err != nil {
 		return fmt.Errorf("error accessing datastore: %w", err)
@@ -127,12 +181,12 @@ func exportListing() error {
 		return fmt.Errorf("error getting listing records: %w", err)
 	}
 
-	listingData, err := json.Marshal(listingRecords)
-	if err != nil {
-		return fmt.Errorf("error marshaling listing records: %w", err)
+	var listingData bytes.Buffer
+	if err = format.encode(&listingData, listingRecords, listingRecords.Listings); err != nil {
+		return fmt.Errorf("error encoding listing records: %w", err)
 	}
 
-	err = os.WriteFile(exportFile, listingData, 0o600)
+	err = os.WriteFile(exportFile, listingData.Bytes(), 0o600)
 	if err != nil {
 		return fmt.Errorf("error writing listing data export: %w", err)
 	}
@@ -141,13 +195,79 @@ func exportListing() error {
 }
 
 func exportAll() error {
-	if err := exportListing(); err != nil {
-		return fmt.Errorf("error with exporting listings when exporting all records: %w", err)
+	ds, err := datastore.New(viper.GetString("datastore.filename"))
+	if err != nil {
+		return fmt.Errorf("error accessing datastore: %w", err)
+	}
+	defer ds.Stop()
+
+	a, err := newArchive(ds)
+	if err != nil {
+		return err
+	}
+
+	archiveData, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("error marshaling archive: %w", err)
 	}
 
-	if err := exportMail(); err != nil {
-		return fmt.Errorf("error with exporting mail when exporting all records: %w", err)
+	err = os.WriteFile(exportFile, archiveData, 0o600)
+	if err != nil {
+		return fmt.Errorf("error writing archive export: %w", err)
 	}
 
+	log.WithFields(log.Fields{
+		"issues":   a.Metadata.Counts.Issues,
+		"listings": a.Metadata.Counts.Listings,
+		"mails":    a.Metadata.Counts.Mails,
+		"members":  a.Metadata.Counts.Members,
+	}).Info("exported archive")
+
 	return nil
 }
+
+// newArchive collects all records in the datastore into an archive.
+func newArchive(ds *datastore.Manager) (Archive, error) {
+	a := Archive{
+		Issues:   []Issue{},
+		Listings: []lstg.Listing{},
+		Mails:    []Mail{},
+		Members:  []Member{},
+	}
+
+	if err := ds.All(&a.Issues); err != nil {
+		return Archive{}, fmt.Errorf("error getting issue records: %w", err)
+	}
+
+	if err := ds.All(&a.Listings); err != nil {
+		return Archive{}, fmt.Errorf("error getting listing records: %w", err)
+	}
+
+	if err := ds.All(&a.Mails); err != nil {
+		return Archive{}, fmt.Errorf("error getting mail records: %w", err)
+	}
+
+	if err := ds.All(&a.Members); err != nil {
+		return Archive{}, fmt.Errorf("error getting member records: %w", err)
+	}
+
+	v, err := ds.SchemaVersion()
+	if err != nil {
+		return Archive{}, fmt.Errorf("error getting schema version: %w", err)
+	}
+
+	a.Metadata = ArchiveMetadata{
+		FormatVersion: ArchiveFormatVersion,
+		OgmaVersion:   rootCmd.Version,
+		ExportedAt:    time.Now().UTC(),
+		SchemaVersion: v,
+		Counts: ArchiveCounts{
+			Issues:   len(a.Issues),
+			Listings: len(a.Listings),
+			Mails:    len(a.Mails),
+			Members:  len(a.Members),
+		},
+	}
+
+	return a, nil
+}
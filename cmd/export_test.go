@@ -25,6 +25,7 @@ package cmd_test
 import (
 	"bytes"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -112,6 +113,38 @@ func TestRunExportCmd(t *testing.T) {
 			assertion: assert.Error,
 			want:      errorReturn,
 		},
+		{
+			name:      "listing export as csv",
+			args:      []string{"-r=listing", "-o=test/listings.csv"},
+			datastore: dsFile,
+			export:    "test/listings.csv",
+			assertion: assert.NoError,
+			want:      successReturn,
+		},
+		{
+			name:      "mail export as tsv",
+			args:      []string{"-r=mail", "--format=tsv", "-o=test/mail.txt"},
+			datastore: dsFile,
+			export:    "test/mail.txt",
+			assertion: assert.NoError,
+			want:      successReturn,
+		},
+		{
+			name:      "all export as csv",
+			args:      []string{"-r=all", "--format=csv", "-o=test/all.csv"},
+			datastore: dsFile,
+			export:    "test/all.csv",
+			assertion: assert.Error,
+			want:      "invalid option: archives are only written as json:",
+		},
+		{
+			name:      "unknown format",
+			args:      []string{"-r=listing", "--format=xml", "-o=test/listings.xml"},
+			datastore: dsFile,
+			export:    "test/listings.xml",
+			assertion: assert.Error,
+			want:      "invalid option:  format must be json, csv, or tsv: \"xml\"",
+		},
 		{
 			name:      "invalid record type",
 			args:      []string{"-r=foo", validExportArg},
@@ -141,4 +174,24 @@ func TestRunExportCmd(t *testing.T) {
 			assert.Contains(t, b.String(), tt.want)
 		})
 	}
+
+	// the delimiter setting is used when no delimiter is given
+	viper.Set(cmd.ImportDelimiterKey, "|")
+	defer viper.Set(cmd.ImportDelimiterKey, ",")
+
+	c := cmd.NewExportCmd()
+	c.SetOut(bytes.NewBufferString(""))
+	c.SetArgs([]string{"-r=mail", "-o=test/mail.csv"})
+	require.NoError(t, c.Execute())
+
+	got, err := os.ReadFile("test/mail.csv")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(got), "ID|reference|sender|receiver|date|link|"), string(got))
+
+	// csv exports have a header naming each column
+	got, err = os.ReadFile("test/listings.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "ID,volume,issue,year,season,page,category,member,alt,international,review,text,art,flag\n"+
+		"1,1,1,1986,Mollit,1,Pariatur,1234,,false,false,Esse Lorem do nulla sunt mollit nulla in.,false,true\n",
+		strings.SplitAfterN(string(got), "\n", 3)[0]+strings.SplitAfterN(string(got), "\n", 3)[1])
 }
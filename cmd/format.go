@@ -0,0 +1,160 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/delimited"
+)
+
+// Formats of import and export files.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatTSV  = "tsv"
+)
+
+// ImportDelimiterKey is the configuration key for the column delimiter of csv import and export files.
+const ImportDelimiterKey = "import.delimiter"
+
+// A FileFormat is how records are written in an import or export file. Delimiter separates the columns of csv
+// and tsv files.
+type FileFormat struct {
+	Name      string
+	Delimiter rune
+}
+
+// addFormatFlags adds the flags choosing the format of an import or export file.
+func addFormatFlags(cmd *cobra.Command) {
+	cmd.Flags().String("format", "", "File format: json, csv, or tsv. (default is read from the file extension, or json)")
+	cmd.Flags().String("delimiter", "", "Column delimiter for csv files, like ';' or '|'. (default is the 'import.delimiter' setting, or ',')")
+}
+
+// fileFormatFlag reads the file format from the '--format' and '--delimiter' flags. The format is read from the
+// file extension when it isn't given, and a file with a delimiter but no csv or tsv extension is a csv file.
+func fileFormatFlag(cmd *cobra.Command, filename string) (FileFormat, error) {
+	name, _ := cmd.Flags().GetString("format")
+	if name == "" {
+		name = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+
+	d, _ := cmd.Flags().GetString("delimiter")
+	if d != "" && name != FormatCSV && name != FormatTSV {
+		if name == FormatJSON {
+			return FileFormat{}, fmt.Errorf("'--delimiter' is only used with csv files: %q is %s", filename, name)
+		}
+
+		if f, _ := cmd.Flags().GetString("format"); f == "" {
+			name = FormatCSV
+		}
+	}
+
+	switch name {
+	case FormatCSV:
+		if d == "" {
+			d = viper.GetString(ImportDelimiterKey)
+		}
+
+		delimiter, err := parseDelimiter(d)
+		if err != nil {
+			return FileFormat{}, err
+		}
+
+		return FileFormat{Name: FormatCSV, Delimiter: delimiter}, nil
+	case FormatTSV:
+		return FileFormat{Name: FormatTSV, Delimiter: delimited.Tab}, nil
+	case FormatJSON:
+		return FileFormat{Name: FormatJSON}, nil
+	default:
+		if f, _ := cmd.Flags().GetString("format"); f != "" {
+			return FileFormat{}, fmt.Errorf("format must be %s, %s, or %s: %q", FormatJSON, FormatCSV, FormatTSV, f)
+		}
+
+		return FileFormat{Name: FormatJSON}, nil
+	}
+}
+
+// parseDelimiter reads a column delimiter, which is a single character or 'tab'. No delimiter is a comma.
+func parseDelimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return delimited.Comma, nil
+	case "tab", `\t`:
+		return delimited.Tab, nil
+	}
+
+	r, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("delimiter must be a single character other than a quote or line break: %q", s)
+	}
+
+	return r, nil
+}
+
+// isDelimited reports whether the format is csv or tsv.
+func (ff FileFormat) isDelimited() bool {
+	return ff.Name == FormatCSV || ff.Name == FormatTSV
+}
+
+// decode reads an import file. Json files are read into their wrapper document, and csv and tsv files into the
+// records of the wrapper. The fields named by the header of a csv or tsv file are returned, and json files, which
+// can hold every field, return none.
+func (ff FileFormat) decode(f io.Reader, wrapper, records interface{}) ([]string, error) {
+	if !ff.isDelimited() {
+		return nil, parseFromFile(f, wrapper)
+	}
+
+	dr := delimited.NewReader(f, ff.Delimiter)
+	if err := dr.ReadAll(records); err != nil {
+		return nil, fmt.Errorf("failed to read %s file: %w", ff.Name, err)
+	}
+
+	return dr.Columns(), nil
+}
+
+// encode writes an export file. Json files hold the wrapper document, and csv and tsv files its records.
+func (ff FileFormat) encode(w io.Writer, wrapper, records interface{}) error {
+	if ff.isDelimited() {
+		return delimited.Write(w, ff.Delimiter, records)
+	}
+
+	data, err := json.Marshal(wrapper)
+	if err != nil {
+		return fmt.Errorf("error marshaling records: %w", err)
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return fmt.Errorf("error writing records: %w", err)
+	}
+
+	return nil
+}
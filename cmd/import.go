@@ -29,6 +29,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
@@ -73,6 +74,126 @@ func initImportFile(f string) (io.ReadCloser, datastore.SaveStopper, error) {
 	return jsonFile, dsManager, nil
 }
 
+// Stdin is the name of the import file read from standard input.
+const Stdin = "-"
+
+// An ImportFile is a file of records to import, and the format it is written in. Open returns a reader of the file.
+type ImportFile struct {
+	Name   string
+	Format FileFormat
+	Open   func() (io.ReadCloser, error)
+}
+
+// String names the file in reports.
+func (f ImportFile) String() string {
+	if f.Name == Stdin {
+		return "standard input"
+	}
+
+	return f.Name
+}
+
+// importFiles returns the files named by the arguments of an import command. An argument is a file name, a glob
+// pattern matching file names, or '-' to read standard input. Files named more than once are imported once.
+func importFiles(cmd *cobra.Command, args []string) ([]ImportFile, error) {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, arg := range args {
+		matches := []string{arg}
+
+		if arg != Stdin && strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("invalid file pattern %q: %w", arg, err)
+			}
+
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+		}
+
+		for _, name := range matches {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	files := make([]ImportFile, len(names))
+
+	for i, name := range names {
+		format, err := fileFormatFlag(cmd, name)
+		if err != nil {
+			return nil, err
+		}
+
+		files[i] = ImportFile{Name: name, Format: format, Open: openImportFile(cmd, name)}
+	}
+
+	return files, nil
+}
+
+// openImportFile returns a function opening an import file, or standard input.
+func openImportFile(cmd *cobra.Command, name string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		if name == Stdin {
+			return io.NopCloser(cmd.InOrStdin()), nil
+		}
+
+		return os.Open(filepath.Clean(name))
+	}
+}
+
+// initImportDatastore checks that the import files can be read, and opens the datastore to import them into.
+func initImportDatastore(files []ImportFile) (datastore.SaveStopper, error) {
+	for _, f := range files {
+		if f.Name == Stdin {
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Clean(f.Name)); err != nil {
+			log.Error("failed to open import file: ", err)
+
+			return nil, fmt.Errorf("failed to open import file: %w", err)
+		}
+	}
+
+	dsManager, err := datastore.New(viper.GetString("datastore.filename"))
+	if err != nil {
+		log.Error("failed to access datastore: ", err)
+
+		return nil, fmt.Errorf("failed to access datastore: %w", err)
+	}
+
+	return dsManager, nil
+}
+
+// closeImportFile closes an import file, logging a failure.
+func closeImportFile(f io.Closer) {
+	if err := f.Close(); err != nil {
+		log.Error("failed to close import file: ", err)
+	}
+}
+
+// A FileSummary is what was done with the records of one import file.
+type FileSummary struct {
+	File    ImportFile
+	Read    int
+	Summary ImportSummary
+}
+
+// RenderFileSummaries lists what was done with the records of each import file, one file to a line.
+func RenderFileSummaries(ss []FileSummary) string {
+	lines := make([]string, len(ss))
+	for i, s := range ss {
+		lines[i] = fmt.Sprintf("  %s: %d/%d records (%s)", s.File, s.Summary.Imported(), s.Read, s.Summary)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 // parseFromFile unmarshalls json into a Listings struct.
 func parseFromFile(j io.Reader, value interface{}) error {
 	if j == nil {
@@ -0,0 +1,245 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	storm "github.com/asdine/storm/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+)
+
+const importArchiveCommandLongDesc = "Imports a complete archive written by 'ogma export --record=all'. Issues, listings,\n" +
+	"mail, and members are saved with their original IDs, so re-importing an archive updates the records it\n" +
+	"contains instead of duplicating them."
+
+// ErrInvalidArchive is returned when an archive cannot be imported as-is.
+var ErrInvalidArchive = errors.New("invalid archive")
+
+func init() {
+	importCmd.AddCommand(NewImportArchiveCmd())
+}
+
+// NewImportArchiveCmd sets up an import subcommand.
+func NewImportArchiveCmd() *cobra.Command {
+	// cmd represents the import archive command.
+	cmd := &cobra.Command{
+		Use:     "archive [filename]",
+		Short:   "Import a complete archive.",
+		Long:    importArchiveCommandLongDesc,
+		Example: "ogma import archive export.json",
+		Args:    cobra.ExactArgs(1),
+		Run:     RunImportArchiveCmd,
+	}
+
+	return cmd
+}
+
+// RunImportArchiveCmd performs action associated with archive-import application command.
+func RunImportArchiveCmd(cmd *cobra.Command, args []string) {
+	jsonFile, dsManager, err := initImportFile(args[0])
+	// defer closing the import file until after we're done with it
+	defer func() {
+		if dsManager != nil {
+			dsManager.Stop()
+		}
+
+		if jsonFile != nil {
+			if closeErr := jsonFile.Close(); closeErr != nil {
+				log.Error("failed to close import file: ", closeErr)
+			}
+		}
+	}()
+	if err != nil {
+		log.Error("error initializing archive import: ", err)
+		cmd.PrintErrln("error initializing archive import: ", err)
+		return
+	}
+
+	out, err := importArchive(jsonFile, dsManager)
+	if err != nil {
+		log.Error("failed to import archive: ", err)
+		cmd.PrintErrln("failed to import archive: ", err)
+		return
+	}
+
+	cmd.Println(out)
+}
+
+// importArchive saves every record in an archive to the datastore, keeping record IDs.
+func importArchive(f io.Reader, d datastore.Saver) (string, error) {
+	var a Archive
+
+	if err := parseFromFile(f, &a); err != nil {
+		return "", fmt.Errorf("failed to parse input file: %w", err)
+	}
+
+	if err := validateArchive(a); err != nil {
+		return "", err
+	}
+
+	tx, err := d.Begin(true)
+	if err != nil {
+		return "", fmt.Errorf("error beginning datastore transaction: %w", err)
+	}
+	defer func() {
+		if errRollback := tx.Rollback(); errRollback != nil && !errors.Is(errRollback, storm.ErrNotInTransaction) {
+			log.Error("failed to rollback datastore transaction: ", errRollback)
+		}
+	}()
+
+	maxIDs := map[string]int{}
+
+	for i := range a.Issues {
+		if err = tx.Save(&a.Issues[i]); err != nil {
+			return "", fmt.Errorf("error saving issue number=%d: %w", a.Issues[i].Number, err)
+		}
+
+		if a.Issues[i].ID > maxIDs["Issue"] {
+			maxIDs["Issue"] = a.Issues[i].ID
+		}
+	}
+
+	for i := range a.Listings {
+		if err = tx.Save(&a.Listings[i]); err != nil {
+			return "", fmt.Errorf("error saving listing id=%d: %w", a.Listings[i].ID, err)
+		}
+
+		if err = lstg.TextIndex(tx).Add(a.Listings[i].ID, a.Listings[i].ListingText); err != nil {
+			return "", fmt.Errorf("error indexing listing id=%d: %w", a.Listings[i].ID, err)
+		}
+
+		if a.Listings[i].ID > maxIDs["Listing"] {
+			maxIDs["Listing"] = a.Listings[i].ID
+		}
+	}
+
+	changes := []RefChange{}
+
+	for i := range a.Mails {
+		err = tx.Save(&a.Mails[i])
+
+		// earlier versions could store mail sharing a reference, which has to be given a new one to be saved
+		if errors.Is(err, storm.ErrAlreadyExists) {
+			c := RefChange{OldRef: a.Mails[i].Ref}
+
+			if a.Mails[i].Ref, err = NewMailRef(tx, a.Mails[i], len(c.OldRef)); err != nil {
+				return "", fmt.Errorf("error creating reference for mail ref=%s: %w", c.OldRef, err)
+			}
+
+			c.Mail = a.Mails[i]
+			changes = append(changes, c)
+
+			err = tx.Save(&a.Mails[i])
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("error saving mail ref=%s: %w", a.Mails[i].Ref, err)
+		}
+
+		if a.Mails[i].ID > maxIDs["Mail"] {
+			maxIDs["Mail"] = a.Mails[i].ID
+		}
+	}
+
+	for i := range a.Members {
+		if err = tx.Save(&a.Members[i]); err != nil {
+			return "", fmt.Errorf("error saving member number=%s: %w", a.Members[i].Number, err)
+		}
+
+		if a.Members[i].ID > maxIDs["Member"] {
+			maxIDs["Member"] = a.Members[i].ID
+		}
+	}
+
+	// records kept their archived IDs, make sure new records are numbered after them
+	for bucket, id := range maxIDs {
+		if err = datastore.SyncIncrement(tx, bucket, id); err != nil {
+			return "", err
+		}
+	}
+
+	// archives are copies of a datastore, which may already hold links to records deleted since, so they are
+	// reported instead of refusing the archive
+	dd, err := FindDanglingLinks(tx)
+	if err != nil {
+		return "", err
+	}
+
+	if errCommit := tx.Commit(); errCommit != nil {
+		return "", fmt.Errorf("error committing records to datastore: %w", errCommit)
+	}
+
+	log.WithFields(log.Fields{
+		"cmd":      "import",
+		"issues":   len(a.Issues),
+		"listings": len(a.Listings),
+		"mails":    len(a.Mails),
+		"members":  len(a.Members),
+	}).Info("completed importing archive")
+
+	out := fmt.Sprintf("Imported %d issue, %d listing, %d mail, and %d member records.",
+		len(a.Issues), len(a.Listings), len(a.Mails), len(a.Members))
+
+	for _, c := range changes {
+		out += fmt.Sprintf("\nMail %s was given the reference %s, as %s is already used.", c.OldRef, c.Mail.Ref, c.OldRef)
+	}
+
+	if len(dd) > 0 {
+		out += fmt.Sprintf("\nMail links that can't be followed: %d. Use 'ogma check links' to list them.", len(dd))
+	}
+
+	return out, nil
+}
+
+// validateArchive checks that an archive is a supported version and holds the records its metadata describes.
+func validateArchive(a Archive) error {
+	md := a.Metadata
+
+	if md.FormatVersion < 1 || md.FormatVersion > ArchiveFormatVersion {
+		return fmt.Errorf("%w: unsupported format version %d", ErrInvalidArchive, md.FormatVersion)
+	}
+
+	if md.SchemaVersion > datastore.LatestSchemaVersion() {
+		return fmt.Errorf("%w: schema version %d is newer than supported %d", ErrInvalidArchive, md.SchemaVersion, datastore.LatestSchemaVersion())
+	}
+
+	got := ArchiveCounts{
+		Issues:   len(a.Issues),
+		Listings: len(a.Listings),
+		Mails:    len(a.Mails),
+		Members:  len(a.Members),
+	}
+
+	if got != md.Counts {
+		return fmt.Errorf("%w: record counts %+v do not match metadata %+v", ErrInvalidArchive, got, md.Counts)
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/address"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+func TestNewImportArchiveCmd(t *testing.T) {
+	got := cmd.NewImportArchiveCmd()
+
+	assert.Equal(t, "archive", got.Name())
+	assert.Equal(t, "Import a complete archive.", got.Short)
+	assert.True(t, got.Runnable())
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+	require.NoError(t, m.Save(&cmd.Member{Number: member.ID{Number: 1234}, Name: "John Smith", Addresses: address.History{
+		{Address: address.Address{Lines: []string{"123 Fake St"}, Locality: "Springfield", Region: "IL", PostalCode: "62701", Country: "US"}, From: "2021-01-01"},
+	}}))
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dsFile)
+
+	export := cmd.NewExportCmd()
+	b := bytes.NewBufferString("")
+	export.SetOut(b)
+	export.SetErr(b)
+	export.SetArgs([]string{"-r=all", "-o=test/archive.json"})
+	require.NoError(t, export.Execute())
+	require.Contains(t, b.String(), "successfully exported")
+
+	raw, err := os.ReadFile("test/archive.json")
+	require.NoError(t, err)
+
+	var a cmd.Archive
+	require.NoError(t, json.Unmarshal(raw, &a))
+
+	assert.Equal(t, cmd.ArchiveFormatVersion, a.Metadata.FormatVersion)
+	assert.Equal(t, cmd.GetRootCmd().Version, a.Metadata.OgmaVersion)
+	assert.Equal(t, datastore.LatestSchemaVersion(), a.Metadata.SchemaVersion)
+	assert.Equal(t, cmd.ArchiveCounts{Listings: 3, Mails: 3, Members: 2}, a.Metadata.Counts)
+
+	// import into an empty datastore
+	emptyFile := fmt.Sprintf("test/empty_%d.db", time.Now().Unix())
+	viper.Set("datastore.filename", emptyFile)
+
+	imp := cmd.NewImportArchiveCmd()
+	b.Reset()
+	imp.SetOut(b)
+	imp.SetErr(b)
+	imp.SetArgs([]string{"test/archive.json"})
+	require.NoError(t, imp.Execute())
+	assert.Equal(t, "Imported 0 issue, 3 listing, 3 mail, and 2 member records.\n", b.String())
+
+	orig, err := datastore.Open(dsFile)
+	require.NoError(t, err)
+	defer orig.Stop()
+
+	imported, err := datastore.Open(emptyFile)
+	require.NoError(t, err)
+	defer imported.Stop()
+
+	var wantListings, gotListings []lstg.Listing
+	require.NoError(t, orig.All(&wantListings))
+	require.NoError(t, imported.All(&gotListings))
+	assert.Equal(t, wantListings, gotListings)
+
+	var wantMails, gotMails []cmd.Mail
+	require.NoError(t, orig.All(&wantMails))
+	require.NoError(t, imported.All(&gotMails))
+	assert.Equal(t, wantMails, gotMails)
+
+	var wantMembers, gotMembers []cmd.Member
+	require.NoError(t, orig.All(&wantMembers))
+	require.NoError(t, imported.All(&gotMembers))
+	assert.Equal(t, wantMembers, gotMembers)
+
+	// new records must not reuse archived IDs
+	newMail := cmd.Mail{Ref: "abcdef"}
+	require.NoError(t, imported.Save(&newMail))
+	assert.Equal(t, 4, newMail.ID)
+}
+
+func TestRunImportArchiveCmd(t *testing.T) {
+	m, dbFilePath, appFS := setup(t)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+
+	require.NoError(t, os.WriteFile("test/future.json", []byte(`{"metadata":{"format_version":99}}`), 0o600))
+	require.NoError(t, os.WriteFile("test/miscounted.json", []byte(`{
+		"metadata": {"format_version": 1, "schema_version": 1, "counts": {"listings": 2, "mails": 0, "members": 0}},
+		"listings": [{"ID": 1, "volume": 1}]
+	}`), 0o600))
+	require.NoError(t, os.WriteFile("test/dangling.json", []byte(`{
+		"metadata": {"format_version": 4, "schema_version": 7, "counts": {"listings": 0, "mails": 2, "members": 0}},
+		"mails": [
+			{"ID": 1, "reference": "123d5f", "sender": "55", "receiver": "1234", "date": "1986-04-01", "link": "L1"},
+			{"ID": 2, "reference": "b12cd3", "sender": "1234", "receiver": "55", "date": "1986-05-16", "link": "M123d5f"}
+		]
+	}`), 0o600))
+
+	require.NoError(t, os.WriteFile("test/duplicates.json", []byte(`{
+		"metadata": {"format_version": 4, "schema_version": 7, "counts": {"listings": 0, "mails": 2, "members": 0}},
+		"mails": [
+			{"ID": 10, "reference": "f2165e", "sender": "1234", "receiver": "5678", "date": "2021-11-15"},
+			{"ID": 11, "reference": "f2165e", "sender": "1234", "receiver": "5678", "date": "2021-11-15"}
+		]
+	}`), 0o600))
+
+	tests := []struct {
+		name      string
+		args      []string
+		assertion assert.ErrorAssertionFunc
+		want      string
+	}{
+		{
+			name:      "no file argument",
+			args:      []string{},
+			assertion: assert.Error,
+			want:      "Error: accepts 1 arg(s), received 0",
+		},
+		{
+			name:      "invalid json",
+			args:      []string{"test/invalid.json"},
+			assertion: assert.NoError,
+			want:      "failed to import archive:  failed to parse input file:",
+		},
+		{
+			name:      "unsupported format",
+			args:      []string{"test/future.json"},
+			assertion: assert.NoError,
+			want:      "failed to import archive:  invalid archive: unsupported format version 99",
+		},
+		{
+			name:      "record counts do not match",
+			args:      []string{"test/miscounted.json"},
+			assertion: assert.NoError,
+			want:      "failed to import archive:  invalid archive: record counts",
+		},
+		{
+			name:      "dangling links",
+			args:      []string{"test/dangling.json"},
+			assertion: assert.NoError,
+			want: "Imported 0 issue, 0 listing, 2 mail, and 0 member records.\n" +
+				"Mail links that can't be followed: 1. Use 'ogma check links' to list them.\n",
+		},
+		{
+			name:      "duplicate references",
+			args:      []string{"test/duplicates.json"},
+			assertion: assert.NoError,
+			want: "Imported 0 issue, 0 listing, 2 mail, and 0 member records.\n" +
+				"Mail f2165e was given the reference 31502b, as f2165e is already used.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewImportArchiveCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			tt.assertion(t, c.Execute())
+			assert.Contains(t, b.String(), tt.want)
+		})
+	}
+}
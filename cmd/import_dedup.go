@@ -0,0 +1,182 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Configuration keys for the fields that identify imported records.
+const (
+	ListingKeyFieldsKey = "import.keys.listings"
+	MailKeyFieldsKey    = "import.keys.mail"
+)
+
+var (
+	// DefaultListingKeyFields identify a listing by where it is printed.
+	DefaultListingKeyFields = []string{"issue", "page", "member", "alt"}
+
+	// DefaultMailKeyFields identify mail by who it is between and when it was sent.
+	DefaultMailKeyFields = []string{"sender", "receiver", "date"}
+)
+
+// A DuplicateRecord is an imported record that wasn't saved because a record with the same natural key is already
+// stored. Index counts records from 1, in the order they are written in the import file. File names the import
+// file when records are imported from more than one.
+type DuplicateRecord struct {
+	File   string
+	Index  int
+	Stored string
+}
+
+// A keyIndex finds stored records by their natural key, the values of the fields that identify them. Fields are
+// named as they are in import files.
+type keyIndex struct {
+	fields []string
+	ids    map[string]int
+	labels map[string]string
+}
+
+// newKeyIndex returns an index of records by the named fields, which must be fields of zero. No fields makes an
+// index that never finds anything.
+func newKeyIndex(fields []string, zero interface{}) (*keyIndex, error) {
+	known, err := jsonFields(zero)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		if _, ok := known[f]; !ok {
+			return nil, fmt.Errorf("unknown key field: %q", f)
+		}
+	}
+
+	return &keyIndex{fields: fields, ids: map[string]int{}, labels: map[string]string{}}, nil
+}
+
+// key returns the natural key of a record.
+func (ix *keyIndex) key(v interface{}) (string, error) {
+	fields, err := jsonFields(v)
+	if err != nil {
+		return "", err
+	}
+
+	values := make([]string, len(ix.fields))
+	for i, f := range ix.fields {
+		values[i] = string(fields[f])
+	}
+
+	return strings.Join(values, "\x00"), nil
+}
+
+// add indexes a stored record by its natural key, with the label it is reported by.
+func (ix *keyIndex) add(v interface{}, id int, label string) error {
+	if len(ix.fields) == 0 {
+		return nil
+	}
+
+	k, err := ix.key(v)
+	if err != nil {
+		return err
+	}
+
+	ix.ids[k] = id
+	ix.labels[k] = label
+
+	return nil
+}
+
+// remove stops indexing a stored record by its natural key, as it is changing.
+func (ix *keyIndex) remove(v interface{}) error {
+	if len(ix.fields) == 0 {
+		return nil
+	}
+
+	k, err := ix.key(v)
+	if err != nil {
+		return err
+	}
+
+	delete(ix.ids, k)
+	delete(ix.labels, k)
+
+	return nil
+}
+
+// clear stops indexing every stored record, as they are all being removed.
+func (ix *keyIndex) clear() {
+	ix.ids = map[string]int{}
+	ix.labels = map[string]string{}
+}
+
+// find returns the ID and label of the stored record with the same natural key as v, and whether there is one.
+func (ix *keyIndex) find(v interface{}) (int, string, bool, error) {
+	if len(ix.fields) == 0 {
+		return 0, "", false, nil
+	}
+
+	k, err := ix.key(v)
+	if err != nil {
+		return 0, "", false, err
+	}
+
+	id, ok := ix.ids[k]
+
+	return id, ix.labels[k], ok, nil
+}
+
+// addDuplicatesFlag adds the flag for importing records that are already stored under another ID.
+func addDuplicatesFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("keep-duplicates", false, "Import records even when a record with the same key is stored.")
+}
+
+// keepDuplicatesFlag reads the 'keep-duplicates' flag.
+func keepDuplicatesFlag(cmd *cobra.Command) bool {
+	keep, _ := cmd.Flags().GetBool("keep-duplicates")
+
+	return keep
+}
+
+// keyFields returns the fields that identify imported records from a setting, or defaults when it isn't set.
+func keyFields(key string, defaults []string) []string {
+	if !viper.IsSet(key) {
+		return defaults
+	}
+
+	return viper.GetStringSlice(key)
+}
+
+// RenderDuplicateRecords lists skipped duplicates by their position in the import file, and the stored record each
+// duplicates.
+func RenderDuplicateRecords(dd []DuplicateRecord) string {
+	lines := make([]string, len(dd))
+	for i, d := range dd {
+		lines[i] = fmt.Sprintf("  %s: already stored as %s", recordName(d.File, d.Index), d.Stored)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,180 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+)
+
+func TestImportListingDuplicates(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+	m.Stop()
+
+	appFS := afero.NewOsFs()
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+		viper.Set(cmd.ListingKeyFieldsKey, nil)
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+	viper.Set(cmd.ImportModeKey, "")
+
+	require.NoError(t, afero.WriteFile(appFS, "test/issue1.json", []byte(`{"listings": [
+		{"issue": 1, "page": 1, "category": "Pariatur", "member": 1234, "text": "Esse Lorem do nulla sunt mollit nulla in."},
+		{"issue": 1, "page": 2, "category": "Commodo", "member": 5678, "text": "Velit cillum."},
+		{"issue": 1, "page": 2, "category": "Commodo", "member": 5678, "text": "Velit cillum, again."}
+	]}`), 0o644))
+
+	tests := []struct {
+		name      string
+		args      []string
+		keyFields []string
+		want      string
+	}{
+		{
+			name: "stored and repeated listings are skipped",
+			args: []string{"test/issue1.json"},
+			want: "Imported 1/3 listing records (1 created, 0 updated, 0 unchanged, 2 skipped as duplicates).\n" +
+				"Skipped duplicates: 2\n" +
+				"  record 1: already stored as listing 1 (issue 1 page 1 member 1234)\n" +
+				"  record 3: already stored as listing 2 (issue 1 page 2 member 5678)\n",
+		},
+		{
+			name: "importing twice adds nothing",
+			args: []string{"test/issue1.json"},
+			want: "Imported 0/3 listing records (0 created, 0 updated, 0 unchanged, 3 skipped as duplicates).\n",
+		},
+		{
+			name:      "configured key",
+			args:      []string{"test/issue1.json"},
+			keyFields: []string{"issue", "page", "member", "text"},
+			want:      "Imported 1/3 listing records (1 created, 0 updated, 0 unchanged, 2 skipped as duplicates).\n",
+		},
+		{
+			name: "keep duplicates",
+			args: []string{"test/issue1.json", "--keep-duplicates"},
+			want: "Imported 3/3 listing records (3 created, 0 updated, 0 unchanged).\n",
+		},
+		{
+			name:      "unknown key field",
+			args:      []string{"test/issue1.json"},
+			keyFields: []string{"issue", "pages"},
+			want:      "failed to import listing records:  'import.keys.listings': unknown key field: \"pages\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set(cmd.ListingKeyFieldsKey, tt.keyFields)
+			if tt.keyFields == nil {
+				viper.Set(cmd.ListingKeyFieldsKey, cmd.DefaultListingKeyFields)
+			}
+
+			c := cmd.NewImportListingCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+			require.NoError(t, c.Execute())
+			out, err := io.ReadAll(b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(out)[:len(tt.want)])
+		})
+	}
+
+	m, err := datastore.Open(dbFilePath)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	n, err := m.Count(&lstg.Listing{})
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+}
+
+func TestImportMailDuplicates(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+	m.Stop()
+
+	appFS := afero.NewOsFs()
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+	viper.Set(cmd.ImportModeKey, "")
+	viper.Set(cmd.MailKeyFieldsKey, cmd.DefaultMailKeyFields)
+
+	require.NoError(t, afero.WriteFile(appFS, "test/unreferenced.json", []byte(`{"mails": [
+		{"sender": 55, "receiver": 1234, "date": "1986-04-01", "link": "L1"},
+		{"sender": 1234, "receiver": 5678, "date": "2021-11-15"}
+	]}`), 0o644))
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "stored mail without a reference is skipped",
+			args: []string{"test/unreferenced.json"},
+			want: "Imported 1/2 mail records (1 created, 0 updated, 0 unchanged, 1 skipped as duplicates).\n" +
+				"Skipped duplicates: 1\n" +
+				"  record 1: already stored as mail 123d5f (123d5f 55 → 1234 1986-04-01)\n",
+		},
+		{
+			name: "importing twice adds nothing",
+			args: []string{"test/unreferenced.json"},
+			want: "Imported 0/2 mail records (0 created, 0 updated, 0 unchanged, 2 skipped as duplicates).\n",
+		},
+		{
+			name: "merge by key",
+			args: []string{"test/unreferenced.json", "--mode", "merge"},
+			want: "Imported 2/2 mail records (0 created, 0 updated, 2 unchanged).\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewImportMailCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+			require.NoError(t, c.Execute())
+			out, err := io.ReadAll(b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(out)[:len(tt.want)])
+		})
+	}
+}
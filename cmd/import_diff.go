@@ -0,0 +1,690 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/asdine/storm/v3"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+// A DiffStatus is how an imported record compares to the stored records.
+type DiffStatus string
+
+// Diff statuses.
+const (
+	// DiffNew records would be added.
+	DiffNew DiffStatus = "new"
+
+	// DiffIdentical records are already stored as they are.
+	DiffIdentical DiffStatus = "identical"
+
+	// DiffConflict records share an ID or reference with a stored record that has different fields.
+	DiffConflict DiffStatus = "conflicting"
+
+	// DiffDuplicate records would be skipped, as a record with the same key fields is stored.
+	DiffDuplicate DiffStatus = "duplicate"
+
+	// DiffInvalid records can't be imported.
+	DiffInvalid DiffStatus = "invalid"
+)
+
+// diffStatuses are the diff statuses in the order they are counted.
+var diffStatuses = []DiffStatus{DiffNew, DiffIdentical, DiffConflict, DiffDuplicate, DiffInvalid}
+
+// A RecordDiff compares one imported record to the stored records. Index counts records from 1, in the order they
+// are written in the import file. ID is the stored record it matches, if any.
+type RecordDiff struct {
+	Index   int        `json:"index"`
+	Status  DiffStatus `json:"status"`
+	ID      int        `json:"id,omitempty"`
+	Record  string     `json:"record"`
+	Fields  []string   `json:"fields,omitempty"`
+	Problem string     `json:"problem,omitempty"`
+}
+
+// Details describes why the record has its status.
+func (d RecordDiff) Details() string {
+	details := []string{}
+
+	if len(d.Fields) > 0 {
+		details = append(details, "differs in "+strings.Join(d.Fields, ", "))
+	}
+
+	if d.Problem != "" {
+		details = append(details, d.Problem)
+	}
+
+	return strings.Join(details, "; ")
+}
+
+// An ImportDiff compares every record of an import file to the stored records. Removed counts the stored records
+// a replacing import would remove.
+type ImportDiff struct {
+	Type    string             `json:"type"`
+	Counts  map[DiffStatus]int `json:"counts"`
+	Removed int                `json:"removed,omitempty"`
+	Records []RecordDiff       `json:"records"`
+}
+
+func newImportDiff(recordType string, dd []RecordDiff) ImportDiff {
+	d := ImportDiff{Type: recordType, Counts: map[DiffStatus]int{}, Records: dd}
+
+	for _, s := range diffStatuses {
+		d.Counts[s] = 0
+	}
+
+	for _, r := range dd {
+		d.Counts[r.Status]++
+	}
+
+	return d
+}
+
+var importDiffColumnConfigs = []table.ColumnConfig{
+	{
+		Name:  "Record",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "ID",
+		Align: text.AlignRight,
+	},
+}
+
+// addDryRunFlags adds the flags for comparing an import file to the datastore without importing it.
+func addDryRunFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("dry-run", false, "Compare the records to the datastore without importing them.")
+	cmd.Flags().BoolP("pretty", "p", false, "Show prettier results.")
+}
+
+// isDryRun reads the 'dry-run' flag.
+func isDryRun(cmd *cobra.Command) bool {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	return dryRun
+}
+
+// runImportDryRun compares an import file to the datastore with diff, and prints the report. Nothing is written
+// to the datastore. A dry run compares one file at a time.
+func runImportDryRun(cmd *cobra.Command, files []ImportFile, diff func(io.Reader, FileFormat, storm.Finder) (ImportDiff, error)) {
+	if len(files) != 1 {
+		log.Error("invalid dry run: ", len(files), " import files")
+		cmd.PrintErrln("invalid input: ", fmt.Errorf("a dry run compares one import file at a time: %d files given", len(files)))
+		return
+	}
+
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	f, err := files[0].Open()
+	if err != nil {
+		log.Error("failed to open import file: ", err)
+		cmd.PrintErrln("failed to open import file: ", err)
+		return
+	}
+	defer closeImportFile(f)
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	d, err := diff(f, files[0].Format, dsManager.Store)
+	if err != nil {
+		log.Error("failed to compare import file: ", err)
+		cmd.PrintErrln("failed to compare import file: ", err)
+		return
+	}
+
+	printReport(cmd, r, ImportDiffReport(d))
+}
+
+// DiffListings compares the listings in an import file, written in format, to the stored listings. Listings are
+// matched and checked as the import mode would import them: a listing conflicts with the stored listing it would
+// update when their fields differ, and is a duplicate when a listing with the same key fields is stored, or is
+// new earlier in the file.
+func DiffListings(f io.Reader, format FileFormat, ds storm.Finder, opts ListingImportOptions) (ImportDiff, error) {
+	var raw lstg.Listings
+
+	columns, err := format.decode(f, &raw, &raw.Listings)
+	if err != nil {
+		return ImportDiff{}, fmt.Errorf("failed to parse input file: %w", err)
+	}
+
+	if len(raw.Listings) > 0 {
+		if err = checkColumns(opts.Mode, format.Name, columns, lstg.Listing{}); err != nil {
+			return ImportDiff{}, err
+		}
+	}
+
+	ld := &listingDiffer{ds: ds, opts: opts, columns: columns, issues: map[int]Issue{}, first: map[lstg.Listing]int{}}
+
+	var removed int
+
+	if ld.keys, err = listingKeys(ds, opts.KeyFields); err != nil {
+		return ImportDiff{}, err
+	}
+
+	// stored listings are removed before a replacing import, so they aren't matched or duplicated
+	if opts.Mode == ImportReplace {
+		if removed, err = ds.Count(&lstg.Listing{}); err != nil {
+			return ImportDiff{}, fmt.Errorf("failure to read listings: %w", err)
+		}
+
+		ld.keys.clear()
+	}
+
+	dd := make([]RecordDiff, len(raw.Listings))
+
+	for i, l := range raw.Listings {
+		if dd[i], err = ld.diff(l, i+1); err != nil {
+			return ImportDiff{}, err
+		}
+	}
+
+	d := newImportDiff("listings", dd)
+	d.Removed = removed
+
+	return d, nil
+}
+
+// A listingDiffer compares imported listings to the stored listings, as a listingImporter would save them.
+// Listings that would be added are indexed by their key fields, by their place in the file.
+type listingDiffer struct {
+	ds      storm.Finder
+	opts    ListingImportOptions
+	columns []string
+	issues  map[int]Issue
+	first   map[lstg.Listing]int
+	keys    *keyIndex
+}
+
+// diff compares an imported listing to the stored listings. index is where the listing is in the import file.
+func (ld *listingDiffer) diff(l lstg.Listing, index int) (RecordDiff, error) {
+	d := RecordDiff{Index: index, Record: listingLabel(l)}
+
+	if n, ok := ld.first[l]; ok {
+		d.Status = DiffIdentical
+		d.Problem = fmt.Sprintf("same as record %d", n)
+
+		return d, nil
+	}
+
+	ld.first[l] = index
+
+	stored, merged, found, err := ld.storedListing(l)
+	if err != nil {
+		return d, err
+	}
+
+	if merged != "" {
+		d.Status = DiffConflict
+		d.Problem = "merged into " + merged
+
+		return d, nil
+	}
+
+	if found && ld.opts.Mode == ImportInsert {
+		return storedDiff(d, ld.opts.Mode, stored.ID, stored, l)
+	}
+
+	listing := l
+	if found {
+		if listing, err = matchedListing(ld.opts.Mode, stored, l, ld.columns); err != nil {
+			return d, err
+		}
+	}
+
+	is, catalogued, err := cataloguedIssue(ld.ds, ld.issues, listing.IssueNumber)
+	if err != nil {
+		return d, err
+	}
+
+	var fillErr error
+	if catalogued {
+		fillErr = is.Fill(&listing)
+	}
+
+	if err = joinFieldErrors(listing.Validate(ld.opts.Rules), fillErr); err != nil {
+		d.Status = DiffInvalid
+		d.Problem = strings.TrimPrefix(err.Error(), lstg.ErrInvalid.Error()+": ")
+
+		return d, nil
+	}
+
+	if !found {
+		if dup, label, dupErr := isDuplicate(ld.keys, ld.opts.KeepDuplicates, listing); dupErr != nil || dup {
+			d.Status = DiffDuplicate
+			d.Problem = "duplicates " + label
+
+			return d, dupErr
+		}
+	}
+
+	// later listings are checked against the issue this one would add to the catalogue
+	if !catalogued {
+		ld.issues[listing.IssueNumber] = IssueFromListing(listing)
+	}
+
+	if !found {
+		d.Status = DiffNew
+
+		return d, ld.keys.add(listing, 0, fmt.Sprintf("record %d", index))
+	}
+
+	if err = ld.keys.remove(stored); err != nil {
+		return d, err
+	}
+
+	if err = ld.keys.add(listing, stored.ID, listingRef(listing)); err != nil {
+		return d, err
+	}
+
+	return storedDiff(d, ld.opts.Mode, stored.ID, stored, listing)
+}
+
+// storedListing returns the stored listing an imported listing is matched to by the import mode, and whether there
+// is one. When merging a listing into one that is new earlier in the file, the name of that record is returned
+// instead.
+func (ld *listingDiffer) storedListing(l lstg.Listing) (lstg.Listing, string, bool, error) {
+	var stored lstg.Listing
+
+	id := l.ID
+
+	switch ld.opts.Mode {
+	case ImportReplace:
+		return stored, "", false, nil
+	case ImportMerge:
+		var (
+			label string
+			found bool
+			err   error
+		)
+
+		if id, label, found, err = ld.keys.find(l); err != nil || !found {
+			return stored, "", false, err
+		}
+
+		if id == 0 {
+			return stored, label, false, nil
+		}
+	}
+
+	if id == 0 {
+		return stored, "", false, nil
+	}
+
+	err := ld.ds.One("ID", id, &stored)
+	if errors.Is(err, storm.ErrNotFound) {
+		return stored, "", false, nil
+	}
+
+	if err != nil {
+		return stored, "", false, fmt.Errorf("error reading listing %d: %w", id, err)
+	}
+
+	return stored, "", true, nil
+}
+
+// DiffMails compares the mail in an import file, written in format, to the stored mail. Mail is matched and checked
+// as the import mode would import it: by its reference, or by its key fields when merging. Mail is a duplicate when
+// mail with the same key fields is stored, or is new earlier in the file. Mail with a link that can't be followed
+// is invalid, unless it links to mail in the same file.
+func DiffMails(f io.Reader, format FileFormat, ds storm.Finder, opts MailImportOptions) (ImportDiff, error) {
+	var raw Mails
+
+	columns, err := format.decode(f, &raw, &raw.Mails)
+	if err != nil {
+		return ImportDiff{}, fmt.Errorf("failed to parse input file: %w", err)
+	}
+
+	if len(raw.Mails) > 0 {
+		if err = checkColumns(opts.Mode, format.Name, columns, Mail{}); err != nil {
+			return ImportDiff{}, err
+		}
+	}
+
+	md := &mailDiffer{ds: ds, opts: opts, columns: columns, refs: map[string]bool{}, first: map[string]int{}}
+	for _, m := range raw.Mails {
+		md.refs[strings.ToLower(strings.TrimSpace(m.Ref))] = true
+	}
+
+	var removed int
+
+	if md.keys, err = mailKeys(ds, opts.KeyFields); err != nil {
+		return ImportDiff{}, err
+	}
+
+	// stored mail is removed before a replacing import, so it isn't matched or duplicated
+	if opts.Mode == ImportReplace {
+		if removed, err = ds.Count(&Mail{}); err != nil {
+			return ImportDiff{}, fmt.Errorf("failure to read mail: %w", err)
+		}
+
+		md.keys.clear()
+	}
+
+	dd := make([]RecordDiff, len(raw.Mails))
+
+	for i, m := range raw.Mails {
+		if dd[i], err = md.diff(m, i+1); err != nil {
+			return ImportDiff{}, err
+		}
+	}
+
+	d := newImportDiff("mail", dd)
+	d.Removed = removed
+
+	return d, nil
+}
+
+// A mailDiffer compares imported mail to the stored mail, as a mailImporter would save it. Mail that would be added
+// is indexed by its key fields, by its place in the file.
+type mailDiffer struct {
+	ds      storm.Finder
+	opts    MailImportOptions
+	columns []string
+	refs    map[string]bool
+	first   map[string]int
+	keys    *keyIndex
+}
+
+// diff compares imported mail to the stored mail. index is where the mail is in the import file.
+func (md *mailDiffer) diff(m Mail, index int) (RecordDiff, error) {
+	m.Ref = strings.ToLower(strings.TrimSpace(m.Ref))
+	d := RecordDiff{Index: index, Record: mailLabel(m)}
+
+	key, err := json.Marshal(m)
+	if err != nil {
+		return d, fmt.Errorf("error reading record %d: %w", index, err)
+	}
+
+	if n, ok := md.first[string(key)]; ok {
+		d.Status = DiffIdentical
+		d.Problem = fmt.Sprintf("same as record %d", n)
+
+		return d, nil
+	}
+
+	md.first[string(key)] = index
+
+	stored, merged, found, err := md.storedMail(m)
+	if err != nil {
+		return d, err
+	}
+
+	if merged != "" {
+		d.Status = DiffConflict
+		d.Problem = "merged into " + merged
+
+		return d, nil
+	}
+
+	if found && md.opts.Mode == ImportInsert {
+		return storedDiff(d, md.opts.Mode, stored.ID, stored, m)
+	}
+
+	if !found {
+		if dup, label, dupErr := isDuplicate(md.keys, md.opts.KeepDuplicates, m); dupErr != nil || dup {
+			d.Status = DiffDuplicate
+			d.Problem = "duplicates " + label
+
+			return d, dupErr
+		}
+	}
+
+	if err = checkLink(md.ds, m.Link); err != nil && !(m.Link.Kind() == link.Mail && md.refs[m.Link.Mail]) {
+		if !errors.Is(err, ErrDanglingLink) && !errors.Is(err, link.ErrInvalidLink) {
+			return d, err
+		}
+
+		d.Status = DiffInvalid
+		d.Problem = err.Error()
+
+		return d, nil
+	}
+
+	if !found {
+		d.Status = DiffNew
+
+		return d, md.keys.add(m, 0, fmt.Sprintf("record %d", index))
+	}
+
+	mail, err := matchedMail(md.opts.Mode, stored, m, md.columns)
+	if err != nil {
+		return d, err
+	}
+
+	mail.Ref = stored.Ref
+
+	if err = md.keys.remove(stored); err != nil {
+		return d, err
+	}
+
+	if err = md.keys.add(mail, stored.ID, mailRef(mail)); err != nil {
+		return d, err
+	}
+
+	return storedDiff(d, md.opts.Mode, stored.ID, stored, mail)
+}
+
+// storedMail returns the stored mail that imported mail is matched to by the import mode, and whether there is one.
+// When merging mail into mail that is new earlier in the file, the name of that record is returned instead.
+func (md *mailDiffer) storedMail(m Mail) (Mail, string, bool, error) {
+	var stored Mail
+
+	if md.opts.Mode == ImportReplace {
+		return stored, "", false, nil
+	}
+
+	err := storm.ErrNotFound
+	if m.Ref != "" {
+		err = md.ds.One("Ref", m.Ref, &stored)
+	}
+
+	if errors.Is(err, storm.ErrNotFound) && md.opts.Mode == ImportMerge {
+		var (
+			id    int
+			label string
+			found bool
+		)
+
+		if id, label, found, err = md.keys.find(m); err != nil || !found {
+			return stored, "", false, err
+		}
+
+		if id == 0 {
+			return stored, label, false, nil
+		}
+
+		err = md.ds.One("ID", id, &stored)
+	}
+
+	if errors.Is(err, storm.ErrNotFound) {
+		return stored, "", false, nil
+	}
+
+	if err != nil {
+		return stored, "", false, fmt.Errorf("error reading mail %s: %w", m.Ref, err)
+	}
+
+	return stored, "", true, nil
+}
+
+// isDuplicate reports whether a new record would be skipped, as a record with the same key fields is stored or
+// added before it, and names that record.
+func isDuplicate(keys *keyIndex, keep bool, v interface{}) (bool, string, error) {
+	if keep {
+		return false, "", nil
+	}
+
+	_, label, found, err := keys.find(v)
+
+	return found, label, err
+}
+
+// storedDiff compares the record that would be saved to the stored record it is matched to. Records imported in
+// insert mode leave the stored record as it is.
+func storedDiff(d RecordDiff, mode ImportMode, id int, stored, saved interface{}) (RecordDiff, error) {
+	fields, err := diffFields(stored, saved)
+	if err != nil {
+		return d, err
+	}
+
+	d.ID = id
+	d.Status = DiffIdentical
+
+	if len(fields) > 0 {
+		d.Status = DiffConflict
+		d.Fields = fields
+
+		if mode == ImportInsert {
+			d.Problem = "kept as stored in insert mode"
+		}
+	}
+
+	return d, nil
+}
+
+// diffFields returns the names of the fields that differ between two records, as they are written in import
+// files. Record IDs aren't compared, and fields left out of the imported record aren't either.
+func diffFields(stored, imported interface{}) ([]string, error) {
+	a, err := jsonFields(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := jsonFields(imported)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+
+	for k, v := range b {
+		if k != "ID" && !bytes.Equal(a[k], v) {
+			fields = append(fields, k)
+		}
+	}
+
+	for k := range a {
+		if _, ok := b[k]; !ok && k != "ID" {
+			fields = append(fields, k)
+		}
+	}
+
+	sort.Strings(fields)
+
+	return fields, nil
+}
+
+func jsonFields(v interface{}) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding record: %w", err)
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("error decoding record: %w", err)
+	}
+
+	return fields, nil
+}
+
+// listingLabel names a listing by where it is printed.
+func listingLabel(l lstg.Listing) string {
+	return fmt.Sprintf("issue %d page %d member %s", l.IssueNumber, l.PageNumber, l.Member())
+}
+
+// mailLabel names mail by its reference and who it is between.
+func mailLabel(m Mail) string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s → %s %s", m.Ref, m.Sender, m.Receiver, m.Date))
+}
+
+// ImportDiffReport returns a dry run as a report of records, noting how many records have each status.
+func ImportDiffReport(d ImportDiff) render.Report {
+	counts := make([]string, len(diffStatuses))
+	for i, s := range diffStatuses {
+		counts[i] = fmt.Sprintf("%s: %d", s, d.Counts[s])
+	}
+
+	summary := fmt.Sprintf("Dry run, nothing was imported. %s.", strings.Join(counts, ", "))
+	if d.Removed > 0 {
+		summary += fmt.Sprintf(" %d stored %s would be removed.", d.Removed, d.Type)
+	}
+
+	rep := render.Report{
+		Title: fmt.Sprintf("Dry Run of %s Import:", strings.Title(d.Type)), //nolint:staticcheck // record types are plain ascii
+		Header: table.Row{
+			"Record",
+			"Status",
+			"ID",
+			"Imported",
+			"Details",
+		},
+		Columns: importDiffColumnConfigs,
+		Records: d,
+		Empty:   fmt.Sprintf("No %s records found.", d.Type),
+		Note:    summary,
+	}
+
+	for _, r := range d.Records {
+		id := ""
+		if r.ID != 0 {
+			id = fmt.Sprint(r.ID)
+		}
+
+		rep.Rows = append(rep.Rows, table.Row{
+			r.Index,
+			r.Status,
+			id,
+			r.Record,
+			r.Details(),
+		})
+	}
+
+	return rep
+}
@@ -0,0 +1,350 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+func initDiffDatastore(t *testing.T) (*datastore.Manager, string) {
+	t.Helper()
+
+	m, dbFilePath, _ := setup(t)
+
+	require.NoError(t, m.Save(&cmd.Issue{Number: 1, Volume: 1, Year: 1986, Season: "Spring", Owned: true}))
+	require.NoError(t, m.Save(&lstg.Listing{
+		Volume:              1,
+		IssueNumber:         1,
+		Year:                1986,
+		Season:              "Spring",
+		PageNumber:          1,
+		IndexedCategory:     "Pariatur",
+		IndexedMemberNumber: 1234,
+		ListingText:         "Esse Lorem do nulla sunt mollit nulla in.",
+	}))
+	require.NoError(t, m.Save(&cmd.Mail{
+		Ref:      "123d5f",
+		Sender:   member.ID{Number: 55},
+		Receiver: member.ID{Number: 1234},
+		Date:     "1986-04-01",
+		Link:     link.ToListing(1),
+	}))
+
+	return m, dbFilePath
+}
+
+func TestDiffListings(t *testing.T) {
+	m, _ := initDiffDatastore(t)
+
+	defer func() {
+		m.Stop()
+		require.NoError(t, afero.NewOsFs().RemoveAll("test/"))
+	}()
+
+	f := strings.NewReader(`{"listings": [
+		{"volume": 1, "issue": 1, "year": 1986, "season": "Spring", "page": 1, "category": "Pariatur", "member": 1234, "text": "Esse Lorem do nulla sunt mollit nulla in."},
+		{"ID": 1, "volume": 1, "issue": 1, "year": 1986, "season": "Spring", "page": 1, "category": "Pariatur", "member": 1234, "text": "Magna officia."},
+		{"issue": 1, "page": 2, "category": "Commodo", "member": 5678, "text": "Velit cillum."},
+		{"issue": 1, "page": 2, "category": "Commodo", "member": 5678, "text": "Velit cillum."},
+		{"issue": 1, "season": "Summer", "page": 3, "category": "Commodo", "member": 4321, "text": "Duis elit."},
+		{"issue": 1, "page": 2, "category": "Commodo", "member": 5678, "text": "Aute irure."}
+	]}`)
+
+	got, err := cmd.DiffListings(f, cmd.FileFormat{}, m.Store, cmd.ListingImportOptions{
+		Rules:     lstg.DefaultRules(),
+		Mode:      cmd.ImportUpsert,
+		KeyFields: cmd.DefaultListingKeyFields,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "listings", got.Type)
+	assert.Equal(t, map[cmd.DiffStatus]int{
+		cmd.DiffNew:       1,
+		cmd.DiffIdentical: 1,
+		cmd.DiffConflict:  1,
+		cmd.DiffDuplicate: 2,
+		cmd.DiffInvalid:   1,
+	}, got.Counts)
+	assert.Equal(t, []cmd.RecordDiff{
+		{Index: 1, Status: cmd.DiffDuplicate, Record: "issue 1 page 1 member 1234", Problem: "duplicates listing 1 (issue 1 page 1 member 1234)"},
+		{Index: 2, Status: cmd.DiffConflict, ID: 1, Record: "issue 1 page 1 member 1234", Fields: []string{"text"}},
+		{Index: 3, Status: cmd.DiffNew, Record: "issue 1 page 2 member 5678"},
+		{Index: 4, Status: cmd.DiffIdentical, Record: "issue 1 page 2 member 5678", Problem: "same as record 3"},
+		{Index: 5, Status: cmd.DiffInvalid, Record: "issue 1 page 3 member 4321", Problem: "season \"Summer\": issue 1 is from Spring"},
+		{Index: 6, Status: cmd.DiffDuplicate, Record: "issue 1 page 2 member 5678", Problem: "duplicates record 3"},
+	}, got.Records)
+}
+
+func TestDiffListingsModes(t *testing.T) {
+	m, _ := initDiffDatastore(t)
+
+	defer func() {
+		m.Stop()
+		require.NoError(t, afero.NewOsFs().RemoveAll("test/"))
+	}()
+
+	// the stored listing's key, with new text
+	file := `{"listings": [{"issue": 1, "page": 1, "category": "Pariatur", "member": 1234, "text": "Magna officia."}]}`
+
+	tests := []struct {
+		name        string
+		mode        cmd.ImportMode
+		keep        bool
+		want        cmd.RecordDiff
+		wantRemoved int
+	}{
+		{
+			name: "insert",
+			mode: cmd.ImportInsert,
+			want: cmd.RecordDiff{Status: cmd.DiffDuplicate, Problem: "duplicates listing 1 (issue 1 page 1 member 1234)"},
+		},
+		{
+			name: "upsert",
+			mode: cmd.ImportUpsert,
+			want: cmd.RecordDiff{Status: cmd.DiffDuplicate, Problem: "duplicates listing 1 (issue 1 page 1 member 1234)"},
+		},
+		{
+			name: "keep duplicates",
+			mode: cmd.ImportUpsert,
+			keep: true,
+			want: cmd.RecordDiff{Status: cmd.DiffNew},
+		},
+		{
+			name: "merge",
+			mode: cmd.ImportMerge,
+			want: cmd.RecordDiff{Status: cmd.DiffConflict, ID: 1, Fields: []string{"text"}},
+		},
+		{
+			name:        "replace",
+			mode:        cmd.ImportReplace,
+			want:        cmd.RecordDiff{Status: cmd.DiffNew},
+			wantRemoved: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cmd.DiffListings(strings.NewReader(file), cmd.FileFormat{}, m.Store, cmd.ListingImportOptions{
+				Rules:          lstg.DefaultRules(),
+				Mode:           tt.mode,
+				KeyFields:      cmd.DefaultListingKeyFields,
+				KeepDuplicates: tt.keep,
+			})
+			require.NoError(t, err)
+
+			tt.want.Index = 1
+			tt.want.Record = "issue 1 page 1 member 1234"
+			assert.Equal(t, []cmd.RecordDiff{tt.want}, got.Records)
+			assert.Equal(t, tt.wantRemoved, got.Removed)
+		})
+	}
+}
+
+func TestDiffMails(t *testing.T) {
+	m, _ := initDiffDatastore(t)
+
+	defer func() {
+		m.Stop()
+		require.NoError(t, afero.NewOsFs().RemoveAll("test/"))
+	}()
+
+	f := strings.NewReader(`{"mails": [
+		{"reference": "123d5f", "sender": 55, "receiver": 1234, "date": "1986-04-01", "link": "L1"},
+		{"reference": "123D5F", "sender": 55, "receiver": 1234, "date": "1986-04-02", "link": "L1"},
+		{"reference": "b12cd3", "sender": 1234, "receiver": 55, "date": "1986-05-16", "link": "M123d5f"},
+		{"reference": "c0ffee", "sender": 55, "receiver": 1234, "date": "1986-06-01", "link": "Mb12cd3"},
+		{"reference": "0a0a0a", "sender": 1234, "receiver": 55, "date": "1986-06-01", "link": "M0b0b0b"}
+	]}`)
+
+	got, err := cmd.DiffMails(f, cmd.FileFormat{}, m.Store, cmd.MailImportOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "mail", got.Type)
+	assert.Equal(t, map[cmd.DiffStatus]int{
+		cmd.DiffNew:       2,
+		cmd.DiffIdentical: 1,
+		cmd.DiffConflict:  1,
+		cmd.DiffDuplicate: 0,
+		cmd.DiffInvalid:   1,
+	}, got.Counts)
+	assert.Equal(t, []cmd.RecordDiff{
+		{Index: 1, Status: cmd.DiffIdentical, ID: 1, Record: "123d5f 55 → 1234 1986-04-01"},
+		{Index: 2, Status: cmd.DiffConflict, ID: 1, Record: "123d5f 55 → 1234 1986-04-02", Fields: []string{"date"}},
+		{Index: 3, Status: cmd.DiffNew, Record: "b12cd3 1234 → 55 1986-05-16"},
+		{Index: 4, Status: cmd.DiffNew, Record: "c0ffee 55 → 1234 1986-06-01"},
+		{Index: 5, Status: cmd.DiffInvalid, Record: "0a0a0a 1234 → 55 1986-06-01", Problem: "dangling link: M0b0b0b: mail not found"},
+	}, got.Records)
+}
+
+func TestDiffMailsModes(t *testing.T) {
+	m, _ := initDiffDatastore(t)
+
+	defer func() {
+		m.Stop()
+		require.NoError(t, afero.NewOsFs().RemoveAll("test/"))
+	}()
+
+	// the stored mail's key, without a reference
+	file := `{"mails": [{"sender": 55, "receiver": 1234, "date": "1986-04-01"}]}`
+
+	tests := []struct {
+		name string
+		mode cmd.ImportMode
+		want cmd.RecordDiff
+	}{
+		{
+			name: "upsert",
+			mode: cmd.ImportUpsert,
+			want: cmd.RecordDiff{Status: cmd.DiffDuplicate, Problem: "duplicates mail 123d5f (123d5f 55 → 1234 1986-04-01)"},
+		},
+		{
+			name: "merge",
+			mode: cmd.ImportMerge,
+			want: cmd.RecordDiff{Status: cmd.DiffIdentical, ID: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cmd.DiffMails(strings.NewReader(file), cmd.FileFormat{}, m.Store, cmd.MailImportOptions{
+				Mode:      tt.mode,
+				KeyFields: cmd.DefaultMailKeyFields,
+			})
+			require.NoError(t, err)
+
+			tt.want.Index = 1
+			tt.want.Record = "55 → 1234 1986-04-01"
+			assert.Equal(t, []cmd.RecordDiff{tt.want}, got.Records)
+		})
+	}
+}
+
+func TestRunImportDryRun(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, afero.NewOsFs().RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+
+	tests := []struct {
+		name string
+		cmd  func() *cobra.Command
+		args []string
+		want []string
+	}{
+		{
+			name: "listings",
+			cmd:  cmd.NewImportListingCmd,
+			args: []string{"test/listings.json", "--dry-run"},
+			want: []string{
+				"Dry Run of Listings Import:",
+				"issue 1 page 1 member 1234",
+				"|      1 | duplicate |    | issue 1 page 1 member 1234  | duplicates listing 1 (issue 1 page 1 member 1234) |",
+				"|      3 | new       |    | issue 1 page 3 member 5678  |",
+				"Dry run, nothing was imported. new: 2, identical: 0, conflicting: 0, duplicate: 1, invalid: 0.",
+			},
+		},
+		{
+			name: "mail",
+			cmd:  cmd.NewImportMailCmd,
+			args: []string{"test/mails.json", "--dry-run"},
+			want: []string{
+				"Dry Run of Mail Import:",
+				"Dry run, nothing was imported. new: 2, identical: 1, conflicting: 0, duplicate: 0, invalid: 0.",
+			},
+		},
+		{
+			name: "missing file",
+			cmd:  cmd.NewImportMailCmd,
+			args: []string{"test/noFile.json", "--dry-run"},
+			want: []string{"failed to open import file:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.cmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+			require.NoError(t, c.Execute())
+			out, err := io.ReadAll(b)
+			require.NoError(t, err)
+
+			for _, w := range tt.want {
+				assert.Contains(t, string(out), w)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		c := cmd.NewImportMailCmd()
+		b := bytes.NewBufferString("")
+		c.SetOut(b)
+		c.SetArgs([]string{"test/mails.json", "--dry-run"})
+
+		viper.Set(cmd.OutputKey, "json")
+		defer viper.Set(cmd.OutputKey, "")
+
+		require.NoError(t, c.Execute())
+
+		var got cmd.ImportDiff
+		require.NoError(t, json.Unmarshal(b.Bytes(), &got))
+		assert.Equal(t, 2, got.Counts[cmd.DiffNew])
+		assert.Equal(t, 1, got.Counts[cmd.DiffIdentical])
+		assert.Len(t, got.Records, 3)
+	})
+
+	// nothing was imported
+	m, err := datastore.Open(dbFilePath)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	n, err := m.Count(&lstg.Listing{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = m.Count(&cmd.Mail{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
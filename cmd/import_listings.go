@@ -23,18 +23,75 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/asdine/storm/v3"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/asphaltbuffet/ogma/pkg/datastore"
 	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
 )
 
+// Import configuration keys and the policies for invalid records.
+const (
+	ImportInvalidKey     = "import.invalid"
+	ListingCategoriesKey = "listings.categories"
+
+	// ImportStrict imports nothing when any record is invalid.
+	ImportStrict = "strict"
+
+	// ImportSkipInvalid imports the valid records and skips the rest.
+	ImportSkipInvalid = "skip"
+)
+
 const importListingCommandLongDesc = "Imports one-to-many LEX ads from a json file. This json file\n" +
-	"should follow the format provided in the project 'examples' directory."
+	"should follow the format provided in the project 'examples' directory.\n\n" +
+	"Every field of a listing is checked before it is saved, and invalid records are listed by their position in\n" +
+	"the file. Nothing is imported when any record is invalid, unless '--skip-invalid' is given or 'import.invalid'\n" +
+	"is set to 'skip'. Categories are checked against 'listings.categories' when it is set.\n\n" +
+	"Listings are also checked against the catalogued issue they are printed in. A listing's volume, year, and\n" +
+	"season are filled in from the issue when they are left out. Issues that aren't catalogued yet are added from\n" +
+	"the first listing imported for them.\n\n" +
+	"Any number of files and glob patterns can be given, and '-' reads listings from standard input. The listings of\n" +
+	"every file are read one at a time, and saved in one transaction, so nothing is imported if the import fails,\n" +
+	"unless '--batch-size' (or 'import.batch_size') is given. Listings are then saved in batches of that size, with\n" +
+	"progress shown after each, and the batches saved before a failure are kept.\n\n" +
+	"Listings are matched to stored listings by the import mode ('--mode' or 'import.mode'):\n" +
+	"  insert   add listings, skipping any with an ID that is already stored\n" +
+	"  upsert   replace the stored listing with the same ID (default)\n" +
+	"  merge    update the stored listing with the same key fields with the fields the record sets\n" +
+	"  replace  remove all stored listings before importing\n\n" +
+	"Listings that aren't matched are skipped as duplicates when a listing with the same key fields is stored\n" +
+	"('import.keys.listings', by default issue, page, member, and alt), unless '--keep-duplicates' is given."
+
+// ListingImportOptions control how listings are checked when they are imported.
+type ListingImportOptions struct {
+	// SkipInvalid imports the valid listings when some are invalid, instead of none.
+	SkipInvalid bool
+	Rules       lstg.Rules
+	Mode        ImportMode
+
+	// KeyFields identify a listing, to merge it or to find it already stored under another ID.
+	KeyFields []string
+
+	// KeepDuplicates imports listings even when a listing with the same key is stored.
+	KeepDuplicates bool
+
+	Batch BatchOptions
+}
+
+// An InvalidRecord is an imported record that can't be stored. Index counts records from 1, in the order they are
+// written in the import file. File names the import file when records are imported from more than one.
+type InvalidRecord struct {
+	File  string
+	Index int
+	Err   error
+}
 
 func init() {
 	importCmd.AddCommand(NewImportListingCmd())
@@ -44,38 +101,82 @@ func init() {
 func NewImportListingCmd() *cobra.Command {
 	// cmd represents the import listing command.
 	cmd := &cobra.Command{
-		Use:     "listings [filename]",
-		Short:   "Bulk import listing records.",
-		Long:    importListingCommandLongDesc,
-		Example: "ogma import listings listingImport.json",
-		Run:     RunImportListingsCmd,
+		Use:   "listings [filename...]",
+		Short: "Bulk import listing records.",
+		Long:  importListingCommandLongDesc,
+		Example: "ogma import listings listingImport.json\n" +
+			"ogma import listings issues/*.json\n" +
+			"generate-listings | ogma import listings -",
+		Args: cobra.MinimumNArgs(1),
+		Run:  RunImportListingsCmd,
 	}
 
+	addInvalidFlags(cmd)
+	addImportModeFlag(cmd)
+	addDuplicatesFlag(cmd)
+	addFormatFlags(cmd)
+	addBatchFlag(cmd)
+	addDryRunFlags(cmd)
+
 	return cmd
 }
 
 // RunImportListingsCmd performs action associated with listings-import application command.
 func RunImportListingsCmd(cmd *cobra.Command, args []string) {
-	jsonFile, dsManager, err := initImportFile(args[0])
-	// defer closing the import file until after we're done with it
-	defer func() {
-		if dsManager != nil {
-			dsManager.Stop()
-		}
+	skip, err := skipInvalidFlag(cmd)
+	if err != nil {
+		log.Error("invalid import policy: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
 
-		if jsonFile != nil {
-			if closeErr := jsonFile.Close(); closeErr != nil {
-				log.Error("failed to close import file: ", closeErr)
-			}
-		}
-	}()
+	mode, err := importModeFlag(cmd)
+	if err != nil {
+		log.Error("invalid import mode: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	files, err := importFiles(cmd, args)
+	if err != nil {
+		log.Error("invalid import files: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	batch, err := batchFlag(cmd)
+	if err != nil {
+		log.Error("invalid import batch size: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	opts := ListingImportOptions{
+		SkipInvalid:    skip,
+		Rules:          listingRules(),
+		Mode:           mode,
+		KeyFields:      keyFields(ListingKeyFieldsKey, DefaultListingKeyFields),
+		KeepDuplicates: keepDuplicatesFlag(cmd),
+		Batch:          batch,
+	}
+
+	if isDryRun(cmd) {
+		runImportDryRun(cmd, files, func(f io.Reader, format FileFormat, ds storm.Finder) (ImportDiff, error) {
+			return DiffListings(f, format, ds, opts)
+		})
+
+		return
+	}
+
+	dsManager, err := initImportDatastore(files)
 	if err != nil {
 		log.Error("error initializing listings import: ", err)
 		cmd.PrintErrln("error initializing listings import: ", err)
 		return
 	}
+	defer dsManager.Stop()
 
-	listOut, err := ImportListings(jsonFile, dsManager)
+	listOut, err := ImportListings(files, dsManager, opts)
 	if err != nil {
 		log.Error("failed to import listing records: ", err)
 		cmd.PrintErrln("failed to import listing records: ", err)
@@ -85,59 +186,544 @@ func RunImportListingsCmd(cmd *cobra.Command, args []string) {
 	cmd.Println(listOut)
 }
 
-// ImportListings adds one to many listings to the datastore from a file.
-func ImportListings(f io.Reader, d datastore.Saver) (string, error) {
-	// convert import file into a listings struct
-	var rawListings lstg.Listings
-	err := parseFromFile(f, &rawListings)
+// listingRules returns the rules listings are validated with, using the 'listings.categories' setting.
+func listingRules() lstg.Rules {
+	rules := lstg.DefaultRules()
+	rules.Categories = viper.GetStringSlice(ListingCategoriesKey)
+
+	return rules
+}
+
+// addInvalidFlags adds the flags choosing what is done when imported records are invalid.
+func addInvalidFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("strict", false, "Import nothing if any record is invalid. (default unless 'import.invalid' is 'skip')")
+	cmd.Flags().Bool("skip-invalid", false, "Import the valid records and skip invalid ones.")
+	cmd.MarkFlagsMutuallyExclusive("strict", "skip-invalid")
+}
+
+// skipInvalidFlag reads whether invalid records are skipped from the '--strict' and '--skip-invalid' flags, or the
+// 'import.invalid' setting when neither is given.
+func skipInvalidFlag(cmd *cobra.Command) (bool, error) {
+	if strict, _ := cmd.Flags().GetBool("strict"); strict {
+		return false, nil
+	}
+
+	if skip, _ := cmd.Flags().GetBool("skip-invalid"); skip {
+		return true, nil
+	}
+
+	switch p := viper.GetString(ImportInvalidKey); p {
+	case ImportStrict, "":
+		return false, nil
+	case ImportSkipInvalid:
+		return true, nil
+	default:
+		return false, fmt.Errorf("'%s' must be '%s' or '%s': %s", ImportInvalidKey, ImportStrict, ImportSkipInvalid, p)
+	}
+}
+
+// ImportListings adds one to many listings to the datastore from import files. Listings are validated, and checked
+// against the catalogued issue they are printed in. Nothing is imported if any listing is invalid, unless
+// opts.SkipInvalid is set. Listings are read from each file one at a time, and the listings of every file are
+// saved together, in batches of opts.Batch.Size.
+func ImportListings(files []ImportFile, d datastore.Saver, opts ListingImportOptions) (string, error) {
+	li := &listingImporter{
+		opts:   opts,
+		issues: map[int]Issue{},
+		seen:   recordSet{},
+	}
+
+	if err := checkBatchMode(opts.Mode, opts.Batch); err != nil {
+		return "", err
+	}
+
+	// conduct import as transactions, one for each batch
+	batch, err := newImportBatch(d, opts.Batch, "listing", li.flush)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse input file: %w", err)
+		return "", err
 	}
+	defer batch.rollback()
 
-	listings := UniqueListings(rawListings.Listings)
-	importCount := len(rawListings.Listings)
+	li.batch = batch
+
+	if li.summary.Removed, err = clearListings(batch.tx, opts.Mode); err != nil {
+		return "", err
+	}
+
+	if li.keys, err = listingKeys(batch.tx, opts.KeyFields); err != nil {
+		return "", err
+	}
 
-	// conduct import as a transaction
-	tx, err := d.Begin(true)
+	summaries := make([]FileSummary, len(files))
+
+	for i, file := range files {
+		// records are named by their file when there is more than one
+		if len(files) > 1 {
+			li.file = file.String()
+		}
+
+		before := li.summary
+
+		read, fileErr := li.importFile(file)
+		if fileErr != nil && li.file != "" {
+			fileErr = fmt.Errorf("%s: %w", li.file, fileErr)
+		}
+
+		if fileErr != nil {
+			return "", batch.failed(fileErr)
+		}
+
+		summaries[i] = FileSummary{File: file, Read: read, Summary: li.summary.since(before)}
+	}
+
+	// replaced listings may have been answered by stored mail
+	var dangling []DanglingLink
+	if opts.Mode == ImportReplace {
+		if dangling, err = FindDanglingLinks(batch.tx); err != nil {
+			return "", batch.failed(err)
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"cmd":          "import",
+		"import_count": li.summary.Imported(),
+		"read_count":   batch.read,
+		"file_count":   len(files),
+		"mode":         opts.Mode,
+	}).Info("completed importing records")
+
+	if err = batch.commit(); err != nil {
+		return "", batch.failed(err)
+	}
+
+	// Tell user how many records were imported.
+	out := fmt.Sprintf("Imported %d/%d listing records (%s).", li.summary.Imported(), batch.read, li.summary)
+
+	if len(files) > 1 {
+		out += "\n" + RenderFileSummaries(summaries)
+	}
+
+	for _, is := range li.catalogued {
+		out += fmt.Sprintf("\nAdded issue %d to the catalogue (volume %d, %s %d).", is.Number, is.Volume, is.Season, is.Year)
+	}
+
+	if len(dangling) > 0 {
+		out += fmt.Sprintf("\nMail links that can't be followed: %d. Use 'ogma check links' to list them.", len(dangling))
+	}
+
+	if len(li.duplicates) > 0 {
+		out += fmt.Sprintf("\nSkipped duplicates: %d\n%s", len(li.duplicates), RenderDuplicateRecords(li.duplicates))
+	}
+
+	if len(li.invalid) > 0 {
+		out += fmt.Sprintf("\nSkipped invalid records: %d\n%s", len(li.invalid), RenderInvalidRecords(li.invalid))
+	}
+
+	return out, nil
+}
+
+// A listingImporter saves imported listings in a transaction, and keeps track of what was done with each.
+type listingImporter struct {
+	batch      *importBatch
+	file       string
+	opts       ListingImportOptions
+	seen       recordSet
+	summary    ImportSummary
+	issues     map[int]Issue
+	catalogued []Issue
+	invalid    []InvalidRecord
+	duplicates []DuplicateRecord
+	keys       *keyIndex
+	maxID      int
+	columns    []string
+}
+
+// add saves an imported listing, as the import mode says. index is where the listing is in the import file.
+// Listings repeated in the file are only saved once.
+func (li *listingImporter) add(l lstg.Listing, index int) error {
+	if first, err := li.seen.add(l); err != nil || !first {
+		return err
+	}
+
+	stored, found, err := li.storedListing(l)
+	if err != nil {
+		return err
+	}
+
+	if found && li.opts.Mode == ImportInsert {
+		li.summary.Skipped++
+		return nil
+	}
+
+	listing := l
+	if found {
+		if listing, err = matchedListing(li.opts.Mode, stored, l, li.columns); err != nil {
+			return err
+		}
+	}
+
+	is, catalogueFound, err := cataloguedIssue(li.batch.tx, li.issues, listing.IssueNumber)
 	if err != nil {
-		return "", fmt.Errorf("error beginning datastore transaction: %w", err)
+		return err
+	}
+
+	var fillErr error
+	if catalogueFound {
+		fillErr = is.Fill(&listing)
+	}
+
+	if err = joinFieldErrors(listing.Validate(li.opts.Rules), fillErr); err != nil {
+		log.WithFields(log.Fields{
+			"cmd":     "import",
+			"listing": fmt.Sprintf("%+v", listing),
+		}).Warn("invalid record: ", err)
+		li.invalid = append(li.invalid, InvalidRecord{File: li.file, Index: index, Err: err})
+
+		return nil
 	}
-	defer func() {
-		if errRollback := tx.Rollback(); errRollback != nil {
-			log.Error("failed to rollback datastore transaction: ", errRollback)
+
+	if !found {
+		var dup bool
+		if dup, err = li.skipDuplicate(listing, index); err != nil || dup {
+			return err
 		}
-	}()
+	}
 
-	// datastore needs to add one listing at a time, walk through imported listings and save one by one
-	for _, l := range listings {
-		listing := l
+	if !catalogueFound {
+		is = IssueFromListing(listing)
+		if err = saveIssue(li.batch.tx, &is); err != nil {
+			return fmt.Errorf("error adding issue %d: %w", is.Number, err)
+		}
 
-		err = tx.Save(&listing)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"cmd":     "import",
-				"listing": fmt.Sprintf("%+v", listing),
-			}).Warn("failed to import record:", err)
-			importCount--
+		li.issues[is.Number] = is
+		li.catalogued = append(li.catalogued, is)
+	}
+
+	if found && listing == stored {
+		li.summary.Unchanged++
+		return nil
+	}
+
+	if !found {
+		if listing.ID, err = freeID(li.batch.tx, listing.ID, &lstg.Listing{}); err != nil {
+			return err
 		}
+	}
+
+	if listing.ID > li.maxID {
+		li.maxID = listing.ID
+	}
 
+	if err = li.batch.tx.Save(&listing); err != nil {
 		log.WithFields(log.Fields{
 			"cmd":     "import",
 			"listing": fmt.Sprintf("%+v", listing),
-		}).Debug("imported record")
+		}).Error("failed to import record: ", err)
+
+		return fmt.Errorf("error saving record %d: %w", index, err)
+	}
+
+	if err = lstg.TextIndex(li.batch.tx).Add(listing.ID, listing.ListingText); err != nil {
+		return fmt.Errorf("error indexing listing text: %w", err)
+	}
+
+	if found {
+		if err = li.keys.remove(stored); err != nil {
+			return err
+		}
+	}
+
+	if err = li.keys.add(listing, listing.ID, listingRef(listing)); err != nil {
+		return err
 	}
+
+	if found {
+		li.summary.Updated++
+	} else {
+		li.summary.Created++
+	}
+
 	log.WithFields(log.Fields{
-		"cmd":          "import",
-		"import_count": importCount,
-		"read_count":   len(rawListings.Listings),
-	}).Info("completed importing records")
+		"cmd":     "import",
+		"listing": fmt.Sprintf("%+v", listing),
+	}).Debug("imported record")
+
+	return nil
+}
+
+// importFile saves the listings of an import file, and returns how many were read.
+func (li *listingImporter) importFile(file ImportFile) (int, error) {
+	r, err := file.Open()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer closeImportFile(r)
 
-	if errCommit := tx.Commit(); errCommit != nil {
-		return "", fmt.Errorf("error committing records to datastore: %w", errCommit)
+	records, err := file.Format.stream(r, "listings")
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse input file: %w", err)
 	}
 
-	// Tell user how many records were imported.
-	return fmt.Sprintf("Imported %d/%d listing records.", importCount, len(rawListings.Listings)), nil
+	// datastore needs to add one listing at a time, walk through imported listings and save one by one
+	for read := 0; ; read++ {
+		var l lstg.Listing
+		if err = records.next(&l); errors.Is(err, io.EOF) {
+			return read, nil
+		}
+
+		if err != nil {
+			return read, fmt.Errorf("failed to parse input file: %w", err)
+		}
+
+		if read == 0 {
+			li.columns = records.columns()
+			if err = checkColumns(li.opts.Mode, file.Format.Name, li.columns, l); err != nil {
+				return read, err
+			}
+		}
+
+		if err = li.add(l, read+1); err != nil {
+			return read, err
+		}
+
+		if err = li.batch.next(); err != nil {
+			return read, err
+		}
+	}
+}
+
+// flush is called before each batch of listings is saved. Nothing more is saved once a listing is invalid, unless
+// invalid listings are skipped.
+func (li *listingImporter) flush(tx storm.Node) error {
+	if len(li.invalid) > 0 && !li.opts.SkipInvalid {
+		return fmt.Errorf("%w: %d of %d records, %s (use '--skip-invalid' to import the rest):\n%s",
+			lstg.ErrInvalid, len(li.invalid), li.batch.read, li.batch.stopped(), RenderInvalidRecords(li.invalid))
+	}
+
+	// listings kept the IDs they were imported with, make sure new listings are numbered after them
+	return datastore.SyncIncrement(tx, "Listing", li.maxID)
+}
+
+// skipDuplicate reports whether a new listing is skipped, as a listing with the same key fields is stored.
+func (li *listingImporter) skipDuplicate(l lstg.Listing, index int) (bool, error) {
+	if li.opts.KeepDuplicates {
+		return false, nil
+	}
+
+	_, label, found, err := li.keys.find(l)
+	if err != nil || !found {
+		return false, err
+	}
+
+	log.WithFields(log.Fields{
+		"cmd":     "import",
+		"listing": fmt.Sprintf("%+v", l),
+		"stored":  label,
+	}).Debug("skipped duplicate record")
+
+	li.duplicates = append(li.duplicates, DuplicateRecord{File: li.file, Index: index, Stored: label})
+	li.summary.Duplicates++
+
+	return true, nil
+}
+
+// storedListing returns the stored listing an imported listing is matched to by the import mode, and whether there
+// is one. Listings are matched by ID, or by their key fields when merging.
+func (li *listingImporter) storedListing(l lstg.Listing) (lstg.Listing, bool, error) {
+	var stored lstg.Listing
+
+	id := l.ID
+
+	switch li.opts.Mode {
+	case ImportReplace:
+		return stored, false, nil
+	case ImportMerge:
+		var (
+			found bool
+			err   error
+		)
+
+		if id, _, found, err = li.keys.find(l); err != nil || !found {
+			return stored, false, err
+		}
+	}
+
+	if id == 0 {
+		return stored, false, nil
+	}
+
+	err := li.batch.tx.One("ID", id, &stored)
+	if errors.Is(err, storm.ErrNotFound) {
+		return stored, false, nil
+	}
+
+	if err != nil {
+		return stored, false, fmt.Errorf("error reading stored listing: %w", err)
+	}
+
+	return stored, true, nil
+}
+
+// listingKeys returns an index of the stored listings by the fields that identify them.
+func listingKeys(tx storm.Finder, fields []string) (*keyIndex, error) {
+	keys, err := newKeyIndex(fields, lstg.Listing{})
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", ListingKeyFieldsKey, err)
+	}
+
+	ll := []lstg.Listing{}
+	if err = tx.All(&ll); err != nil {
+		return nil, fmt.Errorf("failure to read listings: %w", err)
+	}
+
+	for _, l := range ll {
+		if err = keys.add(l, l.ID, listingRef(l)); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// listingRef names a stored listing in reports.
+func listingRef(l lstg.Listing) string {
+	return fmt.Sprintf("listing %d (%s)", l.ID, listingLabel(l))
+}
+
+// matchedListing returns the listing saved in place of a stored listing that an imported listing is matched to.
+// columns are the fields the import file holds, and nil columns hold every field.
+func matchedListing(mode ImportMode, stored, imported lstg.Listing, columns []string) (lstg.Listing, error) {
+	var (
+		matched lstg.Listing
+		err     error
+	)
+
+	switch {
+	case mode == ImportMerge:
+		err = mergeRecord(stored, imported, &matched)
+	case columns != nil:
+		err = keepStoredFields(stored, imported, &matched, columns)
+	default:
+		matched = imported
+	}
+
+	if err != nil {
+		return matched, err
+	}
+
+	matched.ID = stored.ID
+
+	return matched, nil
+}
+
+// clearListings removes every stored listing, and their indexed text, when the import mode replaces them. It
+// returns how many listings were removed.
+func clearListings(tx storm.Node, mode ImportMode) (int, error) {
+	if mode != ImportReplace {
+		return 0, nil
+	}
+
+	n, err := tx.Count(&lstg.Listing{})
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	if err = tx.Drop(&lstg.Listing{}); err != nil {
+		return 0, fmt.Errorf("error removing stored listings: %w", err)
+	}
+
+	if err = lstg.TextIndex(tx).Clear(); err != nil {
+		return 0, fmt.Errorf("error clearing listing text index: %w", err)
+	}
+
+	return n, nil
+}
+
+// cataloguedIssue returns the catalogued issue with a number, and whether there is one. Issues already read are kept
+// in issues.
+func cataloguedIssue(tx storm.Finder, issues map[int]Issue, n int) (Issue, bool, error) {
+	if n <= 0 {
+		return Issue{}, false, nil
+	}
+
+	if is, ok := issues[n]; ok {
+		return is, true, nil
+	}
+
+	is, err := findIssue(tx, n)
+	if errors.Is(err, ErrIssueNotFound) {
+		return Issue{}, false, nil
+	}
+
+	if err != nil {
+		return Issue{}, false, err
+	}
+
+	issues[n] = is
+
+	return is, true, nil
+}
+
+// joinFieldErrors combines the field errors of a record into one error, keeping the first error for each field.
+// Errors that aren't field errors are returned as they are.
+func joinFieldErrors(errs ...error) error {
+	ee := lstg.FieldErrors{}
+	seen := map[string]bool{}
+
+	for _, err := range errs {
+		var fe lstg.FieldErrors
+
+		switch {
+		case err == nil:
+			continue
+		case !errors.As(err, &fe):
+			return err
+		}
+
+		for _, e := range fe {
+			if !seen[e.Field] {
+				seen[e.Field] = true
+				ee = append(ee, e)
+			}
+		}
+	}
+
+	if len(ee) == 0 {
+		return nil
+	}
+
+	ee.Sort()
+
+	return ee
+}
+
+// recordName names an imported record by where it is in its import file.
+func recordName(file string, index int) string {
+	if file == "" {
+		return fmt.Sprintf("record %d", index)
+	}
+
+	return fmt.Sprintf("%s record %d", file, index)
+}
+
+// RenderInvalidRecords lists invalid records by their position in the import file, with one line for each invalid
+// field.
+func RenderInvalidRecords(rr []InvalidRecord) string {
+	lines := []string{}
+
+	for _, r := range rr {
+		var ee lstg.FieldErrors
+		if !errors.As(r.Err, &ee) {
+			lines = append(lines, fmt.Sprintf("  %s: %v", recordName(r.File, r.Index), r.Err))
+			continue
+		}
+
+		for _, e := range ee {
+			lines = append(lines, fmt.Sprintf("  %s: %v", recordName(r.File, r.Index), e))
+		}
+	}
+
+	return strings.Join(lines, "\n")
 }
 
 // UniqueListings returns the passed in slice of listings with at most one of each listing. Listing order is
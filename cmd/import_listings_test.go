@@ -28,11 +28,13 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/spf13/afero"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
 	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
 )
 
@@ -52,6 +54,25 @@ func TestRunImportListingsCmd(t *testing.T) {
 		require.NoError(t, appFS.RemoveAll("test/"))
 	}()
 
+	require.NoError(t, afero.WriteFile(appFS, "test/issue.json", []byte(`{"listings": [
+		{"issue": 1, "page": 4, "category": "Commodo", "member": 4321, "text": "Ullamco ex amet."},
+		{"volume": 1, "issue": 1, "year": 1986, "season": "Summer", "page": 5, "category": "Commodo", "member": 4322, "text": "Duis elit."},
+		{"volume": 2, "issue": 1, "year": 1986, "page": 6, "category": "Commodo", "member": 4323, "text": "Sint enim."}
+	]}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/invalid_fields.json", []byte(`{"listings": [
+		{"volume": 3, "issue": 7, "year": 1990, "season": "Fall", "page": 1, "category": "Commodo", "member": 4325, "text": "Culpa qui."},
+		{"volume": 3, "issue": 7, "year": 1990, "season": "Sprng", "page": -1, "category": "Commodo", "member": 0, "alt": "AB", "text": " "},
+		{"page": 7, "category": "", "member": 4324, "text": "Nisi est."}
+	]}`), 0o644))
+
+	require.NoError(t, afero.WriteFile(appFS, "test/issue.csv", []byte("Issue,Page,Category,Member,Alt,Text,International,Art\n"+
+		"1,8,Commodo,4330,,\"Amet, consectetur.\",yes,x\n"+
+		"1,9,Commodo,4331,B,Elit sed.,,\n"), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/invalid.csv", []byte("issue;page;member;text\n"+
+		"1;ten;4332;Nope.\n"), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/issue.txt", []byte("issue|page|category|member|text\n"+
+		"1|10|Commodo|4333|Piped, from a spreadsheet.\n"), 0o644))
+
 	tests := []struct {
 		name      string
 		args      []string
@@ -63,7 +84,7 @@ func TestRunImportListingsCmd(t *testing.T) {
 			name:      "single entry",
 			args:      []string{"test/listing.json"},
 			datastore: dbFilePath,
-			want:      "Imported 1/1 listing records.\n",
+			want:      "Imported 1/1 listing records (1 created, 0 updated, 0 unchanged).\nAdded issue 55 to the catalogue (volume 2, Spring 2021).\n",
 			assertion: assert.NoError,
 		},
 		{
@@ -71,7 +92,77 @@ func TestRunImportListingsCmd(t *testing.T) {
 			args:      []string{"test/listings.json"},
 			datastore: dbFilePath,
 			assertion: assert.NoError,
-			want:      "Imported 3/3 listing records.\n",
+			want:      "Imported 3/3 listing records (3 created, 0 updated, 0 unchanged).\nAdded issue 1 to the catalogue (volume 1, Spring 1986).\n",
+		},
+		{
+			name:      "checked against issue",
+			args:      []string{"test/issue.json", "--skip-invalid"},
+			datastore: dbFilePath,
+			assertion: assert.NoError,
+			want: "Imported 1/3 listing records (1 created, 0 updated, 0 unchanged).\n" +
+				"Skipped invalid records: 2\n" +
+				"  record 2: season \"Summer\": issue 1 is from Spring\n" +
+				"  record 3: volume 2: issue 1 is volume 1\n",
+		},
+		{
+			name:      "invalid fields",
+			args:      []string{"test/invalid_fields.json"},
+			datastore: dbFilePath,
+			assertion: assert.NoError,
+			want: "failed to import listing records:  invalid listing: 2 of 3 records, nothing was imported (use '--skip-invalid' to import the rest):\n" +
+				"  record 2: season \"Sprng\": must be Spring, Summer, Fall, Winter\n" +
+				"  record 2: page -1: must be positive\n" +
+				"  record 2: member 0: must be positive\n" +
+				"  record 2: alt \"AB\": must be a single letter\n" +
+				"  record 2: text \" \": must not be empty\n" +
+				"  record 3: volume 0: must be positive\n" +
+				"  record 3: issue 0: must be positive\n" +
+				"  record 3: year 0: must be from 1970 to ",
+		},
+		{
+			name:      "skip invalid fields",
+			args:      []string{"test/invalid_fields.json", "--skip-invalid"},
+			datastore: dbFilePath,
+			assertion: assert.NoError,
+			want: "Imported 1/3 listing records (1 created, 0 updated, 0 unchanged).\n" +
+				"Added issue 7 to the catalogue (volume 3, Fall 1990).\n" +
+				"Skipped invalid records: 2\n",
+		},
+		{
+			name:      "strict and skip invalid",
+			args:      []string{"test/invalid_fields.json", "--skip-invalid", "--strict"},
+			datastore: dbFilePath,
+			assertion: assert.Error,
+			want:      "Error: if any flags in the group [strict skip-invalid] are set none of the others can be",
+		},
+		{
+			name:      "csv import",
+			args:      []string{"test/issue.csv"},
+			datastore: dbFilePath,
+			assertion: assert.NoError,
+			want:      "Imported 2/2 listing records (2 created, 0 updated, 0 unchanged).\n",
+		},
+		{
+			name:      "invalid csv cell",
+			args:      []string{"test/invalid.csv", "--delimiter", ";"},
+			datastore: dbFilePath,
+			assertion: assert.NoError,
+			want: "failed to import listing records:  failed to parse input file: failed to read csv file: invalid delimited text:\n" +
+				"row 2: page \"ten\": must be a whole number\n",
+		},
+		{
+			name:      "delimiter without a csv extension",
+			args:      []string{"test/issue.txt", "--delimiter", "|"},
+			datastore: dbFilePath,
+			assertion: assert.NoError,
+			want:      "Imported 1/1 listing records (1 created, 0 updated, 0 unchanged).\n",
+		},
+		{
+			name:      "delimiter with a json file",
+			args:      []string{"test/invalid.json", "--delimiter", ";"},
+			datastore: dbFilePath,
+			assertion: assert.NoError,
+			want:      "invalid input:  '--delimiter' is only used with csv files: \"test/invalid.json\" is json\n",
 		},
 		{
 			name:      "missing file",
@@ -111,6 +202,29 @@ func TestRunImportListingsCmd(t *testing.T) {
 			assert.Equal(t, tt.want, string(out)[:len(tt.want)])
 		})
 	}
+
+	// imported listing text is searchable
+	m, err := datastore.Open(dbFilePath)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	rr, err := lstg.TextIndex(m.Store).Search("fingerpainting", 0)
+	require.NoError(t, err)
+	assert.Len(t, rr, 1)
+
+	// listings without issue details are filled in from the catalogue
+	var l lstg.Listing
+	require.NoError(t, m.One("IndexedMemberNumber", 4321, &l))
+	assert.Equal(t, 1, l.Volume)
+	assert.Equal(t, 1986, l.Year)
+	assert.Equal(t, "Spring", l.Season)
+
+	// csv flags are read from spreadsheet values
+	require.NoError(t, m.One("IndexedMemberNumber", 4330, &l))
+	assert.Equal(t, "Amet, consectetur.", l.ListingText)
+	assert.True(t, l.IsInternational)
+	assert.True(t, l.IsArt)
+	assert.False(t, l.IsReview)
 }
 
 func TestUniqueListings(t *testing.T) {
@@ -23,19 +23,34 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/asdine/storm/v3"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
 	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
 )
 
 const importMailCommandLongDesc = "Imports one-to-many correspondence records from a json file. This json\n" +
 	"file should follow the format provided in the project 'examples' directory.\n\n" +
-	"The reference field should be unique for each record. Manually creating this may increase the chance of duplicate values."
+	"The reference field should be unique for each record, and records without one are given a new reference.\n" +
+	"Records with a reference that is already stored are handled by the import mode ('--mode' or 'import.mode'):\n" +
+	"  insert   skip them\n" +
+	"  upsert   replace the stored mail (default)\n" +
+	"  merge    update the stored mail (or mail with the same key fields) with the fields the record sets\n" +
+	"  replace  remove all stored mail before importing\n\n" +
+	"Mail that isn't matched is skipped as a duplicate when mail with the same key fields is stored\n" +
+	"('import.keys.mail', by default sender, receiver, and date), unless '--keep-duplicates' is given.\n\n" +
+	"Any number of files and glob patterns can be given, and '-' reads mail from standard input. The mail of every\n" +
+	"file is read one at a time, and saved in one transaction unless '--batch-size' (or 'import.batch_size') is\n" +
+	"given. Mail is then saved in batches of that size, with progress shown after each, and the batches saved\n" +
+	"before a failure are kept."
 
 func init() {
 	importCmd.AddCommand(NewImportMailCmd())
@@ -45,38 +60,72 @@ func init() {
 func NewImportMailCmd() *cobra.Command {
 	// cmd represents the import command.
 	cmd := &cobra.Command{
-		Use:     "mail [filename]",
-		Short:   "Bulk import mail records.",
-		Long:    importMailCommandLongDesc,
-		Example: "ogma import mail mImport.json",
-		Run:     RunImportMailCmd,
+		Use:   "mail [filename...]",
+		Short: "Bulk import mail records.",
+		Long:  importMailCommandLongDesc,
+		Example: "ogma import mail mImport.json\n" +
+			"ogma import mail letters/*.csv\n" +
+			"generate-mail | ogma import mail -",
+		Args: cobra.MinimumNArgs(1),
+		Run:  RunImportMailCmd,
 	}
 
+	addImportModeFlag(cmd)
+	addDuplicatesFlag(cmd)
+	addFormatFlags(cmd)
+	addBatchFlag(cmd)
+	addDryRunFlags(cmd)
+
 	return cmd
 }
 
 // RunImportMailCmd performs action associated with mail-import application command.
 func RunImportMailCmd(cmd *cobra.Command, args []string) {
-	jsonFile, dsManager, err := initImportFile(args[0])
-	// defer closing the import file until after we're done with it
-	defer func() {
-		if dsManager != nil {
-			dsManager.Stop()
-		}
+	mode, err := importModeFlag(cmd)
+	if err != nil {
+		log.Error("invalid import mode: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
 
-		if jsonFile != nil {
-			if closeErr := jsonFile.Close(); closeErr != nil {
-				log.Error("failed to close import file: ", closeErr)
-			}
-		}
-	}()
+	files, err := importFiles(cmd, args)
+	if err != nil {
+		log.Error("invalid import files: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	batch, err := batchFlag(cmd)
+	if err != nil {
+		log.Error("invalid import batch size: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	opts := MailImportOptions{
+		Mode:           mode,
+		KeyFields:      keyFields(MailKeyFieldsKey, DefaultMailKeyFields),
+		KeepDuplicates: keepDuplicatesFlag(cmd),
+		Batch:          batch,
+	}
+
+	if isDryRun(cmd) {
+		runImportDryRun(cmd, files, func(f io.Reader, format FileFormat, ds storm.Finder) (ImportDiff, error) {
+			return DiffMails(f, format, ds, opts)
+		})
+
+		return
+	}
+
+	dsManager, err := initImportDatastore(files)
 	if err != nil {
-		log.Error("error initializing listings import: ", err)
-		cmd.PrintErrln("error initializing listings import: ", err)
+		log.Error("error initializing mail import: ", err)
+		cmd.PrintErrln("error initializing mail import: ", err)
 		return
 	}
+	defer dsManager.Stop()
 
-	mailOut, err := importMail(jsonFile, dsManager)
+	mailOut, err := importMail(files, dsManager, opts)
 	if err != nil {
 		log.Error("failed to import mail records: ", err)
 		cmd.PrintErr("failed to import mail records: ", err)
@@ -86,66 +135,417 @@ func RunImportMailCmd(cmd *cobra.Command, args []string) {
 	cmd.Println(mailOut)
 }
 
-// importMail adds one to many mail to the datastore from a file.
-func importMail(f io.Reader, d datastore.Saver) (string, error) {
-	var rawMail Mails
+// MailImportOptions control how imported mail is matched to stored mail.
+type MailImportOptions struct {
+	Mode ImportMode
 
-	// convert import file into a mails struct
-	err := parseFromFile(f, &rawMail)
+	// KeyFields identify mail without a stored reference, to merge it or to find it already stored.
+	KeyFields []string
+
+	// KeepDuplicates imports mail even when mail with the same key is stored.
+	KeepDuplicates bool
+
+	Batch BatchOptions
+}
+
+// importMail adds one to many mail to the datastore from import files. Mail is matched to stored mail by its
+// reference, or by its key fields, and what is done with a match depends on the import mode. Mail is read from each
+// file one at a time, and the mail of every file is saved together, in batches of opts.Batch.Size.
+func importMail(files []ImportFile, d datastore.Saver, opts MailImportOptions) (string, error) {
+	mi := &mailImporter{opts: opts, seen: recordSet{}}
+
+	if err := checkBatchMode(opts.Mode, opts.Batch); err != nil {
+		return "", err
+	}
+
+	// conduct import as transactions, one for each batch
+	batch, err := newImportBatch(d, opts.Batch, "mail", mi.flush)
 	if err != nil {
-		log.WithFields(log.Fields{"cmd": "import"}).Error("failed to parse input file: ", err)
-		return "", fmt.Errorf("failed to parse input file: %w", err)
+		return "", err
+	}
+	defer batch.rollback()
+
+	mi.batch = batch
+
+	if opts.Mode == ImportReplace {
+		if mi.summary.Removed, err = clearMail(batch.tx); err != nil {
+			return "", err
+		}
+	}
+
+	if mi.keys, err = mailKeys(batch.tx, opts.KeyFields); err != nil {
+		return "", err
 	}
 
-	if len(rawMail.Mails) == 0 {
+	summaries := make([]FileSummary, len(files))
+
+	for i, file := range files {
+		// records are named by their file when there is more than one
+		if len(files) > 1 {
+			mi.file = file.String()
+		}
+
+		before := mi.summary
+
+		read, fileErr := mi.importFile(file)
+		if fileErr != nil && mi.file != "" {
+			fileErr = fmt.Errorf("%s: %w", mi.file, fileErr)
+		}
+
+		if fileErr != nil {
+			return "", batch.failed(fileErr)
+		}
+
+		summaries[i] = FileSummary{File: file, Read: read, Summary: mi.summary.since(before)}
+	}
+
+	if batch.read == 0 {
 		log.Debug("no mail entries found to import")
 		return "", errors.New("no mail entries in import file")
 	}
 
-	mails := UniqueMails(rawMail.Mails)
-	importCount := len(mails)
+	// mail can reply to mail later in the file, or in another file, so links are checked once everything is saved
+	for _, mail := range mi.linked {
+		if err = checkLink(batch.tx, mail.Link); err != nil {
+			return "", batch.failed(fmt.Errorf("mail %s: %w", mail.Ref, err))
+		}
+	}
 
-	// conduct import as a transaction
-	tx, err := d.Begin(true)
+	log.WithFields(log.Fields{
+		"cmd":          "import",
+		"import_count": mi.summary.Imported(),
+		"read_count":   batch.read,
+		"file_count":   len(files),
+		"mode":         opts.Mode,
+	}).Info("completed importing records")
+
+	if err = batch.commit(); err != nil {
+		return "", batch.failed(err)
+	}
+
+	// Tell user how many records were imported.
+	out := fmt.Sprintf("Imported %d/%d mail records (%s).", mi.summary.Imported(), batch.read, mi.summary)
+
+	if len(files) > 1 {
+		out += "\n" + RenderFileSummaries(summaries)
+	}
+
+	if len(mi.duplicates) > 0 {
+		out += fmt.Sprintf("\nSkipped duplicates: %d\n%s", len(mi.duplicates), RenderDuplicateRecords(mi.duplicates))
+	}
+
+	return out, nil
+}
+
+// A mailImporter saves imported mail in a transaction, and keeps track of what was done with each.
+type mailImporter struct {
+	batch      *importBatch
+	file       string
+	opts       MailImportOptions
+	seen       recordSet
+	summary    ImportSummary
+	duplicates []DuplicateRecord
+	keys       *keyIndex
+	linked     []Mail
+	maxID      int
+	columns    []string
+}
+
+// add saves imported mail, as the import mode says. index is where the mail is in the import file. Mail repeated
+// in the file is only saved once.
+func (mi *mailImporter) add(r Mail, index int) error {
+	if first, err := mi.seen.add(r); err != nil || !first {
+		return err
+	}
+
+	imported := r
+	imported.Ref = strings.ToLower(strings.TrimSpace(imported.Ref))
+
+	stored, found, err := mi.storedMail(imported)
+	if err != nil {
+		return err
+	}
+
+	if found && mi.opts.Mode == ImportInsert {
+		mi.summary.Skipped++
+		return nil
+	}
+
+	if !found {
+		var dup bool
+		if dup, err = mi.skipDuplicate(imported, index); err != nil || dup {
+			return err
+		}
+	}
+
+	mail, err := mi.savedMail(imported, stored, found)
 	if err != nil {
-		return "", fmt.Errorf("error beginning datastore transaction: %w", err)
+		return err
 	}
-	defer func() {
-		if errRollback := tx.Rollback(); errRollback != nil {
-			log.Error("failed to rollback datastore transaction: ", errRollback)
+
+	if found {
+		var changed []string
+		if changed, err = diffFields(stored, mail); err != nil {
+			return err
 		}
-	}()
 
-	// datastore needs to add one listing at a time, walk through imported listings and save one by one
-	for _, r := range mails {
-		mail := r
+		if len(changed) == 0 {
+			mi.summary.Unchanged++
+			mi.keepLink(mail)
 
-		err = tx.Save(&mail)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"cmd": "import",
-				// "mail": fmt.Sprintf("%v", mail),
-			}).Warn("failed to import record:", err)
-			importCount--
+			return nil
 		}
+	}
 
+	if mail.ID > mi.maxID {
+		mi.maxID = mail.ID
+	}
+
+	if err = mi.batch.tx.Save(&mail); err != nil {
 		log.WithFields(log.Fields{
-			"cmd":     "import",
-			"listing": fmt.Sprintf("%+v", mail),
-		}).Debug("imported record")
+			"cmd": "import",
+			"ref": mail.Ref,
+		}).Error("failed to import record: ", err)
+
+		return fmt.Errorf("error saving record %d: %w", index, err)
 	}
+
+	if found {
+		mi.summary.Updated++
+
+		if err = mi.keys.remove(stored); err != nil {
+			return err
+		}
+	} else {
+		mi.summary.Created++
+	}
+
+	if err = mi.keys.add(mail, mail.ID, mailRef(mail)); err != nil {
+		return err
+	}
+
+	mi.keepLink(mail)
+
 	log.WithFields(log.Fields{
-		"cmd":          "import",
-		"import_count": importCount,
-		"read_count":   len(rawMail.Mails),
-	}).Info("completed importing records")
+		"cmd":     "import",
+		"listing": fmt.Sprintf("%+v", mail),
+	}).Debug("imported record")
+
+	return nil
+}
 
-	if errCommit := tx.Commit(); errCommit != nil {
-		return "", fmt.Errorf("error committing records to datastore: %w", errCommit)
+// importFile saves the mail of an import file, and returns how many were read.
+func (mi *mailImporter) importFile(file ImportFile) (int, error) {
+	r, err := file.Open()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open import file: %w", err)
 	}
+	defer closeImportFile(r)
 
-	// Tell user how many records were imported.
-	return fmt.Sprintf("Imported %d/%d mail records.", importCount, len(rawMail.Mails)), nil
+	records, err := file.Format.stream(r, "mails")
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse input file: %w", err)
+	}
+
+	// datastore needs to add one mail at a time, walk through imported mail and save one by one
+	for read := 0; ; read++ {
+		var m Mail
+		if err = records.next(&m); errors.Is(err, io.EOF) {
+			return read, nil
+		}
+
+		if err != nil {
+			log.WithFields(log.Fields{"cmd": "import"}).Error("failed to parse input file: ", err)
+			return read, fmt.Errorf("failed to parse input file: %w", err)
+		}
+
+		if read == 0 {
+			mi.columns = records.columns()
+			if err = checkColumns(mi.opts.Mode, file.Format.Name, mi.columns, m); err != nil {
+				return read, err
+			}
+		}
+
+		if err = mi.add(m, read+1); err != nil {
+			return read, err
+		}
+
+		if err = mi.batch.next(); err != nil {
+			return read, err
+		}
+	}
+}
+
+// keepLink keeps saved mail with a link, to check the link once all mail is saved.
+func (mi *mailImporter) keepLink(m Mail) {
+	if m.Link.Kind() != link.None {
+		mi.linked = append(mi.linked, m)
+	}
+}
+
+// flush is called before each batch of mail is saved.
+func (mi *mailImporter) flush(tx storm.Node) error {
+	// mail kept the IDs it was imported with, make sure new mail is numbered after them
+	return datastore.SyncIncrement(tx, "Mail", mi.maxID)
+}
+
+// storedMail returns the stored mail that imported mail is matched to, and whether there is one. Mail is matched by
+// its reference, or by its key fields when merging.
+func (mi *mailImporter) storedMail(m Mail) (Mail, bool, error) {
+	var stored Mail
+
+	if mi.opts.Mode == ImportReplace {
+		return stored, false, nil
+	}
+
+	err := storm.ErrNotFound
+	if m.Ref != "" {
+		err = mi.batch.tx.One("Ref", m.Ref, &stored)
+	}
+
+	if errors.Is(err, storm.ErrNotFound) && mi.opts.Mode == ImportMerge {
+		var (
+			id    int
+			found bool
+		)
+
+		if id, _, found, err = mi.keys.find(m); err != nil || !found {
+			return stored, false, err
+		}
+
+		err = mi.batch.tx.One("ID", id, &stored)
+	}
+
+	if errors.Is(err, storm.ErrNotFound) {
+		return stored, false, nil
+	}
+
+	if err != nil {
+		return stored, false, fmt.Errorf("error reading mail %s: %w", m.Ref, err)
+	}
+
+	return stored, true, nil
+}
+
+// skipDuplicate reports whether new mail is skipped, as mail with the same key fields is stored.
+func (mi *mailImporter) skipDuplicate(m Mail, index int) (bool, error) {
+	if mi.opts.KeepDuplicates {
+		return false, nil
+	}
+
+	_, label, found, err := mi.keys.find(m)
+	if err != nil || !found {
+		return false, err
+	}
+
+	log.WithFields(log.Fields{
+		"cmd":    "import",
+		"mail":   fmt.Sprintf("%+v", m),
+		"stored": label,
+	}).Debug("skipped duplicate record")
+
+	mi.duplicates = append(mi.duplicates, DuplicateRecord{File: mi.file, Index: index, Stored: label})
+	mi.summary.Duplicates++
+
+	return true, nil
+}
+
+// savedMail returns the mail saved for imported mail. A match is updated with the imported fields when merging, and
+// replaced otherwise, but keeps its ID and reference, and the fields the import file has no column for. New mail keeps its ID when it is free, and is given a
+// reference when it has none.
+func (mi *mailImporter) savedMail(imported, stored Mail, found bool) (Mail, error) {
+	var err error
+
+	if !found {
+		if imported.Ref == "" {
+			if imported.Ref, err = NewMailRef(mi.batch.tx, imported, RefLength); err != nil {
+				return imported, fmt.Errorf("error creating mail reference: %w", err)
+			}
+		}
+
+		imported.ID, err = freeID(mi.batch.tx, imported.ID, &Mail{})
+
+		return imported, err
+	}
+
+	mail, err := matchedMail(mi.opts.Mode, stored, imported, mi.columns)
+	if err != nil {
+		return mail, err
+	}
+
+	mail.Ref = stored.Ref
+
+	return mail, nil
+}
+
+// matchedMail returns the mail saved in place of stored mail that imported mail is matched to. columns are the
+// fields the import file holds, and nil columns hold every field.
+func matchedMail(mode ImportMode, stored, imported Mail, columns []string) (Mail, error) {
+	var (
+		matched Mail
+		err     error
+	)
+
+	switch {
+	case mode == ImportMerge:
+		err = mergeRecord(stored, imported, &matched)
+	case columns != nil:
+		err = keepStoredFields(stored, imported, &matched, columns)
+	default:
+		matched = imported
+	}
+
+	if err != nil {
+		return matched, err
+	}
+
+	matched.ID = stored.ID
+
+	return matched, nil
+}
+
+// clearMail removes every stored mail, and returns how many were removed.
+func clearMail(tx storm.Node) (int, error) {
+	n, err := tx.Count(&Mail{})
+	if err != nil {
+		return 0, fmt.Errorf("failure to read mail: %w", err)
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err = tx.Drop(&Mail{}); err != nil {
+		return 0, fmt.Errorf("error removing stored mail: %w", err)
+	}
+
+	return n, nil
+}
+
+// mailKeys returns an index of the stored mail by the fields that identify it.
+func mailKeys(tx storm.Finder, fields []string) (*keyIndex, error) {
+	keys, err := newKeyIndex(fields, Mail{})
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", MailKeyFieldsKey, err)
+	}
+
+	mm := []Mail{}
+	if err = tx.All(&mm); err != nil {
+		return nil, fmt.Errorf("failure to read mail: %w", err)
+	}
+
+	for _, m := range mm {
+		if err = keys.add(m, m.ID, mailRef(m)); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// mailRef names stored mail in reports.
+func mailRef(m Mail) string {
+	return fmt.Sprintf("mail %s (%s)", m.Ref, mailLabel(m))
 }
 
 // UniqueMails returns the passed in slice of mail with at most one of each mail. Mail order is
@@ -165,12 +565,19 @@ func UniqueMails(rawMails []Mail) []Mail {
 		return rawMails
 	}
 
-	keys := make(map[Mail]bool)
+	// mail holds slices and pointers, so records are compared by their encoded form
+	keys := make(map[string]bool)
 	cleanMails := []Mail{}
 
 	for _, mail := range rawMails {
-		if _, found := keys[mail]; !found {
-			keys[mail] = true
+		key, err := json.Marshal(mail)
+		if err != nil {
+			cleanMails = append(cleanMails, mail)
+			continue
+		}
+
+		if _, found := keys[string(key)]; !found {
+			keys[string(key)] = true
 			cleanMails = append(cleanMails, mail)
 		}
 	}
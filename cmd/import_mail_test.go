@@ -26,13 +26,17 @@ import (
 	"bytes"
 	"io"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/spf13/afero"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/asphaltbuffet/ogma/cmd"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
 )
 
 func TestNewImportMailCmd(t *testing.T) {
@@ -45,6 +49,7 @@ func TestNewImportMailCmd(t *testing.T) {
 
 func TestRunImportMailCmd(t *testing.T) {
 	m, dbFilePath, appFS := setup(t)
+	require.NoError(t, m.Save(&lstg.Listing{Volume: 1, IssueNumber: 1, ListingText: "Magna officia anim dolore enim."}))
 	m.Stop()
 
 	defer func() {
@@ -53,6 +58,35 @@ func TestRunImportMailCmd(t *testing.T) {
 
 	viper.Set("datastore.filename", dbFilePath)
 
+	require.NoError(t, afero.WriteFile(appFS, "test/dangling.json", []byte(`{
+		"mails": [
+			{
+				"reference": "0a0a0a",
+				"sender": 1234,
+				"receiver": 55,
+				"date": "1986-06-01",
+				"link": "M0b0b0b"
+			}
+		]
+		}`), 0o644))
+
+	require.NoError(t, afero.WriteFile(appFS, "test/unreferenced.json", []byte(`{
+		"mails": [
+			{
+				"sender": 1234,
+				"receiver": 5678,
+				"date": "2021-11-15"
+			}
+		]
+		}`), 0o644))
+
+	require.NoError(t, afero.WriteFile(appFS, "test/mail.tsv", []byte("reference\tsender\treceiver\tdate\tlink\n"+
+		"abc123\t1234\t55\t1986-07-01\tM123d5f\n"), 0o644))
+
+	// references are indexed, and bolt can't store an index key this long
+	require.NoError(t, afero.WriteFile(appFS, "test/unsaved.tsv", []byte("reference\tsender\treceiver\tdate\n"+
+		strings.Repeat("a", 40000)+"\t4321\t55\t1986-08-01\n"), 0o644))
+
 	tests := []struct {
 		name      string
 		args      []string
@@ -63,7 +97,37 @@ func TestRunImportMailCmd(t *testing.T) {
 			name:      "mail import",
 			args:      []string{"test/mails.json"},
 			assertion: assert.NoError,
-			want:      "Imported 3/3 mail records.\n",
+			want:      "Imported 3/3 mail records (3 created, 0 updated, 0 unchanged).\n",
+		},
+		{
+			name:      "already imported",
+			args:      []string{"test/mails.json"},
+			assertion: assert.NoError,
+			want:      "Imported 3/3 mail records (0 created, 0 updated, 3 unchanged).\n",
+		},
+		{
+			name:      "no reference",
+			args:      []string{"test/unreferenced.json"},
+			assertion: assert.NoError,
+			want:      "Imported 1/1 mail records (1 created, 0 updated, 0 unchanged).\n",
+		},
+		{
+			name:      "tsv import",
+			args:      []string{"test/mail.tsv"},
+			assertion: assert.NoError,
+			want:      "Imported 1/1 mail records (1 created, 0 updated, 0 unchanged).\n",
+		},
+		{
+			name:      "dangling link",
+			args:      []string{"test/dangling.json"},
+			assertion: assert.NoError,
+			want:      "failed to import mail records: mail 0a0a0a: dangling link: M0b0b0b: mail not found",
+		},
+		{
+			name:      "record can't be saved",
+			args:      []string{"test/unsaved.tsv"},
+			assertion: assert.NoError,
+			want:      "failed to import mail records: error saving record 1: key too large",
 		},
 		{
 			name:      "invalid json",
@@ -108,44 +172,44 @@ func TestUniqueMail(t *testing.T) {
 			name: "no duplicates",
 			args: args{
 				mm: []cmd.Mail{
-					{Ref: "", Sender: 0, Receiver: 0, Date: "", Link: ""},
+					{Ref: "", Sender: member.ID{Number: 0}, Receiver: member.ID{Number: 0}, Date: ""},
 				},
 			},
 			want: []cmd.Mail{
-				{Ref: "", Sender: 0, Receiver: 0, Date: "", Link: ""},
+				{Ref: "", Sender: member.ID{Number: 0}, Receiver: member.ID{Number: 0}, Date: ""},
 			},
 		},
 		{
 			name: "only duplicates",
 			args: args{
 				mm: []cmd.Mail{
-					{Ref: "", Sender: 0, Receiver: 0, Date: "", Link: ""},
+					{Ref: "", Sender: member.ID{Number: 0}, Receiver: member.ID{Number: 0}, Date: ""},
 				},
 			},
 			want: []cmd.Mail{
-				{Ref: "", Sender: 0, Receiver: 0, Date: "", Link: ""},
+				{Ref: "", Sender: member.ID{Number: 0}, Receiver: member.ID{Number: 0}, Date: ""},
 			},
 		},
 		{
 			name: "duplicates with unique",
 			args: args{
 				mm: []cmd.Mail{
-					{Ref: "", Sender: 0, Receiver: 0, Date: "", Link: ""},
+					{Ref: "", Sender: member.ID{Number: 0}, Receiver: member.ID{Number: 0}, Date: ""},
 				},
 			},
 			want: []cmd.Mail{
-				{Ref: "", Sender: 0, Receiver: 0, Date: "", Link: ""},
+				{Ref: "", Sender: member.ID{Number: 0}, Receiver: member.ID{Number: 0}, Date: ""},
 			},
 		},
 		{
 			name: "multiple duplicates with unique",
 			args: args{
 				mm: []cmd.Mail{
-					{Ref: "", Sender: 0, Receiver: 0, Date: "", Link: ""},
+					{Ref: "", Sender: member.ID{Number: 0}, Receiver: member.ID{Number: 0}, Date: ""},
 				},
 			},
 			want: []cmd.Mail{
-				{Ref: "", Sender: 0, Receiver: 0, Date: "", Link: ""},
+				{Ref: "", Sender: member.ID{Number: 0}, Receiver: member.ID{Number: 0}, Date: ""},
 			},
 		},
 	}
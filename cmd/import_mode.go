@@ -0,0 +1,286 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/asdine/storm/v3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ImportModeKey is the configuration key for the default import mode.
+const ImportModeKey = "import.mode"
+
+// An ImportMode is how imported records are matched to stored records, and what is done with a match.
+type ImportMode string
+
+// Import modes.
+const (
+	// ImportInsert adds records, and skips any with an ID (or a mail reference) that is already stored.
+	ImportInsert ImportMode = "insert"
+
+	// ImportUpsert replaces the stored record with the same ID (or mail reference), and adds the rest.
+	ImportUpsert ImportMode = "upsert"
+
+	// ImportMerge updates the stored record with the same natural key with the fields the import sets, and adds
+	// the rest. Listings are matched by issue, page, and member; mail by reference.
+	ImportMerge ImportMode = "merge"
+
+	// ImportReplace removes every stored record of the imported type before adding the imported records.
+	ImportReplace ImportMode = "replace"
+)
+
+// ImportModes are the import modes, in the order they are listed in help.
+var ImportModes = []ImportMode{ImportInsert, ImportUpsert, ImportMerge, ImportReplace}
+
+// ParseImportMode reads an import mode by its name.
+func ParseImportMode(s string) (ImportMode, error) {
+	for _, m := range ImportModes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+
+	return "", fmt.Errorf("import mode must be one of %v: %q", ImportModes, s)
+}
+
+// checkBatchMode returns an error when records imported in a mode can't be saved in batches. Replacing removes the
+// stored records with the first batch, and they couldn't be put back if a later batch failed.
+func checkBatchMode(mode ImportMode, batch BatchOptions) error {
+	if mode == ImportReplace && batch.Size > 0 {
+		return fmt.Errorf("import mode %s can't save records in batches of %d: use '--batch-size 0'", mode, batch.Size)
+	}
+
+	return nil
+}
+
+// addImportModeFlag adds the flag choosing how imported records are matched to stored records.
+func addImportModeFlag(cmd *cobra.Command) {
+	cmd.Flags().String("mode", "",
+		"How records are matched to stored records: insert, upsert, merge, or replace. (default is the 'import.mode' setting)")
+}
+
+// importModeFlag reads the import mode from the '--mode' flag, or the 'import.mode' setting when it isn't given.
+func importModeFlag(cmd *cobra.Command) (ImportMode, error) {
+	s, _ := cmd.Flags().GetString("mode")
+	if s == "" {
+		s = viper.GetString(ImportModeKey)
+	}
+
+	if s == "" {
+		return ImportUpsert, nil
+	}
+
+	return ParseImportMode(s)
+}
+
+// An ImportSummary counts what an import did with the records it read.
+type ImportSummary struct {
+	Created   int
+	Updated   int
+	Unchanged int
+
+	// Skipped records were already stored, and left as they are by an insert.
+	Skipped int
+
+	// Duplicates weren't saved, as a record with the same key is stored under another ID.
+	Duplicates int
+
+	// Removed records were stored before a replace.
+	Removed int
+}
+
+// Imported is how many records were created, updated, or found unchanged.
+func (s ImportSummary) Imported() int {
+	return s.Created + s.Updated + s.Unchanged
+}
+
+// since returns what was done with records after an earlier summary. Stored records removed before the import
+// are left out.
+func (s ImportSummary) since(before ImportSummary) ImportSummary {
+	return ImportSummary{
+		Created:    s.Created - before.Created,
+		Updated:    s.Updated - before.Updated,
+		Unchanged:  s.Unchanged - before.Unchanged,
+		Skipped:    s.Skipped - before.Skipped,
+		Duplicates: s.Duplicates - before.Duplicates,
+	}
+}
+
+// String returns the summary as it is printed after an import.
+func (s ImportSummary) String() string {
+	out := fmt.Sprintf("%d created, %d updated, %d unchanged", s.Created, s.Updated, s.Unchanged)
+
+	if s.Skipped > 0 {
+		out += fmt.Sprintf(", %d skipped as already stored", s.Skipped)
+	}
+
+	if s.Duplicates > 0 {
+		out += fmt.Sprintf(", %d skipped as duplicates", s.Duplicates)
+	}
+
+	if s.Removed > 0 {
+		out += fmt.Sprintf(", %d stored records removed first", s.Removed)
+	}
+
+	return out
+}
+
+// mergeRecord sets dst to the stored record updated with the fields the imported record sets. Fields the imported
+// record leaves at their zero value (empty, zero, or false) keep their stored value.
+func mergeRecord(stored, imported, dst interface{}) error {
+	fields, err := jsonFields(stored)
+	if err != nil {
+		return err
+	}
+
+	set, err := jsonFields(imported)
+	if err != nil {
+		return err
+	}
+
+	zero, err := jsonFields(reflect.Zero(reflect.TypeOf(imported)).Interface())
+	if err != nil {
+		return err
+	}
+
+	for k, v := range set {
+		if !bytes.Equal(zero[k], v) {
+			fields[k] = v
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("error encoding merged record: %w", err)
+	}
+
+	if err = json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("error decoding merged record: %w", err)
+	}
+
+	return nil
+}
+
+// keepStoredFields sets dst to the imported record, with the stored values of the fields that aren't in columns.
+// Csv and tsv files only hold the fields of their columns, and the fields they leave out aren't cleared.
+func keepStoredFields(stored, imported, dst interface{}, columns []string) error {
+	fields, err := jsonFields(imported)
+	if err != nil {
+		return err
+	}
+
+	kept, err := jsonFields(stored)
+	if err != nil {
+		return err
+	}
+
+	held := map[string]bool{}
+	for _, c := range columns {
+		held[c] = true
+	}
+
+	for k, v := range kept {
+		if !held[k] {
+			fields[k] = v
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("error encoding updated record: %w", err)
+	}
+
+	if err = json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("error decoding updated record: %w", err)
+	}
+
+	return nil
+}
+
+// checkColumns returns an error when the import mode removes stored records, like v, before importing a file
+// without a column for each of their fields, as the fields it leaves out would be lost. columns are the fields the
+// file holds, and nil columns hold every field.
+func checkColumns(mode ImportMode, format string, columns []string, v interface{}) error {
+	if mode != ImportReplace || columns == nil {
+		return nil
+	}
+
+	held := map[string]bool{}
+	for _, c := range columns {
+		held[c] = true
+	}
+
+	missing := []string{}
+	t := reflect.TypeOf(v)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		if !held[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("import mode %s removes stored records, and the %s file has no %s column: "+
+			"use a json file, or import mode upsert", mode, format, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// freeID returns id when no record like v is stored with it, and 0 otherwise so the record is given a new ID.
+// Imported records keep their ID when it is free, so links to them from other imported records still work.
+func freeID(tx storm.Finder, id int, v interface{}) (int, error) {
+	if id == 0 {
+		return 0, nil
+	}
+
+	err := tx.One("ID", id, v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return id, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("error reading record %d: %w", id, err)
+	}
+
+	return 0, nil
+}
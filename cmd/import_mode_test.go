@@ -0,0 +1,267 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/address"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+)
+
+func TestImportListingModes(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+	m.Stop()
+
+	appFS := afero.NewOsFs()
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+	viper.Set(cmd.ImportModeKey, "")
+
+	require.NoError(t, afero.WriteFile(appFS, "test/edit.json", []byte(`{"listings": [
+		{"ID": 1, "volume": 1, "issue": 1, "year": 1986, "season": "Spring", "page": 1, "category": "Pariatur", "member": 1234, "text": "Magna officia."},
+		{"issue": 1, "page": 2, "category": "Commodo", "member": 5678, "text": "Velit cillum."}
+	]}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/upsert.json", []byte(`{"listings": [
+		{"ID": 1, "volume": 1, "issue": 1, "year": 1986, "season": "Spring", "page": 1, "category": "Pariatur", "member": 1234, "text": "Magna officia."}
+	]}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/merge.json", []byte(`{"listings": [
+		{"issue": 1, "page": 2, "member": 5678, "text": "Velit cillum esse."},
+		{"issue": 1, "page": 4, "category": "Commodo", "member": 4321, "text": "Duis elit."}
+	]}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/replace.json", []byte(`{"listings": [
+		{"ID": 7, "issue": 1, "page": 3, "category": "Commodo", "member": 4321, "text": "Sint enim."}
+	]}`), 0o644))
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "insert skips stored ids",
+			args: []string{"test/edit.json", "--mode", "insert"},
+			want: "Imported 1/2 listing records (1 created, 0 updated, 0 unchanged, 1 skipped as already stored).\n",
+		},
+		{
+			name: "upsert by id",
+			args: []string{"test/upsert.json", "--mode", "upsert"},
+			want: "Imported 1/1 listing records (0 created, 1 updated, 0 unchanged).\n",
+		},
+		{
+			name: "upsert is the default",
+			args: []string{"test/upsert.json"},
+			want: "Imported 1/1 listing records (0 created, 0 updated, 1 unchanged).\n",
+		},
+		{
+			name: "merge by issue page and member",
+			args: []string{"test/merge.json", "--mode", "merge"},
+			want: "Imported 2/2 listing records (1 created, 1 updated, 0 unchanged).\n",
+		},
+		{
+			name: "replace",
+			args: []string{"test/replace.json", "--mode", "replace"},
+			want: "Imported 1/1 listing records (1 created, 0 updated, 0 unchanged, 3 stored records removed first).\n" +
+				"Mail links that can't be followed: 1. Use 'ogma check links' to list them.\n",
+		},
+		{
+			name: "unknown mode",
+			args: []string{"test/replace.json", "--mode", "bogus"},
+			want: "invalid input:  import mode must be one of [insert upsert merge replace]: \"bogus\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewImportListingCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+			require.NoError(t, c.Execute())
+			out, err := io.ReadAll(b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(out))
+		})
+	}
+
+	m, err := datastore.Open(dbFilePath)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	ll := []lstg.Listing{}
+	require.NoError(t, m.All(&ll))
+	require.Len(t, ll, 1)
+	assert.Equal(t, 7, ll[0].ID)
+
+	// listings added after a replace are numbered after the imported ids
+	l := lstg.Listing{Volume: 1, IssueNumber: 1, PageNumber: 5}
+	require.NoError(t, m.Save(&l))
+	assert.Equal(t, 8, l.ID)
+
+	rr, err := lstg.TextIndex(m.Store).Search("velit", 0)
+	require.NoError(t, err)
+	assert.Empty(t, rr)
+}
+
+func TestImportMailModes(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+	m.Stop()
+
+	appFS := afero.NewOsFs()
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+	viper.Set(cmd.ImportModeKey, "")
+
+	require.NoError(t, afero.WriteFile(appFS, "test/mail_edit.json", []byte(`{"mails": [
+		{"reference": "123D5F", "sender": 55, "receiver": 1234, "date": "1986-04-02", "link": "L1"}
+	]}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/mail_merge.json", []byte(`{"mails": [
+		{"reference": "123d5f", "date": "1986-04-03"}
+	]}`), 0o644))
+
+	tests := []struct {
+		name     string
+		args     []string
+		want     string
+		wantDate string
+	}{
+		{
+			name:     "insert skips stored references",
+			args:     []string{"test/mail_edit.json", "--mode", "insert"},
+			want:     "Imported 0/1 mail records (0 created, 0 updated, 0 unchanged, 1 skipped as already stored).\n",
+			wantDate: "1986-04-01",
+		},
+		{
+			name:     "merge keeps fields left out",
+			args:     []string{"test/mail_merge.json", "--mode", "merge"},
+			want:     "Imported 1/1 mail records (0 created, 1 updated, 0 unchanged).\n",
+			wantDate: "1986-04-03",
+		},
+		{
+			name:     "upsert by reference",
+			args:     []string{"test/mail_edit.json"},
+			want:     "Imported 1/1 mail records (0 created, 1 updated, 0 unchanged).\n",
+			wantDate: "1986-04-02",
+		},
+		{
+			name:     "replace",
+			args:     []string{"test/mails.json", "--mode", "replace"},
+			want:     "Imported 3/3 mail records (3 created, 0 updated, 0 unchanged, 1 stored records removed first).\n",
+			wantDate: "1986-04-01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewImportMailCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+			require.NoError(t, c.Execute())
+			out, err := io.ReadAll(b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(out))
+
+			m, err := datastore.Open(dbFilePath)
+			require.NoError(t, err)
+			defer m.Stop()
+
+			var got cmd.Mail
+			require.NoError(t, m.One("Ref", "123d5f", &got))
+			assert.Equal(t, tt.wantDate, got.Date)
+			assert.Equal(t, 55, got.Sender.Number)
+			assert.Equal(t, link.ToListing(1), got.Link)
+		})
+	}
+}
+
+func TestImportMailDelimitedRoundTrip(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+
+	var stored cmd.Mail
+	require.NoError(t, m.One("Ref", "123d5f", &stored))
+
+	stored.SentTo = &address.Address{Recipient: "Jo Smith", Lines: []string{"12 High St"}, Locality: "Springfield"}
+	stored.Status = cmd.StatusSent
+	stored.StatusHistory = []cmd.StatusEvent{
+		{Status: cmd.StatusDrafted, At: time.Date(1986, 3, 30, 0, 0, 0, 0, time.UTC)},
+		{Status: cmd.StatusSent, At: time.Date(1986, 4, 1, 0, 0, 0, 0, time.UTC), Note: "first class"},
+	}
+	require.NoError(t, m.Save(&stored))
+	m.Stop()
+
+	appFS := afero.NewOsFs()
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+	viper.Set(cmd.ImportModeKey, "")
+
+	run := func(c *cobra.Command, args ...string) string {
+		b := bytes.NewBufferString("")
+		c.SetOut(b)
+		c.SetErr(b)
+		c.SetArgs(args)
+		require.NoError(t, c.Execute())
+
+		return b.String()
+	}
+
+	run(cmd.NewExportCmd(), "-r=mail", "--format=tsv", "-o=test/mail.tsv")
+
+	// the tsv file has no column for the address or the status history
+	assert.Equal(t, "Imported 1/1 mail records (0 created, 0 updated, 1 unchanged).\n",
+		run(cmd.NewImportMailCmd(), "test/mail.tsv"))
+	assert.Equal(t, "failed to import mail records: import mode replace removes stored records, and the tsv file "+
+		"has no sent_to, status_history column: use a json file, or import mode upsert",
+		run(cmd.NewImportMailCmd(), "test/mail.tsv", "--mode", "replace"))
+
+	m, err := datastore.Open(dbFilePath)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	var got cmd.Mail
+	require.NoError(t, m.One("Ref", "123d5f", &got))
+	assert.Equal(t, stored, got)
+}
@@ -0,0 +1,344 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/asdine/storm/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/delimited"
+)
+
+// ImportBatchKey is the configuration key for how many records are saved in each datastore transaction.
+const ImportBatchKey = "import.batch_size"
+
+// BatchOptions control how imported records are saved. A Size of 0 saves every record in one transaction, so
+// nothing is saved if the import fails. Progress is written to Progress after each batch is saved, when it is set.
+type BatchOptions struct {
+	Size     int
+	Progress io.Writer
+}
+
+// addBatchFlag adds the flag setting how many records are saved in each transaction.
+func addBatchFlag(cmd *cobra.Command) {
+	cmd.Flags().Int("batch-size", 0, "Save records in batches of this size, showing progress. (default is the 'import.batch_size' setting, or one batch)")
+}
+
+// batchFlag reads the batch options from the '--batch-size' flag, or the 'import.batch_size' setting when it isn't
+// given. Progress is shown on the command's error output when records are saved in batches.
+func batchFlag(cmd *cobra.Command) (BatchOptions, error) {
+	size := viper.GetInt(ImportBatchKey)
+	if cmd.Flags().Changed("batch-size") {
+		size, _ = cmd.Flags().GetInt("batch-size")
+	}
+
+	if size < 0 {
+		return BatchOptions{}, fmt.Errorf("batch size must not be negative: %d", size)
+	}
+
+	opts := BatchOptions{Size: size}
+	if size > 0 {
+		opts.Progress = cmd.ErrOrStderr()
+	}
+
+	return opts, nil
+}
+
+// A recordStream reads the records of an import file one at a time.
+type recordStream interface {
+	// next reads the next record into v, which must point to a zero record. It returns io.EOF after the last record.
+	next(v interface{}) error
+
+	// columns returns the fields the file's records can hold, once a record is read. It is nil when every field can
+	// be held.
+	columns() []string
+}
+
+// stream returns a reader of the records in an import file. Json files hold their records in a list under field.
+func (ff FileFormat) stream(f io.Reader, field string) (recordStream, error) {
+	if f == nil {
+		return nil, errors.New("argument cannot be nil")
+	}
+
+	if ff.isDelimited() {
+		return &delimitedStream{format: ff.Name, r: delimited.NewReader(f, ff.Delimiter)}, nil
+	}
+
+	return &jsonStream{dec: json.NewDecoder(f), field: field}, nil
+}
+
+// A jsonStream reads the records of a json import file one at a time, walking the document to the list of
+// records and decoding each in turn.
+type jsonStream struct {
+	dec   *json.Decoder
+	field string
+	open  bool
+	done  bool
+}
+
+func (js *jsonStream) next(v interface{}) error {
+	if !js.open && !js.done {
+		if err := js.openList(); err != nil {
+			return fmt.Errorf("failed to unmarshall import file: %w", err)
+		}
+	}
+
+	if js.done {
+		return io.EOF
+	}
+
+	if !js.dec.More() {
+		js.done = true
+
+		// the closing bracket of the list
+		_, err := js.dec.Token()
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to unmarshall import file: %w", err)
+		}
+
+		return io.EOF
+	}
+
+	if err := js.dec.Decode(v); err != nil {
+		return fmt.Errorf("failed to unmarshall import file: %w", err)
+	}
+
+	return nil
+}
+
+func (js *jsonStream) columns() []string {
+	return nil
+}
+
+// openList reads the document up to the start of the list of records. Other fields of the document are skipped,
+// and a document without the list has no records.
+func (js *jsonStream) openList() error {
+	if err := js.expect(json.Delim('{')); err != nil {
+		return err
+	}
+
+	for js.dec.More() {
+		tok, err := js.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if key, ok := tok.(string); !ok || !strings.EqualFold(key, js.field) {
+			var skipped json.RawMessage
+			if err = js.dec.Decode(&skipped); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if tok, err = js.dec.Token(); err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('['):
+			js.open = true
+		case nil:
+			js.done = true
+		default:
+			return fmt.Errorf("%q must be a list of records", js.field)
+		}
+
+		return nil
+	}
+
+	js.done = true
+
+	return nil
+}
+
+// expect reads the next token, which must be want.
+func (js *jsonStream) expect(want json.Token) error {
+	tok, err := js.dec.Token()
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if tok != want {
+		return fmt.Errorf("expected %v, found %v", want, tok)
+	}
+
+	return nil
+}
+
+// A delimitedStream reads the records of a csv or tsv import file one row at a time.
+type delimitedStream struct {
+	format string
+	r      *delimited.Reader
+}
+
+func (ds *delimitedStream) next(v interface{}) error {
+	err := ds.r.Read(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read %s file: %w", ds.format, err)
+	}
+
+	return err
+}
+
+func (ds *delimitedStream) columns() []string {
+	return ds.r.Columns()
+}
+
+// A recordSet holds a fingerprint of each record read from an import file, so records repeated in the file are
+// skipped without keeping every record in memory.
+type recordSet map[[sha256.Size]byte]bool
+
+// add reports whether a record is new to the set, and adds it.
+func (rs recordSet) add(v interface{}) (bool, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false, fmt.Errorf("error reading record: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	if rs[sum] {
+		return false, nil
+	}
+
+	rs[sum] = true
+
+	return true, nil
+}
+
+// An importBatch saves imported records in datastore transactions of a batch size. Records are saved in the
+// current transaction, tx, which is committed and replaced as each batch is filled.
+type importBatch struct {
+	d    datastore.Saver
+	tx   storm.Node
+	opts BatchOptions
+	kind string
+
+	// flush is called before each transaction is committed.
+	flush func(tx storm.Node) error
+
+	read      int
+	batches   int
+	committed int
+}
+
+// newImportBatch begins the first transaction of an import of a kind of record.
+func newImportBatch(d datastore.Saver, opts BatchOptions, kind string, flush func(tx storm.Node) error) (*importBatch, error) {
+	b := &importBatch{d: d, opts: opts, kind: kind, flush: flush}
+
+	if err := b.begin(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *importBatch) begin() error {
+	tx, err := b.d.Begin(true)
+	if err != nil {
+		return fmt.Errorf("error beginning datastore transaction: %w", err)
+	}
+
+	b.tx = tx
+
+	return nil
+}
+
+// next counts a record read from the import file, and saves the batch once it is full.
+func (b *importBatch) next() error {
+	b.read++
+
+	if b.opts.Size == 0 || b.read%b.opts.Size != 0 {
+		return nil
+	}
+
+	if err := b.commit(); err != nil {
+		return err
+	}
+
+	if b.opts.Progress != nil {
+		fmt.Fprintf(b.opts.Progress, "Saved batch %d: %d %s records read.\n", b.batches, b.read, b.kind)
+	}
+
+	return b.begin()
+}
+
+// commit saves the current transaction.
+func (b *importBatch) commit() error {
+	if err := b.flush(b.tx); err != nil {
+		return err
+	}
+
+	if err := b.tx.Commit(); err != nil {
+		return fmt.Errorf("error committing records to datastore: %w", err)
+	}
+
+	b.batches++
+	b.committed = b.read
+
+	return nil
+}
+
+// rollback discards the records of the current transaction. Batches already saved are kept.
+func (b *importBatch) rollback() {
+	if err := b.tx.Rollback(); err != nil && !errors.Is(err, storm.ErrNotInTransaction) {
+		log.Error("failed to rollback datastore transaction: ", err)
+	}
+}
+
+// stopped describes what is saved when an import stops before the current transaction is committed.
+func (b *importBatch) stopped() string {
+	if b.committed == 0 {
+		return "nothing was imported"
+	}
+
+	return "nothing more was imported"
+}
+
+// failed returns the error an import stopped with, noting the records kept when batches were already saved.
+func (b *importBatch) failed(err error) error {
+	if b.committed == 0 {
+		return err
+	}
+
+	return fmt.Errorf("%w\nThe first %d records were imported in earlier batches.", err, b.committed)
+}
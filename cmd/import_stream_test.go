@@ -0,0 +1,172 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+)
+
+func TestImportListingBatches(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+	m.Stop()
+
+	appFS := afero.NewOsFs()
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+	viper.Set(cmd.ImportModeKey, "")
+	viper.Set(cmd.ImportBatchKey, 0)
+
+	require.NoError(t, afero.WriteFile(appFS, "test/batch_invalid.json", []byte(`{"listings": [
+		{"issue": 1, "page": 10, "category": "Commodo", "member": 2010, "text": "Velit cillum."},
+		{"issue": 1, "page": 11, "category": "Commodo", "member": 2011, "text": "Duis elit."},
+		{"issue": 1, "page": 12, "category": "Commodo", "member": 2012, "text": "Sint enim."},
+		{"issue": 1, "page": -1, "category": "Commodo", "member": 2013, "text": "Magna officia."},
+		{"issue": 1, "page": 14, "category": "Commodo", "member": 2014, "text": "Esse culpa."}
+	]}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/batch.json", []byte(`{"version": 2, "listings": [
+		{"issue": 1, "page": 2, "category": "Commodo", "member": 2002, "text": "Velit cillum."},
+		{"issue": 1, "page": 3, "category": "Commodo", "member": 2003, "text": "Duis elit."},
+		{"issue": 1, "page": 3, "category": "Commodo", "member": 2003, "text": "Duis elit."},
+		{"issue": 1, "page": 4, "category": "Commodo", "member": 2004, "text": "Sint enim."},
+		{"issue": 1, "page": 5, "category": "Commodo", "member": 2005, "text": "Esse culpa."}
+	], "notes": {"source": "scan"}}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/batch_empty.json", []byte(`{"listings": null}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/batch_object.json", []byte(`{"listings": {"page": 1}}`), 0o644))
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "strict keeps earlier batches",
+			args: []string{"test/batch_invalid.json", "--batch-size", "2"},
+			want: "Saved batch 1: 2 listing records read.\n" +
+				"failed to import listing records:  invalid listing: 1 of 4 records, nothing more was imported (use '--skip-invalid' to import the rest):\n" +
+				"  record 4: page -1: must be positive\n" +
+				"The first 2 records were imported in earlier batches.\n",
+		},
+		{
+			name: "batches",
+			args: []string{"test/batch.json", "--batch-size", "2"},
+			want: "Saved batch 1: 2 listing records read.\n" +
+				"Saved batch 2: 4 listing records read.\n" +
+				"Imported 4/5 listing records (4 created, 0 updated, 0 unchanged).\n",
+		},
+		{
+			name: "no listings",
+			args: []string{"test/batch_empty.json"},
+			want: "Imported 0/0 listing records (0 created, 0 updated, 0 unchanged).\n",
+		},
+		{
+			name: "listings are not a list",
+			args: []string{"test/batch_object.json"},
+			want: "failed to import listing records:  failed to parse input file: failed to unmarshall import file: \"listings\" must be a list of records\n",
+		},
+		{
+			name: "negative batch size",
+			args: []string{"test/batch.json", "--batch-size", "-1"},
+			want: "invalid input:  batch size must not be negative: -1\n",
+		},
+		{
+			name: "replace in batches",
+			args: []string{"test/batch.json", "--batch-size", "2", "--mode", "replace"},
+			want: "failed to import listing records:  import mode replace can't save records in batches of 2: use '--batch-size 0'\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewImportListingCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+			require.NoError(t, c.Execute())
+			out, err := io.ReadAll(b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(out))
+		})
+	}
+
+	m, err := datastore.Open(dbFilePath)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	n, err := m.Count(&lstg.Listing{})
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+
+	// listings saved in every batch are searchable
+	rr, err := lstg.TextIndex(m.Store).Search("culpa", 0)
+	require.NoError(t, err)
+	assert.Len(t, rr, 1)
+}
+
+func TestImportMailBatches(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+	m.Stop()
+
+	appFS := afero.NewOsFs()
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+	viper.Set(cmd.ImportModeKey, "")
+	viper.Set(cmd.ImportBatchKey, 1)
+
+	// the first reply links to mail later in the file
+	require.NoError(t, afero.WriteFile(appFS, "test/mail_batch.json", []byte(`{"mails": [
+		{"reference": "aaaa01", "sender": 1234, "receiver": 55, "date": "1986-05-01", "link": "Maaaa02"},
+		{"reference": "aaaa02", "sender": 55, "receiver": 1234, "date": "1986-04-20"}
+	]}`), 0o644))
+
+	c := cmd.NewImportMailCmd()
+	b := bytes.NewBufferString("")
+	c.SetOut(b)
+	c.SetErr(b)
+	c.SetArgs([]string{"test/mail_batch.json"})
+	require.NoError(t, c.Execute())
+	out, err := io.ReadAll(b)
+	require.NoError(t, err)
+	assert.Equal(t, "Saved batch 1: 1 mail records read.\n"+
+		"Saved batch 2: 2 mail records read.\n"+
+		"Imported 2/2 mail records (2 created, 0 updated, 0 unchanged).\n", string(out))
+
+	viper.Set(cmd.ImportBatchKey, 0)
+}
@@ -25,15 +25,20 @@ package cmd_test
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/asphaltbuffet/ogma/cmd"
 	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
 )
 
 func setup(t *testing.T) (*datastore.Manager, string, afero.Fs) {
@@ -106,7 +111,7 @@ func setup(t *testing.T) (*datastore.Manager, string, afero.Fs) {
 				"volume": 1,
 				"issue": 1,
 				"year": 1986,
-				"season": "Mollit",
+				"season": "Spring",
 				"page": 1,
 				"category": "Pariatur",
 				"member": 1234,
@@ -121,7 +126,7 @@ func setup(t *testing.T) (*datastore.Manager, string, afero.Fs) {
 				"volume": 1,
 				"issue": 1,
 				"year": 1986,
-				"season": "Eiusmod",
+				"season": "Spring",
 				"page": 2,
 				"category": "Commodo",
 				"member": 1234,
@@ -136,7 +141,7 @@ func setup(t *testing.T) (*datastore.Manager, string, afero.Fs) {
 				"volume": 1,
 				"issue": 1,
 				"year": 1986,
-				"season": "Id",
+				"season": "Spring",
 				"page": 3,
 				"category": "Pariatur",
 				"member": 5678,
@@ -193,3 +198,115 @@ func ExecuteCommandC(t *testing.T, root *cobra.Command, args ...string) (c *cobr
 
 	return c, buf.String(), err
 }
+
+func TestImportFiles(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+	m.Stop()
+
+	appFS := afero.NewOsFs()
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+	viper.Set(cmd.ImportModeKey, "")
+	viper.Set(cmd.ImportBatchKey, 0)
+
+	require.NoError(t, appFS.MkdirAll("test/files", 0o755))
+	require.NoError(t, afero.WriteFile(appFS, "test/files/a.json", []byte(`{"listings": [
+		{"issue": 1, "page": 2, "category": "Commodo", "member": 2002, "text": "Velit cillum."},
+		{"issue": 1, "page": 3, "category": "Commodo", "member": 2003, "text": "Sint enim."}
+	]}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/files/b.csv", []byte("issue,page,category,member,text\n"+
+		"1,4,Commodo,2004,Duis elit.\n"+
+		"1,1,Pariatur,1234,Esse Lorem do nulla sunt mollit nulla in.\n"), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/truncated.json", []byte(`{"listings": [`), 0o644))
+
+	tests := []struct {
+		name      string
+		args      []string
+		stdin     string
+		assertion assert.ErrorAssertionFunc
+		want      string
+	}{
+		{
+			name:      "no files",
+			args:      []string{},
+			assertion: assert.Error,
+			want:      "Error: requires at least 1 arg(s), only received 0\n",
+		},
+		{
+			name:      "no files match",
+			args:      []string{"test/none/*.json"},
+			assertion: assert.NoError,
+			want:      "invalid input:  no files match \"test/none/*.json\"\n",
+		},
+		{
+			name:      "dry run of more than one file",
+			args:      []string{"test/files/*", "--dry-run"},
+			assertion: assert.NoError,
+			want:      "invalid input:  a dry run compares one import file at a time: 2 files given\n",
+		},
+		{
+			name:      "files are imported together",
+			args:      []string{"test/files/a.json", "test/truncated.json"},
+			assertion: assert.NoError,
+			want: "failed to import listing records:  test/truncated.json: failed to parse input file: " +
+				"failed to unmarshall import file: unexpected end of JSON input\n",
+		},
+		{
+			name:      "glob and standard input",
+			args:      []string{"test/files/*", "test/files/a.json", "-"},
+			stdin:     `{"listings": [{"issue": 1, "page": 5, "category": "Commodo", "member": 2005, "text": "Esse culpa."}]}`,
+			assertion: assert.NoError,
+			want: "Imported 4/5 listing records (4 created, 0 updated, 0 unchanged, 1 skipped as duplicates).\n" +
+				"  test/files/a.json: 2/2 records (2 created, 0 updated, 0 unchanged)\n" +
+				"  test/files/b.csv: 1/2 records (1 created, 0 updated, 0 unchanged, 1 skipped as duplicates)\n" +
+				"  standard input: 1/1 records (1 created, 0 updated, 0 unchanged)\n" +
+				"Skipped duplicates: 1\n" +
+				"  test/files/b.csv record 2: already stored as listing 1 (issue 1 page 1 member 1234)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewImportListingCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetIn(strings.NewReader(tt.stdin))
+			c.SetArgs(tt.args)
+			tt.assertion(t, c.Execute())
+			out, err := io.ReadAll(b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(out)[:len(tt.want)])
+		})
+	}
+
+	m, err := datastore.Open(dbFilePath)
+	require.NoError(t, err)
+
+	n, err := m.Count(&lstg.Listing{})
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	m.Stop()
+
+	// mail can reply to mail in another file
+	require.NoError(t, afero.WriteFile(appFS, "test/reply.json", []byte(`{"mails": [
+		{"reference": "aaaa01", "sender": 1234, "receiver": 55, "date": "1986-05-01", "link": "Maaaa02"}
+	]}`), 0o644))
+	require.NoError(t, afero.WriteFile(appFS, "test/letter.json", []byte(`{"mails": [
+		{"reference": "aaaa02", "sender": 55, "receiver": 1234, "date": "1986-04-20"}
+	]}`), 0o644))
+
+	c := cmd.NewImportMailCmd()
+	b := bytes.NewBufferString("")
+	c.SetOut(b)
+	c.SetErr(b)
+	c.SetArgs([]string{"test/reply.json", "test/letter.json"})
+	require.NoError(t, c.Execute())
+	assert.Equal(t, "Imported 2/2 mail records (2 created, 0 updated, 0 unchanged).\n"+
+		"  test/reply.json: 1/1 records (1 created, 0 updated, 0 unchanged)\n"+
+		"  test/letter.json: 1/1 records (1 created, 0 updated, 0 unchanged)\n", b.String())
+}
@@ -0,0 +1,384 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/asdine/storm/v3"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/adtext"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+// DefaultMinConfidence is the confidence score below which a parsed ad is marked for checking.
+const DefaultMinConfidence = 0.8
+
+const importTextCommandLongDesc = "Reads the ads of a LEX page from its typed text, for review before they are\n" +
+	"imported. Ads are separated by blank lines, or end with the member number of the member who placed them, like\n" +
+	"'#1234' or '#1234A'. Short lines in capitals (or names from 'listings.categories') are read as category\n" +
+	"headings, and '(I)' or 'INTL', '(R)', and '(A)' or '[ART]' mark international ads, reviews, and ads with art.\n\n" +
+	"Each ad is given a confidence score from 0 to 1, lowered by problems like a missing member number or heading.\n" +
+	"Ads scoring below '--min-confidence' are marked for checking.\n\n" +
+	"Nothing is saved unless '--save' is given, and then only when no ad needs checking. The ads are imported like\n" +
+	"'ogma import listings', by the import mode. Use '--output json' to write the ads as a listings import file\n" +
+	"instead, to correct by hand and import.\n\n" +
+	"Listings need the volume, year, and season of their issue. They are taken from the catalogue, so add the issue\n" +
+	"with 'ogma issue add' first, or give them with '--volume', '--year', and '--season'."
+
+func init() {
+	importCmd.AddCommand(NewImportTextCmd())
+}
+
+// NewImportTextCmd sets up an import subcommand.
+func NewImportTextCmd() *cobra.Command {
+	// cmd represents the import text command.
+	cmd := &cobra.Command{
+		Use:   "text [filename]",
+		Short: "Read listings from the typed text of a page.",
+		Long:  importTextCommandLongDesc,
+		Example: "ogma import text --issue 56 --page 3 page3.txt\n" +
+			"scan-page | ogma import text --issue 56 --page 3 -",
+		Args: cobra.ExactArgs(1),
+		Run:  RunImportTextCmd,
+	}
+
+	cmd.Flags().Int("issue", 0, "Issue the page is printed in.")
+	cmd.Flags().Int("page", 0, "Page number.")
+	cmd.Flags().Int("volume", 0, "Volume the issue is part of. (default is the catalogued issue's)")
+	cmd.Flags().Int("year", 0, "Year the issue was published. (default is the catalogued issue's)")
+	cmd.Flags().String("season", "", "Season the issue was published. (default is the catalogued issue's)")
+	cmd.Flags().Float64("min-confidence", DefaultMinConfidence, "Mark ads scoring below this for checking.")
+	cmd.Flags().Bool("save", false, "Import the ads when none need checking.")
+	cmd.Flags().BoolP("pretty", "p", false, "Show prettier results.")
+	addInvalidFlags(cmd)
+	addImportModeFlag(cmd)
+	addDuplicatesFlag(cmd)
+
+	return cmd
+}
+
+// RunImportTextCmd performs action associated with text-import application command.
+func RunImportTextCmd(cmd *cobra.Command, args []string) {
+	opts, minConfidence, err := pageFlags(cmd)
+	if err != nil {
+		log.Error("invalid page: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	// a page is read from one file, named as it is; patterns aren't expanded
+	page := ImportFile{Name: args[0], Open: openImportFile(cmd, args[0])}
+
+	ads, err := readPage(page, opts)
+	if err != nil {
+		log.Error("failed to read page: ", err)
+		cmd.PrintErrln("failed to read page: ", err)
+		return
+	}
+
+	save, _ := cmd.Flags().GetBool("save")
+
+	rep := AdsReport(ads, minConfidence, r.Pretty)
+	if !save && len(ads) > 0 {
+		rep.Note += "\nNothing was saved. Use '--save' to import the ads."
+	}
+
+	printReport(cmd, r, rep)
+
+	if !save {
+		return
+	}
+
+	out, err := saveAds(cmd, page, ads, opts, minConfidence)
+	if err != nil {
+		log.Error("failed to import listing records: ", err)
+		cmd.PrintErrln("failed to import listing records: ", err)
+		return
+	}
+
+	cmd.Println(out)
+}
+
+// pageFlags reads the page being read from the '--issue' and '--page' flags, the issue's details from '--volume',
+// '--year', and '--season', and the confidence score below which ads are checked from '--min-confidence'.
+func pageFlags(cmd *cobra.Command) (adtext.Options, float64, error) {
+	issue, _ := cmd.Flags().GetInt("issue")
+	if issue <= 0 {
+		return adtext.Options{}, 0, fmt.Errorf("'--issue' must be positive: %d", issue)
+	}
+
+	page, _ := cmd.Flags().GetInt("page")
+	if page <= 0 {
+		return adtext.Options{}, 0, fmt.Errorf("'--page' must be positive: %d", page)
+	}
+
+	minConfidence, _ := cmd.Flags().GetFloat64("min-confidence")
+	if minConfidence < 0 || minConfidence > 1 {
+		return adtext.Options{}, 0, fmt.Errorf("'--min-confidence' must be from 0 to 1: %v", minConfidence)
+	}
+
+	volume, _ := cmd.Flags().GetInt("volume")
+	year, _ := cmd.Flags().GetInt("year")
+	season, _ := cmd.Flags().GetString("season")
+
+	opts := adtext.Options{
+		Issue:      issue,
+		Page:       page,
+		Volume:     volume,
+		Year:       year,
+		Season:     season,
+		Categories: viper.GetStringSlice(ListingCategoriesKey),
+	}
+
+	return opts, minConfidence, nil
+}
+
+// readPage reads the ads of a page from a text file, or from standard input.
+func readPage(file ImportFile, opts adtext.Options) ([]adtext.Ad, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer closeImportFile(r)
+
+	return adtext.Parse(r, opts)
+}
+
+// saveAds imports the listings read from a page, when no ad needs checking.
+func saveAds(cmd *cobra.Command, page ImportFile, ads []adtext.Ad, opts adtext.Options, minConfidence float64) (string, error) {
+	if n := countChecked(ads, minConfidence); n > 0 {
+		return "", fmt.Errorf("%d of %d ads need checking (confidence below %.2f), nothing was imported", n, len(ads), minConfidence)
+	}
+
+	if len(ads) == 0 {
+		return "", errors.New("no ads found on the page")
+	}
+
+	skip, err := skipInvalidFlag(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	mode, err := importModeFlag(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(lstg.Listings{Listings: adListings(ads)})
+	if err != nil {
+		return "", fmt.Errorf("error encoding listings: %w", err)
+	}
+
+	// the ads are imported as a listings import file
+	file := ImportFile{
+		Name:   page.Name,
+		Format: FileFormat{Name: FormatJSON},
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		},
+	}
+
+	dsManager, err := initImportDatastore(nil)
+	if err != nil {
+		return "", err
+	}
+	defer dsManager.Stop()
+
+	if err = checkPageIssue(dsManager, opts); err != nil {
+		return "", err
+	}
+
+	return ImportListings([]ImportFile{file}, dsManager, ListingImportOptions{
+		SkipInvalid:    skip,
+		Rules:          listingRules(),
+		Mode:           mode,
+		KeyFields:      keyFields(ListingKeyFieldsKey, DefaultListingKeyFields),
+		KeepDuplicates: keepDuplicatesFlag(cmd),
+	})
+}
+
+// checkPageIssue returns an error when the listings of a page can't be given the volume, year, and season of their
+// issue, as the flags leave some out and the issue isn't catalogued.
+func checkPageIssue(ds datastore.Saver, opts adtext.Options) error {
+	if opts.Volume != 0 && opts.Year != 0 && opts.Season != "" {
+		return nil
+	}
+
+	tx, err := ds.Begin(false)
+	if err != nil {
+		return fmt.Errorf("failed to begin datastore transaction: %w", err)
+	}
+
+	defer func() {
+		if errRollback := tx.Rollback(); errRollback != nil && !errors.Is(errRollback, storm.ErrNotInTransaction) {
+			log.Error("failed to rollback datastore transaction: ", errRollback)
+		}
+	}()
+
+	_, err = findIssue(tx, opts.Issue)
+	if errors.Is(err, ErrIssueNotFound) {
+		return fmt.Errorf("catalogue issue %d first with 'ogma issue add', or give '--volume', '--year', and '--season'", opts.Issue)
+	}
+
+	return err
+}
+
+// adListings returns the listings of the ads read from a page.
+func adListings(ads []adtext.Ad) []lstg.Listing {
+	ll := make([]lstg.Listing, len(ads))
+	for i, ad := range ads {
+		ll[i] = ad.Listing
+	}
+
+	return ll
+}
+
+// countChecked returns how many ads score below the minimum confidence.
+func countChecked(ads []adtext.Ad, minConfidence float64) int {
+	n := 0
+
+	for _, ad := range ads {
+		if ad.Confidence < minConfidence {
+			n++
+		}
+	}
+
+	return n
+}
+
+var adColumnConfigs = []table.ColumnConfig{
+	{
+		Name:  "Ad",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Line",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Member",
+		Align: text.AlignRight,
+	},
+	{
+		Name:             "Text",
+		WidthMax:         60, //nolint:gomnd // the text column is wrapped to keep the table readable
+		WidthMaxEnforcer: text.WrapSoft,
+	},
+	{
+		Name:  "Confidence",
+		Align: text.AlignRight,
+	},
+}
+
+// AdsReport returns the ads read from a page as a report for review, as a listings import file in structured
+// formats. Ads scoring below the minimum confidence are marked for checking, with the problems found.
+func AdsReport(ads []adtext.Ad, minConfidence float64, p bool) render.Report {
+	rep := render.Report{
+		Header: table.Row{
+			"Ad",
+			"Line",
+			"Category",
+			"Member",
+			"Flags",
+			"Text",
+			"Confidence",
+			"Check",
+		},
+		Columns: adColumnConfigs,
+		Records: lstg.Listings{Listings: adListings(ads)},
+		Empty:   "No ads found on the page.",
+	}
+
+	if len(ads) > 0 {
+		rep.Title = fmt.Sprintf("Ads on Issue %d, Page %d:", ads[0].Listing.IssueNumber, ads[0].Listing.PageNumber)
+		rep.Note = fmt.Sprintf("Read %d ads, %d need checking (confidence below %.2f).",
+			len(ads), countChecked(ads, minConfidence), minConfidence)
+	}
+
+	for i, ad := range ads {
+		l := ad.Listing
+
+		confidence := fmt.Sprintf("%.2f", ad.Confidence)
+		check := ""
+
+		if ad.Confidence < minConfidence {
+			check = strings.Join(ad.Problems, "; ")
+
+			if p {
+				confidence = text.FgHiRed.Sprint(confidence)
+			}
+		}
+
+		rep.Rows = append(rep.Rows, table.Row{
+			i + 1,
+			ad.Line,
+			l.IndexedCategory,
+			member.ID{Number: l.IndexedMemberNumber, Extension: l.MemberExtension},
+			adFlags(l),
+			l.ListingText,
+			confidence,
+			check,
+		})
+	}
+
+	return rep
+}
+
+// adFlags writes the flags of a listing as letters: I for international, R for review, and A for art.
+func adFlags(l lstg.Listing) string {
+	flags := ""
+
+	for _, f := range []struct {
+		set    bool
+		letter string
+	}{
+		{l.IsInternational, "I"},
+		{l.IsReview, "R"},
+		{l.IsArt, "A"},
+	} {
+		if f.set {
+			flags += f.letter
+		}
+	}
+
+	return flags
+}
@@ -0,0 +1,162 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+)
+
+func TestRunImportTextCmd(t *testing.T) {
+	m, dbFilePath := initDiffDatastore(t)
+	m.Stop()
+
+	appFS := afero.NewOsFs()
+	defer func() {
+		require.NoError(t, appFS.RemoveAll("test/"))
+	}()
+
+	viper.Set("datastore.filename", dbFilePath)
+	viper.Set(cmd.ImportModeKey, "")
+	viper.Set(cmd.ListingCategoriesKey, nil)
+
+	require.NoError(t, afero.WriteFile(appFS, "test/page3.txt", []byte("PEN PALS\n\n"+
+		"Looking for letters about trains, stamps, and tea. #2345\n\n"+
+		"(I) Trading zines and collage from abroad. #3456B\n\n"+
+		"Knitting circle meets on Sundays\n"), 0o644))
+
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+		want   []string
+	}{
+		{
+			name: "missing issue",
+			args: []string{"test/page3.txt", "--page", "3"},
+			want: []string{"invalid input:  '--issue' must be positive: 0\n"},
+		},
+		{
+			name: "patterns are not expanded",
+			args: []string{"test/page*.txt", "--issue", "1", "--page", "3"},
+			want: []string{"failed to read page:  failed to open import file: open test/page*.txt: no such file or directory\n"},
+		},
+		{
+			name: "review",
+			args: []string{"test/page3.txt", "--issue", "1", "--page", "3"},
+			want: []string{
+				"Ads on Issue 1, Page 3:",
+				"Trading zines and collage from abroad.",
+				"3456B",
+				"no member number at the end of the ad",
+				"Read 3 ads, 1 need checking (confidence below 0.80).\n",
+				"Nothing was saved. Use '--save' to import the ads.\n",
+			},
+		},
+		{
+			name:   "json",
+			args:   []string{"test/page3.txt", "--issue", "1", "--page", "3"},
+			output: "json",
+			want:   []string{"{\n  \"listings\": [\n", "\"alt\": \"B\",\n", "\"international\": true,\n"},
+		},
+		{
+			name: "ads need checking",
+			args: []string{"test/page3.txt", "--issue", "1", "--page", "3", "--save"},
+			want: []string{"failed to import listing records:  1 of 3 ads need checking (confidence below 0.80), nothing was imported\n"},
+		},
+		{
+			name: "save",
+			args: []string{"test/page3.txt", "--issue", "1", "--page", "3", "--save", "--min-confidence", "0.5", "--skip-invalid"},
+			want: []string{
+				"Imported 2/3 listing records (2 created, 0 updated, 0 unchanged).\n",
+				"  record 3: member 0: must be positive\n",
+			},
+		},
+		{
+			name: "issue not catalogued",
+			args: []string{"test/page3.txt", "--issue", "2", "--page", "3", "--save", "--min-confidence", "0.5", "--skip-invalid"},
+			want: []string{"failed to import listing records:  catalogue issue 2 first with 'ogma issue add', or give '--volume', '--year', and '--season'\n"},
+		},
+		{
+			name: "issue from flags",
+			args: []string{
+				"test/page3.txt", "--issue", "2", "--page", "3", "--volume", "1", "--year", "1986", "--season", "Summer",
+				"--save", "--min-confidence", "0.5", "--skip-invalid",
+			},
+			want: []string{"Imported 2/3 listing records (2 created, 0 updated, 0 unchanged).\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewImportTextCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			viper.Set(cmd.OutputKey, tt.output)
+			defer viper.Set(cmd.OutputKey, "")
+
+			require.NoError(t, c.Execute())
+			out, err := io.ReadAll(b)
+			require.NoError(t, err)
+
+			for _, w := range tt.want {
+				assert.Contains(t, string(out), w)
+			}
+		})
+	}
+
+	m, err := datastore.Open(dbFilePath)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	// listings are filled in from the catalogued issue
+	var l lstg.Listing
+	require.NoError(t, m.One("IndexedMemberNumber", 3456, &l))
+	assert.Equal(t, "Pen Pals", l.IndexedCategory)
+	assert.Equal(t, "B", l.MemberExtension)
+	assert.True(t, l.IsInternational)
+	assert.Equal(t, 1986, l.Year)
+
+	// and from the flags, cataloguing the issue
+	ll := []lstg.Listing{}
+	require.NoError(t, m.Find("IssueNumber", 2, &ll))
+	require.Len(t, ll, 2)
+	assert.Equal(t, "Summer", ll[0].Season)
+
+	var is cmd.Issue
+	require.NoError(t, m.One("Number", 2, &is))
+	assert.Equal(t, 1, is.Volume)
+}
@@ -0,0 +1,665 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+// DefaultIssueKey is the configuration key of the issue used when a command isn't given one.
+const DefaultIssueKey = "defaults.issue"
+
+var (
+	// ErrIssueExists is returned when adding an issue number that is already catalogued.
+	ErrIssueExists = errors.New("issue already exists")
+
+	// ErrIssueNotFound is returned when no issue is catalogued with a number.
+	ErrIssueNotFound = errors.New("issue not found")
+)
+
+// An Issue is a LEX issue in the catalogue. Listings are checked against the issue they are printed in.
+type Issue struct {
+	ID       int    `storm:"id,increment"`
+	Number   int    `storm:"unique" json:"number"`
+	Volume   int    `json:"volume"`
+	Year     int    `json:"year"`
+	Season   string `json:"season"`
+	Pages    int    `json:"pages,omitempty"`
+	Acquired string `json:"acquired,omitempty"`
+	Owned    bool   `json:"owned"`
+}
+
+// IssueFromListing returns a catalogue entry for the issue a listing is printed in.
+func IssueFromListing(l lstg.Listing) Issue {
+	return Issue{
+		Number: l.IssueNumber,
+		Volume: l.Volume,
+		Year:   l.Year,
+		Season: l.Season,
+	}
+}
+
+// Fill copies the issue's volume, year, and season into a listing that doesn't have them, and checks the ones it
+// has match. Listings can't be printed past the last page of an issue. Mismatched fields are returned as
+// lstg.FieldErrors.
+func (is Issue) Fill(l *lstg.Listing) error {
+	ee := lstg.FieldErrors{}
+
+	if l.IssueNumber != is.Number {
+		ee = append(ee, lstg.FieldError{Field: "issue", Value: l.IssueNumber, Message: fmt.Sprintf("must be issue %d", is.Number)})
+	}
+
+	if l.Volume == 0 {
+		l.Volume = is.Volume
+	} else if is.Volume != 0 && l.Volume != is.Volume {
+		ee = append(ee, lstg.FieldError{Field: "volume", Value: l.Volume, Message: fmt.Sprintf("issue %d is volume %d", is.Number, is.Volume)})
+	}
+
+	if l.Year == 0 {
+		l.Year = is.Year
+	} else if is.Year != 0 && l.Year != is.Year {
+		ee = append(ee, lstg.FieldError{Field: "year", Value: l.Year, Message: fmt.Sprintf("issue %d is from %d", is.Number, is.Year)})
+	}
+
+	if l.Season == "" {
+		l.Season = is.Season
+	} else if is.Season != "" && !strings.EqualFold(l.Season, is.Season) {
+		ee = append(ee, lstg.FieldError{Field: "season", Value: l.Season, Message: fmt.Sprintf("issue %d is from %s", is.Number, is.Season)})
+	}
+
+	if is.Pages != 0 && l.PageNumber > is.Pages {
+		ee = append(ee, lstg.FieldError{Field: "page", Value: l.PageNumber, Message: fmt.Sprintf("issue %d has %d pages", is.Number, is.Pages)})
+	}
+
+	if len(ee) > 0 {
+		return ee
+	}
+
+	return nil
+}
+
+const issueCommandLongDesc = "The issue command manages the catalogue of LEX issues. Listings are checked against the\n" +
+	"issue they are printed in when they are imported, and issues that aren't catalogued yet are added from the\n" +
+	"first listing imported for them."
+
+var issueColumnConfigs = []table.ColumnConfig{
+	{
+		Name:  "Issue",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Owned",
+		Align: text.AlignCenter,
+	},
+}
+
+var issueSummaryColumnConfigs = []table.ColumnConfig{
+	{
+		Name:  "Listings",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Answered",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Replied",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Unanswered",
+		Align: text.AlignRight,
+	},
+}
+
+// issueFlags are the issue flags that can be set by add and edit.
+var issueFlags = []string{"volume", "year", "season", "pages", "acquired", "owned"}
+
+func init() {
+	rootCmd.AddCommand(NewIssueCmd())
+}
+
+// NewIssueCmd creates an issue command with its subcommands.
+func NewIssueCmd() *cobra.Command {
+	// cmd represents the issue command
+	cmd := &cobra.Command{
+		Use:   "issue",
+		Short: "Catalogues LEX issues",
+		Long:  issueCommandLongDesc,
+	}
+
+	cmd.PersistentFlags().BoolP("pretty", "p", false, "Show prettier results.")
+
+	cmd.AddCommand(
+		NewIssueAddCmd(),
+		NewIssueEditCmd(),
+		NewIssueListCmd(),
+		NewIssueShowCmd(),
+	)
+
+	return cmd
+}
+
+// NewIssueAddCmd creates an issue add command.
+func NewIssueAddCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "add <number>",
+		Short:   "Add an issue to the catalogue",
+		Example: "ogma issue add 56 --volume=14 --year=2021 --season=Spring --pages=48 --owned",
+		Args:    issueNumberArg,
+		Run:     RunIssueAddCmd,
+	}
+
+	addIssueFlags(cmd)
+
+	return cmd
+}
+
+// NewIssueEditCmd creates an issue edit command.
+func NewIssueEditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "edit <number>",
+		Short:   "Change an issue",
+		Long:    "Changes a catalogued issue. Only the given flags are changed.",
+		Example: "ogma issue edit 56 --pages=52 --acquired=2021-04-02",
+		Args:    issueNumberArg,
+		Run:     RunIssueEditCmd,
+	}
+
+	addIssueFlags(cmd)
+
+	return cmd
+}
+
+// NewIssueListCmd creates an issue list command.
+func NewIssueListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "List all catalogued issues",
+		Example: "ogma issue list",
+		Args:    cobra.NoArgs,
+		Run:     RunIssueListCmd,
+	}
+}
+
+// NewIssueShowCmd creates an issue show command.
+func NewIssueShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "show [number]",
+		Short:   "Summarize an issue",
+		Long:    "Shows an issue with its listings counted by category and response. The 'defaults.issue' issue is shown if none is given.",
+		Example: "ogma issue show 56",
+		Args:    cobra.MaximumNArgs(1),
+		Run:     RunIssueShowCmd,
+	}
+}
+
+// addIssueFlags adds the flags for an issue's details to an issue command.
+func addIssueFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("volume", 0, "Volume the issue is part of.")
+	cmd.Flags().Int("year", 0, "Year the issue was published.")
+	cmd.Flags().String("season", "", "Season the issue was published.")
+	cmd.Flags().Int("pages", 0, "Number of pages in the issue.")
+	cmd.Flags().String("acquired", "", "Date the issue was acquired, as 'yyyy-mm-dd'.")
+	cmd.Flags().Bool("owned", false, "The issue is in your collection.")
+}
+
+// issueNumberArg requires a single valid issue number argument.
+func issueNumberArg(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("requires a single issue number")
+	}
+
+	_, err := parseIssueNumber(args[0])
+
+	return err
+}
+
+// parseIssueNumber reads an issue number argument.
+func parseIssueNumber(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("issue: must be an issue number: %s", s)
+	}
+
+	return n, nil
+}
+
+// issueFlag returns the issue number given by a flag, or the 'defaults.issue' setting if the flag isn't set.
+func issueFlag(cmd *cobra.Command) (int, error) {
+	n, _ := cmd.Flags().GetInt("issue")
+	if !cmd.Flags().Changed("issue") {
+		n = viper.GetInt(DefaultIssueKey)
+	}
+
+	if n <= 0 {
+		return 0, fmt.Errorf("issue: must be an issue number: %d", n)
+	}
+
+	return n, nil
+}
+
+// RunIssueAddCmd implements functionality of an issue add command.
+func RunIssueAddCmd(cmd *cobra.Command, args []string) {
+	// number is already validated by cobra
+	n, _ := parseIssueNumber(args[0])
+	is := Issue{Number: n}
+
+	if err := applyIssueFlags(cmd, &is); err != nil {
+		log.WithField("issue", n).Error("invalid issue input: ", err)
+		cmd.PrintErrln("invalid issue input: ", err)
+		return
+	}
+
+	dsManager, err := datastore.New(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	if err = saveIssue(dsManager, &is); err != nil {
+		log.WithField("issue", n).Error("unable to save issue: ", err)
+		cmd.PrintErrln("Failed to save issue: ", err)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"issue":  is.Number,
+		"volume": is.Volume,
+	}).Info("added issue")
+
+	cmd.Printf("Added issue %d.\n", is.Number)
+}
+
+// RunIssueEditCmd implements functionality of an issue edit command.
+func RunIssueEditCmd(cmd *cobra.Command, args []string) {
+	// number is already validated by cobra
+	n, _ := parseIssueNumber(args[0])
+
+	dsManager, err := datastore.New(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	is, err := findIssue(dsManager, n)
+	if err != nil {
+		log.WithField("issue", n).Error("failed to find issue: ", err)
+		cmd.PrintErrln("failed to find issue: ", err)
+		return
+	}
+
+	if err = applyIssueFlags(cmd, &is); err != nil {
+		log.WithField("issue", n).Error("invalid issue input: ", err)
+		cmd.PrintErrln("invalid issue input: ", err)
+		return
+	}
+
+	if err = saveIssue(dsManager, &is); err != nil {
+		log.WithField("issue", n).Error("unable to save issue: ", err)
+		cmd.PrintErrln("Failed to save issue: ", err)
+		return
+	}
+
+	log.WithField("issue", is.Number).Info("updated issue")
+
+	cmd.Printf("Updated issue %d.\n", is.Number)
+}
+
+// RunIssueListCmd implements functionality of an issue list command.
+func RunIssueListCmd(cmd *cobra.Command, args []string) {
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	ii := []Issue{}
+	if err = dsManager.All(&ii); err != nil {
+		log.Error("failed to read issues: ", err)
+		cmd.PrintErrln("failed to read issues: ", err)
+		return
+	}
+
+	counts, err := issueListingCounts(dsManager.Store)
+	if err != nil {
+		log.Error("failed to count listings: ", err)
+		cmd.PrintErrln("failed to count listings: ", err)
+		return
+	}
+
+	printReport(cmd, r, IssuesReport(ii, counts))
+}
+
+// RunIssueShowCmd implements functionality of an issue show command.
+func RunIssueShowCmd(cmd *cobra.Command, args []string) {
+	n := viper.GetInt(DefaultIssueKey)
+
+	if len(args) == 1 {
+		var err error
+		if n, err = parseIssueNumber(args[0]); err != nil {
+			cmd.PrintErrln("invalid input: ", err)
+			return
+		}
+	}
+
+	if n <= 0 {
+		cmd.PrintErrln("invalid input: ", fmt.Errorf("issue: must be an issue number or set with '%s': %d", DefaultIssueKey, n))
+		return
+	}
+
+	self, err := member.Parse(viper.GetString("member"))
+	if err != nil {
+		log.Error("invalid member configuration: ", err)
+		cmd.PrintErrln("invalid 'member' configuration: ", err)
+		return
+	}
+
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	is, err := findIssue(dsManager, n)
+	if err != nil {
+		log.WithField("issue", n).Error("failed to find issue: ", err)
+		cmd.PrintErrln("failed to find issue: ", err)
+		return
+	}
+
+	rr, err := FindListingResponses(dsManager.Store, n, self, time.Now())
+	if err != nil {
+		log.WithField("issue", n).Error("failed to find listing responses: ", err)
+		cmd.PrintErrln("failed to find listings: ", err)
+		return
+	}
+
+	issue := IssuesReport([]Issue{is}, map[int]int{n: len(rr)})
+	issue.Records = IssueListings{Issue: is, Listings: len(rr)}
+
+	out, err := r.RenderSections([]render.Section{
+		{Name: "issue", Report: issue},
+		{Name: "categories", Report: IssueSummaryReport(SummarizeIssue(rr))},
+	})
+
+	printOutput(cmd, out, err)
+}
+
+// applyIssueFlags copies the issue flags that were set on the command line into is.
+func applyIssueFlags(cmd *cobra.Command, is *Issue) error {
+	for _, flag := range issueFlags {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+
+		var err error
+
+		switch flag {
+		case "volume":
+			is.Volume, err = cmd.Flags().GetInt(flag)
+		case "year":
+			is.Year, err = cmd.Flags().GetInt(flag)
+		case "season":
+			is.Season, err = cmd.Flags().GetString(flag)
+		case "pages":
+			is.Pages, err = cmd.Flags().GetInt(flag)
+		case "acquired":
+			is.Acquired, _ = cmd.Flags().GetString(flag)
+			if is.Acquired, err = ValidateDate(is.Acquired); err != nil {
+				err = fmt.Errorf("acquired: %w", err)
+			}
+		case "owned":
+			is.Owned, err = cmd.Flags().GetBool(flag)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for flag, v := range map[string]int{"volume": is.Volume, "year": is.Year, "pages": is.Pages} {
+		if v < 0 {
+			return fmt.Errorf("%s: must not be negative: %d", flag, v)
+		}
+	}
+
+	return nil
+}
+
+// findIssue returns the issue catalogued with a number.
+func findIssue(ds storm.Finder, n int) (Issue, error) {
+	var is Issue
+
+	err := ds.One("Number", n, &is)
+	if errors.Is(err, storm.ErrNotFound) {
+		return Issue{}, fmt.Errorf("%w: %d", ErrIssueNotFound, n)
+	}
+
+	if err != nil {
+		return Issue{}, fmt.Errorf("error reading issue %d: %w", n, err)
+	}
+
+	return is, nil
+}
+
+// saveIssue saves a new or changed issue. Issue numbers are unique.
+func saveIssue(ds datastore.Saver, is *Issue) error {
+	err := ds.Save(is)
+	if errors.Is(err, storm.ErrAlreadyExists) {
+		return fmt.Errorf("%w: %d", ErrIssueExists, is.Number)
+	}
+
+	return err
+}
+
+// issueListingCounts returns the number of listings stored for each issue number.
+func issueListingCounts(ds storm.Finder) (map[int]int, error) {
+	ll := []lstg.Listing{}
+	if err := ds.All(&ll); err != nil {
+		return nil, fmt.Errorf("failure to read listings: %w", err)
+	}
+
+	counts := map[int]int{}
+	for _, l := range ll {
+		counts[l.IssueNumber]++
+	}
+
+	return counts, nil
+}
+
+// An IssueCategory counts the listings of one category in an issue by how they were answered.
+type IssueCategory struct {
+	Category   string `json:"category"`
+	Listings   int    `json:"listings"`
+	Answered   int    `json:"answered"`
+	Replied    int    `json:"replied"`
+	Unanswered int    `json:"unanswered"`
+}
+
+// SummarizeIssue counts the listings of an issue by category, in category order.
+func SummarizeIssue(rr []ListingResponse) []IssueCategory {
+	byCategory := map[string]*IssueCategory{}
+	cc := []*IssueCategory{}
+
+	for _, r := range rr {
+		c, ok := byCategory[r.Listing.IndexedCategory]
+		if !ok {
+			c = &IssueCategory{Category: r.Listing.IndexedCategory}
+			byCategory[c.Category] = c
+			cc = append(cc, c)
+		}
+
+		c.Listings++
+
+		switch {
+		case !r.Answered():
+			c.Unanswered++
+		case r.Reply != nil:
+			c.Answered++
+			c.Replied++
+		default:
+			c.Answered++
+		}
+	}
+
+	sort.SliceStable(cc, func(i, j int) bool { return cc[i].Category < cc[j].Category })
+
+	summary := make([]IssueCategory, len(cc))
+	for i, c := range cc {
+		summary[i] = *c
+	}
+
+	return summary
+}
+
+// IssueListings is a catalogued issue with the number of listings stored for it.
+type IssueListings struct {
+	Issue
+	Listings int `json:"listings"`
+}
+
+// IssuesReport returns catalogued issues as a report, by issue number, with the number of listings stored for each.
+func IssuesReport(ii []Issue, counts map[int]int) render.Report {
+	ii = append([]Issue{}, ii...)
+	sort.SliceStable(ii, func(i, j int) bool { return ii[i].Number < ii[j].Number })
+
+	il := make([]IssueListings, len(ii))
+
+	rep := render.Report{
+		Title: "LEX Issues:",
+		Header: table.Row{
+			"Issue",
+			"Volume",
+			"Year",
+			"Season",
+			"Pages",
+			"Acquired",
+			"Owned",
+			"Listings",
+		},
+		Columns: issueColumnConfigs,
+		Records: il,
+		Empty:   "No issues catalogued.",
+	}
+
+	for i, is := range ii {
+		il[i] = IssueListings{Issue: is, Listings: counts[is.Number]}
+
+		pages := ""
+		if is.Pages != 0 {
+			pages = strconv.Itoa(is.Pages)
+		}
+
+		owned := ""
+		if is.Owned {
+			owned = "Y"
+		}
+
+		rep.Rows = append(rep.Rows, table.Row{
+			is.Number,
+			is.Volume,
+			is.Year,
+			is.Season,
+			pages,
+			is.Acquired,
+			owned,
+			counts[is.Number],
+		})
+	}
+
+	return rep
+}
+
+// IssueSummaryReport returns the listing counts of an issue by category as a report, with their totals.
+func IssueSummaryReport(cc []IssueCategory) render.Report {
+	rep := render.Report{
+		Title: "Listings by Category:",
+		Header: table.Row{
+			"Category",
+			"Listings",
+			"Answered",
+			"Replied",
+			"Unanswered",
+		},
+		Columns: issueSummaryColumnConfigs,
+		Records: cc,
+		Empty:   "No listings in this issue.",
+	}
+
+	total := IssueCategory{}
+
+	for _, c := range cc {
+		rep.Rows = append(rep.Rows, table.Row{
+			c.Category,
+			c.Listings,
+			c.Answered,
+			c.Replied,
+			c.Unanswered,
+		})
+
+		total.Listings += c.Listings
+		total.Answered += c.Answered
+		total.Replied += c.Replied
+		total.Unanswered += c.Unanswered
+	}
+
+	rep.Footer = table.Row{"Total", total.Listings, total.Answered, total.Replied, total.Unanswered}
+
+	return rep
+}
@@ -0,0 +1,207 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+)
+
+func TestNewIssueCmd(t *testing.T) {
+	got := cmd.NewIssueCmd()
+
+	assert.Equal(t, "issue", got.Name())
+	assert.Equal(t, "Catalogues LEX issues", got.Short)
+
+	names := []string{}
+	for _, c := range got.Commands() {
+		names = append(names, c.Name())
+	}
+
+	assert.ElementsMatch(t, []string{"add", "edit", "list", "show"}, names)
+}
+
+func TestRunIssueCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.DatastoreFilenameKey, dsFile)
+	viper.Set("member", "55")
+
+	tests := []struct {
+		name         string
+		args         []string
+		defaultIssue int
+		want         string
+	}{
+		{
+			name: "add",
+			args: []string{"add", "1", "--volume=1", "--year=1986", "--season=Mollit", "--pages=12", "--owned"},
+			want: "Added issue 1.\n",
+		},
+		{
+			name: "add existing",
+			args: []string{"add", "1"},
+			want: "Failed to save issue:  issue already exists: 1\n",
+		},
+		{
+			name: "add invalid number",
+			args: []string{"add", "L56"},
+			want: "Error: issue: must be an issue number: L56\n",
+		},
+		{
+			name: "add invalid date",
+			args: []string{"add", "2", "--acquired=yesterday"},
+			want: "invalid issue input:  acquired: ",
+		},
+		{
+			name: "edit",
+			args: []string{"edit", "1", "--acquired=1986-03-02"},
+			want: "Updated issue 1.\n",
+		},
+		{
+			name: "edit missing",
+			args: []string{"edit", "2", "--pages=4"},
+			want: "failed to find issue:  issue not found: 2\n",
+		},
+		{
+			name: "list",
+			args: []string{"list"},
+			want: "|     1 |      1 | 1986 | Mollit | 12    | 1986-03-02 |   Y   |        3 |\n",
+		},
+		{
+			name: "show",
+			args: []string{"show", "1"},
+			want: "| Commodo  |        1 |        0 |       0 |          1 |\n" +
+				"| Pariatur |        2 |        1 |       1 |          1 |\n" +
+				"+----------+----------+----------+---------+------------+\n" +
+				"| TOTAL    |        3 |        1 |       1 |          2 |\n",
+		},
+		{
+			name:         "show default issue",
+			args:         []string{"show"},
+			defaultIssue: 1,
+			want:         "|     1 |      1 | 1986 | Mollit | 12    | 1986-03-02 |   Y   |        3 |\n",
+		},
+		{
+			name: "show without default issue",
+			args: []string{"show"},
+			want: "invalid input:  issue: must be an issue number or set with 'defaults.issue': 0\n",
+		},
+		{
+			name: "show missing",
+			args: []string{"show", "56"},
+			want: "failed to find issue:  issue not found: 56\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set(cmd.DefaultIssueKey, tt.defaultIssue)
+
+			c := cmd.NewIssueCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			_ = c.Execute()
+			assert.Contains(t, b.String(), tt.want)
+		})
+	}
+}
+
+func TestIssueFill(t *testing.T) {
+	is := cmd.Issue{Number: 56, Volume: 14, Year: 2021, Season: "Spring", Pages: 48}
+
+	tests := []struct {
+		name      string
+		listing   lstg.Listing
+		want      lstg.Listing
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "filled in",
+			listing:   lstg.Listing{IssueNumber: 56, PageNumber: 3},
+			want:      lstg.Listing{Volume: 14, IssueNumber: 56, Year: 2021, Season: "Spring", PageNumber: 3},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "matching",
+			listing:   lstg.Listing{Volume: 14, IssueNumber: 56, Year: 2021, Season: "spring", PageNumber: 48},
+			want:      lstg.Listing{Volume: 14, IssueNumber: 56, Year: 2021, Season: "spring", PageNumber: 48},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "other issue",
+			listing:   lstg.Listing{IssueNumber: 55},
+			assertion: assert.Error,
+		},
+		{
+			name:      "wrong volume",
+			listing:   lstg.Listing{Volume: 13, IssueNumber: 56},
+			assertion: assert.Error,
+		},
+		{
+			name:      "wrong year",
+			listing:   lstg.Listing{IssueNumber: 56, Year: 2020},
+			assertion: assert.Error,
+		},
+		{
+			name:      "wrong season",
+			listing:   lstg.Listing{IssueNumber: 56, Season: "Fall"},
+			assertion: assert.Error,
+		},
+		{
+			name:      "past last page",
+			listing:   lstg.Listing{IssueNumber: 56, PageNumber: 49},
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.listing
+			err := is.Fill(&l)
+			tt.assertion(t, err)
+
+			if err == nil {
+				assert.Equal(t, tt.want, l)
+			} else {
+				assert.ErrorIs(t, err, lstg.ErrInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+const listingsCommandLongDesc = "The listings command shows which listings in an issue have been written to.\n\n" +
+	"A listing is answered once a letter from the configured 'member' number links to it ('--link L<id>') and has\n" +
+	"been sent. Drafted letters, and letters that came back or were lost, don't answer a listing. A reply is a\n" +
+	"letter that links to the answer, or any letter from the listing's member since the answer was sent."
+
+var listingResponseColumnConfigs = []table.ColumnConfig{
+	{
+		Name:  "ID",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Member",
+		Align: text.AlignRight,
+	},
+	{
+		Name:             "Text",
+		WidthMax:         threadTextWidth,
+		WidthMaxEnforcer: render.Truncate,
+	},
+	{
+		Name:  "Letter",
+		Align: text.AlignCenter,
+	},
+	{
+		Name:  "Days",
+		Align: text.AlignRight,
+	},
+	{
+		Name:  "Reply",
+		Align: text.AlignCenter,
+	},
+}
+
+// A ListingResponse is a listing and the letter written in answer to it. Letter is nil for a listing that hasn't
+// been written to, and Reply is nil until a letter comes back.
+type ListingResponse struct {
+	Listing lstg.Listing `json:"listing"`
+	Letter  *Mail        `json:"letter,omitempty"`
+	Reply   *Mail        `json:"reply,omitempty"`
+	Days    int          `json:"days,omitempty"`
+}
+
+// Answered reports whether a letter answering the listing was sent.
+func (r ListingResponse) Answered() bool {
+	return r.Letter != nil && countsAsLetter(*r.Letter)
+}
+
+func init() {
+	rootCmd.AddCommand(NewListingsCmd())
+}
+
+// NewListingsCmd creates a listings command with its subcommands.
+func NewListingsCmd() *cobra.Command {
+	// cmd represents the listings command
+	cmd := &cobra.Command{
+		Use:   "listings",
+		Short: "Show which listings have been answered",
+		Long:  listingsCommandLongDesc,
+	}
+
+	cmd.PersistentFlags().IntP("issue", "i", 0, "LEX issue number. (default is the 'defaults.issue' setting)")
+	cmd.PersistentFlags().StringP("date", "d", time.Now().Format(DateFormat), "Date to count days from.")
+	cmd.PersistentFlags().BoolP("pretty", "p", false, "Show prettier results.")
+
+	cmd.AddCommand(
+		NewListingsUnansweredCmd(),
+		NewListingsAnsweredCmd(),
+	)
+
+	return cmd
+}
+
+// NewListingsUnansweredCmd creates a listings unanswered command.
+func NewListingsUnansweredCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "unanswered",
+		Short:   "List listings in an issue that haven't been written to",
+		Example: "ogma listings unanswered --issue 56",
+		Args:    cobra.NoArgs,
+		Run:     RunListingsCmd,
+	}
+}
+
+// NewListingsAnsweredCmd creates a listings answered command.
+func NewListingsAnsweredCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "answered",
+		Short:   "List listings in an issue that have been written to",
+		Example: "ogma listings answered --issue 56",
+		Args:    cobra.NoArgs,
+		Run:     RunListingsCmd,
+	}
+}
+
+// RunListingsCmd implements functionality of the listings answered and unanswered commands.
+func RunListingsCmd(cmd *cobra.Command, args []string) {
+	issue, err := issueFlag(cmd)
+	if err != nil {
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	self, err := member.Parse(viper.GetString("member"))
+	if err != nil {
+		log.Error("invalid member configuration: ", err)
+		cmd.PrintErrln("invalid 'member' configuration: ", err)
+		return
+	}
+
+	date, _ := cmd.Flags().GetString("date")
+
+	asOf, err := time.Parse(DateFormat, date)
+	if err != nil {
+		log.WithField("date", date).Error("invalid date argument: ", err)
+		cmd.PrintErrln("invalid input: ", fmt.Errorf("date format must be 'yyyy-mm-dd': %w", err))
+		return
+	}
+
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	rr, err := FindListingResponses(dsManager.Store, issue, self, asOf)
+	if err != nil {
+		log.WithField("issue", issue).Error("failed to find listing responses: ", err)
+		cmd.PrintErrln("failed to find listings: ", err)
+		return
+	}
+
+	answered := cmd.Name() == "answered"
+	kept := []ListingResponse{}
+
+	for _, r := range rr {
+		if r.Answered() == answered {
+			kept = append(kept, r)
+		}
+	}
+
+	printReport(cmd, r, ListingResponsesReport(kept, issue, answered))
+}
+
+// FindListingResponses returns every listing in an issue with the letter self wrote in answer to it, by page.
+// The first letter sent is used when there is more than one.
+func FindListingResponses(ds storm.Finder, issue int, self member.ID, asOf time.Time) ([]ListingResponse, error) {
+	ll := []lstg.Listing{}
+
+	err := ds.Select(q.Eq("IssueNumber", issue)).Find(&ll)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, fmt.Errorf("failure to read listings: %w", err)
+	}
+
+	mails := []Mail{}
+	if err = ds.All(&mails); err != nil {
+		return nil, fmt.Errorf("failure to read mail: %w", err)
+	}
+
+	sort.SliceStable(mails, func(i, j int) bool { return mails[i].Date < mails[j].Date })
+
+	rr := make([]ListingResponse, len(ll))
+
+	for i, l := range ll {
+		r := ListingResponse{Listing: l}
+		to := link.ToListing(l.ID)
+
+		for j := range mails {
+			m := &mails[j]
+			if m.Link != to || !self.Matches(m.Sender) {
+				continue
+			}
+
+			// a sent letter answers the listing, but a draft is kept until one is
+			if r.Letter == nil || (!countsAsLetter(*r.Letter) && countsAsLetter(*m)) {
+				r.Letter = m
+			}
+		}
+
+		if r.Answered() {
+			r.Reply = listingReply(mails, l, r.Letter, self)
+
+			if sent, err := time.Parse(DateFormat, r.Letter.Date); err == nil {
+				r.Days = int(asOf.Sub(sent).Hours() / 24)
+			}
+		}
+
+		rr[i] = r
+	}
+
+	sort.SliceStable(rr, func(i, j int) bool {
+		if rr[i].Listing.PageNumber != rr[j].Listing.PageNumber {
+			return rr[i].Listing.PageNumber < rr[j].Listing.PageNumber
+		}
+
+		return rr[i].Listing.ID < rr[j].Listing.ID
+	})
+
+	return rr, nil
+}
+
+// listingReply returns the first letter replying to an answer: one linked to it, or one from the listing's member
+// to self since it was sent.
+func listingReply(mails []Mail, l lstg.Listing, answer *Mail, self member.ID) *Mail {
+	to := link.ToMail(answer.Ref)
+	lm := l.Member()
+
+	for i := range mails {
+		m := &mails[i]
+		if m == answer || !countsAsLetter(*m) {
+			continue
+		}
+
+		if m.Link == to || (lm.Matches(m.Sender) && self.Matches(m.Receiver) && m.Date >= answer.Date) {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// ListingResponsesReport returns listings of an issue with the letters that answered them as a report.
+func ListingResponsesReport(rr []ListingResponse, issue int, answered bool) render.Report {
+	rep := render.Report{
+		Title:   fmt.Sprintf("Unanswered Listings in Issue %d:", issue),
+		Header:  table.Row{"ID", "Page", "Category", "Member", "Text", "Letter", "Status"},
+		Columns: listingResponseColumnConfigs,
+		Records: rr,
+		Empty:   fmt.Sprintf("No unanswered listings in issue %d.", issue),
+	}
+
+	if answered {
+		rep.Title = fmt.Sprintf("Answered Listings in Issue %d:", issue)
+		rep.Header = append(rep.Header, "Sent", "Days", "Reply")
+		rep.Empty = fmt.Sprintf("No answered listings in issue %d.", issue)
+	}
+
+	for _, r := range rr {
+		l := r.Listing
+		row := table.Row{l.ID, l.PageNumber, l.IndexedCategory, l.Member().String(), l.ListingText}
+
+		if r.Letter == nil {
+			row = append(row, "", "")
+		} else {
+			row = append(row, r.Letter.Ref, r.Letter.Status)
+		}
+
+		if answered {
+			reply := "no"
+			if r.Reply != nil {
+				reply = r.Reply.Ref
+			}
+
+			row = append(row, r.Letter.Date, r.Days, reply)
+		}
+
+		rep.Rows = append(rep.Rows, row)
+	}
+
+	return rep
+}
@@ -0,0 +1,145 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+func TestNewListingsCmd(t *testing.T) {
+	got := cmd.NewListingsCmd()
+
+	assert.Equal(t, "listings", got.Name())
+	assert.Equal(t, "Show which listings have been answered", got.Short)
+
+	names := []string{}
+	for _, c := range got.Commands() {
+		names = append(names, c.Name())
+	}
+
+	assert.ElementsMatch(t, []string{"answered", "unanswered"}, names)
+}
+
+func TestRunListingsCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+
+	// a letter to listing 3 that hasn't been sent yet
+	require.NoError(t, m.Save(&cmd.Mail{
+		Ref:      "d4d4d4",
+		Sender:   member.ID{Number: 55},
+		Receiver: member.ID{Number: 5678},
+		Date:     "1986-04-20",
+		Link:     link.ToListing(3),
+		Status:   cmd.StatusDrafted,
+	}))
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.DatastoreFilenameKey, dsFile)
+	viper.Set("member", "55")
+
+	tests := []struct {
+		name         string
+		args         []string
+		defaultIssue int
+		output       string
+		want         string
+		notWant      string
+	}{
+		{
+			name:    "answered",
+			args:    []string{"answered", "--issue", "1", "-d1986-05-01"},
+			want:    "|  1 |    1 | Pariatur |   1234 | Esse Lorem do nulla sunt mollit nulla i… | 123d5f | received | 1986-04-01 |   30 | b12cd3 |\n",
+			notWant: "Magna",
+		},
+		{
+			name: "unanswered",
+			args: []string{"unanswered", "-i1"},
+			want: "|  2 |    2 | Commodo  |  1234B | Magna officia anim dolore enim.          |        |         |\n" +
+				"|  3 |    3 | Pariatur |   5678 | Velit cillum cillum ea officia nulla en… | d4d4d4 | drafted |\n",
+			notWant: "Esse",
+		},
+		{
+			name:   "csv keeps the full text",
+			args:   []string{"unanswered", "-i1"},
+			output: "csv",
+			want: "2,2,Commodo,1234B,Magna officia anim dolore enim.,,\n" +
+				"3,3,Pariatur,5678,Velit cillum cillum ea officia nulla enim.,d4d4d4,drafted\n",
+		},
+		{
+			name:   "markdown keeps the full text",
+			args:   []string{"unanswered", "-i1"},
+			output: "markdown",
+			want:   "| 3 | 3 | Pariatur | 5678 | Velit cillum cillum ea officia nulla enim. | d4d4d4 | drafted |",
+		},
+		{
+			name: "empty issue",
+			args: []string{"unanswered", "-i56"},
+			want: "No unanswered listings in issue 56.\n",
+		},
+		{
+			name:         "default issue",
+			args:         []string{"answered"},
+			defaultIssue: 1,
+			want:         "Answered Listings in Issue 1:",
+		},
+		{
+			name: "no issue",
+			args: []string{"answered"},
+			want: "invalid input:  issue: must be an issue number: 0\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set(cmd.DefaultIssueKey, tt.defaultIssue)
+			viper.Set(cmd.OutputKey, tt.output)
+			defer viper.Set(cmd.OutputKey, "")
+
+			c := cmd.NewListingsCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			require.NoError(t, c.Execute())
+			assert.Contains(t, b.String(), tt.want)
+			if tt.notWant != "" {
+				assert.NotContains(t, b.String(), tt.notWant)
+			}
+		})
+	}
+}
@@ -28,15 +28,22 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"time"
 
+	"github.com/asdine/storm/v3"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/asphaltbuffet/ogma/pkg/address"
 	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/query"
+	"github.com/asphaltbuffet/ogma/pkg/render"
 )
 
 // Mails is a container for multiple mail objects.
@@ -46,12 +53,43 @@ type Mails struct {
 
 // Mail contains relevant information for correspondence.
 type Mail struct {
-	ID       int    `storm:"id,increment"`
-	Ref      string `json:"reference"`
-	Sender   int    `json:"sender"`
-	Receiver int    `json:"receiver"`
-	Date     string `json:"date"`
-	Link     string `json:"link"`
+	ID       int       `storm:"id,increment"`
+	Ref      string    `storm:"unique" json:"reference"`
+	Sender   member.ID `json:"sender"`
+	Receiver member.ID `json:"receiver"`
+	Date     string    `json:"date"`
+	Link     link.Link `json:"link"`
+
+	// SentTo is the receiver's address on the day the mail was sent, if it was known.
+	SentTo *address.Address `json:"sent_to,omitempty"`
+
+	Status        MailStatus    `json:"status,omitempty"`
+	StatusHistory []StatusEvent `json:"status_history,omitempty"`
+}
+
+var (
+	// ErrInvalidRefLength is returned for a reference length that can't be used.
+	ErrInvalidRefLength = errors.New("invalid reference length")
+
+	// ErrNoFreeRef is returned when every reference tried for new mail is already taken.
+	ErrNoFreeRef = errors.New("no free mail reference")
+)
+
+// MailQuerySchema describes the mail fields available to search queries. Bare member identifiers search by
+// member, which matches either the sender or receiver.
+var MailQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":       {Fields: []string{"ID"}, Kind: query.Int},
+		"ref":      {Fields: []string{"Ref"}, Kind: query.String},
+		"sender":   {Fields: []string{"Sender"}, Matcher: member.FieldMatcher},
+		"receiver": {Fields: []string{"Receiver"}, Matcher: member.FieldMatcher},
+		"member":   {Fields: []string{"Sender", "Receiver"}, Matcher: member.FieldMatcher},
+		"date":     {Fields: []string{"Date"}, Kind: query.Date},
+		"link":     {Fields: []string{"Link"}, Kind: query.String},
+		"status":   {Fields: []string{"Status"}, Matcher: statusMatcher},
+	},
+	BareInt:   "member",
+	IsBareInt: member.IsID,
 }
 
 const (
@@ -64,14 +102,21 @@ const (
 	// RefLength is the default reference length.
 	RefLength = 6
 
+	// refAttempts is how many salted references of a length are tried before a longer one is used.
+	refAttempts = 16
+
 	// DateFormat is the date format for mail date.
 	DateFormat = "2006-01-02"
 )
 
 const mailCommandLongDesc = "The mail command supports entering correspondence details and getting a reference number\n" +
 	"back that can be used for tracking physical artifacts.\n\n" +
+	"'Sender' and 'Receiver' are member numbers with an optional extension letter, like '1234' or '1234A'.\n" +
 	"'Date' must be in the 'yyyy-mm-dd' format.\n" +
-	"'Link' is optional. It must start with 'L' to link with an ad (using ID field from ad output) or 'M' to link to a correspondence reference."
+	"'Link' is optional. It must start with 'L' to link with an ad (using ID field from ad output) or 'M' to link to a correspondence reference.\n" +
+	"The listing or correspondence it links to must already be stored.\n" +
+	"'Status' is optional. New mail is 'received' when it was sent to you, and 'sent' otherwise. Use 'ogma mail status'\n" +
+	"to track it from there."
 
 var mailColumnConfigs = []table.ColumnConfig{
 	{
@@ -94,10 +139,17 @@ var mailColumnConfigs = []table.ColumnConfig{
 		Name:  "Link",
 		Align: text.AlignCenter,
 	},
+	{
+		Name:  "Status",
+		Align: text.AlignLeft,
+	},
 }
 
 func init() {
-	rootCmd.AddCommand(NewMailCmd())
+	mailCmd := NewMailCmd()
+	mailCmd.AddCommand(NewMailStatusCmd())
+
+	rootCmd.AddCommand(mailCmd)
 }
 
 // NewMailCmd creates a mail command.
@@ -111,12 +163,14 @@ func NewMailCmd() *cobra.Command {
 		Run:     RunMailCmd,
 	}
 
-	dm := viper.GetInt("member")
-	cmd.Flags().IntP("sender", "s", dm, "Correspondence sender.")
-	cmd.Flags().IntP("receiver", "r", dm, "Correspondence receiver.")
+	dm := viper.GetString("member")
+	cmd.Flags().StringP("sender", "s", dm, "Correspondence sender.")
+	cmd.Flags().StringP("receiver", "r", dm, "Correspondence receiver.")
 	cmd.Flags().StringP("date", "d", time.Now().Format(DateFormat), "Correspondence date.")
 	cmd.Flags().StringP("link", "l", "", "Link to listing ID or previous correspondence. 'L' prefix for listing entry, 'M' prefix for mail")
-	cmd.Flags().IntP("length", "L", RefLength, "Correspondence receiver.")
+	cmd.Flags().IntP("length", "L", RefLength, "Correspondence reference length.")
+	cmd.Flags().StringP("status", "S", "", "Correspondence status: drafted, sent, or received. (default based on receiver)")
+	cmd.Flags().StringP("note", "n", "", "Note about the correspondence status.")
 
 	return cmd
 }
@@ -144,6 +198,28 @@ func RunMailCmd(cmd *cobra.Command, args []string) {
 	}
 	defer dsManager.Stop()
 
+	if err = checkLink(dsManager, m.Link); err != nil {
+		log.WithFields(log.Fields{
+			"command": cmd.Name(),
+			"link":    m.Link.String(),
+		}).Error("failed to validate link: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	length, _ := cmd.Flags().GetInt("length")
+
+	if m.Ref, err = NewMailRef(dsManager, m, length); err != nil {
+		log.WithFields(log.Fields{
+			"command": cmd.Name(),
+			"length":  length,
+		}).Error("failed to create reference: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	m.SentTo = sentTo(dsManager, m)
+
 	err = dsManager.Save(&m)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -156,18 +232,34 @@ func RunMailCmd(cmd *cobra.Command, args []string) {
 	log.WithFields(log.Fields{
 		"command":  cmd.Name(),
 		"ref":      m.Ref,
-		"sender":   m.Sender,
-		"receiver": m.Receiver,
+		"sender":   m.Sender.String(),
+		"receiver": m.Receiver.String(),
 		"date":     m.Date,
-		"link":     m.Link,
+		"link":     m.Link.String(),
 	}).Info("added mail entry")
 
 	cmd.Printf("Added mail. Reference: %s\n", m.Ref)
 }
 
+// sentTo returns the address the receiver of a mail had on its date, or nil if it isn't known.
+func sentTo(ds storm.Finder, m Mail) *address.Address {
+	r, err := findMember(ds, m.Receiver)
+	if err != nil {
+		log.WithField("receiver", m.Receiver.String()).Debug("no address for mail receiver: ", err)
+		return nil
+	}
+
+	e, ok := r.Addresses.At(m.Date)
+	if !ok {
+		return nil
+	}
+
+	return &e.Address
+}
+
 // mailFromArgs creates a new mail object from command arguments.
 func mailFromArgs(cmd *cobra.Command) (Mail, error) {
-	s, err := cmd.Flags().GetInt("sender")
+	s, err := cmd.Flags().GetString("sender")
 	if err != nil {
 		log.WithFields(log.Fields{
 			"command": cmd.Name(),
@@ -175,7 +267,7 @@ func mailFromArgs(cmd *cobra.Command) (Mail, error) {
 		}).Warn("failed to get sender argument")
 	}
 
-	r, err := cmd.Flags().GetInt("receiver")
+	r, err := cmd.Flags().GetString("receiver")
 	if err != nil {
 		log.WithFields(log.Fields{
 			"command": cmd.Name(),
@@ -183,9 +275,22 @@ func mailFromArgs(cmd *cobra.Command) (Mail, error) {
 		}).Warn("failed to get receiver argument")
 	}
 
-	m := Mail{
-		Sender:   s,
-		Receiver: r,
+	var m Mail
+
+	if m.Sender, err = member.Parse(s); err != nil {
+		log.WithFields(log.Fields{
+			"command": cmd.Name(),
+			"sender":  s,
+		}).Error("failed to parse sender")
+		return Mail{}, fmt.Errorf("sender: %w", err)
+	}
+
+	if m.Receiver, err = member.Parse(r); err != nil {
+		log.WithFields(log.Fields{
+			"command":  cmd.Name(),
+			"receiver": r,
+		}).Error("failed to parse receiver")
+		return Mail{}, fmt.Errorf("receiver: %w", err)
 	}
 
 	date, err := cmd.Flags().GetString("date")
@@ -205,7 +310,7 @@ func mailFromArgs(cmd *cobra.Command) (Mail, error) {
 		return Mail{}, errors.New("date: failed to add correspondence")
 	}
 
-	m.Link, err = cmd.Flags().GetString("link")
+	l, err := cmd.Flags().GetString("link")
 	if err != nil {
 		log.WithFields(log.Fields{
 			"command": cmd.Name(),
@@ -213,13 +318,71 @@ func mailFromArgs(cmd *cobra.Command) (Mail, error) {
 		}).Warn("failed to get link argument")
 	}
 
-	m.Ref = MailHash(m, RefLength)
+	if m.Link, err = link.Parse(l); err != nil {
+		log.WithFields(log.Fields{
+			"command": cmd.Name(),
+			"link":    l,
+		}).Error("failed to parse link")
+		return Mail{}, fmt.Errorf("link: %w", err)
+	}
+
+	e := StatusEvent{Status: defaultMailStatus(m), At: time.Now().UTC()}
+
+	if status, _ := cmd.Flags().GetString("status"); status != "" {
+		if e.Status, err = ParseMailStatus(status); err != nil {
+			return Mail{}, fmt.Errorf("status: %w", err)
+		}
+	}
+
+	switch e.Status { //nolint:exhaustive // returned and lost mail must have been sent first
+	case StatusDrafted, StatusSent, StatusReceived:
+	default:
+		return Mail{}, fmt.Errorf("status: new mail must be drafted, sent, or received: %s", e.Status)
+	}
+
+	e.Note, _ = cmd.Flags().GetString("note")
+
+	// new mail has no status yet, so this can't fail
+	_ = m.SetStatus(e, false)
 
 	return m, nil
 }
 
-// MailHash creates a 'unique' hash of the sender, receiver, and mail date.
+// NewMailRef returns a reference for mail that no stored mail has. It is the mail's hash when that is free;
+// otherwise salted hashes are tried, and then longer ones.
+func NewMailRef(ds storm.Finder, m Mail, length int) (string, error) {
+	if length < 1 || length > MaxHashLength {
+		return "", fmt.Errorf("%w: must be from 1 to %d: %d", ErrInvalidRefLength, MaxHashLength, length)
+	}
+
+	for l := length; l <= MaxHashLength; l++ {
+		for salt := 0; salt < refAttempts; salt++ {
+			ref := mailHash(m, l, salt)
+
+			err := ds.One("Ref", ref, &Mail{})
+			if errors.Is(err, storm.ErrNotFound) {
+				return ref, nil
+			}
+
+			if err != nil {
+				return "", fmt.Errorf("error reading mail %s: %w", ref, err)
+			}
+
+			log.WithField("ref", ref).Debug("reference is taken")
+		}
+	}
+
+	return "", ErrNoFreeRef
+}
+
+// MailHash creates a 'unique' hash of the sender, receiver, and mail date. Use NewMailRef for a reference that
+// isn't already taken.
 func MailHash(m Mail, l int) string {
+	return mailHash(m, l, 0)
+}
+
+// mailHash hashes the sender, receiver, and mail date with a salt. Salt 0 leaves the hash unsalted.
+func mailHash(m Mail, l int, salt int) string {
 	if l > MaxHashLength {
 		l = MaxHashLength
 	} else if l < MinHashLength {
@@ -229,6 +392,9 @@ func MailHash(m Mail, l int) string {
 	h := md5.New() //nolint:gosec // not using this for security purposes
 	padding := "qwertyuiopasdfghjklzxcvbnm1234567890"
 	hSrc := fmt.Sprint(m.Sender, m.Receiver, m.Date)
+	if salt > 0 {
+		hSrc = fmt.Sprint(hSrc, " ", salt)
+	}
 	if _, err := io.WriteString(h, padding); err != nil {
 		log.WithFields(log.Fields{
 			"pre-hash": hSrc,
@@ -271,48 +437,36 @@ func ValidateDate(dd string) (string, error) {
 	return d.Local().Format(DateFormat), nil
 }
 
-// RenderMail returns a pretty formatted listing as table.
-func RenderMail(mm []Mail, p bool) string {
-	// empty string if there are no listings to render
-	if len(mm) == 0 {
-		return "No correspondences found."
+// MailReport returns mail as a report, by date.
+func MailReport(mm []Mail) render.Report {
+	mm = append([]Mail{}, mm...)
+	sort.SliceStable(mm, func(i, j int) bool { return mm[i].Date < mm[j].Date })
+
+	rep := render.Report{
+		Title: "Correspondence Matches:",
+		Header: table.Row{
+			"Reference",
+			"Sender",
+			"Receiver",
+			"Date",
+			"Link",
+			"Status",
+		},
+		Columns: mailColumnConfigs,
+		Records: mm,
+		Empty:   "No correspondences found.",
 	}
 
-	mt := table.NewWriter()
-
-	mt.SetTitle("Correspondence Matches:")
-
-	mt.AppendHeader(table.Row{
-		"Reference",
-		"Sender",
-		"Receiver",
-		"Date",
-		"Link",
-	})
-
 	for _, m := range mm {
-		mt.AppendRow([]interface{}{
+		rep.Rows = append(rep.Rows, table.Row{
 			m.Ref,
-			m.Sender,
-			m.Receiver,
+			m.Sender.String(),
+			m.Receiver.String(),
 			m.Date,
-			m.Link,
+			m.Link.String(),
+			m.Status,
 		})
 	}
 
-	mt.SetColumnConfigs(mailColumnConfigs)
-
-	mt.SortBy([]table.SortBy{
-		{Name: "Date", Mode: table.Asc},
-		{Name: "Ref", Mode: table.Asc},
-	})
-
-	if p {
-		mt.SetStyle(table.StyleColoredBright)
-	}
-	log.WithFields(log.Fields{
-		"is_pretty": p,
-	}).Debug("set rendering style")
-
-	return mt.Render()
+	return rep
 }
@@ -0,0 +1,315 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+	"github.com/jedib0t/go-pretty/v6/table"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/query"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+// A MailStatus is where a letter is in its life.
+type MailStatus string
+
+// Mail statuses.
+const (
+	StatusDrafted  MailStatus = "drafted"
+	StatusSent     MailStatus = "sent"
+	StatusReceived MailStatus = "received"
+	StatusReturned MailStatus = "returned"
+	StatusLost     MailStatus = "lost"
+)
+
+var (
+	// ErrInvalidStatus is returned for text that isn't a mail status.
+	ErrInvalidStatus = errors.New("invalid mail status")
+
+	// ErrStatusTransition is returned when mail can't move from its current status to a new one.
+	ErrStatusTransition = errors.New("invalid status change")
+
+	// ErrMailNotFound is returned when no mail is stored with a reference.
+	ErrMailNotFound = errors.New("mail not found")
+)
+
+// mailStatuses lists every status, in the order a letter usually goes through them.
+var mailStatuses = []MailStatus{StatusDrafted, StatusSent, StatusReceived, StatusReturned, StatusLost}
+
+// statusTransitions are the statuses mail can move to from each status. Mail stored before statuses were tracked
+// has none, and can move to any status.
+var statusTransitions = map[MailStatus][]MailStatus{
+	StatusDrafted:  {StatusSent, StatusLost},
+	StatusSent:     {StatusReceived, StatusReturned, StatusLost},
+	StatusReceived: {},
+	StatusReturned: {StatusDrafted, StatusSent},
+	StatusLost:     {StatusSent, StatusReceived, StatusReturned},
+}
+
+// A StatusEvent records mail changing status.
+type StatusEvent struct {
+	Status MailStatus `json:"status"`
+	At     time.Time  `json:"at"`
+	Note   string     `json:"note,omitempty"`
+}
+
+// ParseMailStatus reads a mail status, ignoring case.
+func ParseMailStatus(s string) (MailStatus, error) {
+	for _, st := range mailStatuses {
+		if strings.EqualFold(s, string(st)) {
+			return st, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q (must be one of %s)", ErrInvalidStatus, s, statusList())
+}
+
+// CanChangeTo reports whether mail with status s can move to next.
+func (s MailStatus) CanChangeTo(next MailStatus) bool {
+	if s == "" {
+		return true
+	}
+
+	for _, st := range statusTransitions[s] {
+		if st == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetStatus moves mail to a new status, adding it to the status history. Moves that don't make sense for a letter,
+// like a received letter being lost, are refused unless forced.
+func (m *Mail) SetStatus(e StatusEvent, force bool) error {
+	if !force && !m.Status.CanChangeTo(e.Status) {
+		return fmt.Errorf("%w: %s cannot go from %s to %s", ErrStatusTransition, m.Ref, m.Status, e.Status)
+	}
+
+	m.Status = e.Status
+	m.StatusHistory = append(m.StatusHistory, e)
+
+	return nil
+}
+
+// statusMatcher matches the status field of search queries.
+func statusMatcher(t query.Term) (q.FieldMatcher, error) {
+	if t.IsRange {
+		return nil, errors.New("ranges are not supported")
+	}
+
+	st, err := ParseMailStatus(t.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return statusMatch(st), nil
+}
+
+type statusMatch MailStatus
+
+func (m statusMatch) MatchField(v interface{}) (bool, error) {
+	st, ok := v.(MailStatus)
+	if !ok {
+		return false, fmt.Errorf("field of type %T is not a mail status", v)
+	}
+
+	return st == MailStatus(m), nil
+}
+
+const mailStatusCommandLongDesc = "The status command changes where a letter is in its life, or shows its status\n" +
+	"history when no status is given.\n\n" +
+	"Mail is 'drafted', 'sent', 'received', 'returned' as undeliverable, or 'lost'. Changes that don't make sense,\n" +
+	"like a received letter going back to drafted, need '--force'."
+
+// NewMailStatusCmd creates a mail status command.
+func NewMailStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status <ref> [status]",
+		Short: "Track the status of a letter",
+		Long:  mailStatusCommandLongDesc,
+		Example: `ogma mail status f2165e
+ogma mail status f2165e returned --note "no such street"`,
+		Args: cobra.RangeArgs(1, 2),
+		Run:  RunMailStatusCmd,
+	}
+
+	cmd.Flags().StringP("note", "n", "", "Note about the change.")
+	cmd.Flags().StringP("date", "d", "", "Date of the change, as 'yyyy-mm-dd'. (default now)")
+	cmd.Flags().BoolP("force", "f", false, "Allow any status change.")
+	cmd.Flags().BoolP("pretty", "p", false, "Show prettier results.")
+
+	return cmd
+}
+
+// RunMailStatusCmd implements functionality of a mail status command.
+func RunMailStatusCmd(cmd *cobra.Command, args []string) {
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	dsManager, err := datastore.New(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"command": cmd.Name(),
+		}).Error("failed to open datastore: ", err)
+		cmd.PrintErrln("Failed to access datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	m, err := findMail(dsManager, args[0])
+	if err != nil {
+		log.WithField("ref", args[0]).Error("failed to find mail: ", err)
+		cmd.PrintErrln("failed to find mail: ", err)
+		return
+	}
+
+	if len(args) == 1 {
+		printReport(cmd, r, MailStatusReport(m))
+		return
+	}
+
+	e, err := statusEventFromArgs(cmd, args[1])
+	if err != nil {
+		log.WithField("ref", m.Ref).Error("invalid status input: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+
+	if err = m.SetStatus(e, force); err != nil {
+		log.WithField("ref", m.Ref).Error("invalid status change: ", err)
+		cmd.PrintErrln("invalid input: ", err)
+		return
+	}
+
+	if err = dsManager.Save(&m); err != nil {
+		log.WithField("ref", m.Ref).Error("unable to save mail: ", err)
+		cmd.PrintErrln("Failed to save entry: ", err)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"ref":    m.Ref,
+		"status": m.Status,
+		"note":   e.Note,
+	}).Info("changed mail status")
+
+	cmd.Printf("Mail %s is %s.\n", m.Ref, m.Status)
+}
+
+// statusEventFromArgs creates a status event from command arguments.
+func statusEventFromArgs(cmd *cobra.Command, status string) (StatusEvent, error) {
+	st, err := ParseMailStatus(status)
+	if err != nil {
+		return StatusEvent{}, err
+	}
+
+	e := StatusEvent{Status: st, At: time.Now().UTC()}
+
+	e.Note, _ = cmd.Flags().GetString("note")
+
+	if date, _ := cmd.Flags().GetString("date"); date != "" {
+		if _, err = ValidateDate(date); err != nil {
+			return StatusEvent{}, err
+		}
+
+		d, _ := time.ParseInLocation(DateFormat, date, time.Local)
+		e.At = d.UTC()
+	}
+
+	return e, nil
+}
+
+// defaultMailStatus is the status of new mail: received when it was sent to the configured member, otherwise sent.
+func defaultMailStatus(m Mail) MailStatus {
+	self, err := member.Parse(viper.GetString("member"))
+	if err == nil && m.Receiver == self {
+		return StatusReceived
+	}
+
+	return StatusSent
+}
+
+// findMail returns the mail stored with a reference.
+func findMail(ds storm.Finder, ref string) (Mail, error) {
+	var m Mail
+
+	err := ds.One("Ref", ref, &m)
+	if errors.Is(err, storm.ErrNotFound) {
+		return Mail{}, fmt.Errorf("%w: %s", ErrMailNotFound, ref)
+	}
+
+	if err != nil {
+		return Mail{}, fmt.Errorf("error reading mail %s: %w", ref, err)
+	}
+
+	return m, nil
+}
+
+func statusList() string {
+	ss := make([]string, len(mailStatuses))
+	for i, st := range mailStatuses {
+		ss[i] = string(st)
+	}
+
+	return strings.Join(ss, ", ")
+}
+
+// MailStatusReport returns the status history of mail as a report, oldest first.
+func MailStatusReport(m Mail) render.Report {
+	rep := render.Report{
+		Title: fmt.Sprintf("Status History for %s:", m.Ref),
+		Header: table.Row{
+			"Date",
+			"Status",
+			"Note",
+		},
+		Records: m.StatusHistory,
+		Empty:   fmt.Sprintf("Mail %s has no status history.", m.Ref),
+	}
+
+	for _, e := range m.StatusHistory {
+		rep.Rows = append(rep.Rows, table.Row{
+			e.At.Local().Format(DateFormat),
+			e.Status,
+			e.Note,
+		})
+	}
+
+	return rep
+}
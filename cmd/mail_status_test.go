@@ -0,0 +1,209 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+)
+
+func TestNewMailStatusCmd(t *testing.T) {
+	got := cmd.NewMailStatusCmd()
+
+	assert.Equal(t, "status", got.Name())
+	assert.Equal(t, "Track the status of a letter", got.Short)
+	assert.True(t, got.Runnable())
+}
+
+func TestParseMailStatus(t *testing.T) {
+	got, err := cmd.ParseMailStatus("Returned")
+	require.NoError(t, err)
+	assert.Equal(t, cmd.StatusReturned, got)
+
+	_, err = cmd.ParseMailStatus("misplaced")
+	assert.ErrorIs(t, err, cmd.ErrInvalidStatus)
+	assert.EqualError(t, err, `invalid mail status: "misplaced" (must be one of drafted, sent, received, returned, lost)`)
+}
+
+func TestMailSetStatus(t *testing.T) {
+	m := cmd.Mail{Ref: "f2165e"}
+	at := time.Date(2021, 11, 15, 0, 0, 0, 0, time.UTC)
+
+	// mail from before statuses were tracked can take any status
+	require.NoError(t, m.SetStatus(cmd.StatusEvent{Status: cmd.StatusLost, At: at}, false))
+	require.NoError(t, m.SetStatus(cmd.StatusEvent{Status: cmd.StatusReceived, At: at, Note: "turned up"}, false))
+
+	err := m.SetStatus(cmd.StatusEvent{Status: cmd.StatusDrafted, At: at}, false)
+	assert.ErrorIs(t, err, cmd.ErrStatusTransition)
+	assert.EqualError(t, err, "invalid status change: f2165e cannot go from received to drafted")
+	assert.Equal(t, cmd.StatusReceived, m.Status)
+
+	require.NoError(t, m.SetStatus(cmd.StatusEvent{Status: cmd.StatusDrafted, At: at}, true))
+
+	assert.Equal(t, cmd.StatusDrafted, m.Status)
+	assert.Equal(t, []cmd.StatusEvent{
+		{Status: cmd.StatusLost, At: at},
+		{Status: cmd.StatusReceived, At: at, Note: "turned up"},
+		{Status: cmd.StatusDrafted, At: at},
+	}, m.StatusHistory)
+
+	tests := []struct {
+		from, to cmd.MailStatus
+		want     bool
+	}{
+		{from: cmd.StatusDrafted, to: cmd.StatusSent, want: true},
+		{from: cmd.StatusSent, to: cmd.StatusReturned, want: true},
+		{from: cmd.StatusSent, to: cmd.StatusDrafted, want: false},
+		{from: cmd.StatusReturned, to: cmd.StatusSent, want: true},
+		{from: cmd.StatusLost, to: cmd.StatusReceived, want: true},
+		{from: cmd.StatusReceived, to: cmd.StatusLost, want: false},
+		{from: cmd.StatusSent, to: cmd.StatusSent, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.from.CanChangeTo(tt.to))
+		})
+	}
+}
+
+func TestRunMailStatusCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.DatastoreFilenameKey, dsFile)
+
+	// steps run in order against the same datastore
+	tests := []struct {
+		name      string
+		args      []string
+		output    string
+		assertion assert.ErrorAssertionFunc
+		want      string
+	}{
+		{
+			name:      "no ref",
+			args:      []string{},
+			assertion: assert.Error,
+			want:      "Error: accepts between 1 and 2 arg(s), received 0",
+		},
+		{
+			name:      "unknown ref",
+			args:      []string{"abcdef"},
+			assertion: assert.NoError,
+			want:      "failed to find mail:  mail not found: abcdef\n",
+		},
+		{
+			name:      "history",
+			args:      []string{"6beef9"},
+			assertion: assert.NoError,
+			want:      "Mail 6beef9 has no status history.\n",
+		},
+		{
+			name:      "invalid status",
+			args:      []string{"6beef9", "misplaced"},
+			assertion: assert.NoError,
+			want:      `invalid input:  invalid mail status: "misplaced"`,
+		},
+		{
+			name:      "invalid date",
+			args:      []string{"6beef9", "sent", "--date", "yesterday"},
+			assertion: assert.NoError,
+			want:      "invalid input:  date format must be 'yyyy-mm-dd'",
+		},
+		{
+			name:      "invalid change",
+			args:      []string{"6beef9", "received"},
+			assertion: assert.NoError,
+			want:      "invalid input:  invalid status change: 6beef9 cannot go from returned to received\n",
+		},
+		{
+			name:      "resend",
+			args:      []string{"6beef9", "sent", "--date", "2021-04-02", "--note", "new address"},
+			assertion: assert.NoError,
+			want:      "Mail 6beef9 is sent.\n",
+		},
+		{
+			name:      "returned",
+			args:      []string{"6beef9", "returned", "-d", "2021-05-01", "-n", "no such street"},
+			assertion: assert.NoError,
+			want:      "Mail 6beef9 is returned.\n",
+		},
+		{
+			name:      "forced",
+			args:      []string{"6beef9", "received", "-d", "2021-06-01", "--force"},
+			assertion: assert.NoError,
+			want:      "Mail 6beef9 is received.\n",
+		},
+		{
+			name:      "history after changes",
+			args:      []string{"6beef9"},
+			assertion: assert.NoError,
+			want: "+----------------------------------------+\n" +
+				"| Status History for 6beef9:             |\n" +
+				"+------------+----------+----------------+\n" +
+				"| DATE       | STATUS   | NOTE           |\n" +
+				"+------------+----------+----------------+\n" +
+				"| 2021-04-02 | sent     | new address    |\n" +
+				"| 2021-05-01 | returned | no such street |\n" +
+				"| 2021-06-01 | received |                |\n" +
+				"+------------+----------+----------------+\n",
+		},
+		{
+			name:      "history as csv",
+			args:      []string{"6beef9"},
+			output:    "csv",
+			assertion: assert.NoError,
+			want:      "Date,Status,Note\n2021-04-02,sent,new address\n2021-05-01,returned,no such street\n2021-06-01,received,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set(cmd.OutputKey, tt.output)
+			defer viper.Set(cmd.OutputKey, "")
+
+			c := cmd.NewMailStatusCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			tt.assertion(t, c.Execute())
+
+			assert.Contains(t, b.String(), tt.want)
+		})
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"os"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
@@ -34,6 +35,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/member"
 )
 
 func TestNewMailCmd(t *testing.T) {
@@ -68,6 +70,76 @@ func TestRunMailCmd(t *testing.T) {
 			assertion: assert.NoError,
 			want:      "Added mail. Reference: f2165e\n",
 		},
+		{
+			name:      "same day",
+			args:      []string{"-d2021-11-15", "-s1234", "-r5678"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "Added mail. Reference: 31502b\n",
+		},
+		{
+			name:      "longer reference",
+			args:      []string{"-d2021-11-15", "-s1234", "-r5678", "--length", "8"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "Added mail. Reference: 89f2165e\n",
+		},
+		{
+			name:      "invalid length",
+			args:      []string{"-d2021-11-15", "-s1234", "-r5678", "-L0"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "invalid input:  invalid reference length: must be from 1 to 32: 0\n",
+		},
+		{
+			name:      "extended member",
+			args:      []string{"-d2021-11-15", "-s1234a", "-r5678"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "Added mail. Reference: e617cc\n",
+		},
+		{
+			name:      "drafted",
+			args:      []string{"-d2021-11-16", "-s1234", "-r5678", "--status", "drafted"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "Added mail. Reference: d34be3\n",
+		},
+		{
+			name:      "invalid status",
+			args:      []string{"-d2021-11-16", "-s1234", "-r5678", "--status", "lost"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "invalid input:  status: new mail must be drafted, sent, or received: lost\n",
+		},
+		{
+			name:      "mail link",
+			args:      []string{"-d2021-11-17", "-s5678", "-r1234", "-lMf2165e"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "Added mail. Reference: 76839d\n",
+		},
+		{
+			name:      "dangling link",
+			args:      []string{"-d2021-11-17", "-s5678", "-r1234", "-lL42"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "invalid input:  dangling link: L42: listing not found\n",
+		},
+		{
+			name:      "invalid link",
+			args:      []string{"-d2021-11-17", "-s5678", "-r1234", "-l42"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "invalid input:  link: invalid link: must start with 'L' for a listing or 'M' for mail: \"42\"\n",
+		},
+		{
+			name:      "invalid sender",
+			args:      []string{"-d2021-11-15", "-s12x4", "-r5678"},
+			config:    "/test/.tconfig",
+			assertion: assert.NoError,
+			want:      "invalid input:  sender: invalid member identifier: \"12x4\"\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -77,6 +149,7 @@ func TestRunMailCmd(t *testing.T) {
 			b := bytes.NewBufferString("")
 			cmd.InitConfig(fs, tt.config)
 			c.SetOut(b)
+			c.SetErr(b)
 			c.SetArgs(tt.args)
 			tt.assertion(t, c.Execute())
 			out, err := io.ReadAll(b)
@@ -96,8 +169,8 @@ func TestMailHash(t *testing.T) {
 		{
 			name: "6 char hash",
 			mail: cmd.Mail{
-				Sender:   1234,
-				Receiver: 5678,
+				Sender:   member.ID{Number: 1234},
+				Receiver: member.ID{Number: 5678},
 				Date:     "2021-11-15",
 			},
 			length: 6,
@@ -106,18 +179,28 @@ func TestMailHash(t *testing.T) {
 		{
 			name: "6 char hash - 2", // try with different values to ensure we're getting variation
 			mail: cmd.Mail{
-				Sender:   123,
-				Receiver: 45678,
+				Sender:   member.ID{Number: 123},
+				Receiver: member.ID{Number: 45678},
 				Date:     "2021-11-15",
 			},
 			length: 6,
 			want:   "650e0a",
 		},
+		{
+			name: "extended member",
+			mail: cmd.Mail{
+				Sender:   member.ID{Number: 1234, Extension: "A"},
+				Receiver: member.ID{Number: 5678},
+				Date:     "2021-11-15",
+			},
+			length: 6,
+			want:   "e617cc",
+		},
 		{
 			name: "0 char hash",
 			mail: cmd.Mail{
-				Sender:   1234,
-				Receiver: 5678,
+				Sender:   member.ID{Number: 1234},
+				Receiver: member.ID{Number: 5678},
 				Date:     "2021-11-15",
 			},
 			length: 0,
@@ -126,8 +209,8 @@ func TestMailHash(t *testing.T) {
 		{
 			name: "full hash",
 			mail: cmd.Mail{
-				Sender:   1234,
-				Receiver: 5678,
+				Sender:   member.ID{Number: 1234},
+				Receiver: member.ID{Number: 5678},
 				Date:     "2021-11-15",
 			},
 			length: 32,
@@ -136,8 +219,8 @@ func TestMailHash(t *testing.T) {
 		{
 			name: "overbound hash",
 			mail: cmd.Mail{
-				Sender:   1234,
-				Receiver: 5678,
+				Sender:   member.ID{Number: 1234},
+				Receiver: member.ID{Number: 5678},
 				Date:     "2021-11-15",
 			},
 			length: 33,
@@ -146,8 +229,8 @@ func TestMailHash(t *testing.T) {
 		{
 			name: "underbound hash",
 			mail: cmd.Mail{
-				Sender:   1234,
-				Receiver: 5678,
+				Sender:   member.ID{Number: 1234},
+				Receiver: member.ID{Number: 5678},
 				Date:     "2021-11-15",
 			},
 			length: -1,
@@ -163,6 +246,33 @@ func TestMailHash(t *testing.T) {
 	}
 }
 
+func TestNewMailRef(t *testing.T) {
+	m, _ := initDatastoreManager(t)
+
+	defer func() {
+		m.Stop()
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	mail := cmd.Mail{Sender: member.ID{Number: 1234}, Receiver: member.ID{Number: 5678}, Date: "2021-11-15"}
+
+	got, err := cmd.NewMailRef(m, mail, 6)
+	require.NoError(t, err)
+	assert.Equal(t, "f2165e", got)
+
+	// every one character reference is taken, so a longer one is used
+	for _, r := range "0123456789abcdef" {
+		require.NoError(t, m.Save(&cmd.Mail{Ref: string(r)}))
+	}
+
+	got, err = cmd.NewMailRef(m, mail, 1)
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	_, err = cmd.NewMailRef(m, mail, 33)
+	assert.ErrorIs(t, err, cmd.ErrInvalidRefLength)
+}
+
 func TestValidateDate(t *testing.T) {
 	tests := []struct {
 		name      string
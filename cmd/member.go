@@ -23,31 +23,104 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/asphaltbuffet/ogma/pkg/address"
 	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/query"
+	"github.com/asphaltbuffet/ogma/pkg/render"
 )
 
-// Members is a container for multiple mail objects.
+var (
+	// ErrMemberExists is returned when adding or renumbering a member to a number that is already stored.
+	ErrMemberExists = errors.New("member already exists")
+
+	// ErrMemberNotFound is returned when no member is stored with a number.
+	ErrMemberNotFound = errors.New("member not found")
+)
+
+// Members is a container for multiple member objects.
 type Members struct {
-	Mails []Mail `json:"members"`
+	Members []Member `json:"members"`
 }
 
 // Member contains relevant information for a member.
 type Member struct {
-	ID      int    `storm:"id,increment"`
-	Number  int    `json:"reference"`
-	Name    string `json:"sender"`
-	Address string `json:"receiver"`
+	ID        int             `storm:"id,increment"`
+	Number    member.ID       `storm:"unique" json:"number"`
+	Name      string          `json:"name"`
+	Addresses address.History `json:"addresses,omitempty"`
+
+	// ReplyDays and QuietDays replace the configured thresholds for 'ogma due' when they are set.
+	ReplyDays int `json:"reply_days,omitempty"`
+	QuietDays int `json:"quiet_days,omitempty"`
+}
+
+// Address returns the member's current address.
+func (m Member) Address() address.Address {
+	e, _ := m.Addresses.Current()
+
+	return e.Address
+}
+
+// UnmarshalJSON reads a member, including members written with a single 'address' by earlier versions.
+func (m *Member) UnmarshalJSON(data []byte) error {
+	type record Member
+
+	var v struct {
+		record
+		Address *address.Address `json:"address"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*m = Member(v.record)
+
+	if len(m.Addresses) == 0 && v.Address != nil && !v.Address.IsZero() {
+		m.Addresses = address.History{{Address: *v.Address}}
+	}
+
+	return nil
+}
+
+// Envelope returns the member's address as it is written on an envelope sent from the home country. The member's
+// name is used when the address has no recipient.
+func (m Member) Envelope(home string) []string {
+	a := m.Address()
+	if a.Recipient == "" && !a.IsZero() {
+		a.Recipient = m.Name
+	}
+
+	return a.Format(home)
+}
+
+// MemberQuerySchema describes the member fields available to search queries.
+var MemberQuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"member": {Fields: []string{"Number"}, Matcher: member.FieldMatcher},
+	},
+	BareInt:   "member",
+	IsBareInt: member.IsID,
 }
 
-const memberCommandLongDesc = "The member command allows you to add a new member to the tracker with name and/or address information."
+const memberCommandLongDesc = "The member command manages stored penpal information. Each member is stored once per member\n" +
+	"number; numbers with an extension letter, like '1234A', are separate members."
 
 var memberColumnConfigs = []table.ColumnConfig{
 	{
@@ -60,35 +133,147 @@ var memberColumnConfigs = []table.ColumnConfig{
 	},
 	{
 		Name:  "Address",
-		Align: text.AlignCenter,
+		Align: text.AlignLeft,
 	},
 }
 
+// addressFlags are the member flags that make up a postal address.
+var addressFlags = []string{"recipient", "line", "locality", "region", "postal-code", "country", "from", "source"}
+
 func init() {
 	rootCmd.AddCommand(NewMemberCmd())
 }
 
-// NewMemberCmd creates a member command.
+// NewMemberCmd creates a member command with its subcommands.
 func NewMemberCmd() *cobra.Command {
 	// cmd represents the member command
 	cmd := &cobra.Command{
-		Use:     "member",
-		Short:   "Tracks penpal information",
-		Long:    memberCommandLongDesc,
-		Example: `ogma member --number=1234 --name="John Smith" --address="123 Fake St, Fakeville, FS 12345"`,
-		Run:     RunMemberCmd,
+		Use:   "member",
+		Short: "Tracks penpal information",
+		Long:  memberCommandLongDesc,
+	}
+
+	cmd.PersistentFlags().BoolP("pretty", "p", false, "Show prettier results.")
+
+	cmd.AddCommand(
+		NewMemberAddCmd(),
+		NewMemberShowCmd(),
+		NewMemberEditCmd(),
+		NewMemberListCmd(),
+		NewMemberRemoveCmd(),
+	)
+
+	return cmd
+}
+
+// NewMemberAddCmd creates a member add command.
+func NewMemberAddCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "add <number>",
+		Short:   "Add a member",
+		Example: `ogma member add 1234 -n "John Smith" -l "123 Fake St" --locality=Springfield --region=IL --postal-code=62701`,
+		Args:    memberNumberArg,
+		Run:     RunMemberAddCmd,
+	}
+
+	cmd.Flags().StringP("name", "n", "", "Member name.")
+	addDueFlags(cmd)
+	addAddressFlags(cmd)
+
+	return cmd
+}
+
+// NewMemberShowCmd creates a member show command.
+func NewMemberShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "show <number>",
+		Short:   "Show a member",
+		Example: "ogma member show 1234A --envelope",
+		Args:    memberNumberArg,
+		Run:     RunMemberShowCmd,
+	}
+
+	cmd.Flags().BoolP("envelope", "e", false, "Only show the address, ready to write on an envelope.")
+
+	return cmd
+}
+
+// NewMemberEditCmd creates a member edit command.
+func NewMemberEditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "edit <number>",
+		Short:   "Change a member",
+		Long:    "Changes the stored information of a member. Only the given flags are changed.",
+		Example: `ogma member edit 1234 --line="42 Real Rd" --postal-code=62702`,
+		Args:    memberNumberArg,
+		Run:     RunMemberEditCmd,
 	}
 
-	cmd.Flags().IntP("number", "i", 0, "Member number.")
+	cmd.Flags().StringP("number", "i", "", "New member number, with extension letter if it has one.")
 	cmd.Flags().StringP("name", "n", "", "Member name.")
-	cmd.Flags().StringP("address", "a", "", "Mailing address.")
+	addDueFlags(cmd)
+	addAddressFlags(cmd)
 
 	return cmd
 }
 
-// RunMemberCmd implements functionality of a mail command.
-func RunMemberCmd(cmd *cobra.Command, args []string) {
-	m, err := memberFromArgs(cmd)
+// NewMemberListCmd creates a member list command.
+func NewMemberListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "List all members",
+		Example: "ogma member list",
+		Args:    cobra.NoArgs,
+		Run:     RunMemberListCmd,
+	}
+}
+
+// NewMemberRemoveCmd creates a member remove command.
+func NewMemberRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "rm <number>",
+		Aliases: []string{"remove"},
+		Short:   "Remove a member",
+		Example: "ogma member rm 1234A",
+		Args:    memberNumberArg,
+		Run:     RunMemberRemoveCmd,
+	}
+}
+
+// addDueFlags adds the flags for a member's due thresholds to a member command.
+func addDueFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("reply-days", 0, "Days to answer this member's letters before a reply is overdue. (0 uses the configuration)")
+	cmd.Flags().Int("quiet-days", 0, "Days to wait for an answer before this member has gone quiet. (0 uses the configuration)")
+}
+
+// addAddressFlags adds the postal address flags to a member command.
+func addAddressFlags(cmd *cobra.Command) {
+	cmd.Flags().String("recipient", "", "Name on the envelope, if it isn't the member name.")
+	cmd.Flags().StringArrayP("line", "l", []string{}, "Street address line. Repeat for each line.")
+	cmd.Flags().String("locality", "", "City, town, or post town.")
+	cmd.Flags().String("region", "", "State, province, or county.")
+	cmd.Flags().String("postal-code", "", "Postal or ZIP code.")
+	cmd.Flags().String("country", "", "Two letter country code. (default is the 'defaults.country' setting)")
+	cmd.Flags().String("from", "", "Date the address is used from, as 'yyyy-mm-dd'. (default today)")
+	cmd.Flags().String("source", "", "Where the address came from. 'L' prefix for listing entry, 'M' prefix for mail")
+}
+
+// memberNumberArg requires a single valid member number argument.
+func memberNumberArg(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("requires a single member number")
+	}
+
+	if _, err := member.Parse(args[0]); err != nil {
+		return fmt.Errorf("member number: %w", err)
+	}
+
+	return nil
+}
+
+// RunMemberAddCmd implements functionality of a member add command.
+func RunMemberAddCmd(cmd *cobra.Command, args []string) {
+	m, err := memberFromArgs(cmd, args)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"command": cmd.Name(),
@@ -98,7 +283,7 @@ func RunMemberCmd(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	dsManager, err := datastore.New(viper.GetString("datastore.filename"))
+	dsManager, err := datastore.New(viper.GetString(DatastoreFilenameKey))
 	if err != nil {
 		log.WithFields(log.Fields{
 			"command": cmd.Name(),
@@ -108,8 +293,7 @@ func RunMemberCmd(cmd *cobra.Command, args []string) {
 	}
 	defer dsManager.Stop()
 
-	err = dsManager.Save(&m)
-	if err != nil {
+	if err = saveMember(dsManager, &m); err != nil {
 		log.WithFields(log.Fields{
 			"command": cmd.Name(),
 		}).Error("unable to save member info: ", err)
@@ -118,85 +302,443 @@ func RunMemberCmd(cmd *cobra.Command, args []string) {
 	}
 
 	log.WithFields(log.Fields{
-		"number":  m.Number,
+		"number":  m.Number.String(),
 		"name":    m.Name,
-		"address": m.Address,
+		"address": m.Address().String(),
 	}).Info("added member info")
 
-	cmd.Printf("Added member info.")
+	cmd.Printf("Added member %s.\n", m.Number)
 }
 
-// memberFromArgs creates a new member object from command arguments.
-func memberFromArgs(cmd *cobra.Command) (Member, error) {
-	i, err := cmd.Flags().GetInt("member")
+// RunMemberShowCmd implements functionality of a member show command.
+func RunMemberShowCmd(cmd *cobra.Command, args []string) {
+	// number is already validated by cobra
+	id, _ := member.Parse(args[0])
+
+	r, err := resultRenderer(cmd)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"command": cmd.Name(),
-			"args":    cmd.Args,
-		}).Error("failed to get member number argument")
-		return Member{}, fmt.Errorf("failed to get member number argument: %w", err)
+		return
 	}
 
-	n, err := cmd.Flags().GetString("name")
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
 	if err != nil {
-		log.WithFields(log.Fields{
-			"command": cmd.Name(),
-			"args":    cmd.Args,
-		}).Error("failed to get name argument")
-		return Member{}, fmt.Errorf("failed to get member name argument: %w", err)
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
 	}
+	defer dsManager.Stop()
 
-	a, err := cmd.Flags().GetString("address")
+	m, err := findMember(dsManager, id)
+	if err != nil {
+		log.WithField("number", id.String()).Error("failed to find member: ", err)
+		cmd.PrintErrln("failed to find member: ", err)
+		return
+	}
+
+	if envelope, _ := cmd.Flags().GetBool("envelope"); envelope {
+		if m.Address().IsZero() {
+			cmd.PrintErrf("Member %s has no address.\n", m.Number)
+			return
+		}
+
+		cmd.Println(strings.Join(m.Envelope(viper.GetString(HomeCountryKey)), "\n"))
+
+		return
+	}
+
+	mails := []Mail{}
+
+	err = dsManager.Select(q.NewFieldMatcher("Receiver", member.Exact(m.Number))).Find(&mails)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		log.WithField("number", id.String()).Error("failed to read mail sent to member: ", err)
+		cmd.PrintErrln("failed to read mail sent to member: ", err)
+		return
+	}
+
+	info := MemberReport([]Member{m})
+	info.Records = m
+
+	sections := []render.Section{{Name: "member", Report: info}}
+
+	// other programs are always given the address history, even when there is none
+	if len(m.Addresses) > 0 || r.Format.Structured() {
+		sections = append(sections, render.Section{Name: "addresses", Report: AddressHistoryReport(m, mails)})
+	}
+
+	out, err := r.RenderSections(sections)
+
+	printOutput(cmd, out, err)
+}
+
+// RunMemberEditCmd implements functionality of a member edit command.
+func RunMemberEditCmd(cmd *cobra.Command, args []string) {
+	// number is already validated by cobra
+	id, _ := member.Parse(args[0])
+
+	dsManager, err := datastore.New(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	m, err := findMember(dsManager, id)
+	if err != nil {
+		log.WithField("number", id.String()).Error("failed to find member: ", err)
+		cmd.PrintErrln("failed to find member: ", err)
+		return
+	}
+
+	if err = applyMemberFlags(cmd, &m); err != nil {
+		log.WithField("number", id.String()).Error("invalid member info input: ", err)
+		cmd.PrintErrln("invalid member info input: ", err)
+		return
+	}
+
+	if err = saveMember(dsManager, &m); err != nil {
+		log.WithField("number", id.String()).Error("unable to save member info: ", err)
+		cmd.PrintErrln("Failed to save member info: ", err)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"number":  m.Number.String(),
+		"name":    m.Name,
+		"address": m.Address().String(),
+	}).Info("updated member info")
+
+	cmd.Printf("Updated member %s.\n", m.Number)
+}
+
+// RunMemberListCmd implements functionality of a member list command.
+func RunMemberListCmd(cmd *cobra.Command, args []string) {
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	mm := []Member{}
+	if err = dsManager.All(&mm); err != nil {
+		log.Error("failed to read members: ", err)
+		cmd.PrintErrln("failed to read members: ", err)
+		return
+	}
+
+	printReport(cmd, r, MemberReport(mm))
+}
+
+// RunMemberRemoveCmd implements functionality of a member rm command.
+func RunMemberRemoveCmd(cmd *cobra.Command, args []string) {
+	// number is already validated by cobra
+	id, _ := member.Parse(args[0])
+
+	dsManager, err := datastore.New(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	m, err := findMember(dsManager, id)
+	if err != nil {
+		log.WithField("number", id.String()).Error("failed to find member: ", err)
+		cmd.PrintErrln("failed to find member: ", err)
+		return
+	}
+
+	if err = dsManager.DeleteStruct(&m); err != nil {
+		log.WithField("number", id.String()).Error("failed to remove member: ", err)
+		cmd.PrintErrln("failed to remove member: ", err)
+		return
+	}
+
+	log.WithField("number", id.String()).Info("removed member info")
+
+	cmd.Printf("Removed member %s.\n", m.Number)
+}
+
+// memberFromArgs creates a new member object from command arguments.
+func memberFromArgs(cmd *cobra.Command, args []string) (Member, error) {
+	if len(args) != 1 {
+		return Member{}, errors.New("requires a single member number")
+	}
+
+	i, err := member.Parse(args[0])
 	if err != nil {
 		log.WithFields(log.Fields{
 			"command": cmd.Name(),
-			"args":    cmd.Args,
-		}).Error("failed to get member address argument")
-		return Member{}, fmt.Errorf("failed to get member address argument: %w", err)
+			"number":  args[0],
+		}).Error("failed to parse member number argument")
+		return Member{}, fmt.Errorf("member number: %w", err)
+	}
+
+	m := Member{Number: i}
+
+	if err = applyMemberFlags(cmd, &m); err != nil {
+		return Member{}, err
+	}
+
+	return m, nil
+}
+
+// applyMemberFlags copies the member flags that were set on the command line into m.
+func applyMemberFlags(cmd *cobra.Command, m *Member) error {
+	if f := cmd.Flags().Lookup("number"); f != nil && f.Changed {
+		i, err := member.Parse(f.Value.String())
+		if err != nil {
+			return fmt.Errorf("member number: %w", err)
+		}
+
+		m.Number = i
+	}
+
+	if f := cmd.Flags().Lookup("name"); f != nil && f.Changed {
+		m.Name = f.Value.String()
+	}
+
+	for flag, days := range map[string]*int{"reply-days": &m.ReplyDays, "quiet-days": &m.QuietDays} {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+
+		n, err := cmd.Flags().GetInt(flag)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: must be a number of days: %s", flag, cmd.Flags().Lookup(flag).Value)
+		}
+
+		*days = n
+	}
+
+	return applyAddressFlags(cmd, m)
+}
+
+// applyAddressFlags adds an address to the member's history, starting from the current address and changing the
+// parts set by flags. An address without a country is in the configured home country. A new address from the
+// same date as an earlier one replaces it. The history is left alone when no address flag is set.
+func applyAddressFlags(cmd *cobra.Command, m *Member) error {
+	changed := false
+
+	for _, flag := range addressFlags {
+		if f := cmd.Flags().Lookup(flag); f != nil && f.Changed {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	a := m.Address()
+
+	if lines, err := cmd.Flags().GetStringArray("line"); err == nil && cmd.Flags().Changed("line") {
+		a.Lines = lines
+	}
+
+	for flag, field := range map[string]*string{
+		"recipient":   &a.Recipient,
+		"locality":    &a.Locality,
+		"region":      &a.Region,
+		"postal-code": &a.PostalCode,
+		"country":     &a.Country,
+	} {
+		if f := cmd.Flags().Lookup(flag); f != nil && f.Changed {
+			*field = f.Value.String()
+		}
 	}
 
-	m := Member{
-		Number:  i,
-		Name:    n,
-		Address: a,
+	if a.Country == "" {
+		a.Country = viper.GetString(HomeCountryKey)
+	}
+
+	e := address.Entry{Address: a.Normalize()}
+
+	if err := e.Address.Validate(); err != nil {
+		return err
+	}
+
+	e.From, _ = cmd.Flags().GetString("from")
+	if e.From == "" {
+		e.From = time.Now().Format(DateFormat)
+	}
+
+	from, err := ValidateDate(e.From)
+	if err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+
+	e.From = from
+	e.Source, _ = cmd.Flags().GetString("source")
+
+	m.Addresses = m.Addresses.Add(e)
+
+	return nil
+}
+
+// findMember returns the member stored with exactly the given number.
+func findMember(ds storm.Finder, id member.ID) (Member, error) {
+	var m Member
+
+	err := ds.One("Number", id, &m)
+	if errors.Is(err, storm.ErrNotFound) {
+		return Member{}, fmt.Errorf("%w: %s", ErrMemberNotFound, id)
+	}
+
+	if err != nil {
+		return Member{}, fmt.Errorf("error reading member %s: %w", id, err)
 	}
 
 	return m, nil
 }
 
+// saveMember saves a new or changed member. Member numbers are unique.
+func saveMember(ds datastore.Saver, m *Member) error {
+	err := ds.Save(m)
+	if errors.Is(err, storm.ErrAlreadyExists) {
+		return fmt.Errorf("%w: %s", ErrMemberExists, m.Number)
+	}
+
+	return err
+}
+
+// searchMembers returns the stored members named by the member terms of a query. Excluded members and ranges
+// are ignored, since the panel only describes who was searched for.
+func searchMembers(terms []query.Term, ds storm.Finder) ([]Member, error) {
+	mm := []Member{}
+
+	for _, t := range terms {
+		isMember := t.Field == "member" || (t.Field == "" && member.IsID(t.Value))
+		if !isMember || t.Negate || t.IsRange {
+			continue
+		}
+
+		t.Field = "member"
+
+		m, err := query.Build([]query.Term{t}, MemberQuerySchema)
+		if err != nil {
+			return nil, err
+		}
+
+		found := []Member{}
+
+		err = ds.Select(m).Find(&found)
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return nil, fmt.Errorf("failure to query database for members: %w", err)
+		}
+
+		mm = append(mm, found...)
+	}
+
+	return mm, nil
+}
+
+// prettyFlag reads the 'pretty' flag, defaulting to plain output.
+func prettyFlag(cmd *cobra.Command) bool {
+	p, err := cmd.Flags().GetBool("pretty")
+	if err != nil {
+		log.Error("unable to read 'pretty' flag: ", err)
+		return false
+	}
+
+	return p
+}
+
 // RenderMember returns a pretty formatted member info as table.
 func RenderMember(mm []Member, p bool) string {
-	// empty string if there no information to render
-	if len(mm) == 0 {
-		return "No member information found."
-	}
+	log.WithFields(log.Fields{
+		"is_pretty": p,
+	}).Debug("set rendering style")
 
-	mt := table.NewWriter()
+	return MemberReport(mm).Table(p)
+}
 
-	mt.SetTitle("Member Information:")
+// MemberReport returns members as a report, by member number.
+func MemberReport(mm []Member) render.Report {
+	mm = append([]Member{}, mm...)
+	sort.SliceStable(mm, func(i, j int) bool {
+		if mm[i].Number.Number != mm[j].Number.Number {
+			return mm[i].Number.Number < mm[j].Number.Number
+		}
 
-	mt.AppendHeader(table.Row{
-		"Number",
-		"Name",
-		"Address",
+		return mm[i].Number.Extension < mm[j].Number.Extension
 	})
 
+	rep := render.Report{
+		Title: "Member Information:",
+		Header: table.Row{
+			"Number",
+			"Name",
+			"Address",
+		},
+		Columns: memberColumnConfigs,
+		Records: mm,
+		Empty:   "No member information found.",
+	}
+
+	home := viper.GetString(HomeCountryKey)
+
 	for _, m := range mm {
-		mt.AppendRow([]interface{}{
-			m.Number,
+		rep.Rows = append(rep.Rows, table.Row{
+			m.Number.String(),
 			m.Name,
-			m.Address,
+			strings.Join(m.Address().Format(home), "\n"),
 		})
 	}
 
-	mt.SetColumnConfigs(memberColumnConfigs)
+	return rep
+}
+
+// An AddressUse is an address a member has used, with the mail sent to it.
+type AddressUse struct {
+	address.Entry
+	Mail []string `json:"mail"`
+}
 
-	if p {
-		mt.SetStyle(table.StyleColoredBright)
+// AddressHistoryReport returns a member's addresses as a report, oldest first, with the mail sent to each one.
+func AddressHistoryReport(m Member, mm []Mail) render.Report {
+	rep := render.Report{
+		Title: "Address History:",
+		Header: table.Row{
+			"From",
+			"Address",
+			"Source",
+			"Mail",
+		},
 	}
-	log.WithFields(log.Fields{
-		"is_pretty": p,
-	}).Debug("set rendering style")
 
-	return mt.Render()
+	home := viper.GetString(HomeCountryKey)
+	uu := make([]AddressUse, len(m.Addresses))
+
+	for i, e := range m.Addresses {
+		refs := []string{}
+
+		for _, mail := range mm {
+			if mail.SentTo != nil && mail.SentTo.Equal(e.Address) {
+				refs = append(refs, mail.Ref)
+			}
+		}
+
+		sort.Strings(refs)
+
+		uu[i] = AddressUse{Entry: e, Mail: refs}
+		rep.Rows = append(rep.Rows, table.Row{
+			e.From,
+			strings.Join(e.Address.Format(home), "\n"),
+			e.Source,
+			strings.Join(refs, "\n"),
+		})
+	}
+
+	rep.Records = uu
+
+	return rep
 }
@@ -0,0 +1,320 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/address"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+func TestNewMemberCmd(t *testing.T) {
+	got := cmd.NewMemberCmd()
+
+	assert.Equal(t, "member", got.Name())
+	assert.Equal(t, "Tracks penpal information", got.Short)
+	assert.False(t, got.Runnable())
+
+	names := []string{}
+	for _, c := range got.Commands() {
+		names = append(names, c.Name())
+	}
+
+	assert.ElementsMatch(t, []string{"add", "show", "edit", "list", "rm"}, names)
+}
+
+func TestRunMemberCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.DatastoreFilenameKey, dsFile)
+	viper.Set(cmd.HomeCountryKey, "US")
+
+	// steps run in order against the same datastore
+	tests := []struct {
+		name      string
+		args      []string
+		assertion assert.ErrorAssertionFunc
+		want      string
+		notWant   string
+	}{
+		{
+			name:      "add requires a number",
+			args:      []string{"add"},
+			assertion: assert.Error,
+			want:      "Error: requires a single member number",
+		},
+		{
+			name:      "add invalid number",
+			args:      []string{"add", "12x4"},
+			assertion: assert.Error,
+			want:      "Error: member number: invalid member identifier",
+		},
+		{
+			name:      "add invalid address",
+			args:      []string{"add", "1234", "--name", "John Smith", "-l", "123 Fake St", "--postal-code", "6270"},
+			assertion: assert.NoError,
+			want:      "invalid member info input:  invalid address: United States address needs a locality",
+		},
+		{
+			name: "add invalid postal code",
+			args: []string{
+				"add", "1234", "--name", "John Smith", "-l", "123 Fake St", "--locality", "Springfield",
+				"--region", "IL", "--postal-code", "6270",
+			},
+			assertion: assert.NoError,
+			want:      `invalid member info input:  invalid address: "6270" is not a United States postal code`,
+		},
+		{
+			name: "add",
+			args: []string{
+				"add", "1234", "--name", "John Smith", "-l", "123 Fake St", "--locality", "Springfield",
+				"--region", "il", "--postal-code", "62701",
+			},
+			assertion: assert.NoError,
+			want:      "Added member 1234.\n",
+		},
+		{
+			name:      "add extension",
+			args:      []string{"add", "1234b", "-n", "Jane Smith"},
+			assertion: assert.NoError,
+			want:      "Added member 1234B.\n",
+		},
+		{
+			name:      "add duplicate",
+			args:      []string{"add", "1234", "--name", "Someone Else"},
+			assertion: assert.NoError,
+			want:      "Failed to save member info:  member already exists: 1234",
+		},
+		{
+			name:      "show",
+			args:      []string{"show", "1234B"},
+			assertion: assert.NoError,
+			want:      "|  1234B | Jane Smith |         |",
+			notWant:   "John Smith",
+		},
+		{
+			name:      "show envelope",
+			args:      []string{"show", "1234", "--envelope"},
+			assertion: assert.NoError,
+			want:      "John Smith\n123 Fake St\nSPRINGFIELD, IL 62701\n",
+		},
+		{
+			name:      "show envelope without address",
+			args:      []string{"show", "1234B", "-e"},
+			assertion: assert.NoError,
+			want:      "Member 1234B has no address.\n",
+		},
+		{
+			name:      "show missing",
+			args:      []string{"show", "42"},
+			assertion: assert.NoError,
+			want:      "failed to find member:  member not found: 42",
+		},
+		{
+			name:      "edit only changes given fields",
+			args:      []string{"edit", "1234", "--line", "42 Real Rd", "--postal-code", "62702"},
+			assertion: assert.NoError,
+			want:      "Updated member 1234.\n",
+		},
+		{
+			name:      "edit due thresholds",
+			args:      []string{"edit", "1234", "--reply-days", "14", "--quiet-days", "60"},
+			assertion: assert.NoError,
+			want:      "Updated member 1234.\n",
+		},
+		{
+			name:      "invalid due threshold",
+			args:      []string{"edit", "1234", "--quiet-days", "-3"},
+			assertion: assert.NoError,
+			want:      "invalid member info input:  quiet-days: must be a number of days: -3",
+		},
+		{
+			name:      "edit abroad",
+			args:      []string{"edit", "1234B", "-l", "Heidestraße 17", "--locality", "Köln", "--postal-code", "51147", "--country", "de"},
+			assertion: assert.NoError,
+			want:      "Updated member 1234B.\n",
+		},
+		{
+			name:      "show envelope abroad",
+			args:      []string{"show", "1234B", "--envelope"},
+			assertion: assert.NoError,
+			want:      "Jane Smith\nHeidestraße 17\n51147 Köln\nGERMANY\n",
+		},
+		{
+			name:      "edit to existing number",
+			args:      []string{"edit", "1234", "--number", "1234B"},
+			assertion: assert.NoError,
+			want:      "Failed to save member info:  member already exists: 1234B",
+		},
+		{
+			name:      "edit number",
+			args:      []string{"edit", "1234B", "--number", "1234C"},
+			assertion: assert.NoError,
+			want:      "Updated member 1234C.\n",
+		},
+		{
+			name:      "list",
+			args:      []string{"list"},
+			assertion: assert.NoError,
+			want:      "|  1234  | John Smith | 42 Real Rd            |\n|        |            | SPRINGFIELD, IL 62702 |\n|  1234C | Jane Smith | Heidestraße 17        |\n|        |            | 51147 Köln            |\n|        |            | GERMANY               |",
+		},
+		{
+			name:      "remove",
+			args:      []string{"rm", "1234C"},
+			assertion: assert.NoError,
+			want:      "Removed member 1234C.\n",
+		},
+		{
+			name:      "remove missing",
+			args:      []string{"rm", "1234C"},
+			assertion: assert.NoError,
+			want:      "failed to find member:  member not found: 1234C",
+		},
+		{
+			name:      "list after remove",
+			args:      []string{"list"},
+			assertion: assert.NoError,
+			want:      "John Smith",
+			notWant:   "Jane Smith",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewMemberCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			tt.assertion(t, c.Execute())
+
+			assert.Contains(t, b.String(), tt.want)
+
+			if tt.notWant != "" {
+				assert.NotContains(t, b.String(), tt.notWant)
+			}
+		})
+	}
+}
+
+func TestMemberAddressHistory(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.DatastoreFilenameKey, dsFile)
+	viper.Set(cmd.HomeCountryKey, "US")
+
+	run := func(c *cobra.Command, args ...string) string {
+		t.Helper()
+
+		b := bytes.NewBufferString("")
+		c.SetOut(b)
+		c.SetErr(b)
+		c.SetArgs(args)
+		require.NoError(t, c.Execute())
+
+		return b.String()
+	}
+
+	assert.Equal(t, "Added member 4321.\n", run(cmd.NewMemberCmd(),
+		"add", "4321", "-n", "Ann Lee", "-l", "1 Old Rd", "--locality", "Dayton", "--region", "OH",
+		"--postal-code", "45402", "--from", "2021-01-01", "--source", "L1"))
+
+	assert.Equal(t, "Added mail. Reference: 0c3baf\n", run(cmd.NewMailCmd(), "-s1234", "-r4321", "-d2021-06-01"))
+
+	assert.Equal(t, "Updated member 4321.\n", run(cmd.NewMemberCmd(),
+		"edit", "4321", "-l", "2 New St", "--from", "2022-03-01", "--source", "M0c3baf"))
+
+	assert.Equal(t, "invalid member info input:  from: date format must be 'yyyy-mm-dd': "+
+		"parsing time \"March\" as \"2006-01-02\": cannot parse \"March\" as \"2006\"\n",
+		run(cmd.NewMemberCmd(), "edit", "4321", "--from", "March"))
+
+	assert.Equal(t, "Added mail. Reference: 7bfe69\n", run(cmd.NewMailCmd(), "-s1234", "-r4321", "-d2022-04-01"))
+
+	// letters sent before the member had an address have none recorded
+	assert.Equal(t, "Added mail. Reference: 1b7a31\n", run(cmd.NewMailCmd(), "-s1234", "-r4321", "-d2020-12-31"))
+
+	got := run(cmd.NewMemberCmd(), "show", "4321")
+	assert.Contains(t, got, "|  4321  | Ann Lee | 2 New St         |\n|        |         | DAYTON, OH 45402 |\n")
+	assert.Contains(t, got, "+\n\n+--------------------------------------------------+\n"+
+		"| Address History:                                 |\n"+
+		"+------------+------------------+---------+--------+\n"+
+		"| FROM       | ADDRESS          | SOURCE  | MAIL   |\n"+
+		"+------------+------------------+---------+--------+\n"+
+		"| 2021-01-01 | 1 Old Rd         | L1      | 0c3baf |\n"+
+		"|            | DAYTON, OH 45402 |         |        |\n"+
+		"| 2022-03-01 | 2 New St         | M0c3baf | 7bfe69 |\n"+
+		"|            | DAYTON, OH 45402 |         |        |\n")
+	assert.NotContains(t, got, "1b7a31")
+
+	viper.Set(cmd.OutputKey, "json")
+	got = run(cmd.NewMemberCmd(), "show", "4321")
+	viper.Set(cmd.OutputKey, "")
+	assert.Contains(t, got, "{\n  \"member\": {\n    \"ID\": ")
+	assert.Contains(t, got, "  \"addresses\": [\n    {\n      \"address\": {\n")
+	assert.Contains(t, got, "      \"from\": \"2022-03-01\",\n      \"source\": \"M0c3baf\",\n      \"mail\": [\n        \"7bfe69\"\n      ]\n")
+}
+
+func TestMemberJSON(t *testing.T) {
+	var got cmd.Member
+
+	// members written before addresses had a history
+	require.NoError(t, json.Unmarshal([]byte(`{"ID":1,"number":"1234","name":"John Smith","address":{"lines":["1 Main St"]}}`), &got))
+	assert.Equal(t, cmd.Member{
+		ID:        1,
+		Number:    member.ID{Number: 1234},
+		Name:      "John Smith",
+		Addresses: address.History{{Address: address.Address{Lines: []string{"1 Main St"}}}},
+	}, got)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"ID":2,"number":1234,"name":"John Smith","address":"1 Main St\nApt 2"}`), &got))
+	assert.Equal(t, address.Address{Lines: []string{"1 Main St", "Apt 2"}}, got.Address())
+
+	var none cmd.Member
+	require.NoError(t, json.Unmarshal([]byte(`{"ID":3,"number":"1234","name":"John Smith","address":""}`), &none))
+	assert.Empty(t, none.Addresses)
+
+	data, err := json.Marshal(got)
+	require.NoError(t, err)
+	assert.Equal(t, `{"ID":2,"number":"1234","name":"John Smith","addresses":[{"address":{"lines":["1 Main St","Apt 2"]}}]}`, string(data))
+}
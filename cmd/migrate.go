@@ -0,0 +1,827 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/asdine/storm/v3/index"
+	"github.com/jedib0t/go-pretty/v6/table"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+const migrateCommandLongDesc = "The migrate command upgrades the datastore schema to the version used by this application.\n" +
+	"Migrations change stored records, so a datastore with pending migrations isn't opened by other commands until\n" +
+	"it is upgraded. The datastore is backed up before it is changed.\n\n" +
+	"Use '--dry-run' to report what each pending migration would change without writing anything."
+
+func init() {
+	rootCmd.AddCommand(NewMigrateCmd())
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     1,
+		Description: "baseline schema for listings, mail, and members",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			// the records as they were first stored, so the migration doesn't depend on the current structs
+			type Listing struct {
+				ID                  int    `storm:"id,increment"`
+				IndexedCategory     string `storm:"index" json:"category"`
+				IndexedMemberNumber int    `storm:"index" json:"member"`
+			}
+
+			type Mail struct {
+				ID int `storm:"id,increment"`
+			}
+
+			type Member struct {
+				ID int `storm:"id,increment"`
+			}
+
+			for _, data := range []interface{}{&Listing{}, &Mail{}, &Member{}} {
+				if err := tx.Init(data); err != nil {
+					return nil, fmt.Errorf("error initializing buckets: %w", err)
+				}
+			}
+
+			return datastore.Changes{}, nil
+		},
+	})
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     2,
+		Description: "rename member fields to number, name, and address",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			n, err := tx.RewriteRecords("Member", renameFields(map[string]string{
+				"reference": "number",
+				"sender":    "name",
+				"receiver":  "address",
+			}))
+
+			return datastore.Changes{"Member": n}, err
+		},
+	})
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     3,
+		Description: "build full-text index of listing text",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			// read raw records so the migration doesn't depend on the current listing struct
+			type indexed struct {
+				ID   int
+				Text string
+			}
+
+			ll := []indexed{}
+
+			_, err := tx.RewriteRecords("Listing", func(r map[string]json.RawMessage) (bool, error) {
+				var l indexed
+
+				if err := json.Unmarshal(r["ID"], &l.ID); err != nil {
+					return false, fmt.Errorf("error reading listing id: %w", err)
+				}
+
+				if t, ok := r["text"]; ok {
+					if err := json.Unmarshal(t, &l.Text); err != nil {
+						return false, fmt.Errorf("error reading listing id=%d text: %w", l.ID, err)
+					}
+				}
+
+				ll = append(ll, l)
+
+				return false, nil
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			ix := lstg.TextIndex(tx)
+			if err = ix.Clear(); err != nil {
+				return nil, err
+			}
+
+			for _, l := range ll {
+				if err = ix.Add(l.ID, l.Text); err != nil {
+					return nil, fmt.Errorf("error indexing listing id=%d: %w", l.ID, err)
+				}
+			}
+
+			return datastore.Changes{"Listing": len(ll)}, nil
+		},
+	})
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     4,
+		Description: "store mail and member numbers as member identifiers",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			mails, err := tx.RewriteRecords("Mail", numbersToMemberIDs("sender", "receiver"))
+			if err != nil {
+				return nil, err
+			}
+
+			members, err := tx.RewriteRecords("Member", numbersToMemberIDs("number"))
+
+			return datastore.Changes{"Mail": mails, "Member": members}, err
+		},
+	})
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     5,
+		Description: "merge duplicate members and index member numbers",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			n, err := uniqueMemberNumbers(tx)
+
+			return datastore.Changes{"Member": n}, err
+		},
+	})
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     6,
+		Description: "store member addresses as structured addresses",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			n, err := tx.RewriteRecords("Member", structuredAddress)
+
+			return datastore.Changes{"Member": n}, err
+		},
+	})
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     7,
+		Description: "start member address histories",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			n, err := tx.RewriteRecords("Member", addressHistory)
+
+			return datastore.Changes{"Member": n}, err
+		},
+	})
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     8,
+		Description: "store mail links as typed references",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			n, err := tx.RewriteRecords("Mail", typedLink)
+
+			return datastore.Changes{"Mail": n}, err
+		},
+	})
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     9,
+		Description: "index mail references",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			n, err := indexMailRefs(tx)
+
+			return datastore.Changes{"Mail": n}, err
+		},
+	})
+
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     10,
+		Description: "catalogue issues from listings",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			n, err := catalogueIssues(tx)
+
+			return datastore.Changes{"Issue": n}, err
+		},
+	})
+}
+
+// catalogueIssues adds an issue for every issue number used by a listing, with the volume, year, and season of its
+// oldest listing. Issues with listings are owned, since the listings were copied from them.
+func catalogueIssues(tx *datastore.MigrationTx) (int, error) {
+	// the issue record as it was first stored, so the migration doesn't depend on the current issue struct
+	type Issue struct {
+		ID     int    `storm:"id,increment"`
+		Number int    `storm:"unique" json:"number"`
+		Volume int    `json:"volume"`
+		Year   int    `json:"year"`
+		Season string `json:"season"`
+		Owned  bool   `json:"owned"`
+	}
+
+	ii := []Issue{}
+	seen := map[int]int{}
+
+	_, err := tx.RewriteRecords("Listing", func(r map[string]json.RawMessage) (bool, error) {
+		var l struct {
+			Volume int    `json:"volume"`
+			Number int    `json:"issue"`
+			Year   int    `json:"year"`
+			Season string `json:"season"`
+		}
+
+		raw, err := json.Marshal(r)
+		if err != nil {
+			return false, err
+		}
+
+		if err = json.Unmarshal(raw, &l); err != nil {
+			return false, fmt.Errorf("error reading listing: %w", err)
+		}
+
+		if l.Number <= 0 {
+			return false, nil
+		}
+
+		if i, ok := seen[l.Number]; ok {
+			is := ii[i]
+			if is.Volume != l.Volume || is.Year != l.Year || !strings.EqualFold(is.Season, l.Season) {
+				log.WithField("issue", l.Number).Warn("listing doesn't match the issue catalogued from earlier listings")
+			}
+
+			return false, nil
+		}
+
+		seen[l.Number] = len(ii)
+		ii = append(ii, Issue{Number: l.Number, Volume: l.Volume, Year: l.Year, Season: l.Season, Owned: true})
+
+		return false, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range ii {
+		if err = tx.Save(&ii[i]); err != nil {
+			return 0, fmt.Errorf("error adding issue %d: %w", ii[i].Number, err)
+		}
+	}
+
+	return len(ii), nil
+}
+
+// mailRefIndex is the storm index bucket for unique mail references.
+const mailRefIndex = "__storm_index_Ref"
+
+// indexMailRefs adds mail references to a unique index. References may already be written on letters, so
+// duplicates aren't changed: only the oldest mail with a reference is indexed, and 'ogma check refs' reports the
+// rest.
+func indexMailRefs(tx *datastore.MigrationTx) (int, error) {
+	b := tx.Tx.Bucket([]byte("Mail"))
+	if b == nil {
+		return 0, nil
+	}
+
+	type record struct {
+		key []byte
+		ref string
+	}
+
+	rr := []record{}
+
+	err := b.ForEach(func(k, v []byte) error {
+		// nested buckets (indexes and storm metadata) have no value
+		if v == nil {
+			return nil
+		}
+
+		var fields struct {
+			Ref string `json:"reference"`
+		}
+
+		if err := json.Unmarshal(v, &fields); err != nil {
+			return fmt.Errorf("error decoding mail %x: %w", k, err)
+		}
+
+		if fields.Ref != "" {
+			rr = append(rr, record{key: append([]byte{}, k...), ref: fields.Ref})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err = b.DeleteBucket([]byte(mailRefIndex)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return 0, fmt.Errorf("error clearing mail reference index: %w", err)
+	}
+
+	idx, err := index.NewUniqueIndex(b, []byte(mailRefIndex))
+	if err != nil {
+		return 0, fmt.Errorf("error creating mail reference index: %w", err)
+	}
+
+	indexed := 0
+
+	// keys are big-endian IDs, so records arrive oldest first
+	for _, r := range rr {
+		err = idx.Add([]byte(r.ref), r.key)
+		if errors.Is(err, index.ErrAlreadyExists) {
+			log.WithField("ref", r.ref).Warn("duplicate mail reference left out of index")
+			continue
+		}
+
+		if err != nil {
+			return indexed, fmt.Errorf("error indexing mail %s: %w", r.ref, err)
+		}
+
+		indexed++
+	}
+
+	return indexed, nil
+}
+
+// typedLink replaces a mail link written as text, like "L42", with the record it points to. Text that isn't a
+// link is kept so it can be found and fixed.
+func typedLink(r map[string]json.RawMessage) (bool, error) {
+	var s string
+	if err := json.Unmarshal(r["link"], &s); err != nil {
+		return false, nil
+	}
+
+	var ref map[string]interface{}
+
+	l, err := link.Parse(s)
+
+	switch {
+	case err != nil:
+		ref = map[string]interface{}{"text": s}
+	case l.Kind() == link.Listing:
+		ref = map[string]interface{}{"listing": l.Listing}
+	case l.Kind() == link.Mail:
+		ref = map[string]interface{}{"mail": l.Mail}
+	default:
+		delete(r, "link")
+
+		return true, nil
+	}
+
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return false, fmt.Errorf("error encoding link: %w", err)
+	}
+
+	r["link"] = data
+
+	return true, nil
+}
+
+// addressHistory moves a member's address into the last entry of their address history, after any former
+// addresses kept when duplicate records were merged. The dates the addresses were first used aren't known, so the
+// entries have none.
+func addressHistory(r map[string]json.RawMessage) (bool, error) {
+	a, hasAddress := r["address"]
+	former, hasFormer := r["former_addresses"]
+
+	if !hasAddress && !hasFormer {
+		return false, nil
+	}
+
+	delete(r, "address")
+	delete(r, "former_addresses")
+
+	entries := []map[string]json.RawMessage{}
+
+	if hasFormer {
+		aa := []json.RawMessage{}
+		if err := json.Unmarshal(former, &aa); err != nil {
+			return false, fmt.Errorf("error reading former addresses: %w", err)
+		}
+
+		for _, fa := range aa {
+			entries = append(entries, map[string]json.RawMessage{
+				"address": fa,
+				"source":  json.RawMessage(`"merged member record"`),
+			})
+		}
+	}
+
+	if hasAddress && string(a) != "null" && string(a) != "{}" {
+		entries = append(entries, map[string]json.RawMessage{"address": a})
+	}
+
+	if len(entries) == 0 {
+		return true, nil
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return false, fmt.Errorf("error encoding address history: %w", err)
+	}
+
+	r["addresses"] = data
+
+	return true, nil
+}
+
+// structuredAddress moves a free-form member address, and any former addresses, into the street lines of a
+// structured address. The other parts are left blank since they can't be picked out of the text reliably.
+func structuredAddress(r map[string]json.RawMessage) (bool, error) {
+	changed := false
+
+	var s string
+	if err := json.Unmarshal(r["address"], &s); err == nil {
+		a, err := addressLines(s)
+		if err != nil {
+			return false, err
+		}
+
+		if a == nil {
+			delete(r, "address")
+		} else {
+			r["address"] = a
+		}
+
+		changed = true
+	}
+
+	var ss []string
+	if err := json.Unmarshal(r["former_addresses"], &ss); err == nil && ss != nil {
+		aa := []json.RawMessage{}
+
+		for _, s := range ss {
+			a, err := addressLines(s)
+			if err != nil {
+				return false, err
+			}
+
+			if a != nil {
+				aa = append(aa, a)
+			}
+		}
+
+		data, err := json.Marshal(aa)
+		if err != nil {
+			return false, fmt.Errorf("error encoding former addresses: %w", err)
+		}
+
+		r["former_addresses"] = data
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// addressLines returns free-form address text as the street lines of a structured address. It is nil when the
+// text has no lines.
+func addressLines(s string) (json.RawMessage, error) {
+	lines := []string{}
+
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(map[string][]string{"lines": lines})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding address: %w", err)
+	}
+
+	return data, nil
+}
+
+// memberNumberIndex is the storm index bucket for unique member numbers.
+const memberNumberIndex = "__storm_index_Number"
+
+// uniqueMemberNumbers merges members stored more than once under the same number and builds the unique index
+// on member numbers. Newer records win, with blank fields filled in from older ones, and the addresses of older
+// records are kept as former addresses for the address history. The merged member keeps the oldest ID. Each merge
+// is logged so it can be checked. It returns the number of records removed by merging.
+func uniqueMemberNumbers(tx *datastore.MigrationTx) (int, error) {
+	b := tx.Tx.Bucket([]byte("Member"))
+	if b == nil {
+		return 0, nil
+	}
+
+	type record struct {
+		key    []byte
+		fields map[string]json.RawMessage
+	}
+
+	groups := map[string][]record{}
+	order := []string{}
+
+	err := b.ForEach(func(k, v []byte) error {
+		// nested buckets (indexes and storm metadata) have no value
+		if v == nil {
+			return nil
+		}
+
+		r := record{key: append([]byte{}, k...), fields: map[string]json.RawMessage{}}
+		if err := json.Unmarshal(v, &r.fields); err != nil {
+			return fmt.Errorf("error decoding member %x: %w", k, err)
+		}
+
+		var id member.ID
+		if err := json.Unmarshal(r.fields["number"], &id); err != nil || id.IsZero() {
+			return nil
+		}
+
+		number, err := json.Marshal(id)
+		if err != nil {
+			return fmt.Errorf("error encoding member number %s: %w", id, err)
+		}
+
+		r.fields["number"] = number
+
+		if _, ok := groups[string(number)]; !ok {
+			order = append(order, string(number))
+		}
+
+		// keys are big-endian IDs, so records arrive oldest first
+		groups[string(number)] = append(groups[string(number)], r)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err = b.DeleteBucket([]byte(memberNumberIndex)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return 0, fmt.Errorf("error clearing member number index: %w", err)
+	}
+
+	idx, err := index.NewUniqueIndex(b, []byte(memberNumberIndex))
+	if err != nil {
+		return 0, fmt.Errorf("error creating member number index: %w", err)
+	}
+
+	removed := 0
+
+	for _, number := range order {
+		rr := groups[number]
+		kept := rr[0]
+
+		if len(rr) > 1 {
+			records := make([]map[string]json.RawMessage, len(rr))
+			for i, r := range rr {
+				records[i] = r.fields
+			}
+
+			merged, err := mergeMembers(records)
+			if err != nil {
+				return removed, fmt.Errorf("error merging member %s: %w", number, err)
+			}
+
+			data, err := json.Marshal(merged)
+			if err != nil {
+				return removed, fmt.Errorf("error encoding merged member %s: %w", number, err)
+			}
+
+			if err = b.Put(kept.key, data); err != nil {
+				return removed, fmt.Errorf("error saving merged member %s: %w", number, err)
+			}
+
+			for _, r := range rr[1:] {
+				if err = b.Delete(r.key); err != nil {
+					return removed, fmt.Errorf("error removing duplicate member %s: %w", number, err)
+				}
+
+				removed++
+			}
+		}
+
+		if err = idx.Add([]byte(number), kept.key); err != nil {
+			return removed, fmt.Errorf("error indexing member %s: %w", number, err)
+		}
+	}
+
+	return removed, nil
+}
+
+// mergeMembers merges records of the same member, oldest first. Newer values win and blank ones are filled in from
+// older records. Addresses the merged member doesn't keep are listed, oldest first, as its former addresses.
+func mergeMembers(records []map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	merged := map[string]json.RawMessage{}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		for field, v := range records[i] {
+			if cur, ok := merged[field]; !ok || blankField(cur) {
+				merged[field] = v
+			}
+		}
+	}
+
+	merged["ID"] = records[0]["ID"]
+
+	former := []json.RawMessage{}
+	seen := map[string]bool{string(merged["address"]): true}
+
+	for _, r := range records {
+		a, ok := r["address"]
+		if !ok || blankField(a) || seen[string(a)] {
+			continue
+		}
+
+		seen[string(a)] = true
+		former = append(former, a)
+	}
+
+	if len(former) > 0 {
+		data, err := json.Marshal(former)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding former addresses: %w", err)
+		}
+
+		merged["former_addresses"] = data
+	}
+
+	log.WithFields(log.Fields{
+		"number":           string(merged["number"]),
+		"id":               string(merged["ID"]),
+		"records":          len(records),
+		"name":             string(merged["name"]),
+		"former_addresses": len(former),
+	}).Warn("merged duplicate member records")
+
+	return merged, nil
+}
+
+// blankField reports whether a stored value is empty.
+func blankField(v json.RawMessage) bool {
+	return string(v) == `""` || string(v) == "null"
+}
+
+// numbersToMemberIDs returns a rewrite that converts plain member numbers into member identifier strings.
+func numbersToMemberIDs(fields ...string) datastore.RewriteFunc {
+	return func(r map[string]json.RawMessage) (bool, error) {
+		changed := false
+
+		for _, f := range fields {
+			var n int
+
+			v, ok := r[f]
+			if !ok || json.Unmarshal(v, &n) != nil {
+				continue
+			}
+
+			id := member.ID{Number: n}
+
+			data, err := json.Marshal(id)
+			if err != nil {
+				return false, fmt.Errorf("error encoding member %s: %w", id, err)
+			}
+
+			r[f] = data
+			changed = true
+		}
+
+		return changed, nil
+	}
+}
+
+// renameFields returns a rewrite that moves stored values from old field names to new ones.
+func renameFields(names map[string]string) datastore.RewriteFunc {
+	return func(r map[string]json.RawMessage) (bool, error) {
+		changed := false
+
+		for from, to := range names {
+			v, ok := r[from]
+			if !ok {
+				continue
+			}
+
+			r[to] = v
+			delete(r, from)
+			changed = true
+		}
+
+		return changed, nil
+	}
+}
+
+// NewMigrateCmd creates a migrate command.
+func NewMigrateCmd() *cobra.Command {
+	// cmd represents the migrate command
+	cmd := &cobra.Command{
+		Use:     "migrate",
+		Short:   "Upgrade datastore schema",
+		Long:    migrateCommandLongDesc,
+		Example: "ogma migrate --dry-run",
+		Args:    cobra.NoArgs,
+		Run:     RunMigrateCmd,
+	}
+
+	cmd.Flags().BoolP("dry-run", "n", false, "report pending migrations without applying them")
+
+	return cmd
+}
+
+// RunMigrateCmd performs action associated with migrate command.
+func RunMigrateCmd(cmd *cobra.Command, args []string) {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		log.Error("unable to read 'dry-run' flag: ", err)
+		dryRun = true
+	}
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey), datastore.WithoutMigrations())
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	v, err := dsManager.SchemaVersion()
+	if err != nil {
+		log.Error("error reading schema version: ", err)
+		cmd.PrintErrln("error reading schema version: ", err)
+		return
+	}
+
+	if !dryRun && v < datastore.LatestSchemaVersion() {
+		s, backupErr := dsManager.Backup(backupDir(), "pre-migrate")
+		if backupErr != nil {
+			log.Error("error creating backup: ", backupErr)
+			cmd.PrintErrln("error creating backup: ", backupErr)
+			return
+		}
+
+		cmd.Printf("Created backup: %s\n", s.Name)
+
+		if _, err = pruneSnapshots(); err != nil {
+			log.Warn("failed to prune backups: ", err)
+		}
+	}
+
+	reports, err := dsManager.Migrate(dryRun)
+	if err != nil {
+		log.Error("error migrating datastore: ", err)
+		cmd.PrintErrln("error migrating datastore: ", err)
+		return
+	}
+
+	if len(reports) == 0 {
+		cmd.Printf("Datastore schema is up to date (version %d).\n", v)
+		return
+	}
+
+	cmd.Println(RenderMigrations(reports))
+
+	if dryRun {
+		cmd.Printf("Dry run: datastore left at schema version %d.\n", v)
+		return
+	}
+
+	cmd.Printf("Datastore upgraded from schema version %d to %d.\n", v, datastore.LatestSchemaVersion())
+}
+
+// RenderMigrations returns migration reports formatted as a table.
+func RenderMigrations(reports []datastore.MigrationReport) string {
+	mt := table.NewWriter()
+
+	mt.SetTitle("Schema Migrations:")
+
+	mt.AppendHeader(table.Row{
+		"Version",
+		"Description",
+		"Records",
+		"Buckets",
+	})
+
+	for _, r := range reports {
+		buckets := make([]string, 0, len(r.Changes))
+		for b, n := range r.Changes {
+			buckets = append(buckets, fmt.Sprintf("%s=%d", b, n))
+		}
+
+		sort.Strings(buckets)
+
+		mt.AppendRow([]interface{}{
+			r.Version,
+			r.Description,
+			r.Changes.Total(),
+			strings.Join(buckets, ", "),
+		})
+	}
+
+	return mt.Render()
+}
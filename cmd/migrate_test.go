@@ -0,0 +1,245 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/address"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+func TestNewMigrateCmd(t *testing.T) {
+	got := cmd.NewMigrateCmd()
+
+	assert.Equal(t, "migrate", got.Name())
+	assert.True(t, got.Runnable())
+}
+
+func TestRunMigrateCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	legacyFile := fmt.Sprintf("test/legacy_%d.db", time.Now().Unix())
+	legacy, err := datastore.New(legacyFile, datastore.WithoutMigrations())
+	require.NoError(t, err)
+
+	// member record as stored before field names were corrected
+	type Member struct {
+		ID      int    `storm:"id,increment"`
+		Number  int    `json:"reference"`
+		Name    string `json:"sender"`
+		Address string `json:"receiver"`
+	}
+
+	require.NoError(t, legacy.Save(&Member{Number: 1234, Name: "John Smith", Address: "123 Fake St"}))
+	require.NoError(t, legacy.Save(&Member{Number: 5678, Name: "Jane Doe"}))
+	require.NoError(t, legacy.Save(&Member{Number: 9999, Name: "Bob", Address: "1 Main St"}))
+	// duplicates saved before member numbers were unique; newer details win, blanks are filled from older ones, and
+	// older addresses are kept in the address history
+	require.NoError(t, legacy.Save(&Member{Number: 1234, Address: "42 Real Rd"}))
+	require.NoError(t, legacy.Save(&Member{Number: 9999, Name: "Bob dup", Address: "2 Elm"}))
+	require.NoError(t, legacy.Save(&lstg.Listing{IndexedMemberNumber: 1234, ListingText: "Poetry exchange."}))
+	require.NoError(t, legacy.Save(&lstg.Listing{Volume: 14, IssueNumber: 56, Year: 2021, Season: "Spring", IndexedMemberNumber: 5678}))
+	require.NoError(t, legacy.Save(&lstg.Listing{Volume: 14, IssueNumber: 56, Year: 2021, Season: "Summer", IndexedMemberNumber: 4321}))
+
+	// mail record as stored before member numbers could have extensions
+	type Mail struct {
+		ID       int    `storm:"id,increment"`
+		Ref      string `json:"reference"`
+		Sender   int    `json:"sender"`
+		Receiver int    `json:"receiver"`
+		Link     string `json:"link"`
+	}
+
+	require.NoError(t, legacy.Save(&Mail{Ref: "abc123", Sender: 55, Receiver: 1234, Link: "L1"}))
+	require.NoError(t, legacy.Save(&Mail{Ref: "def456", Sender: 1234, Receiver: 55, Link: "Mabc123"}))
+	require.NoError(t, legacy.Save(&Mail{Ref: "aaa111", Sender: 1234, Receiver: 55, Link: "see letter"}))
+	require.NoError(t, legacy.Save(&Mail{Ref: "bbb222", Sender: 1234, Receiver: 55}))
+	// saved before references were unique
+	require.NoError(t, legacy.Save(&Mail{Ref: "abc123", Sender: 55, Receiver: 1234}))
+	legacy.Stop()
+
+	// other commands leave the legacy datastore alone until it is migrated
+	_, err = datastore.Open(legacyFile)
+	require.ErrorIs(t, err, datastore.ErrSchemaTooOld)
+
+	viper.Set(cmd.BackupDirKey, "test/backups")
+
+	tests := []struct {
+		name      string
+		args      []string
+		datastore string
+		want      string
+	}{
+		{
+			name:      "no datastore",
+			args:      []string{},
+			datastore: "test/foo.db",
+			want:      "error opening datastore:",
+		},
+		{
+			name:      "up to date",
+			args:      []string{},
+			datastore: dsFile,
+			want:      fmt.Sprintf("Datastore schema is up to date (version %d).\n", datastore.LatestSchemaVersion()),
+		},
+		{
+			name:      "legacy dry run",
+			args:      []string{"--dry-run"},
+			datastore: legacyFile,
+			want:      "Dry run: datastore left at schema version 0.\n",
+		},
+		{
+			name:      "legacy upgrade",
+			args:      []string{},
+			datastore: legacyFile,
+			want:      fmt.Sprintf("Datastore upgraded from schema version 0 to %d.\n", datastore.LatestSchemaVersion()),
+		},
+		{
+			name:      "legacy after upgrade",
+			args:      []string{},
+			datastore: legacyFile,
+			want:      fmt.Sprintf("Datastore schema is up to date (version %d).\n", datastore.LatestSchemaVersion()),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("datastore.filename", tt.datastore)
+
+			c := cmd.NewMigrateCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			require.NoError(t, c.Execute())
+
+			assert.Contains(t, b.String(), tt.want)
+		})
+	}
+
+	// the legacy datastore was backed up before it was upgraded, and only then
+	ss, err := datastore.Snapshots("test/backups")
+	require.NoError(t, err)
+	require.Len(t, ss, 1)
+	assert.Equal(t, "pre-migrate", ss[0].Label)
+
+	snapshot, err := datastore.Open(ss[0].Path, datastore.WithoutMigrations())
+	require.NoError(t, err)
+
+	v, err := snapshot.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 0, v)
+	snapshot.Stop()
+
+	migrated, err := datastore.Open(legacyFile)
+	require.NoError(t, err)
+	defer migrated.Stop()
+
+	var got cmd.Member
+	require.NoError(t, migrated.One("ID", 1, &got))
+	assert.Equal(t, cmd.Member{ID: 1, Number: member.ID{Number: 1234}, Name: "John Smith", Addresses: address.History{
+		{Address: address.Address{Lines: []string{"123 Fake St"}}, Source: "merged member record"},
+		{Address: address.Address{Lines: []string{"42 Real Rd"}}},
+	}}, got)
+
+	raw, err := migrated.Store.From().GetBytes("Member", 1)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"addresses":[{"address":{"lines":["123 Fake St"]},"source":"merged member record"},{"address":{"lines":["42 Real Rd"]}}]`)
+	assert.NotContains(t, string(raw), "former_addresses")
+
+	require.NoError(t, migrated.One("ID", 3, &got))
+	assert.Equal(t, cmd.Member{ID: 3, Number: member.ID{Number: 9999}, Name: "Bob dup", Addresses: address.History{
+		{Address: address.Address{Lines: []string{"1 Main St"}}, Source: "merged member record"},
+		{Address: address.Address{Lines: []string{"2 Elm"}}},
+	}}, got)
+
+	raw, err = migrated.Store.From().GetBytes("Member", 2)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), `"address`)
+
+	n, err := migrated.Store.Count(&cmd.Member{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	// member numbers are indexed after the upgrade
+	require.NoError(t, migrated.One("Number", member.ID{Number: 5678}, &got))
+	assert.Equal(t, "Jane Doe", got.Name)
+	assert.ErrorIs(t, migrated.Save(&cmd.Member{Number: member.ID{Number: 1234}}), storm.ErrAlreadyExists)
+
+	raw, err = migrated.Store.From().GetBytes("Mail", 1)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"sender":"55"`)
+	assert.Contains(t, string(raw), `"receiver":"1234"`)
+
+	// mail links are typed, and text that isn't a link is kept
+	for id, want := range map[int]string{1: `"link":{"listing":1}`, 2: `"link":{"mail":"abc123"}`, 3: `"link":{"text":"see letter"}`} {
+		raw, err = migrated.Store.From().GetBytes("Mail", id)
+		require.NoError(t, err)
+		assert.Contains(t, string(raw), want)
+	}
+
+	raw, err = migrated.Store.From().GetBytes("Mail", 4)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), `"link"`)
+
+	// mail references are indexed, and the oldest of a duplicate keeps its place in the index
+	var mail cmd.Mail
+	require.NoError(t, migrated.One("Ref", "abc123", &mail))
+	assert.Equal(t, 1, mail.ID)
+	assert.ErrorIs(t, migrated.Save(&cmd.Mail{Ref: "def456"}), storm.ErrAlreadyExists)
+
+	dups, err := cmd.FindDuplicateRefs(migrated.Store)
+	require.NoError(t, err)
+	require.Len(t, dups, 1)
+	assert.Equal(t, 5, dups[0][1].ID)
+
+	// listings saved before the text index existed are indexed
+	rr, err := lstg.TextIndex(migrated.Store).Search("poetry", 0)
+	require.NoError(t, err)
+	require.Len(t, rr, 1)
+	assert.Equal(t, 1, rr[0].ID)
+
+	// issues are catalogued from the oldest listing printed in them
+	ii := []cmd.Issue{}
+	require.NoError(t, migrated.All(&ii))
+	assert.Equal(t, []cmd.Issue{{ID: 1, Number: 56, Volume: 14, Year: 2021, Season: "Spring", Owned: true}}, ii)
+}
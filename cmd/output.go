@@ -0,0 +1,94 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+// OutputKey is the setting for the format results are written in when '--output' isn't given.
+const OutputKey = "output"
+
+// addOutputFlag adds the '--output' flag to a command and all of its subcommands.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().String(OutputKey, "",
+		"Format of results: "+render.FormatNames()+". (default is the 'output' setting)")
+}
+
+// outputRenderer returns a renderer for the format given with '--output', or the 'output' setting. Tables are
+// colored when pretty.
+func outputRenderer(cmd *cobra.Command, pretty bool) (render.Renderer, error) {
+	name := viper.GetString(OutputKey)
+
+	if f := cmd.Flags().Lookup(OutputKey); f != nil && f.Changed {
+		name = f.Value.String()
+	}
+
+	if name == "" {
+		return render.Renderer{Format: render.Table, Pretty: pretty}, nil
+	}
+
+	format, err := render.ParseFormat(name)
+	if err != nil {
+		return render.Renderer{}, err
+	}
+
+	return render.Renderer{Format: format, Pretty: pretty}, nil
+}
+
+// resultRenderer returns the renderer for a command's results, with tables colored by its '--pretty' flag. An
+// output format that can't be used is reported.
+func resultRenderer(cmd *cobra.Command) (render.Renderer, error) {
+	r, err := outputRenderer(cmd, prettyFlag(cmd))
+	if err != nil {
+		log.Error("invalid output format: ", err)
+		cmd.PrintErrln("invalid output format: ", err)
+	}
+
+	return r, err
+}
+
+// printOutput writes rendered results, if there are any.
+func printOutput(cmd *cobra.Command, out string, err error) {
+	if err != nil {
+		log.Error("failed to write results: ", err)
+		cmd.PrintErrln("failed to write results: ", err)
+
+		return
+	}
+
+	if out != "" {
+		cmd.Println(out)
+	}
+}
+
+// printReport writes a report in the renderer's format.
+func printReport(cmd *cobra.Command, r render.Renderer, rep render.Report) {
+	out, err := r.Render(rep)
+
+	printOutput(cmd, out, err)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+)
+
+const restoreCommandLongDesc = "The restore command replaces the datastore with a backup snapshot. Use 'ogma backup list'\n" +
+	"to see available snapshot names.\n\n" +
+	"The snapshot is verified before it is restored, and the current datastore is backed up first."
+
+func init() {
+	rootCmd.AddCommand(NewRestoreCmd())
+}
+
+// NewRestoreCmd creates a restore command.
+func NewRestoreCmd() *cobra.Command {
+	// cmd represents the restore command
+	cmd := &cobra.Command{
+		Use:     "restore [snapshot name]",
+		Short:   "Restore the datastore from a backup",
+		Long:    restoreCommandLongDesc,
+		Example: "ogma restore ogma-20211115T120000.000.db",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires a single snapshot name")
+			}
+
+			return nil
+		},
+		Run: RunRestoreCmd,
+	}
+
+	return cmd
+}
+
+// RunRestoreCmd performs action associated with restore command.
+func RunRestoreCmd(cmd *cobra.Command, args []string) {
+	s, err := restoreSnapshot(args[0])
+	if err != nil {
+		log.Error("error restoring backup: ", err)
+		cmd.PrintErrln("error restoring backup: ", err)
+		return
+	}
+
+	cmd.Printf("Restored datastore from backup: %s\n", s.Name)
+}
+
+func restoreSnapshot(name string) (datastore.Snapshot, error) {
+	s, err := datastore.FindSnapshot(backupDir(), name)
+	if err != nil {
+		return datastore.Snapshot{}, fmt.Errorf("error finding backup: %w", err)
+	}
+
+	// verify before taking a safety snapshot so a bad restore doesn't prune a good backup
+	if err = datastore.Verify(s.Path); err != nil {
+		return datastore.Snapshot{}, fmt.Errorf("backup failed verification: %w", err)
+	}
+
+	if _, err = takeSnapshotIfExists("pre-restore"); err != nil {
+		return datastore.Snapshot{}, fmt.Errorf("error backing up current datastore: %w", err)
+	}
+
+	if err = datastore.Restore(s, viper.GetString(DatastoreFilenameKey)); err != nil {
+		return datastore.Snapshot{}, err
+	}
+
+	log.WithField("snapshot", s.Name).Info("restored datastore")
+
+	return s, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+)
+
+func TestNewRestoreCmd(t *testing.T) {
+	got := cmd.NewRestoreCmd()
+
+	assert.Equal(t, "restore", got.Name())
+	assert.True(t, got.Runnable())
+}
+
+func TestRunRestoreCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.BackupDirKey, "test/backups")
+	viper.Set("datastore.filename", dsFile)
+
+	s, err := m.Backup("test/backups", "")
+	require.NoError(t, err)
+
+	// change data after the backup so the restore is observable
+	require.NoError(t, m.Save(&lstg.Listing{IndexedMemberNumber: 42}))
+	m.Stop()
+
+	require.NoError(t, os.WriteFile("test/backups/ogma-20211115T120000.000.db", []byte("not a datastore"), 0o600))
+
+	tests := []struct {
+		name      string
+		args      []string
+		assertion assert.ErrorAssertionFunc
+		want      string
+	}{
+		{
+			name:      "no snapshot name",
+			args:      []string{},
+			assertion: assert.Error,
+			want:      "Error: requires a single snapshot name",
+		},
+		{
+			name:      "unknown snapshot",
+			args:      []string{"ogma-20000101T000000.000"},
+			assertion: assert.NoError,
+			want:      "error restoring backup:  error finding backup: snapshot not found",
+		},
+		{
+			name:      "corrupt snapshot",
+			args:      []string{"ogma-20211115T120000.000.db"},
+			assertion: assert.NoError,
+			want:      "error restoring backup:  backup failed verification:",
+		},
+		{
+			name:      "valid snapshot",
+			args:      []string{s.Name},
+			assertion: assert.NoError,
+			want:      "Restored datastore from backup: " + s.Name,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cmd.NewRestoreCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			tt.assertion(t, c.Execute())
+			assert.Contains(t, b.String(), tt.want)
+		})
+	}
+
+	restored, err := datastore.Open(dsFile)
+	require.NoError(t, err)
+	defer restored.Stop()
+
+	count, err := restored.Count(&lstg.Listing{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count, "listing saved after backup should be gone")
+
+	ss, err := datastore.Snapshots("test/backups")
+	require.NoError(t, err)
+
+	labels := []string{}
+	for _, sn := range ss {
+		labels = append(labels, sn.Label)
+	}
+
+	assert.Contains(t, labels, "pre-restore")
+}
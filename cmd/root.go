@@ -25,6 +25,7 @@ package cmd
 
 import (
 	"os"
+	"strings"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	log "github.com/sirupsen/logrus"
@@ -34,6 +35,7 @@ import (
 
 	"github.com/asphaltbuffet/ogma/pkg/datastore"
 	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/render"
 )
 
 // default const values for application.
@@ -43,8 +45,11 @@ const (
 	DefaultConfigFilename    = ".ogma"
 	DefaultLoggingLevel      = "info"
 	DefaultDatastoreFilename = "ogma.db"
+	DefaultHomeCountry       = "US"
 
 	DatastoreFilenameKey = "datastore.filename"
+	MaxSearchResultsKey  = "search.max_results"
+	HomeCountryKey       = "defaults.country"
 )
 
 var (
@@ -75,6 +80,7 @@ var rootCmd = &cobra.Command{
 func init() {
 	// rootCmd.PersistentFlags().String("config", ".ogma", "Configuration file to use for application.")
 	rootCmd.Flags().BoolVarP(&pretty, "pretty", "p", true, "pretty print info")
+	addOutputFlag(rootCmd)
 }
 
 // GetRootCmd gets the application root command.
@@ -83,14 +89,23 @@ func GetRootCmd() *cobra.Command {
 }
 
 func summarizeDatastore(cmd *cobra.Command, isPretty bool) {
-	if _, err := os.Stat(viper.GetString(DatastoreFilenameKey)); err != nil {
+	r, err := outputRenderer(cmd, isPretty)
+	if err != nil {
+		log.Error("invalid output format: ", err)
+		cmd.PrintErrln("invalid output format: ", err)
+		return
+	}
+
+	if _, err = os.Stat(viper.GetString(DatastoreFilenameKey)); err != nil {
 		cmd.Println("No datastore file is available.")
 		return
 	}
 
 	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
 	if err != nil {
+		log.Error("error opening datastore: ", err)
 		cmd.PrintErrln("error opening datastore: ", err)
+		return
 	}
 	defer dsManager.Stop()
 
@@ -98,26 +113,37 @@ func summarizeDatastore(cmd *cobra.Command, isPretty bool) {
 	countMail, _ := dsManager.Count(&m)
 	var l lstg.Listing
 	countListings, _ := dsManager.Count(&l)
+	var i Issue
+	countIssues, _ := dsManager.Count(&i)
+	var mbr Member
+	countMembers, _ := dsManager.Count(&mbr)
+
+	printReport(cmd, r, SummaryReport([]RecordCount{
+		{Records: "mail", Count: countMail},
+		{Records: "listings", Count: countListings},
+		{Records: "issues", Count: countIssues},
+		{Records: "members", Count: countMembers},
+	}))
+}
 
-	mt := table.NewWriter()
-
-	mt.SetTitle("Data Records:")
-
-	mt.AppendRow([]interface{}{
-		"Mail",
-		countMail,
-	})
+// RecordCount is the number of stored records of a type.
+type RecordCount struct {
+	Records string `json:"records"`
+	Count   int    `json:"count"`
+}
 
-	mt.AppendRow([]interface{}{
-		"Listings",
-		countListings,
-	})
+// SummaryReport returns the number of stored records of each type.
+func SummaryReport(cc []RecordCount) render.Report {
+	rep := render.Report{
+		Title:   "Data Records:",
+		Records: cc,
+	}
 
-	if isPretty {
-		mt.SetStyle(table.StyleColoredBright)
+	for _, c := range cc {
+		rep.Rows = append(rep.Rows, table.Row{strings.ToUpper(c.Records[:1]) + c.Records[1:], c.Count})
 	}
 
-	cmd.Println(mt.Render())
+	return rep
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -145,8 +171,20 @@ func InitConfig(fs afero.Fs, cfg string) {
 
 	viper.SetDefault("logging.level", DefaultLoggingLevel)
 	viper.SetDefault(DatastoreFilenameKey, DefaultDatastoreFilename)
-	viper.SetDefault("search:max_results", DefaultMaxSearchResults)
+	viper.SetDefault(MaxSearchResultsKey, DefaultMaxSearchResults)
 	viper.SetDefault("member", DefaultMemberNumber)
+	viper.SetDefault(HomeCountryKey, DefaultHomeCountry)
+	viper.SetDefault(BackupDirKey, DefaultBackupDir)
+	viper.SetDefault(BackupKeepKey, DefaultBackupKeep)
+	viper.SetDefault(DueReplyDaysKey, DefaultDueReplyDays)
+	viper.SetDefault(DueQuietDaysKey, DefaultDueQuietDays)
+	viper.SetDefault(ImportInvalidKey, ImportStrict)
+	viper.SetDefault(ImportModeKey, string(ImportUpsert))
+	viper.SetDefault(ImportBatchKey, 0)
+	viper.SetDefault(ListingKeyFieldsKey, DefaultListingKeyFields)
+	viper.SetDefault(MailKeyFieldsKey, DefaultMailKeyFields)
+	viper.SetDefault(ImportDelimiterKey, ",")
+	viper.SetDefault(OutputKey, string(render.Table))
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err != nil {
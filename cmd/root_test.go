@@ -56,7 +56,14 @@ func TestGenerateInfo(t *testing.T) {
 			name:      "valid - empty db",
 			args:      []string{"-p=false"},
 			assertion: assert.NoError,
-			want:      "+--------------+\n| Data Records |\n| :            |\n+----------+---+\n| Mail     | 0 |\n| Listings | 0 |\n+----------+---+\n",
+			want:      "+--------------+\n| Data Records |\n| :            |\n+----------+---+\n| Mail     | 0 |\n| Listings | 0 |\n| Issues   | 0 |\n| Members  | 0 |\n+----------+---+\n",
+		},
+		{
+			name:      "valid - json",
+			args:      []string{"--output=json"},
+			assertion: assert.NoError,
+			want: "[\n  {\n    \"records\": \"mail\",\n    \"count\": 0\n  },\n  {\n    \"records\": \"listings\",\n    \"count\": 0\n  },\n" +
+				"  {\n    \"records\": \"issues\",\n    \"count\": 0\n  },\n  {\n    \"records\": \"members\",\n    \"count\": 0\n  }\n]\n",
 		},
 	}
 
@@ -80,6 +87,30 @@ func TestGenerateInfo(t *testing.T) {
 	}
 }
 
+func TestGenerateInfoUnreadableDatastore(t *testing.T) {
+	fs := afero.NewOsFs()
+
+	defer func() {
+		require.NoError(t, fs.RemoveAll("test/"))
+	}()
+
+	require.NoError(t, fs.MkdirAll("test", 0o755))
+	require.NoError(t, afero.WriteFile(fs, "test/unreadable.db", []byte("not a datastore"), 0o644))
+
+	viper.Set("datastore.filename", "test/unreadable.db")
+
+	c := cmd.GetRootCmd()
+
+	b := bytes.NewBufferString("")
+	c.SetOut(b)
+	c.SetErr(b)
+	c.SetArgs([]string{"-p=false"})
+
+	require.NotPanics(t, func() { assert.NoError(t, c.Execute()) })
+	assert.Contains(t, b.String(), "error opening datastore: ")
+	assert.NotContains(t, b.String(), "Data Records")
+}
+
 func TestInitConfig(t *testing.T) {
 	var err error
 
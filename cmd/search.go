@@ -25,7 +25,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"strconv"
+	"strings"
 
 	"github.com/asdine/storm/v3"
 	"github.com/asdine/storm/v3/q"
@@ -35,30 +35,63 @@ import (
 
 	"github.com/asphaltbuffet/ogma/pkg/datastore"
 	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/query"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+	"github.com/asphaltbuffet/ogma/pkg/textindex"
 )
 
-const searchCommandLongDesc = "The search command queries all LEX ads by the member who placed the ad. It" +
-	"also searches any mail records by sender and receiver for matches with provided member number.\n\n" +
-	"By default, it displays results in a colored table output. This can be changed with the '--pretty=false' flag."
+const searchCommandLongDesc = "The search command queries LEX ads and mail records. A query is a list of terms, each\n" +
+	"either 'field:value' or a bare value. Bare numbers search by member number (the ad's member, or\n" +
+	"the sender or receiver of mail) and bare words search ad text.\n\n" +
+	"Terms on different fields must all match; repeating a field matches any of its values. Prefix a\n" +
+	"term with '-' to exclude matches. Excluded terms look like flags, so they must come after a '--'.\n" +
+	"Quote values with spaces, and use 'min..max' for ranges.\n\n" +
+	"Listing fields: id, volume, issue, year, season, page, category, member, alt, international,\n" +
+	"review, text, art, flag\n" +
+	"Mail fields: id, ref, sender, receiver, member, date, link\n\n" +
+	"Only record types that have every field in the query are searched. Stored details of the members\n" +
+	"searched for are shown above the results.\n\n" +
+	"The '--text' flag ranks listings by how well their text matches the given words, ignoring common words\n" +
+	"and word endings, and highlights the matching words. Up to 'search.max_results' listings are shown, and\n" +
+	"a query narrows the ranked listings further.\n\n" +
+	"By default, it displays results in a colored table output. This can be changed with the '--pretty=false' flag.\n" +
+	"Use '--output' to write them as json, jsonl, csv, yaml, markdown, or html instead."
+
+const searchCommandExample = `ogma search 1234
+ogma search category:"Art & Photography" year:1990..1995 international:true text:poetry -- -flag:true
+ogma search member:1234 date:2021-01..2021-06
+ogma search category:Music -- -international:true
+ogma search --text "vintage cameras"
+ogma search --text "vintage cameras" year:1990..1995
+ogma search --output json 1234`
 
 func init() {
 	rootCmd.AddCommand(NewSearchCmd())
 }
 
-// NewSearchCmd creates a mail command.
+// NewSearchCmd creates a search command.
 func NewSearchCmd() *cobra.Command {
-	// cmd represents the mail command
+	// cmd represents the search command
 	cmd := &cobra.Command{
-		Use:   "search [member number]",
-		Short: "Search records by member number.",
-		Long:  searchCommandLongDesc,
+		Use:     "search [query]",
+		Short:   "Search records with a query.",
+		Long:    searchCommandLongDesc,
+		Example: searchCommandExample,
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) != 1 {
-				return errors.New("requires a single member number")
+			if err := checkQueryArgs(cmd, args); err != nil {
+				return err
 			}
 
-			if _, err := strconv.Atoi(args[0]); err != nil {
-				return fmt.Errorf("invalid member number: %w", err)
+			if len(args) == 0 {
+				if text, _ := cmd.Flags().GetString("text"); text != "" {
+					return nil
+				}
+
+				return errors.New("requires a search query")
+			}
+
+			if _, err := query.Parse(joinQueryArgs(args)); err != nil {
+				return fmt.Errorf("invalid query: %w", err)
 			}
 
 			return nil
@@ -67,16 +100,61 @@ func NewSearchCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolP("pretty", "p", false, "Show prettier results.")
+	cmd.Flags().StringP("text", "t", "", "Search listing text, best matches first.")
 
 	return cmd
 }
 
+// checkQueryArgs returns an error for query terms that were meant as flags, or excluded terms that were read as
+// flags because they came before a '--'.
+func checkQueryArgs(cmd *cobra.Command, args []string) error {
+	for _, a := range args {
+		if strings.HasPrefix(a, "--") {
+			return fmt.Errorf("invalid query: %s is a flag, not a term", a)
+		}
+	}
+
+	// '-text:poetry' before a '--' is read as '-t' with the value 'ext:poetry'
+	if text, _ := cmd.Flags().GetString("text"); strings.HasPrefix(text, "ext:") {
+		return fmt.Errorf("invalid query: excluded terms must come after a '--': -t%s", text)
+	}
+
+	return nil
+}
+
 // RunSearchCmd performs action associated with listings application command.
 func RunSearchCmd(cmd *cobra.Command, args []string) {
-	// member number is already validated by cobra
-	member, _ := strconv.Atoi(args[0])
+	text, err := cmd.Flags().GetString("text")
+	if err != nil {
+		log.Error("unable to read 'text' flag: ", err)
+	}
 
-	log.WithField("member", member).Debug("searching by member number")
+	if text != "" {
+		runTextSearch(cmd, text, args)
+		return
+	}
+
+	expr := joinQueryArgs(args)
+
+	// query is already validated by cobra
+	terms, _ := query.Parse(expr)
+
+	log.WithField("query", expr).Debug("searching records")
+
+	listingMatcher, listingErr := query.Build(terms, lstg.QuerySchema)
+	mailMatcher, mailErr := query.Build(terms, MailQuerySchema)
+
+	if err = queryError(listingErr, mailErr); err != nil {
+		log.WithField("query", expr).Error("invalid query: ", err)
+
+		cmd.PrintErrln("invalid query: ", err)
+		return
+	}
+
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
 
 	dsManager, err := datastore.Open(viper.GetString("datastore.filename"))
 	if err != nil {
@@ -87,44 +165,167 @@ func RunSearchCmd(cmd *cobra.Command, args []string) {
 	}
 	defer dsManager.Stop()
 
-	ll, err := searchListings(member, dsManager)
+	mm, err := searchMembers(terms, dsManager)
 	if err != nil {
-		log.WithField("member", member).Error("failed to search listings: ", err)
+		log.WithField("query", expr).Error("failed to search members: ", err)
 
-		cmd.PrintErrln("failed to search listings: ", err)
+		cmd.PrintErrln("failed to search members: ", err)
 		return
 	}
 
-	mm, err := SearchMail(member, dsManager)
+	var sections []render.Section
+
+	// other programs are always given the members, even when there are none
+	if len(mm) > 0 || r.Format.Structured() {
+		sections = append(sections, render.Section{Name: "members", Report: MemberReport(mm)})
+	}
+
+	if listingErr == nil {
+		ll, err := searchListings(listingMatcher, dsManager)
+		if err != nil {
+			log.WithField("query", expr).Error("failed to search listings: ", err)
+
+			cmd.PrintErrln("failed to search listings: ", err)
+			return
+		}
+
+		sections = append(sections, render.Section{Name: "listings", Report: lstg.ListingsReport(ll, r.Pretty)})
+	}
+
+	if mailErr == nil {
+		mm, err := SearchMail(mailMatcher, dsManager)
+		if err != nil {
+			log.WithField("query", expr).Error("failed to search mail: ", err)
+
+			cmd.PrintErrln("failed to search mail: ", err)
+			return
+		}
+
+		sections = append(sections, render.Section{Name: "mail", Report: MailReport(mm)})
+	}
+
+	printSearchResults(cmd, r, sections)
+}
+
+// printSearchResults writes each section of the search results. Tables start after a blank line.
+func printSearchResults(cmd *cobra.Command, r render.Renderer, sections []render.Section) {
+	out, err := r.RenderSections(sections)
+	if err == nil && r.Format == render.Table {
+		out = "\n" + out
+	}
+
+	printOutput(cmd, out, err)
+}
+
+// runTextSearch shows the listings whose text best matches the search words, narrowed by an optional query
+// on the other listing fields.
+func runTextSearch(cmd *cobra.Command, text string, args []string) {
+	var m q.Matcher
+
+	if len(args) > 0 {
+		expr := joinQueryArgs(args)
+
+		// query is already validated by cobra
+		terms, _ := query.Parse(expr)
+
+		var err error
+
+		if m, err = query.Build(terms, lstg.QuerySchema); err != nil {
+			log.WithField("query", expr).Error("invalid query: ", err)
+
+			cmd.PrintErrln("invalid query: ", err)
+			return
+		}
+	}
+
+	log.WithField("text", text).Debug("searching listing text")
+
+	r, err := resultRenderer(cmd)
 	if err != nil {
-		log.WithField("member", member).Error("failed to search mail: ", err)
+		return
+	}
 
-		cmd.PrintErrln("failed to search mail: ", err)
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+
+		cmd.Println("error opening datastore: ", err)
 		return
 	}
+	defer dsManager.Stop()
 
-	p, err := cmd.Flags().GetBool("pretty")
+	ll, err := searchListingText(text, m, viper.GetInt(MaxSearchResultsKey), dsManager.Store)
 	if err != nil {
-		log.Error("unable to read 'pretty' flag: ", err)
-		p = false
+		log.WithField("text", text).Error("failed to search listing text: ", err)
+
+		cmd.PrintErrln("failed to search listing text: ", err)
+		return
+	}
+
+	// only tables show which words matched
+	var highlight []string
+	if r.Format == render.Table {
+		highlight = textindex.Terms(text)
+	}
+
+	printSearchResults(cmd, r, []render.Section{
+		{Name: "listings", Report: lstg.ListingsReport(ll, r.Pretty, highlight...)},
+	})
+}
+
+// joinQueryArgs rebuilds a query from command arguments. The shell has already removed quotes, so any
+// argument containing spaces is quoted again.
+func joinQueryArgs(args []string) string {
+	terms := make([]string, 0, len(args))
+
+	for _, a := range args {
+		if !strings.ContainsAny(a, " \t") || strings.Contains(a, `"`) {
+			terms = append(terms, a)
+			continue
+		}
+
+		field, value, found := strings.Cut(a, ":")
+		if !found || strings.ContainsAny(field, " \t") {
+			field, value = "", a
+		} else {
+			field += ":"
+		}
+
+		terms = append(terms, field+`"`+value+`"`)
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// queryError returns the error to report when a query can't be used for any record type. A query only has
+// to suit one record type, but invalid values are always reported.
+func queryError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, query.ErrUnknownField) {
+			return err
+		}
 	}
 
-	cmd.Printf("\n%s\n", lstg.RenderListings(ll, p))
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
 
-	cmd.Printf("\n%s\n", RenderMail(mm, p))
+	return errs[0]
 }
 
-// searchListings returns all records with a matching member number (ignores member extensions).
-func searchListings(member int, ds storm.Finder) ([]lstg.Listing, error) {
+// searchListings returns all listing records matching a query.
+func searchListings(m q.Matcher, ds storm.Finder) ([]lstg.Listing, error) {
 	searchResults := []lstg.Listing{}
 
-	err := ds.Find("IndexedMemberNumber", member, &searchResults)
+	err := ds.Select(m).Find(&searchResults)
 	if err != nil {
 		switch err {
 		case storm.ErrNotFound:
-			log.WithField("member", member).Debug("no listings found")
+			log.Debug("no listings found")
 		default:
-			log.WithField("member", member).Error("failed to query database for listings: ", err)
+			log.Error("failed to query database for listings: ", err)
 			return nil, fmt.Errorf("failure to query database for listings: %w", err)
 		}
 	}
@@ -132,22 +333,69 @@ func searchListings(member int, ds storm.Finder) ([]lstg.Listing, error) {
 	return searchResults, nil
 }
 
-// SearchMail returns all mail records with a matching member number.
-func SearchMail(member int, ds storm.Finder) ([]Mail, error) {
+// SearchMail returns all mail records matching a query.
+func SearchMail(m q.Matcher, ds storm.Finder) ([]Mail, error) {
 	var searchResults []Mail
 
-	mailQuery := ds.Select(q.Or(q.Eq("Sender", member), q.Eq("Receiver", member)))
-
-	err := mailQuery.Find(&searchResults)
+	err := ds.Select(m).Find(&searchResults)
 	if err != nil {
 		switch err {
 		case storm.ErrNotFound:
-			log.WithField("sender", member).Debug("no sender correspondence found")
+			log.Debug("no correspondence found")
 		default:
-			log.WithField("member", member).Error("failed to query database for mail by sender: ", err)
-			return nil, fmt.Errorf("failure to query database for mail by sender=%d: %w", member, err)
+			log.Error("failed to query database for mail: ", err)
+			return nil, fmt.Errorf("failure to query database for mail: %w", err)
 		}
 	}
 
 	return searchResults, nil
 }
+
+// searchListingText returns up to limit listings ranked by how well their text matches the search words. When
+// a matcher is given, only listings it matches are returned.
+func searchListingText(text string, m q.Matcher, limit int, ds storm.Node) ([]lstg.Listing, error) {
+	// rank every match when filtering so the limit applies to the filtered results
+	rankLimit := limit
+	if m != nil {
+		rankLimit = 0
+	}
+
+	results, err := lstg.TextIndex(ds).Search(text, rankLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failure to search listing text: %w", err)
+	}
+
+	ll := []lstg.Listing{}
+
+	for _, r := range results {
+		var l lstg.Listing
+
+		if err = ds.One("ID", r.ID, &l); err != nil {
+			if errors.Is(err, storm.ErrNotFound) {
+				log.WithField("id", r.ID).Warn("text index refers to a missing listing")
+				continue
+			}
+
+			return nil, fmt.Errorf("failure to read listing id=%d: %w", r.ID, err)
+		}
+
+		if m != nil {
+			ok, errMatch := m.Match(&l)
+			if errMatch != nil {
+				return nil, fmt.Errorf("failure to filter listing id=%d: %w", r.ID, errMatch)
+			}
+
+			if !ok {
+				continue
+			}
+		}
+
+		ll = append(ll, l)
+
+		if limit > 0 && len(ll) == limit {
+			break
+		}
+	}
+
+	return ll, nil
+}
@@ -35,8 +35,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/address"
 	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
 	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
 )
 
 func TestNewSearchCmd(t *testing.T) {
@@ -55,12 +58,15 @@ func TestRunSearchCmd(t *testing.T) {
 		require.NoError(t, os.RemoveAll("test/"))
 	}()
 
+	viper.Set(cmd.HomeCountryKey, "US")
+
 	tests := []struct {
 		name      string
 		args      []string
 		datastore string
 		assertion assert.ErrorAssertionFunc
 		want      string
+		notWant   string
 	}{
 		{
 			name:      "invalid datastore",
@@ -70,32 +76,173 @@ func TestRunSearchCmd(t *testing.T) {
 			want:      "error opening datastore:  error accessing datastore file:",
 		},
 		{
-			name:      "invalid search - too many parameters",
+			name:      "invalid search - no query",
+			args:      []string{},
+			datastore: dsFile,
+			assertion: assert.Error,
+			want:      "Error: requires a search query",
+		},
+		{
+			name:      "invalid search - unterminated quote",
+			args:      []string{`category:"Pariatur`},
+			datastore: dsFile,
+			assertion: assert.Error,
+			want:      "Error: invalid query: query syntax error: unterminated quote",
+		},
+		{
+			name:      "invalid search - unknown field",
+			args:      []string{"color:red"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "invalid query:  unknown query field: color:red",
+		},
+		{
+			name:      "invalid search - bad value",
+			args:      []string{"year:soon"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "invalid query:  invalid query value: year:soon",
+		},
+		{
+			name:      "multiple members",
 			args:      []string{"1234", "5678"},
 			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "Velit cillum cillum ea officia nulla enim.",
+		},
+		{
+			name:      "member panel",
+			args:      []string{"5678"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "| Member Information:           |\n+--------+----------+-----------+\n| NUMBER | NAME     | ADDRESS   |\n+--------+----------+-----------+\n|  5678  | Jane Doe | 1 Main St |",
+		},
+		{
+			name:      "exact member extension",
+			args:      []string{"1234b"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "Magna officia anim dolore enim.",
+			notWant:   "Esse Lorem",
+		},
+		{
+			name:      "member extension excludes mail without it",
+			args:      []string{"1234B"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "No correspondences found.",
+		},
+		{
+			name:      "unused member extension",
+			args:      []string{"member:1234A"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "No LEX listings found.",
+		},
+		{
+			name:      "mail member range",
+			args:      []string{"sender:1000..2000"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "b12cd3",
+			notWant:   "| 123d5f",
+		},
+		{
+			name:      "listing fields only",
+			args:      []string{"category:pariatur", "--", "-member:5678"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "Esse Lorem do nulla sunt mollit nulla in.",
+			notWant:   "Correspondence",
+		},
+		{
+			name:      "flags after the query",
+			args:      []string{"category:pariatur", "--pretty=false"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "Esse Lorem do nulla sunt mollit nulla in.",
+		},
+		{
+			name:      "invalid search - flag after --",
+			args:      []string{"1234", "--", "--output", "json"},
+			datastore: dsFile,
 			assertion: assert.Error,
-			want:      "Error: requires a single member number",
+			want:      "Error: invalid query: --output is a flag, not a term",
 		},
 		{
-			name:      "invalid search - alphanumeric",
-			args:      []string{"1234a"},
+			name:      "invalid search - excluded text before --",
+			args:      []string{"category:pariatur", "-text:poetry"},
 			datastore: dsFile,
 			assertion: assert.Error,
-			want:      "Error: invalid member number: strconv.Atoi: parsing \"1234a\": invalid syntax",
+			want:      "Error: invalid query: excluded terms must come after a '--': -text:poetry",
+		},
+		{
+			name:      "listing text",
+			args:      []string{"officia"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "Magna officia anim dolore enim.",
+			notWant:   "Esse Lorem",
+		},
+		{
+			name:      "ranked listing text",
+			args:      []string{"--text", "cillum officia"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "|  3 |      1 |     1 | 1986 | Id      |    3 | Pariatur |   5678 |               |        | Velit *cillum* *cillum* ea *officia* nulla enim. |        |    ✔    | 0.00      |\n|  2 |",
+			notWant:   "Correspondence",
+		},
+		{
+			name:      "listing text narrowed by query",
+			args:      []string{"--text", "officia", "member:1234"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "Magna *officia* anim dolore enim.",
+			notWant:   "Velit",
+		},
+		{
+			name:      "listing text with mail query",
+			args:      []string{"--text", "officia", "date:1986"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "invalid query:  unknown query field: date:1986",
+		},
+		{
+			name:      "mail status",
+			args:      []string{"status:returned"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "| 6beef9    |   1234 |      666 | 2021-03-15 |      | returned |",
+			notWant:   "b12cd3",
+		},
+		{
+			name:      "invalid mail status",
+			args:      []string{"status:misplaced"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "invalid query:  invalid query value: status:misplaced",
+		},
+		{
+			name:      "mail date range",
+			args:      []string{"date:1986..1986-04"},
+			datastore: dsFile,
+			assertion: assert.NoError,
+			want:      "123d5f",
+			notWant:   "LEX Issue Matches",
 		},
 		{
 			name:      "with listings, no correspondence",
 			args:      []string{"1234"},
 			datastore: dsFile,
 			assertion: assert.NoError,
-			want:      "\n+---------------------------------------------------------------------------------------------------------------------------------------------------------------------+\n| LEX Issue Matches:                                                                                                                                                  |\n+----+--------+-------+------+---------+------+----------+--------+---------------+--------+-------------------------------------------+--------+---------+-----------+\n| ID | VOLUME | ISSUE | YEAR | SEASON  | PAGE | CATEGORY | MEMBER | INTERNATIONAL | REVIEW | TEXT                                      | SKETCH | FLAGGED | SENTIMENT |\n+----+--------+-------+------+---------+------+----------+--------+---------------+--------+-------------------------------------------+--------+---------+-----------+\n|  1 |      1 |     1 | 1986 | Mollit  |    1 | Pariatur |   1234 |               |        | Esse Lorem do nulla sunt mollit nulla in. |        |    ✔    | 0.00      |\n|  2 |      1 |     1 | 1986 | Eiusmod |    2 | Commodo  |  1234B |               |        | Magna officia anim dolore enim.           |        |    ✔    | 0.00      |\n+----+--------+-------+------+---------+------+----------+--------+---------------+--------+-------------------------------------------+--------+---------+-----------+\n\n+------------------------------------------------------+\n| Correspondence Matches:                              |\n+-----------+--------+----------+------------+---------+\n| REFERENCE | SENDER | RECEIVER | DATE       | LINK    |\n+-----------+--------+----------+------------+---------+\n| 123d5f    |     55 |     1234 | 1986-04-01 |    L1   |\n| b12cd3    |   1234 |       55 | 1986-05-16 | M123d5f |\n| 6beef9    |   1234 |      666 | 2021-03-15 |         |\n+-----------+--------+----------+------------+---------+\n",
+			want:      "\n+---------------------------------------------------------------------------------------------------------------------------------------------------------------------+\n| LEX Issue Matches:                                                                                                                                                  |\n+----+--------+-------+------+---------+------+----------+--------+---------------+--------+-------------------------------------------+--------+---------+-----------+\n| ID | VOLUME | ISSUE | YEAR | SEASON  | PAGE | CATEGORY | MEMBER | INTERNATIONAL | REVIEW | TEXT                                      | SKETCH | FLAGGED | SENTIMENT |\n+----+--------+-------+------+---------+------+----------+--------+---------------+--------+-------------------------------------------+--------+---------+-----------+\n|  1 |      1 |     1 | 1986 | Mollit  |    1 | Pariatur |   1234 |               |        | Esse Lorem do nulla sunt mollit nulla in. |        |    ✔    | 0.00      |\n|  2 |      1 |     1 | 1986 | Eiusmod |    2 | Commodo  |  1234B |               |        | Magna officia anim dolore enim.           |        |    ✔    | 0.00      |\n+----+--------+-------+------+---------+------+----------+--------+---------------+--------+-------------------------------------------+--------+---------+-----------+\n\n+-----------------------------------------------------------------+\n| Correspondence Matches:                                         |\n+-----------+--------+----------+------------+---------+----------+\n| REFERENCE | SENDER | RECEIVER | DATE       | LINK    | STATUS   |\n+-----------+--------+----------+------------+---------+----------+\n| 123d5f    |     55 |     1234 | 1986-04-01 |    L1   | received |\n| b12cd3    |   1234 |       55 | 1986-05-16 | M123d5f | sent     |\n| 6beef9    |   1234 |      666 | 2021-03-15 |         | returned |\n+-----------+--------+----------+------------+---------+----------+\n",
 		},
 		{
 			name:      "no listings, with correspondence",
 			args:      []string{"666"},
 			datastore: dsFile,
 			assertion: assert.NoError,
-			want:      "\nNo LEX listings found.\n\n+---------------------------------------------------+\n| Correspondence Matches:                           |\n+-----------+--------+----------+------------+------+\n| REFERENCE | SENDER | RECEIVER | DATE       | LINK |\n+-----------+--------+----------+------------+------+\n| 6beef9    |   1234 |      666 | 2021-03-15 |      |\n+-----------+--------+----------+------------+------+\n",
+			want:      "\nNo LEX listings found.\n\n+--------------------------------------------------------------+\n| Correspondence Matches:                                      |\n+-----------+--------+----------+------------+------+----------+\n| REFERENCE | SENDER | RECEIVER | DATE       | LINK | STATUS   |\n+-----------+--------+----------+------------+------+----------+\n| 6beef9    |   1234 |      666 | 2021-03-15 |      | returned |\n+-----------+--------+----------+------------+------+----------+\n",
 		},
 		{
 			name:      "no listings, no correspondence",
@@ -120,6 +267,79 @@ func TestRunSearchCmd(t *testing.T) {
 			tt.assertion(t, err)
 
 			assert.Contains(t, b.String(), tt.want)
+
+			if tt.notWant != "" {
+				assert.NotContains(t, b.String(), tt.notWant)
+			}
+		})
+	}
+}
+
+func TestRunSearchCmdOutput(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+	m.Stop()
+
+	defer func() {
+		viper.Set(cmd.OutputKey, "")
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.HomeCountryKey, "US")
+	viper.Set("datastore.filename", dsFile)
+
+	tests := []struct {
+		name    string
+		output  string
+		args    []string
+		want    string
+		notWant string
+	}{
+		{
+			name:   "json",
+			output: "json",
+			args:   []string{"666"},
+			want: "{\n  \"members\": [],\n  \"listings\": [],\n  \"mail\": [\n    {\n      \"ID\": 3,\n" +
+				"      \"reference\": \"6beef9\",\n      \"sender\": \"1234\",\n      \"receiver\": \"666\",\n",
+		},
+		{
+			name:    "json lines",
+			output:  "jsonl",
+			args:    []string{"category:commodo"},
+			want:    `{"ID":2,"volume":1,"issue":1,"year":1986,"season":"Eiusmod","page":2,"category":"Commodo","member":1234,"alt":"B",`,
+			notWant: "Esse Lorem",
+		},
+		{
+			name:    "text search without highlights",
+			output:  "csv",
+			args:    []string{"--text", "officia", "member:1234"},
+			want:    "ID,Volume,Issue,Year,Season,Page,Category,Member,International,Review,Text,Sketch,Flagged,Sentiment\n2,1,1,1986,Eiusmod,2,Commodo,1234B,,,Magna officia anim dolore enim.,,✔,0.00\n",
+			notWant: "*officia*",
+		},
+		{
+			name:   "unknown format",
+			output: "xml",
+			args:   []string{"1234"},
+			want:   "invalid output format:  unknown output format \"xml\": must be one of table, json, jsonl, csv, yaml, markdown, html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set(cmd.OutputKey, tt.output)
+
+			cmd := cmd.NewSearchCmd()
+			b := bytes.NewBufferString("")
+			cmd.SetOut(b)
+			cmd.SetErr(b)
+			cmd.SetArgs(tt.args)
+
+			require.NoError(t, cmd.Execute())
+
+			assert.Contains(t, b.String(), tt.want)
+
+			if tt.notWant != "" {
+				assert.NotContains(t, b.String(), tt.notWant)
+			}
 		})
 	}
 }
@@ -189,29 +409,32 @@ func initDatastoreManager(t *testing.T) (*datastore.Manager, string) {
 	for _, record := range ltest {
 		r := record
 		_ = manager.Save(&r)
+		_ = lstg.TextIndex(manager.Store).Add(r.ID, r.ListingText)
 	}
 
 	mtest := []cmd.Mail{
 		{
 			Ref:      "123d5f",
-			Sender:   55,
-			Receiver: 1234,
+			Sender:   member.ID{Number: 55},
+			Receiver: member.ID{Number: 1234},
 			Date:     "1986-04-01",
-			Link:     "L1",
+			Link:     link.ToListing(1),
+			Status:   cmd.StatusReceived,
 		},
 		{
 			Ref:      "b12cd3",
-			Sender:   1234,
-			Receiver: 55,
+			Sender:   member.ID{Number: 1234},
+			Receiver: member.ID{Number: 55},
 			Date:     "1986-05-16",
-			Link:     "M123d5f",
+			Link:     link.ToMail("123d5f"),
+			Status:   cmd.StatusSent,
 		},
 		{
 			Ref:      "6beef9",
-			Sender:   1234,
-			Receiver: 666,
+			Sender:   member.ID{Number: 1234},
+			Receiver: member.ID{Number: 666},
 			Date:     "2021-03-15",
-			Link:     "",
+			Status:   cmd.StatusReturned,
 		},
 	}
 
@@ -220,6 +443,8 @@ func initDatastoreManager(t *testing.T) (*datastore.Manager, string) {
 		_ = manager.Save(&r)
 	}
 
+	_ = manager.Save(&cmd.Member{Number: member.ID{Number: 5678}, Name: "Jane Doe", Addresses: address.History{{Address: address.Address{Lines: []string{"1 Main St"}, Country: "US"}}}})
+
 	return manager, filename
 }
 
@@ -0,0 +1,545 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/asdine/storm/v3"
+	"github.com/jedib0t/go-pretty/v6/list"
+	"github.com/jedib0t/go-pretty/v6/table"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+// threadTextWidth is how much listing text is shown in a thread.
+const threadTextWidth = 40
+
+// threadColumnConfigs are the mail columns, with the listing text in the note shortened in the terminal.
+var threadColumnConfigs = append([]table.ColumnConfig{
+	{
+		Name:             "Note",
+		WidthMax:         threadTextWidth,
+		WidthMaxEnforcer: render.Truncate,
+	},
+}, mailColumnConfigs...)
+
+const threadCommandLongDesc = "The thread command follows mail links to rebuild whole conversations: the LEX listing\n" +
+	"that started one, then every letter in order.\n\n" +
+	"Give a mail reference to see the conversation it is part of, or a member number to see every\n" +
+	"conversation with that member. Letters that are linked to but aren't stored are shown as missing,\n" +
+	"and a letter with more than one reply starts a branch."
+
+// A Thread is a conversation rebuilt from mail links.
+type Thread struct {
+	// ListingID is the listing the conversation started from, or zero.
+	ListingID int `json:"listing_id,omitempty"`
+	// Listing is the stored listing, if it was found.
+	Listing *lstg.Listing `json:"listing,omitempty"`
+	// Letters are the first letters of the conversation, oldest first.
+	Letters []*ThreadLetter `json:"letters"`
+}
+
+// A ThreadLetter is a letter in a thread and the replies to it. Mail is nil for a letter that is linked to but
+// isn't stored.
+type ThreadLetter struct {
+	Ref     string          `json:"reference"`
+	Mail    *Mail           `json:"mail,omitempty"`
+	Replies []*ThreadLetter `json:"replies,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(NewThreadCmd())
+}
+
+// NewThreadCmd creates a thread command.
+func NewThreadCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "thread <ref|member>",
+		Short: "Show whole conversations",
+		Long:  threadCommandLongDesc,
+		Example: `ogma thread b12cd3
+ogma thread 1234 --tree`,
+		Args: cobra.ExactArgs(1),
+		Run:  RunThreadCmd,
+	}
+
+	cmd.Flags().BoolP("tree", "t", false, "Show conversations as a tree of replies, in place of a table.")
+	cmd.Flags().BoolP("pretty", "p", false, "Show prettier results.")
+
+	return cmd
+}
+
+// RunThreadCmd implements functionality of a thread command.
+func RunThreadCmd(cmd *cobra.Command, args []string) {
+	r, err := resultRenderer(cmd)
+	if err != nil {
+		return
+	}
+
+	dsManager, err := datastore.Open(viper.GetString(DatastoreFilenameKey))
+	if err != nil {
+		log.Error("error opening datastore: ", err)
+		cmd.PrintErrln("error opening datastore: ", err)
+		return
+	}
+	defer dsManager.Stop()
+
+	tt, err := FindThreads(dsManager.Store, args[0])
+	if err != nil {
+		log.WithField("arg", args[0]).Error("failed to find threads: ", err)
+		cmd.PrintErrln("failed to find conversations: ", err)
+		return
+	}
+
+	// other programs are given the threads with their replies nested, as the tree shows them
+	if r.Format.Structured() {
+		printReport(cmd, r, render.Report{Records: tt})
+		return
+	}
+
+	if len(tt) == 0 {
+		cmd.Println("No correspondences found.")
+		return
+	}
+
+	if tree, _ := cmd.Flags().GetBool("tree"); tree && r.Format == render.Table {
+		out := make([]string, len(tt))
+		for i, t := range tt {
+			out[i] = RenderThreadTree(t)
+		}
+
+		cmd.Println(strings.Join(out, "\n\n"))
+
+		return
+	}
+
+	sections := make([]render.Section, len(tt))
+	for i, t := range tt {
+		sections[i] = render.Section{Name: t.Title(), Report: ThreadReport(t)}
+	}
+
+	out, err := r.RenderSections(sections)
+
+	printOutput(cmd, out, err)
+}
+
+// FindThreads returns the conversations that include the mail with a reference, or that a member took part in.
+// A mail reference may be written with its 'M' link prefix.
+func FindThreads(ds storm.Node, arg string) ([]Thread, error) {
+	mails := []Mail{}
+	if err := ds.All(&mails); err != nil {
+		return nil, fmt.Errorf("failure to read mail: %w", err)
+	}
+
+	g := newMailGraph(mails)
+
+	var selected []*Mail
+
+	ref := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(arg, "M"), "m"))
+
+	if m, ok := g.byRef[ref]; ok {
+		selected = []*Mail{m}
+	} else if id, err := member.Parse(arg); err == nil {
+		for i := range g.mails {
+			if id.Matches(g.mails[i].Sender) || id.Matches(g.mails[i].Receiver) {
+				selected = append(selected, &g.mails[i])
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("%w: %s", ErrMailNotFound, arg)
+	}
+
+	// each selected letter's conversation is shown once, ordered by the letter that brought it in
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].Date < selected[j].Date })
+
+	seen := map[link.Link]bool{}
+	threads := []Thread{}
+
+	for _, m := range selected {
+		key := g.origin(m)
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+
+		t, err := g.thread(ds, key)
+		if err != nil {
+			return nil, err
+		}
+
+		threads = append(threads, t)
+	}
+
+	return threads, nil
+}
+
+// mailGraph links stored mail to the letters and listings they reply to.
+type mailGraph struct {
+	mails   []Mail
+	byRef   map[string]*Mail
+	replies map[string][]*Mail
+	origins map[string]link.Link
+}
+
+func newMailGraph(mails []Mail) *mailGraph {
+	sort.SliceStable(mails, func(i, j int) bool {
+		if mails[i].Date != mails[j].Date {
+			return mails[i].Date < mails[j].Date
+		}
+
+		return mails[i].Ref < mails[j].Ref
+	})
+
+	g := &mailGraph{
+		mails:   mails,
+		byRef:   map[string]*Mail{},
+		replies: map[string][]*Mail{},
+		origins: map[string]link.Link{},
+	}
+
+	for i := range mails {
+		m := &mails[i]
+		g.byRef[strings.ToLower(m.Ref)] = m
+
+		if m.Link.Kind() == link.Mail {
+			g.replies[m.Link.Mail] = append(g.replies[m.Link.Mail], m)
+		}
+	}
+
+	return g
+}
+
+// origin returns the key of the conversation a letter belongs to: the listing it started from, or the first
+// letter it can be followed back to, which may be a letter that isn't stored.
+func (g *mailGraph) origin(m *Mail) link.Link {
+	if key, ok := g.origins[m.Ref]; ok {
+		return key
+	}
+
+	path := []string{}
+	cur := m
+
+	var key link.Link
+
+	for {
+		path = append(path, strings.ToLower(cur.Ref))
+
+		if cur.Link.Kind() == link.Listing {
+			key = cur.Link
+			break
+		}
+
+		if cur.Link.Kind() != link.Mail {
+			key = link.ToMail(cur.Ref)
+			break
+		}
+
+		ref := cur.Link.Mail
+
+		if i := indexOf(path, ref); i >= 0 {
+			// letters that reply to each other in a loop start from the lowest reference in it
+			loop := append([]string{}, path[i:]...)
+			sort.Strings(loop)
+			key = link.ToMail(loop[0])
+
+			break
+		}
+
+		parent, ok := g.byRef[ref]
+		if !ok {
+			key = cur.Link
+			break
+		}
+
+		cur = parent
+	}
+
+	g.origins[m.Ref] = key
+
+	return key
+}
+
+// thread builds the conversation with an origin key.
+func (g *mailGraph) thread(ds storm.Node, key link.Link) (Thread, error) {
+	t := Thread{}
+
+	if key.Kind() == link.Listing {
+		id := key.Listing
+		t.ListingID = id
+
+		var l lstg.Listing
+
+		err := ds.One("ID", id, &l)
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return Thread{}, fmt.Errorf("error reading listing %d: %w", id, err)
+		}
+
+		if err == nil {
+			t.Listing = &l
+		}
+	}
+
+	for i := range g.mails {
+		m := &g.mails[i]
+		if g.origin(m) != key {
+			continue
+		}
+
+		ref, isReply := m.Link.Mail, m.Link.Kind() == link.Mail
+
+		switch {
+		case !isReply, link.ToMail(m.Ref) == key:
+			// letters that start the conversation, or start a loop of replies
+			t.Letters = append(t.Letters, g.letter(m, map[string]bool{}))
+		case g.byRef[ref] == nil && g.replies[ref][0] == m:
+			// replies to a letter that isn't stored hang off a placeholder for it
+			t.Letters = append(t.Letters, g.missing(ref))
+		}
+	}
+
+	return t, nil
+}
+
+// missing returns a placeholder for a letter that isn't stored, with all of its replies.
+func (g *mailGraph) missing(ref string) *ThreadLetter {
+	l := &ThreadLetter{Ref: ref}
+	visited := map[string]bool{ref: true}
+
+	for _, r := range g.replies[ref] {
+		l.Replies = append(l.Replies, g.letter(r, visited))
+	}
+
+	return l
+}
+
+// letter returns a letter with all of its replies.
+func (g *mailGraph) letter(m *Mail, visited map[string]bool) *ThreadLetter {
+	ref := strings.ToLower(m.Ref)
+	visited[ref] = true
+
+	l := &ThreadLetter{Ref: m.Ref, Mail: m}
+
+	for _, r := range g.replies[ref] {
+		if !visited[strings.ToLower(r.Ref)] {
+			l.Replies = append(l.Replies, g.letter(r, visited))
+		}
+	}
+
+	return l
+}
+
+// Title returns a short name for the thread.
+func (t Thread) Title() string {
+	switch {
+	case t.ListingID != 0:
+		return fmt.Sprintf("Conversation from listing L%d:", t.ListingID)
+	case len(t.Letters) > 0:
+		return fmt.Sprintf("Conversation from %s:", t.Letters[0].Ref)
+	default:
+		return "Conversation:"
+	}
+}
+
+// Timeline returns every letter in the thread by date, each with the reference of the letter it replies to.
+// Missing letters come just before their first reply.
+func (t Thread) Timeline() []*ThreadLetter {
+	var all []*ThreadLetter
+
+	var walk func(l *ThreadLetter)
+	walk = func(l *ThreadLetter) {
+		all = append(all, l)
+		for _, r := range l.Replies {
+			walk(r)
+		}
+	}
+
+	for _, l := range t.Letters {
+		walk(l)
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].date() < all[j].date() })
+
+	return all
+}
+
+// date returns the letter date, or for a letter that isn't stored the date of its first reply.
+func (l *ThreadLetter) date() string {
+	if l.Mail != nil {
+		return l.Mail.Date
+	}
+
+	d := ""
+
+	for _, r := range l.Replies {
+		if rd := r.date(); d == "" || rd < d {
+			d = rd
+		}
+	}
+
+	return d
+}
+
+// String describes the letter on one line.
+func (l *ThreadLetter) String() string {
+	if l.Mail == nil {
+		return fmt.Sprintf("%s (letter not found)", l.Ref)
+	}
+
+	s := fmt.Sprintf("%s %s %s → %s", l.Mail.Ref, l.Mail.Date, l.Mail.Sender, l.Mail.Receiver)
+	if l.Mail.Status != "" {
+		s += fmt.Sprintf(" [%s]", l.Mail.Status)
+	}
+
+	return s
+}
+
+// listingSummary describes the listing a thread started from on one line.
+func (t Thread) listingSummary() string {
+	if t.Listing == nil {
+		return fmt.Sprintf("L%d (listing not found)", t.ListingID)
+	}
+
+	return fmt.Sprintf("L%d %d %s %s: %s", t.ListingID, t.Listing.Year, t.Listing.Season, t.Listing.Member(),
+		truncate(t.Listing.ListingText, threadTextWidth))
+}
+
+// ThreadReport returns a thread as a timeline report, starting with the listing it started from.
+func ThreadReport(t Thread) render.Report {
+	rep := render.Report{
+		Title: t.Title(),
+		Header: table.Row{
+			"Date",
+			"Reference",
+			"Sender",
+			"Receiver",
+			"Reply To",
+			"Status",
+			"Note",
+		},
+		Columns: threadColumnConfigs,
+		Records: t,
+	}
+
+	if t.ListingID != 0 {
+		row := table.Row{"", fmt.Sprintf("L%d", t.ListingID), "", "", "", "", "listing not found"}
+
+		if t.Listing != nil {
+			row = table.Row{
+				fmt.Sprintf("%d %s", t.Listing.Year, t.Listing.Season),
+				fmt.Sprintf("L%d", t.ListingID),
+				t.Listing.Member().String(),
+				"",
+				"",
+				"",
+				t.Listing.ListingText,
+			}
+		}
+
+		rep.Rows = append(rep.Rows, row)
+	}
+
+	for _, l := range t.Timeline() {
+		if l.Mail == nil {
+			rep.Rows = append(rep.Rows, table.Row{"", l.Ref, "", "", "", "", "letter not found"})
+			continue
+		}
+
+		rep.Rows = append(rep.Rows, table.Row{
+			l.Mail.Date,
+			l.Mail.Ref,
+			l.Mail.Sender.String(),
+			l.Mail.Receiver.String(),
+			l.Mail.Link.String(),
+			l.Mail.Status,
+			"",
+		})
+	}
+
+	return rep
+}
+
+// RenderThreadTree returns a thread as a tree of replies.
+func RenderThreadTree(t Thread) string {
+	lw := list.NewWriter()
+	lw.SetStyle(list.StyleConnectedLight)
+
+	if t.ListingID != 0 {
+		lw.AppendItem(t.listingSummary())
+		lw.Indent()
+	}
+
+	var add func(l *ThreadLetter)
+	add = func(l *ThreadLetter) {
+		lw.AppendItem(l.String())
+
+		if len(l.Replies) == 0 {
+			return
+		}
+
+		lw.Indent()
+
+		for _, r := range l.Replies {
+			add(r)
+		}
+
+		lw.UnIndent()
+	}
+
+	for _, l := range t.Letters {
+		add(l)
+	}
+
+	return t.Title() + "\n" + lw.Render()
+}
+
+func indexOf(ss []string, s string) int {
+	for i, v := range ss {
+		if v == s {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// truncate shortens s to at most n runes, marking where it was cut.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,215 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/cmd"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+func TestNewThreadCmd(t *testing.T) {
+	got := cmd.NewThreadCmd()
+
+	assert.Equal(t, "thread", got.Name())
+	assert.Equal(t, "Show whole conversations", got.Short)
+	assert.True(t, got.Runnable())
+}
+
+func TestRunThreadCmd(t *testing.T) {
+	m, dsFile := initDatastoreManager(t)
+
+	id := func(n int) member.ID { return member.ID{Number: n} }
+
+	for _, mail := range []cmd.Mail{
+		// a second reply to b12cd3 starts a branch
+		{Ref: "a1a1a1", Sender: id(55), Receiver: id(1234), Date: "1986-06-01", Link: link.ToMail("b12cd3")},
+		{Ref: "c2c2c2", Sender: id(1234), Receiver: id(55), Date: "1986-06-20", Link: link.ToMail("b12cd3"), Status: cmd.StatusSent},
+		// the first letter of this conversation was never entered
+		{Ref: "d3d3d3", Sender: id(777), Receiver: id(1234), Date: "1990-01-01", Link: link.ToMail("deadbe")},
+		{Ref: "e4e4e4", Sender: id(1234), Receiver: id(777), Date: "1990-02-01", Link: link.ToMail("d3d3d3")},
+		// letters linked to each other by mistake
+		{Ref: "f6f6f6", Sender: id(888), Receiver: id(1234), Date: "1995-01-01", Link: link.ToMail("f5f5f5")},
+		{Ref: "f5f5f5", Sender: id(1234), Receiver: id(888), Date: "1995-02-01", Link: link.ToMail("f6f6f6")},
+		// a listing that was never imported
+		{Ref: "a9a9a9", Sender: id(1234), Receiver: id(999), Date: "1999-01-01", Link: link.ToListing(99)},
+	} {
+		r := mail
+		require.NoError(t, m.Save(&r))
+	}
+
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.RemoveAll("test/"))
+	}()
+
+	viper.Set(cmd.DatastoreFilenameKey, dsFile)
+
+	tests := []struct {
+		name      string
+		args      []string
+		output    string
+		assertion assert.ErrorAssertionFunc
+		want      string
+		notWant   string
+	}{
+		{
+			name:      "no argument",
+			args:      []string{},
+			assertion: assert.Error,
+			want:      "Error: accepts 1 arg(s), received 0",
+		},
+		{
+			name:      "unknown reference",
+			args:      []string{"zzzzzz"},
+			assertion: assert.NoError,
+			want:      "failed to find conversations:  mail not found: zzzzzz\n",
+		},
+		{
+			name:      "member without mail",
+			args:      []string{"42"},
+			assertion: assert.NoError,
+			want:      "No correspondences found.\n",
+		},
+		{
+			name:      "timeline",
+			args:      []string{"b12cd3"},
+			assertion: assert.NoError,
+			want: "| 1986 Mollit | L1        |   1234 |          |          |          | Esse Lorem do nulla sunt mollit nulla i… |\n" +
+				"|  1986-04-01 | 123d5f    |     55 |     1234 | L1       | received |                                          |\n" +
+				"|  1986-05-16 | b12cd3    |   1234 |       55 | M123d5f  | sent     |                                          |\n" +
+				"|  1986-06-01 | a1a1a1    |     55 |     1234 | Mb12cd3  |          |                                          |\n" +
+				"|  1986-06-20 | c2c2c2    |   1234 |       55 | Mb12cd3  | sent     |                                          |\n",
+			notWant: "6beef9",
+		},
+		{
+			name:      "tree",
+			args:      []string{"Mb12cd3", "--tree"},
+			assertion: assert.NoError,
+			want: "Conversation from listing L1:\n" +
+				"── L1 1986 Mollit 1234: Esse Lorem do nulla sunt mollit nulla i…\n" +
+				"   └─ 123d5f 1986-04-01 55 → 1234 [received]\n" +
+				"      └─ b12cd3 1986-05-16 1234 → 55 [sent]\n" +
+				"         ├─ a1a1a1 1986-06-01 55 → 1234\n" +
+				"         └─ c2c2c2 1986-06-20 1234 → 55 [sent]\n",
+		},
+		{
+			name:      "missing letter",
+			args:      []string{"777", "-t"},
+			assertion: assert.NoError,
+			want: "Conversation from deadbe:\n" +
+				"── deadbe (letter not found)\n" +
+				"   └─ d3d3d3 1990-01-01 777 → 1234\n" +
+				"      └─ e4e4e4 1990-02-01 1234 → 777\n",
+		},
+		{
+			name:      "missing letter timeline",
+			args:      []string{"e4e4e4"},
+			assertion: assert.NoError,
+			want: "|            | deadbe    |        |          |          |        | letter not found |\n" +
+				"| 1990-01-01 | d3d3d3    |    777 |     1234 | Mdeadbe  |        |                  |\n",
+		},
+		{
+			name:      "loop",
+			args:      []string{"888", "--tree"},
+			assertion: assert.NoError,
+			want: "Conversation from f5f5f5:\n" +
+				"── f5f5f5 1995-02-01 1234 → 888\n" +
+				"   └─ f6f6f6 1995-01-01 888 → 1234\n",
+		},
+		{
+			name:      "missing listing",
+			args:      []string{"a9a9a9"},
+			assertion: assert.NoError,
+			want:      "|            | L99       |        |          |          |        | listing not found |\n",
+		},
+		{
+			name:      "every conversation with a member",
+			args:      []string{"1234", "--tree"},
+			assertion: assert.NoError,
+			want: "      └─ e4e4e4 1990-02-01 1234 → 777\n\n" +
+				"Conversation from f5f5f5:\n" +
+				"── f5f5f5 1995-02-01 1234 → 888\n" +
+				"   └─ f6f6f6 1995-01-01 888 → 1234\n\n" +
+				"Conversation from listing L99:\n" +
+				"── L99 (listing not found)\n" +
+				"   └─ a9a9a9 1999-01-01 1234 → 999\n\n" +
+				"Conversation from 6beef9:\n" +
+				"── 6beef9 2021-03-15 1234 → 666 [returned]\n",
+		},
+		{
+			name:      "json",
+			args:      []string{"f6f6f6"},
+			output:    "json",
+			assertion: assert.NoError,
+			want: "        \"replies\": [\n          {\n            \"reference\": \"f6f6f6\",\n            \"mail\": {\n" +
+				"              \"ID\": 8,\n              \"reference\": \"f6f6f6\",\n",
+		},
+		{
+			name:      "csv ignores tree",
+			args:      []string{"e4e4e4", "--tree"},
+			output:    "csv",
+			assertion: assert.NoError,
+			want: "Date,Reference,Sender,Receiver,Reply To,Status,Note\n" +
+				",deadbe,,,,,letter not found\n" +
+				"1990-01-01,d3d3d3,777,1234,Mdeadbe,,\n",
+		},
+		{
+			name:      "csv keeps the full listing text",
+			args:      []string{"b12cd3"},
+			output:    "csv",
+			assertion: assert.NoError,
+			want:      "1986 Mollit,L1,1234,,,,Esse Lorem do nulla sunt mollit nulla in.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set(cmd.OutputKey, tt.output)
+			defer viper.Set(cmd.OutputKey, "")
+
+			c := cmd.NewThreadCmd()
+			b := bytes.NewBufferString("")
+			c.SetOut(b)
+			c.SetErr(b)
+			c.SetArgs(tt.args)
+
+			tt.assertion(t, c.Execute())
+
+			assert.Contains(t, b.String(), tt.want)
+
+			if tt.notWant != "" {
+				assert.NotContains(t, b.String(), tt.notWant)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+// Package address holds postal addresses and writes them the way the post office in each country expects.
+//
+// Formatting and postal code rules for common countries are compiled in from rules.json. Addresses in other
+// countries are written in a generic layout and their postal codes are not checked.
+package address
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidAddress is returned when an address is missing parts or has a malformed postal code.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// An Address is a postal address. Country is an ISO 3166 two letter code, like "US" or "GB".
+type Address struct {
+	Recipient  string   `json:"recipient,omitempty"`
+	Lines      []string `json:"lines,omitempty"`
+	Locality   string   `json:"locality,omitempty"`
+	Region     string   `json:"region,omitempty"`
+	PostalCode string   `json:"postal_code,omitempty"`
+	Country    string   `json:"country,omitempty"`
+}
+
+// IsZero reports whether no part of the address is set.
+func (a Address) IsZero() bool {
+	return a.Recipient == "" && len(a.Lines) == 0 && a.Locality == "" && a.Region == "" && a.PostalCode == "" &&
+		a.Country == ""
+}
+
+// Normalize trims every part of the address, drops blank street lines, and uppercases the country and postal
+// code.
+func (a Address) Normalize() Address {
+	n := Address{
+		Recipient:  strings.TrimSpace(a.Recipient),
+		Locality:   strings.TrimSpace(a.Locality),
+		Region:     strings.TrimSpace(a.Region),
+		PostalCode: strings.ToUpper(strings.TrimSpace(a.PostalCode)),
+		Country:    strings.ToUpper(strings.TrimSpace(a.Country)),
+	}
+
+	for _, l := range a.Lines {
+		if l = strings.TrimSpace(l); l != "" {
+			n.Lines = append(n.Lines, l)
+		}
+	}
+
+	return n
+}
+
+// Validate checks that the address has the parts its country requires and that the postal code has the
+// country's shape. An empty address is valid.
+func (a Address) Validate() error {
+	if a.IsZero() {
+		return nil
+	}
+
+	if len(a.Country) != 2 || !isUpperAlpha(a.Country) {
+		return fmt.Errorf("%w: country must be a two letter code: %q", ErrInvalidAddress, a.Country)
+	}
+
+	r, ok := RuleFor(a.Country)
+	if !ok {
+		return nil
+	}
+
+	for _, field := range r.Require {
+		if a.field(field) == "" {
+			return fmt.Errorf("%w: %s address needs a %s", ErrInvalidAddress, r.Name, fieldNames[field])
+		}
+	}
+
+	if a.PostalCode != "" && r.postalCode != nil && !r.postalCode.MatchString(a.PostalCode) {
+		return fmt.Errorf("%w: %q is not a %s postal code (like %q)", ErrInvalidAddress, a.PostalCode, r.Name,
+			r.Example)
+	}
+
+	return nil
+}
+
+// Format returns the lines of the address as they are written on an envelope. The country is written last, in
+// capitals, unless it is home, the country the letter is sent from.
+func (a Address) Format(home string) []string {
+	if a.IsZero() {
+		return []string{}
+	}
+
+	r, _ := RuleFor(a.Country)
+
+	lines := []string{}
+
+	for _, tmpl := range strings.Split(r.Format, "%n") {
+		var sb strings.Builder
+
+		hasValue := false
+
+		for i := 0; i < len(tmpl); i++ {
+			if tmpl[i] != '%' || i+1 == len(tmpl) {
+				sb.WriteByte(tmpl[i])
+				continue
+			}
+
+			i++
+			field := rune(tmpl[i])
+
+			if field == 'A' {
+				// street lines keep their own line breaks; text around them goes on the last one
+				for j, l := range a.Lines {
+					if j > 0 {
+						lines = append(lines, cleanLine(sb.String()))
+						sb.Reset()
+					}
+
+					sb.WriteString(r.upper(field, l))
+					hasValue = true
+				}
+
+				continue
+			}
+
+			if v := a.field(field); v != "" {
+				sb.WriteString(r.upper(field, v))
+				hasValue = true
+			}
+		}
+
+		if hasValue {
+			lines = append(lines, cleanLine(sb.String()))
+		}
+	}
+
+	if a.Country != "" && a.Country != strings.ToUpper(home) {
+		lines = append(lines, strings.ToUpper(CountryName(a.Country)))
+	}
+
+	return lines
+}
+
+// String returns the address on a single line.
+func (a Address) String() string {
+	return strings.Join(a.Format(""), ", ")
+}
+
+// UnmarshalJSON reads a structured address, or a free-form address string as stored by earlier versions. A
+// string keeps each of its lines as a street line.
+func (a *Address) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = Address{Lines: strings.Split(s, "\n")}.Normalize()
+
+		return nil
+	}
+
+	type address Address
+
+	var v address
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAddress, data)
+	}
+
+	*a = Address(v)
+
+	return nil
+}
+
+var fieldNames = map[rune]string{
+	'N': "recipient",
+	'A': "street address",
+	'C': "locality",
+	'S': "region",
+	'Z': "postal code",
+}
+
+func (a Address) field(f rune) string {
+	switch f {
+	case 'N':
+		return a.Recipient
+	case 'A':
+		return strings.Join(a.Lines, "\n")
+	case 'C':
+		return a.Locality
+	case 'S':
+		return a.Region
+	case 'Z':
+		return a.PostalCode
+	}
+
+	return ""
+}
+
+func (r Rule) upper(f rune, v string) string {
+	if strings.ContainsRune(r.Upper, f) {
+		return strings.ToUpper(v)
+	}
+
+	return v
+}
+
+// cleanLine removes separators left dangling by empty fields.
+func cleanLine(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, " ,", ",")
+
+	return strings.Trim(s, " ,-")
+}
+
+func isUpperAlpha(s string) bool {
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
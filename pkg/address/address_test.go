@@ -0,0 +1,226 @@
+package address_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/address"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		addr address.Address
+		home string
+		want []string
+	}{
+		{
+			name: "empty",
+			addr: address.Address{},
+			home: "US",
+			want: []string{},
+		},
+		{
+			name: "domestic us",
+			addr: address.Address{
+				Recipient:  "John Smith",
+				Lines:      []string{"123 Fake St", "Apt 4"},
+				Locality:   "Springfield",
+				Region:     "il",
+				PostalCode: "62701",
+				Country:    "US",
+			},
+			home: "us",
+			want: []string{"John Smith", "123 Fake St", "Apt 4", "SPRINGFIELD, IL 62701"},
+		},
+		{
+			name: "germany from us",
+			addr: address.Address{
+				Recipient:  "Erika Mustermann",
+				Lines:      []string{"Heidestraße 17"},
+				Locality:   "Köln",
+				PostalCode: "51147",
+				Country:    "DE",
+			},
+			home: "US",
+			want: []string{"Erika Mustermann", "Heidestraße 17", "51147 Köln", "GERMANY"},
+		},
+		{
+			name: "united kingdom",
+			addr: address.Address{
+				Lines:      []string{"10 Downing St"},
+				Locality:   "London",
+				PostalCode: "sw1a 2aa",
+				Country:    "GB",
+			},
+			home: "US",
+			want: []string{"10 Downing St", "LONDON", "SW1A 2AA", "UNITED KINGDOM"},
+		},
+		{
+			name: "missing region leaves no separator",
+			addr: address.Address{
+				Lines:      []string{"Rua Um, 10"},
+				Locality:   "Salvador",
+				PostalCode: "40301-110",
+				Country:    "BR",
+			},
+			home: "US",
+			want: []string{"Rua Um, 10", "SALVADOR", "40301-110", "BRAZIL"},
+		},
+		{
+			name: "country without rule",
+			addr: address.Address{
+				Lines:      []string{"Via Roma 1"},
+				Locality:   "Valletta",
+				PostalCode: "VLT 1117",
+				Country:    "MT",
+			},
+			home: "US",
+			want: []string{"Via Roma 1", "Valletta VLT 1117", "MT"},
+		},
+		{
+			name: "legacy free-form",
+			addr: address.Address{Lines: []string{"123 Fake St, Fakeville, FS 12345"}},
+			home: "US",
+			want: []string{"123 Fake St, Fakeville, FS 12345"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.addr.Format(tt.home))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	us := address.Address{Lines: []string{"1 Main St"}, Locality: "Springfield", Region: "IL", Country: "US"}
+
+	withCode := func(a address.Address, code string) address.Address {
+		a.PostalCode = code
+		return a
+	}
+
+	tests := []struct {
+		name      string
+		addr      address.Address
+		assertion assert.ErrorAssertionFunc
+		want      string
+	}{
+		{name: "empty", addr: address.Address{}, assertion: assert.NoError},
+		{name: "us zip", addr: withCode(us, "62701"), assertion: assert.NoError},
+		{name: "us zip+4", addr: withCode(us, "62701-1234"), assertion: assert.NoError},
+		{
+			name:      "us bad zip",
+			addr:      withCode(us, "6270"),
+			assertion: assert.Error,
+			want:      `invalid address: "6270" is not a United States postal code (like "95014")`,
+		},
+		{
+			name:      "us missing zip",
+			addr:      us,
+			assertion: assert.Error,
+			want:      "invalid address: United States address needs a postal code",
+		},
+		{
+			name: "canada",
+			addr: address.Address{
+				Lines: []string{"1 Rue"}, Locality: "Montreal", Region: "QC", PostalCode: "H3Z 2Y7", Country: "CA",
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "netherlands",
+			addr: address.Address{
+				Lines: []string{"Damrak 1"}, Locality: "Amsterdam", PostalCode: "1012LG", Country: "NL",
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "japan bad code",
+			addr: address.Address{
+				Lines: []string{"1-2-3 Shibuya"}, Region: "Tokyo", PostalCode: "1500002x", Country: "JP",
+			},
+			assertion: assert.Error,
+			want:      `invalid address: "1500002X" is not a Japan postal code (like "154-0023")`,
+		},
+		{
+			name:      "country without rule",
+			addr:      address.Address{Lines: []string{"Via Roma 1"}, PostalCode: "anything", Country: "MT"},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "missing country",
+			addr:      address.Address{Lines: []string{"1 Main St"}},
+			assertion: assert.Error,
+			want:      `invalid address: country must be a two letter code: ""`,
+		},
+		{
+			name:      "bad country",
+			addr:      address.Address{Lines: []string{"1 Main St"}, Country: "USA"},
+			assertion: assert.Error,
+			want:      `invalid address: country must be a two letter code: "USA"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.addr.Normalize().Validate()
+			tt.assertion(t, err)
+
+			if err != nil {
+				assert.ErrorIs(t, err, address.ErrInvalidAddress)
+				assert.EqualError(t, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	got := address.Address{
+		Recipient:  " Jane ",
+		Lines:      []string{" 1 Main St ", "", "  "},
+		PostalCode: " h3z 2y7",
+		Country:    "ca ",
+	}.Normalize()
+
+	assert.Equal(t, address.Address{Recipient: "Jane", Lines: []string{"1 Main St"}, PostalCode: "H3Z 2Y7", Country: "CA"}, got)
+}
+
+func TestAddressJSON(t *testing.T) {
+	var a address.Address
+
+	require.NoError(t, json.Unmarshal([]byte(`"123 Fake St\nFakeville"`), &a))
+	assert.Equal(t, address.Address{Lines: []string{"123 Fake St", "Fakeville"}}, a)
+
+	require.NoError(t, json.Unmarshal([]byte(`""`), &a))
+	assert.True(t, a.IsZero())
+
+	in := address.Address{Lines: []string{"10 Downing St"}, Locality: "London", PostalCode: "SW1A 2AA", Country: "GB"}
+	data, err := json.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, `{"lines":["10 Downing St"],"locality":"London","postal_code":"SW1A 2AA","country":"GB"}`, string(data))
+
+	require.NoError(t, json.Unmarshal(data, &a))
+	assert.Equal(t, in, a)
+
+	assert.Error(t, json.Unmarshal([]byte(`42`), &a))
+}
+
+func TestRuleFor(t *testing.T) {
+	r, ok := address.RuleFor("FR")
+	assert.True(t, ok)
+	assert.Equal(t, "France", r.Name)
+
+	_, ok = address.RuleFor("MT")
+	assert.False(t, ok)
+
+	_, ok = address.RuleFor("default")
+	assert.False(t, ok)
+
+	assert.Equal(t, "Germany", address.CountryName("DE"))
+	assert.Equal(t, "MT", address.CountryName("MT"))
+}
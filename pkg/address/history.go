@@ -0,0 +1,59 @@
+package address
+
+import (
+	"reflect"
+	"sort"
+)
+
+// An Entry is an address used from a date on. From is a 'yyyy-mm-dd' date; an entry without one has been in use
+// for as long as is known. Source records where the address came from, like a listing ("L12") or a letter
+// ("Mf2165e").
+type Entry struct {
+	Address Address `json:"address"`
+	From    string  `json:"from,omitempty"`
+	Source  string  `json:"source,omitempty"`
+}
+
+// A History is the addresses used over time, oldest first.
+type History []Entry
+
+// Add records an address, keeping the history in date order. An entry from the same date as e is replaced.
+func (h History) Add(e Entry) History {
+	i := sort.Search(len(h), func(i int) bool { return h[i].From >= e.From })
+
+	if i < len(h) && h[i].From == e.From {
+		h = append(History{}, h...)
+		h[i] = e
+
+		return h
+	}
+
+	h = append(h[:i:i], append(History{e}, h[i:]...)...)
+
+	return h
+}
+
+// Current returns the newest entry. It is false if there is no history.
+func (h History) Current() (Entry, bool) {
+	if len(h) == 0 {
+		return Entry{}, false
+	}
+
+	return h[len(h)-1], true
+}
+
+// At returns the entry in use on a 'yyyy-mm-dd' date. It is false if the history starts after the date.
+func (h History) At(date string) (Entry, bool) {
+	for i := len(h) - 1; i >= 0; i-- {
+		if h[i].From <= date {
+			return h[i], true
+		}
+	}
+
+	return Entry{}, false
+}
+
+// Equal reports whether two addresses are the same.
+func (a Address) Equal(b Address) bool {
+	return reflect.DeepEqual(a.Normalize(), b.Normalize())
+}
@@ -0,0 +1,72 @@
+package address_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/asphaltbuffet/ogma/pkg/address"
+)
+
+func TestHistory(t *testing.T) {
+	first := address.Address{Lines: []string{"1 Old Rd"}, Country: "US"}
+	second := address.Address{Lines: []string{"2 New St"}, Country: "US"}
+	third := address.Address{Lines: []string{"Heidestraße 17"}, Country: "DE"}
+
+	var h address.History
+
+	_, ok := h.Current()
+	assert.False(t, ok)
+
+	h = h.Add(address.Entry{Address: second, From: "2021-06-01", Source: "L3"})
+	h = h.Add(address.Entry{Address: first})
+	h = h.Add(address.Entry{Address: third, From: "2022-01-15", Source: "Mf2165e"})
+
+	assert.Equal(t, address.History{
+		{Address: first},
+		{Address: second, From: "2021-06-01", Source: "L3"},
+		{Address: third, From: "2022-01-15", Source: "Mf2165e"},
+	}, h)
+
+	cur, ok := h.Current()
+	assert.True(t, ok)
+	assert.Equal(t, third, cur.Address)
+
+	tests := []struct {
+		date string
+		want address.Address
+	}{
+		{date: "1999-01-01", want: first},
+		{date: "2021-05-31", want: first},
+		{date: "2021-06-01", want: second},
+		{date: "2021-12-31", want: second},
+		{date: "2023-01-01", want: third},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			got, ok := h.At(tt.date)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, got.Address)
+		})
+	}
+
+	// an entry on the same date replaces the one there
+	fixed := address.Address{Lines: []string{"2 New Street"}, Country: "US"}
+	h2 := h.Add(address.Entry{Address: fixed, From: "2021-06-01"})
+
+	assert.Len(t, h2, 3)
+	assert.Equal(t, fixed, h2[1].Address)
+	assert.Equal(t, second, h[1].Address, "original history is unchanged")
+
+	dated := address.History{}.Add(address.Entry{Address: second, From: "2021-06-01"})
+	_, ok = dated.At("2021-01-01")
+	assert.False(t, ok)
+}
+
+func TestAddressEqual(t *testing.T) {
+	a := address.Address{Lines: []string{"1 Main St"}, PostalCode: "h3z 2y7", Country: "CA"}
+
+	assert.True(t, a.Equal(address.Address{Lines: []string{" 1 Main St", ""}, PostalCode: "H3Z 2Y7", Country: "ca"}))
+	assert.False(t, a.Equal(address.Address{Lines: []string{"1 Main St"}, Country: "CA"}))
+}
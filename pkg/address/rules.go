@@ -0,0 +1,65 @@
+package address
+
+import (
+	_ "embed" // country rules are compiled in
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+//go:embed rules.json
+var rulesJSON []byte
+
+// A Rule describes how addresses are written in a country.
+//
+// Format is a template of address fields: %N recipient, %A street lines, %C locality, %S region, %Z postal code,
+// and %n a line break. Upper lists the fields written in capitals and Require the fields an address must have.
+type Rule struct {
+	Name       string `json:"name"`
+	Format     string `json:"format"`
+	Upper      string `json:"upper"`
+	PostalCode string `json:"postal_code"`
+	Example    string `json:"example"`
+	Require    string `json:"require"`
+
+	postalCode *regexp.Regexp
+}
+
+var rules = loadRules()
+
+func loadRules() map[string]Rule {
+	rr := map[string]Rule{}
+	if err := json.Unmarshal(rulesJSON, &rr); err != nil {
+		panic(fmt.Sprintf("address: invalid rules table: %v", err))
+	}
+
+	for code, r := range rr {
+		if r.PostalCode != "" {
+			r.postalCode = regexp.MustCompile(`^(?:` + r.PostalCode + `)$`)
+		}
+
+		rr[code] = r
+	}
+
+	return rr
+}
+
+// RuleFor returns the addressing rule for a country code. Countries without their own rule use a generic one,
+// and ok is false.
+func RuleFor(country string) (r Rule, ok bool) {
+	r, ok = rules[country]
+	if !ok || country == "default" {
+		return rules["default"], false
+	}
+
+	return r, true
+}
+
+// CountryName returns the english name of a country, or the code itself when the country has no rule.
+func CountryName(country string) string {
+	if r, ok := RuleFor(country); ok {
+		return r.Name
+	}
+
+	return country
+}
@@ -0,0 +1,350 @@
+// Package adtext reads LEX listings from the typed text of a magazine page.
+//
+// A page is a run of ads under category headings. Each ad ends with the number of the member who placed it, like
+// "#1234" or "#1234A", and ads are usually separated by blank lines. Markers in an ad, like "(I)" for ads open to
+// international members, "(R)" for reviews, and "(A)" for ads with art, set the listing's flags and are taken out
+// of its text.
+//
+// Typed pages are rarely tidy, so every ad is given a confidence score, from 0 to 1, with the problems that
+// lowered it. Ads with a low score are the ones to check by hand.
+package adtext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+// Words in an ad outside of these bounds suggest the ad was split or run together with another.
+const (
+	MinWords = 4
+	MaxWords = 100
+)
+
+// An Ad is a listing read from a page, with how sure the parser is that it was read correctly.
+type Ad struct {
+	Listing lstg.Listing
+
+	// Line is the line of the page the ad starts on, counting from 1.
+	Line int
+
+	// Confidence is from 0 to 1. Each problem found lowers it.
+	Confidence float64
+	Problems   []string
+}
+
+// Options describe the page being read.
+type Options struct {
+	Issue int
+	Page  int
+
+	// Volume, Year, and Season are those of the issue, and are left empty when they aren't known.
+	Volume int
+	Year   int
+	Season string
+
+	// Categories are the known category names. Headings are matched to them in any case, and are written as they
+	// are here. Any heading is accepted when there are none.
+	Categories []string
+}
+
+var (
+	// the member number ending an ad, like "#1234", "# 1234-a", or "1234A."
+	trailingMember = regexp.MustCompile(`(?:^|[\s(])(#\s*)?(\d{1,5})(?:-?([A-Za-z]))?[.)]?$`)
+
+	// member numbers left in an ad's text
+	innerMember = regexp.MustCompile(`#\s*\d{1,5}[A-Za-z]?\b`)
+
+	// markers written in parentheses or brackets, like "(I)" or "[ART]"
+	marker = regexp.MustCompile(`[(\[]\s*([A-Za-z']{1,8})\s*[)\]]`)
+
+	// international ads marked without brackets
+	bareInternational = regexp.MustCompile(`\bINT'?L\b`)
+
+	spaces = regexp.MustCompile(`\s+`)
+)
+
+// A flagSetter sets the flag of a listing named by a marker.
+type flagSetter func(l *lstg.Listing)
+
+var markers = map[string]flagSetter{
+	"I":      func(l *lstg.Listing) { l.IsInternational = true },
+	"INTL":   func(l *lstg.Listing) { l.IsInternational = true },
+	"INT'L":  func(l *lstg.Listing) { l.IsInternational = true },
+	"R":      func(l *lstg.Listing) { l.IsReview = true },
+	"REV":    func(l *lstg.Listing) { l.IsReview = true },
+	"REVIEW": func(l *lstg.Listing) { l.IsReview = true },
+	"A":      func(l *lstg.Listing) { l.IsArt = true },
+	"ART":    func(l *lstg.Listing) { l.IsArt = true },
+	"SKETCH": func(l *lstg.Listing) { l.IsArt = true },
+}
+
+// Parse reads the ads of a page. Headings set the category of the ads after them, and each ad ends at a blank
+// line or at a line ending with a member number.
+func Parse(r io.Reader, opts Options) ([]Ad, error) {
+	p := &parser{opts: opts}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1<<20)
+
+	for n := 1; sc.Scan(); n++ {
+		p.line(n, sc.Text())
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading page: %w", err)
+	}
+
+	p.finish()
+
+	return p.ads, nil
+}
+
+// A parser keeps the category and the ad being read while a page is read line by line.
+type parser struct {
+	opts     Options
+	ads      []Ad
+	category string
+	problem  string
+	lines    []string
+	start    int
+}
+
+// line reads a line of the page.
+func (p *parser) line(n int, s string) {
+	s = strings.TrimSpace(strings.TrimPrefix(s, "\ufeff"))
+
+	if s == "" {
+		p.finish()
+		return
+	}
+
+	// headings are only read between ads, unless they name a known category
+	if heading, known := p.heading(s); heading != "" && (len(p.lines) == 0 || known) {
+		p.finish()
+		p.category = heading
+		p.problem = ""
+
+		if !known && len(p.opts.Categories) > 0 {
+			p.problem = fmt.Sprintf("heading %q isn't a known category", heading)
+		}
+
+		return
+	}
+
+	if len(p.lines) == 0 {
+		p.start = n
+	}
+
+	p.lines = append(p.lines, s)
+
+	// a number ends an ad before a blank line only when it is marked as a member number
+	if m := trailingMember.FindStringSubmatch(s); m != nil && m[1] != "" {
+		p.finish()
+	}
+}
+
+// heading returns the category a line names when it is a heading, and whether it is a known category. Headings
+// are short lines in capitals without numbers, or the name of a known category.
+func (p *parser) heading(s string) (string, bool) {
+	name := strings.TrimSpace(strings.TrimRight(s, ":"))
+
+	for _, c := range p.opts.Categories {
+		if strings.EqualFold(name, c) {
+			return c, true
+		}
+	}
+
+	if len(strings.Fields(name)) > 4 || strings.IndexFunc(name, unicode.IsDigit) >= 0 {
+		return "", false
+	}
+
+	if strings.IndexFunc(name, unicode.IsLetter) < 0 || strings.IndexFunc(name, unicode.IsLower) >= 0 {
+		return "", false
+	}
+
+	// an ad marker on a line of its own isn't a heading
+	if _, ok := markers[strings.Trim(name, "()[] ")]; ok {
+		return "", false
+	}
+
+	return headingCase(name), false
+}
+
+// headingCase writes a heading typed in capitals with a capital at the start of each word, like "Pen Pals".
+func headingCase(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for i, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// finish ends the ad being read, if there is one.
+func (p *parser) finish() {
+	if len(p.lines) == 0 {
+		return
+	}
+
+	ad := p.ad(joinLines(p.lines))
+	ad.Line = p.start
+
+	p.ads = append(p.ads, ad)
+	p.lines = nil
+}
+
+// joinLines joins the lines of an ad, mending words broken across lines with a hyphen.
+func joinLines(lines []string) string {
+	var b strings.Builder
+
+	for i, l := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			next, _ := utf8.DecodeRuneInString(l)
+
+			if strings.HasSuffix(prev, "-") && unicode.IsLower(next) {
+				s := b.String()
+				b.Reset()
+				b.WriteString(strings.TrimSuffix(s, "-"))
+			} else {
+				b.WriteString(" ")
+			}
+		}
+
+		b.WriteString(l)
+	}
+
+	return b.String()
+}
+
+// ad reads the listing from the text of an ad, and scores it.
+func (p *parser) ad(text string) Ad {
+	ad := Ad{
+		Listing: lstg.Listing{
+			Volume:          p.opts.Volume,
+			IssueNumber:     p.opts.Issue,
+			Year:            p.opts.Year,
+			Season:          p.opts.Season,
+			PageNumber:      p.opts.Page,
+			IndexedCategory: p.category,
+		},
+		Confidence: 1,
+	}
+
+	text = p.member(&ad, text)
+	text = p.markers(&ad, text)
+	text = strings.TrimSpace(spaces.ReplaceAllString(text, " "))
+	text = strings.TrimRight(text, " -–—")
+
+	ad.Listing.ListingText = text
+
+	switch {
+	case p.category == "":
+		ad.problem(0.2, "no category heading above the ad")
+	case p.problem != "":
+		ad.problem(0.1, p.problem)
+	}
+
+	if innerMember.MatchString(text) {
+		ad.problem(0.3, "text holds another member number, ads may be run together")
+	}
+
+	switch words := len(strings.Fields(text)); {
+	case words < MinWords:
+		ad.problem(0.2, "text is very short")
+	case words > MaxWords:
+		ad.problem(0.2, "text is very long, ads may be run together")
+	}
+
+	ad.Confidence = math.Round(math.Max(ad.Confidence, 0)*100) / 100
+
+	return ad
+}
+
+// member reads the member number ending an ad, and returns the text without it.
+func (p *parser) member(ad *Ad, text string) string {
+	m := trailingMember.FindStringSubmatchIndex(text)
+	if m == nil {
+		ad.problem(0.5, "no member number at the end of the ad")
+		return text
+	}
+
+	hash := m[2] >= 0
+	digits := text[m[4]:m[5]]
+
+	ext := ""
+	if m[6] >= 0 {
+		ext = text[m[6]:m[7]]
+	}
+
+	id, err := member.Parse(digits + ext)
+	if err != nil {
+		ad.problem(0.5, fmt.Sprintf("member number %q can't be read", digits+ext))
+		return text
+	}
+
+	ad.Listing.IndexedMemberNumber = id.Number
+	ad.Listing.MemberExtension = id.Extension
+
+	if !hash {
+		ad.problem(0.1, "member number isn't marked with '#'")
+
+		if n, _ := strconv.Atoi(digits); len(digits) == 4 && ext == "" && n >= lstg.MinYear && n <= 2100 {
+			ad.problem(0.2, "member number looks like a year")
+		}
+	}
+
+	return text[:m[0]]
+}
+
+// markers sets the flags an ad is marked with, and returns the text without the markers.
+func (p *parser) markers(ad *Ad, text string) string {
+	text = marker.ReplaceAllStringFunc(text, func(s string) string {
+		raw := marker.FindStringSubmatch(s)[1]
+		name := strings.ToUpper(raw)
+
+		// lowercase letters in brackets number the points of an ad, like "(a)" and "(b)"
+		if len(raw) == 1 && raw != name {
+			return s
+		}
+
+		set, ok := markers[name]
+		if !ok {
+			// single letters in brackets are most likely markers that were mistyped
+			if len(name) == 1 {
+				ad.problem(0.1, fmt.Sprintf("unknown marker %q", s))
+			}
+
+			return s
+		}
+
+		set(&ad.Listing)
+
+		return " "
+	})
+
+	if bareInternational.MatchString(text) {
+		ad.Listing.IsInternational = true
+		text = bareInternational.ReplaceAllString(text, " ")
+	}
+
+	return text
+}
+
+// problem lowers the confidence of an ad by a penalty, and records why.
+func (ad *Ad) problem(penalty float64, why string) {
+	ad.Confidence -= penalty
+	ad.Problems = append(ad.Problems, why)
+}
@@ -0,0 +1,122 @@
+package adtext_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/adtext"
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+)
+
+const page = `PEN PALS
+
+Looking for letters about trains, stamps, and tea. Write me! #1234
+(I) Trading zines and collage from abroad, all
+ages welcome. #2345b
+
+ART & PHOTOGRAPHY:
+Photographer seeking models for a portrait series. [ART] (R) # 3456-A
+
+Painter looking for a studio share downtown 4567.
+
+Knitting circle meets on Sundays, bring yarn (X)
+`
+
+func TestParse(t *testing.T) {
+	ads, err := adtext.Parse(strings.NewReader(page), adtext.Options{Issue: 56, Page: 3, Volume: 14, Year: 2021, Season: "Spring"})
+	require.NoError(t, err)
+	require.Len(t, ads, 5)
+
+	assert.Equal(t, adtext.Ad{
+		Listing: lstg.Listing{
+			Volume:              14,
+			IssueNumber:         56,
+			Year:                2021,
+			Season:              "Spring",
+			PageNumber:          3,
+			IndexedCategory:     "Pen Pals",
+			IndexedMemberNumber: 1234,
+			ListingText:         "Looking for letters about trains, stamps, and tea. Write me!",
+		},
+		Line:       3,
+		Confidence: 1,
+	}, ads[0])
+
+	// ads end at a member number, and words broken across lines are joined
+	assert.Equal(t, 4, ads[1].Line)
+	assert.Equal(t, 2345, ads[1].Listing.IndexedMemberNumber)
+	assert.Equal(t, "B", ads[1].Listing.MemberExtension)
+	assert.True(t, ads[1].Listing.IsInternational)
+	assert.Equal(t, "Trading zines and collage from abroad, all ages welcome.", ads[1].Listing.ListingText)
+
+	assert.Equal(t, "Art & Photography", ads[2].Listing.IndexedCategory)
+	assert.True(t, ads[2].Listing.IsArt)
+	assert.True(t, ads[2].Listing.IsReview)
+	assert.Equal(t, 3456, ads[2].Listing.IndexedMemberNumber)
+	assert.Equal(t, "A", ads[2].Listing.MemberExtension)
+	assert.Equal(t, "Photographer seeking models for a portrait series.", ads[2].Listing.ListingText)
+
+	assert.Equal(t, 4567, ads[3].Listing.IndexedMemberNumber)
+	assert.Equal(t, 0.9, ads[3].Confidence)
+	assert.Equal(t, []string{"member number isn't marked with '#'"}, ads[3].Problems)
+
+	assert.Equal(t, 0, ads[4].Listing.IndexedMemberNumber)
+	assert.Equal(t, 0.4, ads[4].Confidence)
+	assert.Equal(t, []string{"no member number at the end of the ad", "unknown marker \"(X)\""}, ads[4].Problems)
+}
+
+func TestParseProblems(t *testing.T) {
+	tests := []struct {
+		name       string
+		page       string
+		categories []string
+		want       []string
+		confidence float64
+	}{
+		{
+			name:       "no heading",
+			page:       "Looking for letters about trains. #1234\n",
+			want:       []string{"no category heading above the ad"},
+			confidence: 0.8,
+		},
+		{
+			name:       "unknown heading",
+			page:       "PEN PALZ\nLooking for letters about trains. #1234\n",
+			categories: []string{"Pen Pals"},
+			want:       []string{"heading \"Pen Palz\" isn't a known category"},
+			confidence: 0.9,
+		},
+		{
+			name:       "run together",
+			page:       "Pen Pals\nLooking for letters. #1234 Seeking stamps from Peru. #2345\n",
+			categories: []string{"Pen Pals"},
+			want:       []string{"text holds another member number, ads may be run together"},
+			confidence: 0.7,
+		},
+		{
+			name:       "year",
+			page:       "PEN PALS\nBorn and raised here since 1985\n",
+			want:       []string{"member number isn't marked with '#'", "member number looks like a year"},
+			confidence: 0.7,
+		},
+		{
+			name:       "short",
+			page:       "PEN PALS\nHi! #1234\n",
+			want:       []string{"text is very short"},
+			confidence: 0.8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ads, err := adtext.Parse(strings.NewReader(tt.page), adtext.Options{Categories: tt.categories})
+			require.NoError(t, err)
+			require.Len(t, ads, 1)
+			assert.Equal(t, tt.want, ads[0].Problems)
+			assert.Equal(t, tt.confidence, ads[0].Confidence)
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package datastore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// SnapshotTimeFormat is the timestamp format used in snapshot filenames.
+const SnapshotTimeFormat = "20060102T150405.000"
+
+// ErrSnapshotNotFound is returned when a named snapshot does not exist.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+var snapshotPattern = regexp.MustCompile(`^(.+)-(\d{8}T\d{6}\.\d{3})(?:-([a-z0-9-]+))?\.db$`)
+
+// A Snapshot is a point-in-time copy of a datastore file.
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Label   string    `json:"label"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+// Retention controls which snapshots are kept when pruning. Zero values disable a rule. Keep applies to each label
+// on its own, so snapshots taken automatically before a change never push out the ones taken by hand.
+type Retention struct {
+	Keep   int
+	MaxAge time.Duration
+}
+
+// Backup writes a consistent copy of the datastore to dir from within a read transaction. The optional
+// label is appended to the snapshot name to record why it was taken.
+func (m *Manager) Backup(dir string, label string) (Snapshot, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return Snapshot{}, fmt.Errorf("error creating backup directory: %w", err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(m.filePath), filepath.Ext(m.filePath))
+	path := snapshotPath(dir, base, label, time.Now())
+
+	// never overwrite an existing snapshot taken within the same millisecond
+	for {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+		path = snapshotPath(dir, base, label, time.Now())
+	}
+
+	err := m.Store.Bolt.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(path, 0o600)
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"snapshot": path,
+		}).Error("error writing snapshot: ", err)
+
+		return Snapshot{}, fmt.Errorf("error writing snapshot: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"snapshot": path,
+	}).Info("created datastore snapshot")
+
+	return snapshotFromPath(path)
+}
+
+// Snapshots returns all snapshots found in dir, newest first. A missing directory has no snapshots.
+func Snapshots(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Snapshot{}, nil
+		}
+
+		return nil, fmt.Errorf("error reading backup directory: %w", err)
+	}
+
+	snapshots := []Snapshot{}
+
+	for _, e := range entries {
+		if e.IsDir() || !snapshotPattern.MatchString(e.Name()) {
+			continue
+		}
+
+		s, err := snapshotFromPath(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, s)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.After(snapshots[j].Created)
+	})
+
+	return snapshots, nil
+}
+
+// FindSnapshot returns the snapshot in dir with the given name. The '.db' extension is optional.
+func FindSnapshot(dir string, name string) (Snapshot, error) {
+	if !strings.HasSuffix(name, ".db") {
+		name += ".db"
+	}
+
+	if !snapshotPattern.MatchString(name) {
+		return Snapshot{}, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+
+	s, err := snapshotFromPath(filepath.Join(dir, filepath.Base(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+
+	return s, err
+}
+
+// Prune removes snapshots in dir that fall outside the retention rules and returns the removed snapshots.
+func Prune(dir string, r Retention) ([]Snapshot, error) {
+	snapshots, err := Snapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []Snapshot{}
+	cutoff := time.Now().Add(-r.MaxAge)
+	kept := map[string]int{}
+
+	for _, s := range snapshots {
+		kept[s.Label]++
+
+		tooMany := r.Keep > 0 && kept[s.Label] > r.Keep
+		tooOld := r.MaxAge > 0 && s.Created.Before(cutoff)
+
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := os.Remove(s.Path); err != nil {
+			return removed, fmt.Errorf("error removing snapshot %s: %w", s.Name, err)
+		}
+
+		log.WithFields(log.Fields{
+			"snapshot": s.Path,
+		}).Info("pruned datastore snapshot")
+
+		removed = append(removed, s)
+	}
+
+	return removed, nil
+}
+
+// Verify checks that a datastore file opens, passes a consistency check, and has a supported schema version.
+func Verify(path string) error {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer func() {
+		if errClose := db.Close(); errClose != nil {
+			log.Error("failed to close verified datastore: ", errClose)
+		}
+	}()
+
+	return db.View(func(tx *bolt.Tx) error {
+		var errCheck error
+
+		// drain every error so the checker goroutine finishes before the transaction closes
+		for e := range tx.Check() {
+			if errCheck == nil {
+				errCheck = e
+			}
+		}
+
+		if errCheck != nil {
+			return fmt.Errorf("consistency check failed: %w", errCheck)
+		}
+
+		var v int
+
+		if b := tx.Bucket([]byte(MetadataBucket)); b != nil {
+			if raw := b.Get([]byte(SchemaVersionKey)); raw != nil {
+				if errDecode := json.Unmarshal(raw, &v); errDecode != nil {
+					return fmt.Errorf("error reading schema version: %w", errDecode)
+				}
+			}
+		}
+
+		if v > LatestSchemaVersion() {
+			return fmt.Errorf("%w: datastore=%d supported=%d", ErrSchemaTooNew, v, LatestSchemaVersion())
+		}
+
+		return nil
+	})
+}
+
+// Restore replaces the datastore at filePath with a verified copy of the snapshot. The datastore must not be open.
+func Restore(s Snapshot, filePath string) error {
+	if err := Verify(s.Path); err != nil {
+		return fmt.Errorf("snapshot %s failed verification: %w", s.Name, err)
+	}
+
+	src, err := os.Open(filepath.Clean(s.Path))
+	if err != nil {
+		return fmt.Errorf("error opening snapshot: %w", err)
+	}
+	defer func() {
+		if errClose := src.Close(); errClose != nil {
+			log.Error("failed to close snapshot: ", errClose)
+		}
+	}()
+
+	// copy next to the target first so a failed copy never leaves a partial datastore behind
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".restore-*")
+	if err != nil {
+		return fmt.Errorf("error creating restore file: %w", err)
+	}
+
+	if _, err = io.Copy(tmp, src); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("error copying snapshot: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("error closing restore file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), filePath); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("error replacing datastore: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"snapshot": s.Path,
+		"filePath": filePath,
+	}).Info("restored datastore snapshot")
+
+	return nil
+}
+
+func snapshotPath(dir string, base string, label string, t time.Time) string {
+	name := fmt.Sprintf("%s-%s", base, t.Format(SnapshotTimeFormat))
+
+	if label != "" {
+		name += "-" + label
+	}
+
+	return filepath.Join(dir, name+".db")
+}
+
+func snapshotFromPath(path string) (Snapshot, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("error reading snapshot: %w", err)
+	}
+
+	match := snapshotPattern.FindStringSubmatch(fi.Name())
+	if match == nil {
+		return Snapshot{}, fmt.Errorf("%w: %s", ErrSnapshotNotFound, fi.Name())
+	}
+
+	created, err := time.ParseInLocation(SnapshotTimeFormat, match[2], time.Local)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("invalid snapshot timestamp %s: %w", match[2], err)
+	}
+
+	return Snapshot{
+		Name:    fi.Name(),
+		Path:    path,
+		Label:   match[3],
+		Created: created,
+		Size:    fi.Size(),
+	}, nil
+}
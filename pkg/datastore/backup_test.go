@@ -0,0 +1,183 @@
+package datastore_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+)
+
+func TestManagerBackup(t *testing.T) {
+	manager, dbFilePath := initDatastoreManager(t)
+	dir := t.TempDir()
+
+	defer func() {
+		manager.Stop()
+		require.NoError(t, os.Remove(dbFilePath))
+	}()
+
+	s, err := manager.Backup(dir, "pre-delete")
+	require.NoError(t, err)
+
+	assert.Equal(t, "pre-delete", s.Label)
+	assert.FileExists(t, s.Path)
+	assert.WithinDuration(t, time.Now(), s.Created, time.Minute)
+	assert.NoError(t, datastore.Verify(s.Path))
+
+	// snapshot contains the same records as the datastore
+	m, err := datastore.Open(s.Path)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	var got []testEntry
+	require.NoError(t, m.All(&got))
+	assert.Len(t, got, 3)
+}
+
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+
+	ss, err := datastore.Snapshots(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, ss)
+
+	names := []string{
+		"ogma-20211115T120000.000.db",
+		"ogma-20211116T120000.000-pre-delete.db",
+		"ogma-20211114T120000.000.db",
+		"notes.txt",
+		"ogma.db",
+	}
+
+	for _, n := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, n), []byte{}, 0o600))
+	}
+
+	ss, err = datastore.Snapshots(dir)
+	require.NoError(t, err)
+	require.Len(t, ss, 3)
+
+	assert.Equal(t, "ogma-20211116T120000.000-pre-delete.db", ss[0].Name)
+	assert.Equal(t, "pre-delete", ss[0].Label)
+	assert.Equal(t, "ogma-20211114T120000.000.db", ss[2].Name)
+
+	s, err := datastore.FindSnapshot(dir, "ogma-20211115T120000.000")
+	require.NoError(t, err)
+	assert.Equal(t, "ogma-20211115T120000.000.db", s.Name)
+
+	_, err = datastore.FindSnapshot(dir, "ogma.db")
+	assert.ErrorIs(t, err, datastore.ErrSnapshotNotFound)
+
+	_, err = datastore.FindSnapshot(dir, "ogma-20001115T120000.000")
+	assert.ErrorIs(t, err, datastore.ErrSnapshotNotFound)
+}
+
+func TestPrune(t *testing.T) {
+	// snapshots taken by hand have no label, and automatic ones are labelled with the change they came before
+	snapshots := []struct {
+		id    string
+		label string
+		age   time.Duration
+	}{
+		{id: "new", age: time.Hour},
+		{id: "mid", age: 24 * time.Hour},
+		{id: "old", age: 48 * time.Hour},
+		{id: "auto-new", label: "pre-delete", age: 2 * time.Hour},
+		{id: "auto-mid", label: "pre-delete", age: 3 * time.Hour},
+		{id: "auto-old", label: "pre-delete", age: 4 * time.Hour},
+	}
+
+	tests := []struct {
+		name      string
+		retention datastore.Retention
+		wantLeft  []string
+	}{
+		{
+			name:      "no rules",
+			retention: datastore.Retention{},
+			wantLeft:  []string{"new", "auto-new", "auto-mid", "auto-old", "mid", "old"},
+		},
+		{
+			name:      "keep newest two of each label",
+			retention: datastore.Retention{Keep: 2},
+			wantLeft:  []string{"new", "auto-new", "auto-mid", "mid"},
+		},
+		{
+			name:      "max age",
+			retention: datastore.Retention{MaxAge: 36 * time.Hour},
+			wantLeft:  []string{"new", "auto-new", "auto-mid", "auto-old", "mid"},
+		},
+		{
+			name:      "keep and max age",
+			retention: datastore.Retention{Keep: 1, MaxAge: 36 * time.Hour},
+			wantLeft:  []string{"new", "auto-new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Now()
+			ids := map[string]string{}
+
+			for _, s := range snapshots {
+				n := "ogma-" + now.Add(-s.age).Format(datastore.SnapshotTimeFormat)
+				if s.label != "" {
+					n += "-" + s.label
+				}
+
+				n += ".db"
+				ids[n] = s.id
+
+				require.NoError(t, os.WriteFile(filepath.Join(dir, n), []byte{}, 0o600))
+			}
+
+			_, err := datastore.Prune(dir, tt.retention)
+			require.NoError(t, err)
+
+			ss, err := datastore.Snapshots(dir)
+			require.NoError(t, err)
+
+			got := []string{}
+			for _, s := range ss {
+				got = append(got, ids[s.Name])
+			}
+
+			assert.Equal(t, tt.wantLeft, got)
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	manager, dbFilePath := initDatastoreManager(t)
+	dir := t.TempDir()
+
+	defer func() {
+		require.NoError(t, os.Remove(dbFilePath))
+	}()
+
+	s, err := manager.Backup(dir, "")
+	require.NoError(t, err)
+
+	require.NoError(t, manager.Save(&testEntry{Key: 42, Value: "Added"}))
+	manager.Stop()
+
+	bad := datastore.Snapshot{Name: "bad", Path: filepath.Join(dir, "bad.db")}
+	require.NoError(t, os.WriteFile(bad.Path, []byte("not a datastore"), 0o600))
+	assert.Error(t, datastore.Restore(bad, dbFilePath))
+
+	require.NoError(t, datastore.Restore(s, dbFilePath))
+
+	m, err := datastore.Open(dbFilePath)
+	require.NoError(t, err)
+	defer m.Stop()
+
+	var got []testEntry
+	require.NoError(t, m.All(&got))
+	assert.Len(t, got, 3)
+}
@@ -2,6 +2,8 @@
 package datastore
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
 
@@ -11,6 +13,12 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// storm keeps auto-increment counters in a metadata bucket inside each type bucket.
+const (
+	stormMetadataBucket = "__storm_metadata"
+	idCounterKey        = "IDcounter"
+)
+
 //go:generate mockery --output=../../mocks --log-level=warn --name=Saver
 // A Saver can write to a datastore.
 type Saver interface {
@@ -37,8 +45,32 @@ type Manager struct {
 	filePath string
 }
 
-// New returns a new datastore Manager.
-func New(filePath string) (*Manager, error) {
+// An Option configures how a datastore is opened.
+type Option func(*options)
+
+type options struct {
+	skipMigrations bool
+}
+
+// WithoutMigrations opens the datastore without applying pending schema migrations.
+func WithoutMigrations() Option {
+	return func(o *options) {
+		o.skipMigrations = true
+	}
+}
+
+// New returns a new datastore Manager. A datastore created here is brought up to the latest schema. Migrations
+// can change stored records, so an existing datastore with pending migrations isn't opened until it is upgraded
+// with Migrate, unless migrations are disabled.
+func New(filePath string, opts ...Option) (*Manager, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	_, statErr := os.Stat(filePath)
+	created := errors.Is(statErr, os.ErrNotExist)
+
 	storm, err := storm.Open(filePath)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -48,14 +80,40 @@ func New(filePath string) (*Manager, error) {
 		return nil, fmt.Errorf("error opening datastore file: %w", err)
 	}
 
-	return &Manager{
+	m := &Manager{
 		Store:    storm,
 		filePath: filePath,
-	}, nil
+	}
+
+	if o.skipMigrations {
+		return m, nil
+	}
+
+	if !created {
+		if err = m.checkSchema(); err != nil {
+			m.Stop()
+
+			return nil, err
+		}
+
+		return m, nil
+	}
+
+	if _, err = m.Migrate(false); err != nil {
+		log.WithFields(log.Fields{
+			"filePath": filePath,
+		}).Error("error migrating datastore: ", err)
+
+		m.Stop()
+
+		return nil, fmt.Errorf("error migrating datastore: %w", err)
+	}
+
+	return m, nil
 }
 
 // Open returns a datastore Manager. Error if datastore file does not exist.
-func Open(fp string) (*Manager, error) {
+func Open(fp string, opts ...Option) (*Manager, error) {
 	if _, err := os.Stat(fp); err != nil {
 		log.WithFields(log.Fields{
 			"filePath": fp,
@@ -63,7 +121,7 @@ func Open(fp string) (*Manager, error) {
 		return nil, fmt.Errorf("error accessing datastore file: %w", err)
 	}
 
-	return New(fp)
+	return New(fp, opts...)
 }
 
 // Begin starts a transactional datastore instance.
@@ -95,6 +153,18 @@ func (m *Manager) Save(data interface{}) error {
 	return nil
 }
 
+// DeleteStruct deletes a record from the datastore.
+func (m *Manager) DeleteStruct(data interface{}) error {
+	if err := m.Store.DeleteStruct(data); err != nil {
+		log.WithFields(log.Fields{
+			"record": data,
+		}).Error("error deleting record: ", err)
+		return fmt.Errorf("error deleting record=%+v: %w", data, err)
+	}
+
+	return nil
+}
+
 // One returns one record by the specified index.
 func (m *Manager) One(fieldName string, value interface{}, to interface{}) error {
 	return m.Store.One(fieldName, value, to)
@@ -135,3 +205,32 @@ func (m *Manager) Prefix(fieldName string, prefix string, to interface{}, option
 func (m *Manager) Count(data interface{}) (int, error) {
 	return m.Store.Count(data)
 }
+
+// SyncIncrement raises the auto-increment counter for the ID field of a bucket to at least id. Records
+// saved with explicit IDs do not advance the counter, so without this later inserts could reuse their IDs.
+func SyncIncrement(n storm.Node, bucket string, id int) error {
+	node := n.From(bucket)
+
+	raw, err := node.GetBytes(stormMetadataBucket, idCounterKey)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return fmt.Errorf("error reading %s id counter: %w", bucket, err)
+	}
+
+	var counter int64
+	if len(raw) == 8 { //nolint:gomnd // counters are stored as big-endian int64
+		counter = int64(binary.BigEndian.Uint64(raw))
+	}
+
+	if counter >= int64(id) {
+		return nil
+	}
+
+	buf := make([]byte, 8) //nolint:gomnd // counters are stored as big-endian int64
+	binary.BigEndian.PutUint64(buf, uint64(id))
+
+	if err = node.SetBytes(stormMetadataBucket, idCounterKey, buf); err != nil {
+		return fmt.Errorf("error writing %s id counter: %w", bucket, err)
+	}
+
+	return nil
+}
@@ -546,3 +546,28 @@ func TestManagerCount(t *testing.T) {
 		})
 	}
 }
+
+func TestSyncIncrement(t *testing.T) {
+	m, dbFilePath := initDatastoreManager(t)
+
+	defer func() {
+		m.Stop()
+		err := os.Remove(dbFilePath)
+		require.NoError(t, err)
+	}()
+
+	// explicit IDs do not advance the counter on their own
+	require.NoError(t, m.Save(&testEntry{ID: 10, Key: 1, Value: "Explicit"}))
+	require.NoError(t, datastore.SyncIncrement(m.Store, "testEntry", 10))
+
+	next := testEntry{Key: 2, Value: "Next"}
+	require.NoError(t, m.Save(&next))
+	assert.Equal(t, 11, next.ID)
+
+	// a lower ID never moves the counter back
+	require.NoError(t, datastore.SyncIncrement(m.Store, "testEntry", 2))
+
+	after := testEntry{Key: 3, Value: "After"}
+	require.NoError(t, m.Save(&after))
+	assert.Equal(t, 12, after.ID)
+}
@@ -0,0 +1,260 @@
+package datastore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	storm "github.com/asdine/storm/v3"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// MetadataBucket is the bucket holding ogma datastore metadata.
+	MetadataBucket = "__ogma_metadata"
+
+	// SchemaVersionKey is the metadata key for the datastore schema version.
+	SchemaVersionKey = "schema_version"
+)
+
+var (
+	// ErrSchemaTooNew is returned when a datastore was written by a newer version of the application.
+	ErrSchemaTooNew = errors.New("datastore schema is newer than supported")
+
+	// ErrSchemaTooOld is returned when a datastore has migrations that haven't been applied.
+	ErrSchemaTooOld = errors.New("datastore schema is older than supported, upgrade it with 'ogma migrate'")
+)
+
+// Changes counts the records touched by a migration, keyed by bucket name.
+type Changes map[string]int
+
+// Total returns the number of records touched across all buckets.
+func (c Changes) Total() int {
+	t := 0
+	for _, n := range c {
+		t += n
+	}
+
+	return t
+}
+
+// A MigrationTx is the writable transaction a migration runs in.
+type MigrationTx struct {
+	storm.Node
+	Tx *bolt.Tx
+}
+
+// A RewriteFunc modifies a raw record in place. It returns true if the record was changed.
+type RewriteFunc func(record map[string]json.RawMessage) (bool, error)
+
+// A Migration upgrades the datastore schema to a single version.
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(tx *MigrationTx) (Changes, error)
+}
+
+// A MigrationReport describes what a migration changed, or would change on a dry run.
+type MigrationReport struct {
+	Version     int
+	Description string
+	Changes     Changes
+	DryRun      bool
+}
+
+var migrations []Migration
+
+// RegisterMigration adds a migration to the set applied when a datastore is opened. It panics if
+// a migration with the same version is already registered.
+func RegisterMigration(m Migration) {
+	for _, r := range migrations {
+		if r.Version == m.Version {
+			panic(fmt.Sprintf("datastore: migration version %d registered twice", m.Version))
+		}
+	}
+
+	migrations = append(migrations, m)
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+// LatestSchemaVersion returns the schema version produced by applying all registered migrations.
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the schema version recorded in the datastore. Datastores created before
+// versioning was introduced report version 0.
+func (m *Manager) SchemaVersion() (int, error) {
+	return schemaVersion(m.Store)
+}
+
+func schemaVersion(n storm.Node) (int, error) {
+	var v int
+
+	err := n.Get(MetadataBucket, SchemaVersionKey, &v)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return 0, fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	return v, nil
+}
+
+// checkSchema returns an error if the datastore schema isn't the latest version.
+func (m *Manager) checkSchema() error {
+	current, err := m.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	latest := LatestSchemaVersion()
+
+	switch {
+	case current > latest:
+		return fmt.Errorf("%w: datastore=%d supported=%d", ErrSchemaTooNew, current, latest)
+	case current < latest:
+		log.WithFields(log.Fields{
+			"filePath": m.filePath,
+			"version":  current,
+			"latest":   latest,
+		}).Warn("datastore has pending migrations")
+
+		return fmt.Errorf("%w: datastore=%d supported=%d", ErrSchemaTooOld, current, latest)
+	}
+
+	return nil
+}
+
+// Migrate applies all registered migrations newer than the datastore schema version, in order, within
+// a single transaction. On a dry run the transaction is rolled back and the reports describe what
+// would have changed.
+func (m *Manager) Migrate(dryRun bool) ([]MigrationReport, error) {
+	btx, err := m.Store.Bolt.Begin(true)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning migration transaction: %w", err)
+	}
+	defer func() {
+		if errRollback := btx.Rollback(); errRollback != nil && !errors.Is(errRollback, bolt.ErrTxClosed) {
+			log.Error("failed to rollback migration transaction: ", errRollback)
+		}
+	}()
+
+	tx := &MigrationTx{
+		Node: m.Store.WithTransaction(btx),
+		Tx:   btx,
+	}
+
+	current, err := schemaVersion(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return nil, fmt.Errorf("%w: datastore=%d supported=%d", ErrSchemaTooNew, current, latest)
+	}
+
+	reports := []MigrationReport{}
+
+	for _, mg := range migrations {
+		if mg.Version <= current {
+			continue
+		}
+
+		changes, err := mg.Apply(tx)
+		if err != nil {
+			return nil, fmt.Errorf("error applying migration %d (%s): %w", mg.Version, mg.Description, err)
+		}
+
+		log.WithFields(log.Fields{
+			"version":     mg.Version,
+			"description": mg.Description,
+			"changes":     changes.Total(),
+			"dry_run":     dryRun,
+		}).Info("applied datastore migration")
+
+		reports = append(reports, MigrationReport{
+			Version:     mg.Version,
+			Description: mg.Description,
+			Changes:     changes,
+			DryRun:      dryRun,
+		})
+	}
+
+	if dryRun {
+		return reports, nil
+	}
+
+	if current != latest {
+		if err = tx.Set(MetadataBucket, SchemaVersionKey, latest); err != nil {
+			return nil, fmt.Errorf("error writing schema version: %w", err)
+		}
+	}
+
+	if err = btx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing migrations: %w", err)
+	}
+
+	return reports, nil
+}
+
+// RewriteRecords passes every record stored in a bucket to fn as raw JSON fields and saves any records
+// fn reports as changed. It returns the number of records changed. Missing buckets are skipped.
+func (t *MigrationTx) RewriteRecords(bucket string, fn RewriteFunc) (int, error) {
+	b := t.Tx.Bucket([]byte(bucket))
+	if b == nil {
+		return 0, nil
+	}
+
+	updates := map[string][]byte{}
+
+	err := b.ForEach(func(k, v []byte) error {
+		// nested buckets (indexes and storm metadata) have no value
+		if v == nil {
+			return nil
+		}
+
+		record := map[string]json.RawMessage{}
+		if err := json.Unmarshal(v, &record); err != nil {
+			return fmt.Errorf("error decoding record %x in %s: %w", k, bucket, err)
+		}
+
+		changed, err := fn(record)
+		if err != nil {
+			return fmt.Errorf("error rewriting record %x in %s: %w", k, bucket, err)
+		}
+
+		if !changed {
+			return nil
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("error encoding record %x in %s: %w", k, bucket, err)
+		}
+
+		updates[string(k)] = data
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// bolt does not allow modifying a bucket while iterating over it
+	for k, v := range updates {
+		if err := b.Put([]byte(k), v); err != nil {
+			return 0, fmt.Errorf("error saving record %x in %s: %w", k, bucket, err)
+		}
+	}
+
+	return len(updates), nil
+}
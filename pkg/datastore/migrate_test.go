@@ -0,0 +1,166 @@
+package datastore_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/asphaltbuffet/ogma/pkg/datastore"
+)
+
+const legacyBucket = "legacyEntry"
+
+func init() {
+	// renames the legacy 'Name' field to 'Value' for any stored legacy entries
+	datastore.RegisterMigration(datastore.Migration{
+		Version:     1,
+		Description: "rename legacy name field",
+		Apply: func(tx *datastore.MigrationTx) (datastore.Changes, error) {
+			n, err := tx.RewriteRecords(legacyBucket, func(r map[string]json.RawMessage) (bool, error) {
+				name, ok := r["Name"]
+				if !ok {
+					return false, nil
+				}
+
+				r["Value"] = name
+				delete(r, "Name")
+
+				return true, nil
+			})
+
+			return datastore.Changes{legacyBucket: n}, err
+		},
+	})
+}
+
+func TestRegisterMigrationDuplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		datastore.RegisterMigration(datastore.Migration{Version: 1})
+	})
+}
+
+func TestLatestSchemaVersion(t *testing.T) {
+	assert.Equal(t, 1, datastore.LatestSchemaVersion())
+}
+
+func TestManagerMigrate(t *testing.T) {
+	fn := fmt.Sprintf("test_migrate_%d.db", time.Now().Unix())
+	m, err := datastore.New(fn, datastore.WithoutMigrations())
+	require.NoError(t, err)
+
+	defer func() {
+		m.Stop()
+		require.NoError(t, os.Remove(fn))
+	}()
+
+	// write records in the pre-migration shape
+	err = m.Store.Bolt.Update(func(tx *bolt.Tx) error {
+		b, errBucket := tx.CreateBucketIfNotExists([]byte(legacyBucket))
+		if errBucket != nil {
+			return errBucket
+		}
+
+		if _, errBucket = b.CreateBucketIfNotExists([]byte("__storm_index_Key")); errBucket != nil {
+			return errBucket
+		}
+
+		if errPut := b.Put([]byte("1"), []byte(`{"ID":1,"Name":"Mollit"}`)); errPut != nil {
+			return errPut
+		}
+
+		return b.Put([]byte("2"), []byte(`{"ID":2,"Name":"Comodo"}`))
+	})
+	require.NoError(t, err)
+
+	v, err := m.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 0, v)
+
+	// dry run reports changes without applying them
+	reports, err := m.Migrate(true)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.True(t, reports[0].DryRun)
+	assert.Equal(t, 2, reports[0].Changes.Total())
+
+	v, err = m.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 0, v)
+	assert.Equal(t, `{"ID":1,"Name":"Mollit"}`, string(getRaw(t, m, "1")))
+
+	// real run applies changes and records the schema version
+	reports, err = m.Migrate(false)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.False(t, reports[0].DryRun)
+	assert.Equal(t, datastore.Changes{legacyBucket: 2}, reports[0].Changes)
+
+	v, err = m.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.JSONEq(t, `{"ID":1,"Value":"Mollit"}`, string(getRaw(t, m, "1")))
+
+	// nothing left to apply
+	reports, err = m.Migrate(false)
+	require.NoError(t, err)
+	assert.Empty(t, reports)
+}
+
+func TestManagerMigrateTooNew(t *testing.T) {
+	fn := fmt.Sprintf("test_too_new_%d.db", time.Now().Unix())
+	m, err := datastore.New(fn)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.Remove(fn))
+	}()
+
+	require.NoError(t, m.Store.Set(datastore.MetadataBucket, datastore.SchemaVersionKey, 99))
+	m.Stop()
+
+	_, err = datastore.New(fn)
+	assert.ErrorIs(t, err, datastore.ErrSchemaTooNew)
+}
+
+func TestManagerMigrateTooOld(t *testing.T) {
+	fn := fmt.Sprintf("test_too_old_%d.db", time.Now().Unix())
+	m, err := datastore.New(fn, datastore.WithoutMigrations())
+	require.NoError(t, err)
+	m.Stop()
+
+	defer func() {
+		require.NoError(t, os.Remove(fn))
+	}()
+
+	_, err = datastore.Open(fn)
+	assert.ErrorIs(t, err, datastore.ErrSchemaTooOld)
+
+	// migrations are left for 'ogma migrate' to apply
+	m, err = datastore.Open(fn, datastore.WithoutMigrations())
+	require.NoError(t, err)
+	defer m.Stop()
+
+	v, err := m.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 0, v)
+}
+
+func getRaw(t *testing.T, m *datastore.Manager, key string) []byte {
+	t.Helper()
+
+	var raw []byte
+
+	err := m.Store.Bolt.View(func(tx *bolt.Tx) error {
+		raw = append(raw, tx.Bucket([]byte(legacyBucket)).Get([]byte(key))...)
+		return nil
+	})
+	require.NoError(t, err)
+
+	return raw
+}
@@ -0,0 +1,456 @@
+// Package delimited reads and writes records as delimited text, like CSV and TSV files from a spreadsheet.
+//
+// The first row is a header naming a field in each column. Fields are named as they are in json import files
+// (or by their Go name when they have no json name), in any case and any order. Numbers, text, and flags are
+// read from a column, along with types that read themselves from json text, like member numbers and links.
+// Fields holding lists or nested records can't be written as a column, and are left out.
+package delimited
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Delimiters for the formats read and written.
+const (
+	Comma = ','
+	Tab   = '\t'
+)
+
+// ErrInvalid is returned when delimited text can't be read into records.
+var ErrInvalid = errors.New("invalid delimited text")
+
+// An Error is a cell that can't be read. Row counts from 1 at the header.
+type Error struct {
+	Row    int
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e Error) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+
+	return fmt.Sprintf("row %d: %s %q: %v", e.Row, e.Column, e.Value, e.Err)
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors are all the cells that can't be read, in the order they are found.
+type Errors []Error
+
+func (ee Errors) Error() string {
+	lines := make([]string, len(ee))
+	for i, e := range ee {
+		lines[i] = e.Error()
+	}
+
+	return fmt.Sprintf("%v:\n%s", ErrInvalid, strings.Join(lines, "\n"))
+}
+
+// Is reports whether target is ErrInvalid.
+func (ee Errors) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+type kind int
+
+const (
+	unsupported kind = iota
+	integer
+	flag
+	text
+	unmarshaled
+)
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	stringerType    = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// A column is a field of a record that can be read from delimited text.
+type column struct {
+	name  string
+	index int
+	kind  kind
+}
+
+// columns returns the fields of a record type that can be written as columns, in the order they are declared.
+func columns(t reflect.Type) []column {
+	cc := []column{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		if k := kindOf(f.Type); k != unsupported {
+			cc = append(cc, column{name: name, index: i, kind: k})
+		}
+	}
+
+	return cc
+}
+
+func kindOf(t reflect.Type) kind {
+	if reflect.PtrTo(t).Implements(unmarshalerType) && t.Implements(stringerType) {
+		return unmarshaled
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return integer
+	case reflect.Bool:
+		return flag
+	case reflect.String:
+		return text
+	default:
+		return unsupported
+	}
+}
+
+// Header returns the column names written for records like v.
+func Header(v interface{}) []string {
+	cc := columns(reflect.Indirect(reflect.ValueOf(v)).Type())
+
+	names := make([]string, len(cc))
+	for i, c := range cc {
+		names[i] = c.name
+	}
+
+	return names
+}
+
+// Read reads delimited text into records, which must point to a slice of structs. Every cell that can't be read
+// is reported by its row and column.
+func Read(r io.Reader, delimiter rune, records interface{}) error {
+	return NewReader(r, delimiter).ReadAll(records)
+}
+
+// A Reader reads records from delimited text one row at a time, so a large file isn't held in memory.
+type Reader struct {
+	cr     *csv.Reader
+	header []column
+}
+
+// NewReader returns a Reader of delimited text with a header row.
+func NewReader(r io.Reader, delimiter rune) *Reader {
+	cr := csv.NewReader(r)
+	cr.Comma = delimiter
+	cr.LazyQuotes = delimiter == Tab
+
+	return &Reader{cr: cr}
+}
+
+// Read reads the next row into record, which must point to a struct. It returns io.EOF after the last row. A row
+// with cells that can't be read returns Errors, and the rows after it can still be read. A header that doesn't
+// name the columns of record can't be read past.
+func (dr *Reader) Read(record interface{}) error {
+	rec := reflect.ValueOf(record)
+	if rec.Kind() != reflect.Ptr || rec.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("record must point to a struct: %T", record)
+	}
+
+	if dr.header == nil {
+		if err := dr.readHeader(rec.Elem().Type()); err != nil {
+			return err
+		}
+	}
+
+	cells, err := dr.cr.Read()
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		return Errors{{Row: pe.StartLine, Err: pe.Err}}
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	// rows are numbered by the line they start on, as blank lines are skipped
+	row, _ := dr.cr.FieldPos(0)
+
+	rec.Elem().Set(reflect.Zero(rec.Elem().Type()))
+
+	ee := Errors{}
+
+	for i, c := range dr.header {
+		if err = set(rec.Elem().Field(c.index), c.kind, cells[i]); err != nil {
+			ee = append(ee, Error{Row: row, Column: c.name, Value: cells[i], Err: err})
+		}
+	}
+
+	if len(ee) > 0 {
+		return ee
+	}
+
+	return nil
+}
+
+// ReadAll reads the rest of the rows into records, which must point to a slice of structs. Every cell that can't
+// be read is reported by its row and column.
+func (dr *Reader) ReadAll(records interface{}) error {
+	slice := reflect.ValueOf(records)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("records must point to a slice: %T", records)
+	}
+
+	elem := slice.Elem().Type().Elem()
+	rows := reflect.MakeSlice(slice.Elem().Type(), 0, 0)
+	ee := Errors{}
+
+	for {
+		rec := reflect.New(elem)
+
+		err := dr.Read(rec.Interface())
+		if errors.Is(err, io.EOF) && dr.header == nil {
+			return nil
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var rowErrs Errors
+		if errors.As(err, &rowErrs) && dr.header != nil {
+			ee = append(ee, rowErrs...)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		rows = reflect.Append(rows, rec.Elem())
+	}
+
+	if len(ee) > 0 {
+		return ee
+	}
+
+	slice.Elem().Set(rows)
+
+	return nil
+}
+
+// Columns returns the field names of the header's columns, as they are named in json import files. It is nil until
+// the header is read.
+func (dr *Reader) Columns() []string {
+	if dr.header == nil {
+		return nil
+	}
+
+	names := make([]string, len(dr.header))
+	for i, c := range dr.header {
+		names[i] = c.name
+	}
+
+	return names
+}
+
+// readHeader matches the columns of the header row to the fields of a record type.
+func (dr *Reader) readHeader(t reflect.Type) error {
+	header, err := dr.cr.Read()
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	cc, err := headerColumns(header, columns(t))
+	if err != nil {
+		return err
+	}
+
+	dr.header = cc
+
+	return nil
+}
+
+// headerColumns matches header names to record columns, ignoring case.
+func headerColumns(header []string, cc []column) ([]column, error) {
+	byName := map[string]column{}
+	for _, c := range cc {
+		byName[strings.ToLower(c.name)] = c
+	}
+
+	matched := make([]column, len(header))
+	ee := Errors{}
+	seen := map[string]bool{}
+
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+
+		c, ok := byName[name]
+
+		switch {
+		case !ok:
+			known := make([]string, len(cc))
+			for j, c := range cc {
+				known[j] = c.name
+			}
+
+			sort.Strings(known)
+			ee = append(ee, Error{Row: 1, Column: "column", Value: h, Err: fmt.Errorf("must be one of %s", strings.Join(known, ", "))})
+		case seen[name]:
+			ee = append(ee, Error{Row: 1, Column: "column", Value: h, Err: errors.New("is repeated")})
+		}
+
+		seen[name] = true
+		matched[i] = c
+	}
+
+	if len(ee) > 0 {
+		return nil, ee
+	}
+
+	return matched, nil
+}
+
+// set reads a cell into a field. Empty cells leave the field at its zero value.
+func set(f reflect.Value, k kind, cell string) error {
+	value := strings.TrimSpace(cell)
+	if value == "" {
+		return nil
+	}
+
+	switch k {
+	case integer:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+
+		f.SetInt(n)
+	case flag:
+		b, err := ParseBool(value)
+		if err != nil {
+			return err
+		}
+
+		f.SetBool(b)
+	case text:
+		f.SetString(cell)
+	case unmarshaled:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("error encoding cell: %w", err)
+		}
+
+		u, ok := f.Addr().Interface().(json.Unmarshaler)
+		if !ok {
+			return fmt.Errorf("can't read %s", f.Type())
+		}
+
+		if err = u.UnmarshalJSON(data); err != nil {
+			return err
+		}
+	case unsupported:
+	}
+
+	return nil
+}
+
+// ParseBool reads a flag as it is written in a spreadsheet: true, yes, y, x, or 1 are set, and false, no, n, or 0
+// are not, in any case.
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "yes", "y", "x", "1":
+		return true, nil
+	case "false", "f", "no", "n", "0", "":
+		return false, nil
+	default:
+		return false, errors.New("must be true or false")
+	}
+}
+
+// Write writes records as delimited text with a header row. records must be a slice of structs.
+func Write(w io.Writer, delimiter rune, records interface{}) error {
+	slice := reflect.ValueOf(records)
+	if slice.Kind() != reflect.Slice {
+		return fmt.Errorf("records must be a slice: %T", records)
+	}
+
+	cc := columns(slice.Type().Elem())
+
+	cw := csv.NewWriter(w)
+	cw.Comma = delimiter
+
+	header := make([]string, len(cc))
+	for i, c := range cc {
+		header[i] = c.name
+	}
+
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	for i := 0; i < slice.Len(); i++ {
+		rec := slice.Index(i)
+
+		cells := make([]string, len(cc))
+		for j, c := range cc {
+			cells[j] = format(rec.Field(c.index), c.kind)
+		}
+
+		if err := cw.Write(cells); err != nil {
+			return fmt.Errorf("error writing record %d: %w", i+1, err)
+		}
+	}
+
+	cw.Flush()
+
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("error writing records: %w", err)
+	}
+
+	return nil
+}
+
+// format writes a field as a cell. Zero numbers are written as empty cells, so they read back the same.
+func format(f reflect.Value, k kind) string {
+	switch k {
+	case integer:
+		if f.Int() == 0 {
+			return ""
+		}
+
+		return strconv.FormatInt(f.Int(), 10)
+	case flag:
+		return strconv.FormatBool(f.Bool())
+	case text:
+		return f.String()
+	case unmarshaled:
+		if s, ok := f.Interface().(fmt.Stringer); ok {
+			return s.String()
+		}
+	case unsupported:
+	}
+
+	return ""
+}
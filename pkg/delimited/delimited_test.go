@@ -0,0 +1,154 @@
+package delimited_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/delimited"
+	"github.com/asphaltbuffet/ogma/pkg/link"
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+type record struct {
+	ID      int
+	Page    int              `json:"page"`
+	Member  member.ID        `json:"member"`
+	Text    string           `json:"text"`
+	Art     bool             `json:"art"`
+	Link    link.Link        `json:"link"`
+	Tags    []string         `json:"tags"`
+	Ignored string           `json:"-"`
+	Nested  *struct{ A int } `json:"nested,omitempty"`
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		delimiter rune
+		want      []record
+		wantErr   string
+	}{
+		{
+			name:      "csv in any column order and case",
+			in:        "Text,PAGE,member,art,link,id\n\"Hello, world\",3,1234A,yes,L7,\n\nBye,4,55,,,9\n",
+			delimiter: delimited.Comma,
+			want: []record{
+				{Page: 3, Member: member.ID{Number: 1234, Extension: "A"}, Text: "Hello, world", Art: true, Link: link.ToListing(7)},
+				{ID: 9, Page: 4, Member: member.ID{Number: 55}, Text: "Bye"},
+			},
+		},
+		{
+			name:      "tsv",
+			in:        "page\ttext\tart\n1\tSay \"hi\"\tx\n",
+			delimiter: delimited.Tab,
+			want:      []record{{Page: 1, Text: "Say \"hi\"", Art: true}},
+		},
+		{
+			name:      "empty",
+			in:        "",
+			delimiter: delimited.Comma,
+			want:      nil,
+		},
+		{
+			name:      "unknown and repeated columns",
+			in:        "page,pages,Page\n",
+			delimiter: delimited.Comma,
+			wantErr: "invalid delimited text:\n" +
+				"row 1: column \"pages\": must be one of ID, art, link, member, page, text\n" +
+				"row 1: column \"Page\": is repeated",
+		},
+		{
+			name:      "invalid cells",
+			in:        "page,art,member\n1,true,1234\ntwo,maybe,1234\n3,no,12x4\n",
+			delimiter: delimited.Comma,
+			wantErr: "invalid delimited text:\n" +
+				"row 3: page \"two\": must be a whole number\n" +
+				"row 3: art \"maybe\": must be true or false\n" +
+				"row 4: member \"12x4\": ",
+		},
+		{
+			name:      "wrong number of cells",
+			in:        "page,art\n1,true\n2\n",
+			delimiter: delimited.Comma,
+			wantErr:   "invalid delimited text:\nrow 3: wrong number of fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []record
+
+			err := delimited.Read(strings.NewReader(tt.in), tt.delimiter, &got)
+			if tt.wantErr != "" {
+				require.ErrorIs(t, err, delimited.ErrInvalid)
+				assert.Contains(t, err.Error(), tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReader(t *testing.T) {
+	dr := delimited.NewReader(strings.NewReader("Page,TEXT\n1,one\nten,two\n3,three\n"), delimited.Comma)
+	assert.Nil(t, dr.Columns())
+
+	var r record
+
+	require.NoError(t, dr.Read(&r))
+	assert.Equal(t, []string{"page", "text"}, dr.Columns())
+	assert.Equal(t, record{Page: 1, Text: "one"}, r)
+
+	assert.EqualError(t, dr.Read(&r), "invalid delimited text:\nrow 3: page \"ten\": must be a whole number")
+
+	require.NoError(t, dr.Read(&r))
+	assert.Equal(t, record{Page: 3, Text: "three"}, r)
+
+	assert.ErrorIs(t, dr.Read(&r), io.EOF)
+}
+
+func TestWrite(t *testing.T) {
+	rr := []record{
+		{ID: 1, Page: 3, Member: member.ID{Number: 1234, Extension: "A"}, Text: "Hello, world", Art: true, Link: link.ToMail("abc123")},
+		{Page: 4, Text: "Bye"},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, delimited.Write(&b, delimited.Comma, rr))
+	assert.Equal(t, "ID,page,member,text,art,link\n"+
+		"1,3,1234A,\"Hello, world\",true,Mabc123\n"+
+		",4,,Bye,false,\n", b.String())
+
+	// written records read back the same
+	var got []record
+	require.NoError(t, delimited.Read(&b, delimited.Comma, &got))
+	assert.Equal(t, rr, got)
+
+	assert.Equal(t, []string{"ID", "page", "member", "text", "art", "link"}, delimited.Header(record{}))
+}
+
+func TestParseBool(t *testing.T) {
+	for _, s := range []string{"true", "TRUE", "t", "Yes", "y", "x", "1"} {
+		b, err := delimited.ParseBool(s)
+		require.NoError(t, err)
+		assert.True(t, b, s)
+	}
+
+	for _, s := range []string{"false", "F", "no", "n", "0", "", " "} {
+		b, err := delimited.ParseBool(s)
+		require.NoError(t, err)
+		assert.False(t, b, s)
+	}
+
+	_, err := delimited.ParseBool("maybe")
+	assert.Error(t, err)
+}
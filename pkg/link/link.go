@@ -0,0 +1,148 @@
+// Package link refers from mail to the record it answers.
+//
+// A link is written as a prefix and the key of the record it points to: "L42" is the listing with ID 42 and
+// "Mf2165e" is the mail with reference f2165e. Prefixes are read in either case.
+package link
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidLink is returned when text is not a link.
+var ErrInvalidLink = errors.New("invalid link")
+
+// A Kind is the type of record a link points to.
+type Kind string
+
+// Link kinds.
+const (
+	None    Kind = ""
+	Listing Kind = "listing"
+	Mail    Kind = "mail"
+
+	// Invalid links couldn't be read. They are only found in data stored before links were checked.
+	Invalid Kind = "invalid"
+)
+
+// A Link points to a listing or a mail. Only one of its fields is set.
+type Link struct {
+	Listing int    `json:"listing,omitempty"`
+	Mail    string `json:"mail,omitempty"`
+
+	// Text is a link that couldn't be read, kept as it was written.
+	Text string `json:"text,omitempty"`
+}
+
+// ToListing returns a link to the listing with an ID.
+func ToListing(id int) Link {
+	return Link{Listing: id}
+}
+
+// ToMail returns a link to the mail with a reference.
+func ToMail(ref string) Link {
+	return Link{Mail: strings.ToLower(ref)}
+}
+
+// Parse reads a link such as "L42" or "Mf2165e". Empty text is no link.
+func Parse(s string) (Link, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Link{}, nil
+	}
+
+	key := s[1:]
+
+	switch s[0] {
+	case 'L', 'l':
+		id, err := strconv.Atoi(key)
+		if err != nil || id <= 0 || strings.ContainsAny(key, "+-") {
+			return Link{}, fmt.Errorf("%w: listing links need a listing ID, like 'L42': %q", ErrInvalidLink, s)
+		}
+
+		return ToListing(id), nil
+	case 'M', 'm':
+		if key == "" || strings.IndexFunc(key, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) >= 0 {
+			return Link{}, fmt.Errorf("%w: mail links need a mail reference, like 'Mf2165e': %q", ErrInvalidLink, s)
+		}
+
+		return ToMail(key), nil
+	default:
+		return Link{}, fmt.Errorf("%w: must start with 'L' for a listing or 'M' for mail: %q", ErrInvalidLink, s)
+	}
+}
+
+// Kind returns the type of record the link points to.
+func (l Link) Kind() Kind {
+	switch {
+	case l.Listing != 0:
+		return Listing
+	case l.Mail != "":
+		return Mail
+	case l.Text != "":
+		return Invalid
+	default:
+		return None
+	}
+}
+
+// IsZero reports whether there is no link.
+func (l Link) IsZero() bool {
+	return l == Link{}
+}
+
+// String returns the link as it is written, like "L42".
+func (l Link) String() string {
+	switch l.Kind() {
+	case Listing:
+		return "L" + strconv.Itoa(l.Listing)
+	case Mail:
+		return "M" + l.Mail
+	case Invalid:
+		return l.Text
+	case None:
+	}
+
+	return ""
+}
+
+// jsonLink has the fields of a link without its JSON methods.
+type jsonLink Link
+
+// MarshalJSON writes the link as an object naming the record it points to, or null if there is no link.
+func (l Link) MarshalJSON() ([]byte, error) {
+	if l.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(jsonLink(l))
+}
+
+// UnmarshalJSON reads a link from an object, or from text like "L42" as written by earlier versions and import
+// files. Text that isn't a link is kept as an invalid link.
+func (l *Link) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := Parse(s)
+		if err != nil {
+			parsed = Link{Text: s}
+		}
+
+		*l = parsed
+
+		return nil
+	}
+
+	var jl jsonLink
+	if err := json.Unmarshal(data, &jl); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidLink, data)
+	}
+
+	*l = Link(jl)
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package link_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/link"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      link.Link
+		assertion assert.ErrorAssertionFunc
+	}{
+		{in: "", want: link.Link{}, assertion: assert.NoError},
+		{in: "L42", want: link.ToListing(42), assertion: assert.NoError},
+		{in: " l7 ", want: link.ToListing(7), assertion: assert.NoError},
+		{in: "Mf2165e", want: link.ToMail("f2165e"), assertion: assert.NoError},
+		{in: "mF2165E", want: link.ToMail("f2165e"), assertion: assert.NoError},
+		{in: "L", want: link.Link{}, assertion: assert.Error},
+		{in: "L0", want: link.Link{}, assertion: assert.Error},
+		{in: "L-4", want: link.Link{}, assertion: assert.Error},
+		{in: "L4a", want: link.Link{}, assertion: assert.Error},
+		{in: "M", want: link.Link{}, assertion: assert.Error},
+		{in: "Mf2-65e", want: link.Link{}, assertion: assert.Error},
+		{in: "42", want: link.Link{}, assertion: assert.Error},
+		{in: "f2165e", want: link.Link{}, assertion: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := link.Parse(tt.in)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLinkString(t *testing.T) {
+	assert.Equal(t, "L42", link.ToListing(42).String())
+	assert.Equal(t, "Mf2165e", link.ToMail("F2165E").String())
+	assert.Equal(t, "see letter", link.Link{Text: "see letter"}.String())
+	assert.Equal(t, "", link.Link{}.String())
+}
+
+func TestLinkKind(t *testing.T) {
+	assert.Equal(t, link.Listing, link.ToListing(42).Kind())
+	assert.Equal(t, link.Mail, link.ToMail("f2165e").Kind())
+	assert.Equal(t, link.Invalid, link.Link{Text: "see letter"}.Kind())
+	assert.Equal(t, link.None, link.Link{}.Kind())
+	assert.True(t, link.Link{}.IsZero())
+}
+
+func TestLinkJSON(t *testing.T) {
+	type record struct {
+		Link link.Link `json:"link"`
+	}
+
+	tests := []struct {
+		name      string
+		in        string
+		want      link.Link
+		out       string
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "listing", in: `{"link":{"listing":42}}`, want: link.ToListing(42), out: `{"link":{"listing":42}}`, assertion: assert.NoError},
+		{name: "mail", in: `{"link":{"mail":"f2165e"}}`, want: link.ToMail("f2165e"), out: `{"link":{"mail":"f2165e"}}`, assertion: assert.NoError},
+		{name: "invalid", in: `{"link":{"text":"see letter"}}`, want: link.Link{Text: "see letter"}, out: `{"link":{"text":"see letter"}}`, assertion: assert.NoError},
+		{name: "none", in: `{"link":null}`, want: link.Link{}, out: `{"link":null}`, assertion: assert.NoError},
+		{name: "legacy listing", in: `{"link":"L42"}`, want: link.ToListing(42), out: `{"link":{"listing":42}}`, assertion: assert.NoError},
+		{name: "legacy mail", in: `{"link":"Mf2165e"}`, want: link.ToMail("f2165e"), out: `{"link":{"mail":"f2165e"}}`, assertion: assert.NoError},
+		{name: "legacy none", in: `{"link":""}`, want: link.Link{}, out: `{"link":null}`, assertion: assert.NoError},
+		{name: "legacy invalid", in: `{"link":"see letter"}`, want: link.Link{Text: "see letter"}, out: `{"link":{"text":"see letter"}}`, assertion: assert.NoError},
+		{name: "wrong type", in: `{"link":42}`, assertion: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r record
+			err := json.Unmarshal([]byte(tt.in), &r)
+			tt.assertion(t, err)
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, tt.want, r.Link)
+
+			out, err := json.Marshal(r)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.out, string(out))
+		})
+	}
+}
@@ -25,11 +25,16 @@ package lstg
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/jonreiter/govader"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/render"
+	"github.com/asphaltbuffet/ogma/pkg/textindex"
 )
 
 // A Listings hold unmarshalled listing data for import.
@@ -86,36 +91,46 @@ var listingColumnConfigs = []table.ColumnConfig{
 	},
 }
 
-// RenderListings returns a pretty formatted listing as table.
-func RenderListings(ll []Listing, p bool) string {
-	// empty string if there are no listings to render
-	if len(ll) == 0 {
-		return "No LEX listings found."
+// RenderListings returns a pretty formatted listing as table. Words in the listing text matching any of the
+// highlight terms are emphasized.
+func RenderListings(ll []Listing, p bool, highlight ...string) string {
+	log.WithFields(log.Fields{
+		"is_pretty": p,
+	}).Debug("set rendering style")
+
+	return ListingsReport(ll, p, highlight...).Table(p)
+}
+
+// ListingsReport returns listings as a report. Words in the listing text matching any of the highlight terms are
+// emphasized, in a way that suits a pretty table when p is set.
+func ListingsReport(ll []Listing, p bool, highlight ...string) render.Report {
+	rep := render.Report{
+		Title: "LEX Issue Matches:",
+		Header: table.Row{
+			"ID",
+			"Volume",
+			"Issue",
+			"Year",
+			"Season",
+			"Page",
+			"Category",
+			"Member",
+			"International",
+			"Review",
+			"Text",
+			"Sketch",
+			"Flagged",
+			"Sentiment",
+		},
+		Columns: listingColumnConfigs,
+		Records: ll,
+		Empty:   "No LEX listings found.",
 	}
 
-	lt := table.NewWriter()
-
-	lt.SetTitle("LEX Issue Matches:")
-
-	lt.AppendHeader(table.Row{
-		"ID",
-		"Volume",
-		"Issue",
-		"Year",
-		"Season",
-		"Page",
-		"Category",
-		"Member",
-		"International",
-		"Review",
-		"Text",
-		"Sketch",
-		"Flagged",
-		"Sentiment",
-	})
+	mark := highlightMarker(p)
 
 	for _, l := range ll {
-		lt.AppendRow([]interface{}{
+		rep.Rows = append(rep.Rows, table.Row{
 			l.ID,
 			l.Volume,
 			l.IssueNumber,
@@ -123,27 +138,33 @@ func RenderListings(ll []Listing, p bool) string {
 			l.Season,
 			l.PageNumber,
 			l.IndexedCategory,
-			fmt.Sprint(l.IndexedMemberNumber) + l.MemberExtension,
+			l.Member().String(),
 			convertBool(l.IsInternational),
 			convertBool(l.IsReview),
-			l.ListingText,
+			textindex.Highlight(l.ListingText, highlight, mark),
 			convertBool(l.IsArt),
 			convertBool(l.IsFlagged),
 			fmt.Sprintf("%.2f", l.calcSentiment()),
 		})
-		// lt.AppendSeparator() // disabling for now, it makes it look messy - may want it configurable at run-time
+		// rows aren't separated for now, it makes it look messy - may want it configurable at run-time
 	}
 
-	lt.SetColumnConfigs(listingColumnConfigs)
+	return rep
+}
 
+// highlightMarker returns a function that emphasizes a word with bold underlined text when pretty, or
+// surrounding asterisks otherwise. Only the bold and underline attributes are reset so the table colors are kept.
+func highlightMarker(p bool) func(string) string {
 	if p {
-		lt.SetStyle(table.StyleColoredBright)
+		return func(w string) string { return "\x1b[1;4m" + w + "\x1b[22;24m" }
 	}
-	log.WithFields(log.Fields{
-		"is_pretty": p,
-	}).Debug("set rendering style")
 
-	return lt.Render()
+	return func(w string) string { return "*" + w + "*" }
+}
+
+// Member returns the identifier of the member who placed the listing.
+func (l *Listing) Member() member.ID {
+	return member.ID{Number: l.IndexedMemberNumber, Extension: strings.ToUpper(l.MemberExtension)}
 }
 
 // convertBool strips out 'false' values for easier reading.
@@ -75,3 +75,15 @@ func TestRenderListings(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderListingsHighlight(t *testing.T) {
+	ll := []lstg.Listing{
+		{IndexedMemberNumber: 2989, ListingText: "Vintage cameras, any camera."},
+	}
+
+	got := lstg.RenderListings(ll, false, "vintag", "camera")
+	assert.Contains(t, got, "| *Vintage* *cameras*, any *camera*. |")
+
+	got = lstg.RenderListings(ll, true, "camera")
+	assert.Contains(t, got, "Vintage \x1b[1;4mcameras\x1b[22;24m, any \x1b[1;4mcamera\x1b[22;24m.")
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package lstg
+
+import (
+	"fmt"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/query"
+	"github.com/asphaltbuffet/ogma/pkg/textindex"
+)
+
+// TextIndexName names the full-text index of listing text.
+const TextIndexName = "Listing"
+
+// QuerySchema describes the listing fields available to search queries. Bare member identifiers search by
+// member and bare words search listing text.
+var QuerySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":            {Fields: []string{"ID"}, Kind: query.Int},
+		"volume":        {Fields: []string{"Volume"}, Kind: query.Int},
+		"issue":         {Fields: []string{"IssueNumber"}, Kind: query.Int},
+		"year":          {Fields: []string{"Year"}, Kind: query.Int},
+		"season":        {Fields: []string{"Season"}, Kind: query.String},
+		"page":          {Fields: []string{"PageNumber"}, Kind: query.Int},
+		"category":      {Fields: []string{"IndexedCategory"}, Kind: query.String},
+		"member":        {Build: memberMatcher},
+		"alt":           {Fields: []string{"MemberExtension"}, Kind: query.String},
+		"international": {Fields: []string{"IsInternational"}, Kind: query.Bool},
+		"review":        {Fields: []string{"IsReview"}, Kind: query.Bool},
+		"text":          {Fields: []string{"ListingText"}, Kind: query.Text},
+		"art":           {Fields: []string{"IsArt"}, Kind: query.Bool},
+		"flag":          {Fields: []string{"IsFlagged"}, Kind: query.Bool},
+	},
+	BareInt:   "member",
+	BareText:  "text",
+	IsBareInt: member.IsID,
+}
+
+// memberMatcher matches the listing's member identifier, combined from its member number and extension.
+func memberMatcher(t query.Term) (q.Matcher, error) {
+	fm, err := member.FieldMatcher(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return listingMatcher(func(l *Listing) (bool, error) {
+		return fm.MatchField(l.Member())
+	}), nil
+}
+
+// listingMatcher matches listing records with a predicate.
+type listingMatcher func(l *Listing) (bool, error)
+
+func (m listingMatcher) Match(i interface{}) (bool, error) {
+	switch l := i.(type) {
+	case *Listing:
+		return m(l)
+	case Listing:
+		return m(&l)
+	default:
+		return false, fmt.Errorf("record of type %T is not a listing", i)
+	}
+}
+
+// TextIndex returns the full-text index of listing text stored under n. Pass the transaction that saves or
+// deletes listings so the index stays in step with them.
+func TextIndex(n storm.Node) *textindex.Index {
+	return textindex.New(n, TextIndexName)
+}
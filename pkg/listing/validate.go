@@ -0,0 +1,151 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package lstg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is returned when a listing has fields that can't be stored.
+var ErrInvalid = errors.New("invalid listing")
+
+// MinYear is the earliest year a listing can be published in.
+const MinYear = 1970
+
+// Seasons are the seasons an issue is published in.
+var Seasons = []string{"Spring", "Summer", "Fall", "Winter"}
+
+// A FieldError describes why a field of a listing is invalid. Fields are named as they are in import files.
+type FieldError struct {
+	Field   string
+	Value   interface{}
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s %#v: %s", e.Field, e.Value, e.Message)
+}
+
+// FieldErrors holds every invalid field of a listing.
+type FieldErrors []FieldError
+
+func (ee FieldErrors) Error() string {
+	msgs := make([]string, len(ee))
+	for i, e := range ee {
+		msgs[i] = e.Error()
+	}
+
+	return fmt.Sprintf("%s: %s", ErrInvalid, strings.Join(msgs, "; "))
+}
+
+// Is reports whether target is ErrInvalid, so field errors can be checked with errors.Is.
+func (ee FieldErrors) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// Rules are the limits listings are checked against.
+type Rules struct {
+	// Categories are the known listing categories. Any category is allowed when there are none.
+	Categories []string
+
+	MinYear int
+	MaxYear int
+}
+
+// DefaultRules returns rules allowing listings from MinYear until next year, in any category.
+func DefaultRules() Rules {
+	return Rules{MinYear: MinYear, MaxYear: time.Now().Year() + 1}
+}
+
+// Validate checks every field of a listing, and returns FieldErrors naming the invalid ones.
+func (l *Listing) Validate(r Rules) error {
+	ee := FieldErrors{}
+
+	for field, v := range map[string]int{"volume": l.Volume, "issue": l.IssueNumber, "page": l.PageNumber, "member": l.IndexedMemberNumber} {
+		if v <= 0 {
+			ee = append(ee, FieldError{Field: field, Value: v, Message: "must be positive"})
+		}
+	}
+
+	if l.Year < r.MinYear || l.Year > r.MaxYear {
+		ee = append(ee, FieldError{Field: "year", Value: l.Year, Message: fmt.Sprintf("must be from %d to %d", r.MinYear, r.MaxYear)})
+	}
+
+	if !containsFold(Seasons, l.Season) {
+		ee = append(ee, FieldError{Field: "season", Value: l.Season, Message: "must be " + strings.Join(Seasons, ", ")})
+	}
+
+	switch {
+	case strings.TrimSpace(l.IndexedCategory) == "":
+		ee = append(ee, FieldError{Field: "category", Value: l.IndexedCategory, Message: "must not be empty"})
+	case len(r.Categories) > 0 && !containsFold(r.Categories, l.IndexedCategory):
+		ee = append(ee, FieldError{Field: "category", Value: l.IndexedCategory, Message: "must be a known category"})
+	}
+
+	if ext := l.MemberExtension; len(ext) > 1 || (ext != "" && !isLetter(ext[0])) {
+		ee = append(ee, FieldError{Field: "alt", Value: ext, Message: "must be a single letter"})
+	}
+
+	if strings.TrimSpace(l.ListingText) == "" {
+		ee = append(ee, FieldError{Field: "text", Value: l.ListingText, Message: "must not be empty"})
+	}
+
+	if len(ee) == 0 {
+		return nil
+	}
+
+	ee.Sort()
+
+	return ee
+}
+
+// fieldOrder is the order fields are written in import files.
+var fieldOrder = []string{"volume", "issue", "year", "season", "page", "category", "member", "alt", "text"}
+
+// Sort puts field errors in the order the fields are written in import files.
+func (ee FieldErrors) Sort() {
+	pos := map[string]int{}
+	for i, f := range fieldOrder {
+		pos[f] = i
+	}
+
+	sort.SliceStable(ee, func(i, j int) bool { return pos[ee[i].Field] < pos[ee[j].Field] })
+}
+
+func containsFold(ss []string, s string) bool {
+	for _, v := range ss {
+		if strings.EqualFold(v, strings.TrimSpace(s)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2021 Ben Lechlitner <otherland@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package lstg_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	lstg "github.com/asphaltbuffet/ogma/pkg/listing"
+)
+
+func TestListingValidate(t *testing.T) {
+	valid := lstg.Listing{
+		Volume:              14,
+		IssueNumber:         56,
+		Year:                2021,
+		Season:              "spring",
+		PageNumber:          3,
+		IndexedCategory:     "Art & Photography",
+		IndexedMemberNumber: 1234,
+		MemberExtension:     "b",
+		ListingText:         "Fingerpainting exchange.",
+	}
+
+	rules := lstg.Rules{MinYear: 1970, MaxYear: 2022}
+
+	tests := []struct {
+		name   string
+		change func(l *lstg.Listing)
+		rules  lstg.Rules
+		want   string
+	}{
+		{name: "valid", change: func(l *lstg.Listing) {}, rules: rules},
+		{name: "known category", change: func(l *lstg.Listing) {}, rules: lstg.Rules{Categories: []string{"art & photography"}, MinYear: 1970, MaxYear: 2022}},
+		{name: "unknown category", change: func(l *lstg.Listing) {}, rules: lstg.Rules{Categories: []string{"Pen Pals"}, MinYear: 1970, MaxYear: 2022}, want: `invalid listing: category "Art & Photography": must be a known category`},
+		{name: "empty category", change: func(l *lstg.Listing) { l.IndexedCategory = " " }, rules: rules, want: `invalid listing: category " ": must not be empty`},
+		{name: "volume", change: func(l *lstg.Listing) { l.Volume = 0 }, rules: rules, want: "invalid listing: volume 0: must be positive"},
+		{name: "issue", change: func(l *lstg.Listing) { l.IssueNumber = -56 }, rules: rules, want: "invalid listing: issue -56: must be positive"},
+		{name: "page", change: func(l *lstg.Listing) { l.PageNumber = -1 }, rules: rules, want: "invalid listing: page -1: must be positive"},
+		{name: "member", change: func(l *lstg.Listing) { l.IndexedMemberNumber = 0 }, rules: rules, want: "invalid listing: member 0: must be positive"},
+		{name: "year too early", change: func(l *lstg.Listing) { l.Year = 1969 }, rules: rules, want: "invalid listing: year 1969: must be from 1970 to 2022"},
+		{name: "year too late", change: func(l *lstg.Listing) { l.Year = 2023 }, rules: rules, want: "invalid listing: year 2023: must be from 1970 to 2022"},
+		{name: "season", change: func(l *lstg.Listing) { l.Season = "Sprng" }, rules: rules, want: `invalid listing: season "Sprng": must be Spring, Summer, Fall, Winter`},
+		{name: "extension", change: func(l *lstg.Listing) { l.MemberExtension = "1" }, rules: rules, want: `invalid listing: alt "1": must be a single letter`},
+		{name: "text", change: func(l *lstg.Listing) { l.ListingText = "" }, rules: rules, want: `invalid listing: text "": must not be empty`},
+		{
+			name:   "fields in file order",
+			change: func(l *lstg.Listing) { l.ListingText, l.PageNumber, l.Volume = "", 0, 0 },
+			rules:  rules,
+			want:   `invalid listing: volume 0: must be positive; page 0: must be positive; text "": must not be empty`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := valid
+			tt.change(&l)
+
+			err := l.Validate(tt.rules)
+			if tt.want == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tt.want)
+			assert.True(t, errors.Is(err, lstg.ErrInvalid))
+
+			var ee lstg.FieldErrors
+			assert.True(t, errors.As(err, &ee))
+		})
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	r := lstg.DefaultRules()
+
+	assert.Equal(t, lstg.MinYear, r.MinYear)
+	assert.Greater(t, r.MaxYear, 2021)
+	assert.Empty(t, r.Categories)
+}
@@ -0,0 +1,108 @@
+// Package member identifies LEX members.
+//
+// A member identifier is a member number with an optional one letter extension, written together as
+// "1234" or "1234A". Extensions are stored in uppercase.
+package member
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidID is returned when text is not a member identifier.
+var ErrInvalidID = errors.New("invalid member identifier")
+
+// An ID is a member number and optional extension.
+type ID struct {
+	Number    int
+	Extension string
+}
+
+// Parse reads a member identifier such as "1234" or "1234a".
+func Parse(s string) (ID, error) {
+	s = strings.TrimSpace(s)
+
+	digits := strings.TrimRightFunc(s, unicode.IsLetter)
+	ext := strings.ToUpper(s[len(digits):])
+
+	n, err := strconv.Atoi(digits)
+	if err != nil || n <= 0 || strings.ContainsAny(digits, "+-") {
+		return ID{}, fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+
+	if len(ext) > 1 || (ext != "" && (ext[0] < 'A' || ext[0] > 'Z')) {
+		return ID{}, fmt.Errorf("%w: extension must be a single letter: %q", ErrInvalidID, s)
+	}
+
+	return ID{Number: n, Extension: ext}, nil
+}
+
+// IsID reports whether s can be parsed as a member identifier.
+func IsID(s string) bool {
+	_, err := Parse(s)
+
+	return err == nil
+}
+
+// String returns the identifier as it is written, like "1234A".
+func (id ID) String() string {
+	if id.IsZero() {
+		return ""
+	}
+
+	return strconv.Itoa(id.Number) + id.Extension
+}
+
+// IsZero reports whether the identifier is unset.
+func (id ID) IsZero() bool {
+	return id == ID{}
+}
+
+// Matches reports whether other is identified by id. An id without an extension matches every extension of
+// its number.
+func (id ID) Matches(other ID) bool {
+	if id.Number != other.Number {
+		return false
+	}
+
+	return id.Extension == "" || id.Extension == other.Extension
+}
+
+// MarshalJSON writes the identifier as a string.
+func (id ID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.String())
+}
+
+// UnmarshalJSON reads an identifier from a string, or from a plain number as written by earlier versions.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*id = ID{Number: n}
+
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidID, data)
+	}
+
+	if s == "" {
+		*id = ID{}
+
+		return nil
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*id = parsed
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package member_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/member"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      member.ID
+		assertion assert.ErrorAssertionFunc
+	}{
+		{in: "1234", want: member.ID{Number: 1234}, assertion: assert.NoError},
+		{in: "1234A", want: member.ID{Number: 1234, Extension: "A"}, assertion: assert.NoError},
+		{in: " 1234b ", want: member.ID{Number: 1234, Extension: "B"}, assertion: assert.NoError},
+		{in: "", want: member.ID{}, assertion: assert.Error},
+		{in: "A", want: member.ID{}, assertion: assert.Error},
+		{in: "0", want: member.ID{}, assertion: assert.Error},
+		{in: "-12", want: member.ID{}, assertion: assert.Error},
+		{in: "1234AB", want: member.ID{}, assertion: assert.Error},
+		{in: "12A4", want: member.ID{}, assertion: assert.Error},
+		{in: "1234é", want: member.ID{}, assertion: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := member.Parse(tt.in)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIDString(t *testing.T) {
+	assert.Equal(t, "1234", member.ID{Number: 1234}.String())
+	assert.Equal(t, "1234A", member.ID{Number: 1234, Extension: "A"}.String())
+	assert.Equal(t, "", member.ID{}.String())
+}
+
+func TestIDMatches(t *testing.T) {
+	plain := member.ID{Number: 1234}
+	a := member.ID{Number: 1234, Extension: "A"}
+	b := member.ID{Number: 1234, Extension: "B"}
+
+	assert.True(t, plain.Matches(plain))
+	assert.True(t, plain.Matches(a))
+	assert.True(t, a.Matches(a))
+	assert.False(t, a.Matches(b))
+	assert.False(t, a.Matches(plain))
+	assert.False(t, plain.Matches(member.ID{Number: 5678}))
+}
+
+func TestIDJSON(t *testing.T) {
+	type record struct {
+		Sender member.ID `json:"sender"`
+	}
+
+	data, err := json.Marshal(record{Sender: member.ID{Number: 1234, Extension: "A"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"sender": "1234A"}`, string(data))
+
+	tests := []struct {
+		name      string
+		in        string
+		want      member.ID
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "string", in: `{"sender": "1234a"}`, want: member.ID{Number: 1234, Extension: "A"}, assertion: assert.NoError},
+		{name: "legacy number", in: `{"sender": 1234}`, want: member.ID{Number: 1234}, assertion: assert.NoError},
+		{name: "empty", in: `{"sender": ""}`, want: member.ID{}, assertion: assert.NoError},
+		{name: "invalid", in: `{"sender": "abc"}`, want: member.ID{}, assertion: assert.Error},
+		{name: "wrong type", in: `{"sender": true}`, want: member.ID{}, assertion: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got record
+			tt.assertion(t, json.Unmarshal([]byte(tt.in), &got))
+			assert.Equal(t, tt.want, got.Sender)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package member
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/asphaltbuffet/ogma/pkg/query"
+)
+
+// FieldMatcher returns a matcher for ID fields. A term like '1234' matches every extension of the number,
+// '1234B' only that extension, and 'min..max' a range of numbers.
+func FieldMatcher(t query.Term) (q.FieldMatcher, error) {
+	if !t.IsRange {
+		id, err := Parse(t.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		return idMatcher(id.Matches), nil
+	}
+
+	lo, err := rangeBound(t.Min)
+	if err != nil {
+		return nil, err
+	}
+
+	hi, err := rangeBound(t.Max)
+	if err != nil {
+		return nil, err
+	}
+
+	return idMatcher(func(id ID) bool {
+		return (t.Min == "" || id.Number >= lo) && (t.Max == "" || id.Number <= hi)
+	}), nil
+}
+
+// Exact returns a matcher for ID fields holding exactly id, extension included.
+func Exact(id ID) q.FieldMatcher {
+	return idMatcher(func(other ID) bool { return other == id })
+}
+
+func rangeBound(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("range bounds must be member numbers: %s", s)
+	}
+
+	return n, nil
+}
+
+type idMatcher func(ID) bool
+
+func (m idMatcher) MatchField(v interface{}) (bool, error) {
+	id, ok := v.(ID)
+	if !ok {
+		return false, errors.New("field is not a member identifier")
+	}
+
+	return m(id), nil
+}
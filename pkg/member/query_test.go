@@ -0,0 +1,66 @@
+package member_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/member"
+	"github.com/asphaltbuffet/ogma/pkg/query"
+)
+
+func TestFieldMatcher(t *testing.T) {
+	a := member.ID{Number: 1234, Extension: "A"}
+
+	tests := []struct {
+		name      string
+		term      query.Term
+		want      bool
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "any extension", term: query.Term{Value: "1234"}, want: true, assertion: assert.NoError},
+		{name: "exact extension", term: query.Term{Value: "1234a"}, want: true, assertion: assert.NoError},
+		{name: "other extension", term: query.Term{Value: "1234B"}, want: false, assertion: assert.NoError},
+		{name: "range", term: query.Term{Min: "1000", Max: "1234", IsRange: true}, want: true, assertion: assert.NoError},
+		{name: "open range", term: query.Term{Min: "2000", IsRange: true}, want: false, assertion: assert.NoError},
+		{name: "invalid value", term: query.Term{Value: "abc"}, assertion: assert.Error},
+		{name: "invalid bound", term: query.Term{Max: "1234A", IsRange: true}, assertion: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, err := member.FieldMatcher(tt.term)
+			tt.assertion(t, err)
+
+			if err != nil {
+				return
+			}
+
+			got, err := fm.MatchField(a)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	fm, err := member.FieldMatcher(query.Term{Value: "1234"})
+	require.NoError(t, err)
+
+	_, err = fm.MatchField(1234)
+	assert.Error(t, err)
+}
+
+func TestExact(t *testing.T) {
+	m := member.Exact(member.ID{Number: 1234})
+
+	got, err := m.MatchField(member.ID{Number: 1234})
+	require.NoError(t, err)
+	assert.True(t, got)
+
+	got, err = m.MatchField(member.ID{Number: 1234, Extension: "A"})
+	require.NoError(t, err)
+	assert.False(t, got)
+
+	_, err = m.MatchField(1234)
+	assert.Error(t, err)
+}
@@ -0,0 +1,490 @@
+// Package query parses search expressions into storm matchers.
+//
+// An expression is a list of terms separated by spaces. Each term is either 'field:value' or a bare value,
+// and may be negated with a leading '-'. Values containing spaces are quoted, and 'min..max' matches an
+// inclusive range (either end may be left open).
+//
+//	category:"Art & Photography" year:1990..1995 international:true text:poetry -flag:true
+//
+// Terms on different fields must all match. Terms repeated for the same field match if any of them does.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/asdine/storm/v3/q"
+)
+
+var (
+	// ErrSyntax is returned when an expression cannot be parsed.
+	ErrSyntax = errors.New("query syntax error")
+
+	// ErrUnknownField is returned when a term uses a field the schema doesn't know.
+	ErrUnknownField = errors.New("unknown query field")
+
+	// ErrInvalidValue is returned when a term value doesn't suit its field.
+	ErrInvalidValue = errors.New("invalid query value")
+)
+
+// A Term is a single condition in a query expression.
+type Term struct {
+	Field   string
+	Value   string
+	Min     string
+	Max     string
+	IsRange bool
+	Negate  bool
+}
+
+// String returns the term as it would be written in an expression.
+func (t Term) String() string {
+	var sb strings.Builder
+
+	if t.Negate {
+		sb.WriteString("-")
+	}
+
+	if t.Field != "" {
+		sb.WriteString(t.Field + ":")
+	}
+
+	if t.IsRange {
+		sb.WriteString(quote(t.Min) + ".." + quote(t.Max))
+	} else {
+		sb.WriteString(quote(t.Value))
+	}
+
+	return sb.String()
+}
+
+// Parse splits an expression into terms.
+func Parse(s string) ([]Term, error) {
+	terms := []Term{}
+	rs := []rune(s)
+
+	for i := 0; i < len(rs); {
+		if unicode.IsSpace(rs[i]) {
+			i++
+			continue
+		}
+
+		var t Term
+
+		if rs[i] == '-' {
+			t.Negate = true
+			i++
+		}
+
+		// field name, if the term has one
+		j := i
+		for j < len(rs) && (unicode.IsLetter(rs[j]) || rs[j] == '_') {
+			j++
+		}
+
+		if j < len(rs) && rs[j] == ':' && j > i {
+			t.Field = strings.ToLower(string(rs[i:j]))
+			i = j + 1
+		}
+
+		raw, next, err := readValue(rs, i)
+		if err != nil {
+			return nil, err
+		}
+
+		if raw == "" {
+			return nil, fmt.Errorf("%w: missing value at position %d", ErrSyntax, i)
+		}
+
+		i = next
+
+		if lo, hi, ok := splitRange(raw); ok {
+			t.IsRange = true
+			t.Min, t.Max = unquote(lo), unquote(hi)
+
+			if t.Min == "" && t.Max == "" {
+				return nil, fmt.Errorf("%w: range needs at least one bound: %s", ErrSyntax, raw)
+			}
+		} else {
+			t.Value = unquote(raw)
+		}
+
+		terms = append(terms, t)
+	}
+
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: empty query", ErrSyntax)
+	}
+
+	return terms, nil
+}
+
+// readValue reads a raw term value starting at i. Quoted sections may contain spaces and are kept quoted.
+func readValue(rs []rune, i int) (string, int, error) {
+	start := i
+
+	for i < len(rs) && !unicode.IsSpace(rs[i]) {
+		if rs[i] != '"' {
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(rs) && rs[end] != '"' {
+			end++
+		}
+
+		if end == len(rs) {
+			return "", i, fmt.Errorf("%w: unterminated quote at position %d", ErrSyntax, i)
+		}
+
+		i = end + 1
+	}
+
+	return string(rs[start:i]), i, nil
+}
+
+// splitRange splits a raw value on the first '..' outside of quotes.
+func splitRange(raw string) (string, string, bool) {
+	quoted := false
+
+	for i := 0; i < len(raw)-1; i++ {
+		switch {
+		case raw[i] == '"':
+			quoted = !quoted
+		case !quoted && raw[i] == '.' && raw[i+1] == '.':
+			return raw[:i], raw[i+2:], true
+		}
+	}
+
+	return raw, "", false
+}
+
+// A Kind determines how a term value is compared against a record field.
+type Kind int
+
+// Field kinds supported by queries.
+const (
+	// Int fields match whole numbers and numeric ranges.
+	Int Kind = iota
+	// String fields match whole values, ignoring case.
+	String
+	// Text fields match any part of the value, ignoring case.
+	Text
+	// Bool fields match true/false, yes/no, or 1/0.
+	Bool
+	// Date fields match 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd' prefixes and ranges.
+	Date
+)
+
+// A Field maps a query field name to one or more record fields. A record matches a term if any of its
+// fields do.
+type Field struct {
+	Fields []string
+	Kind   Kind
+
+	// Matcher overrides how a term is compared with the record fields.
+	Matcher func(t Term) (q.FieldMatcher, error)
+
+	// Build overrides how a term is matched against the whole record, for values spread over several fields.
+	Build func(t Term) (q.Matcher, error)
+}
+
+// A Schema describes the query fields available for a record type.
+type Schema struct {
+	Fields map[string]Field
+
+	// BareInt and BareText name the fields used for terms without a field name.
+	BareInt  string
+	BareText string
+
+	// IsBareInt reports whether a bare value uses BareInt instead of BareText. Whole numbers do by default.
+	IsBareInt func(v string) bool
+}
+
+// Build converts terms into a single matcher for records described by the schema.
+func Build(terms []Term, s Schema) (q.Matcher, error) {
+	groups := map[string][]q.Matcher{}
+	order := []string{}
+	matchers := []q.Matcher{}
+
+	for _, t := range terms {
+		name, err := s.resolve(t)
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := s.Fields[name].matcher(t)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidValue, t, err) //nolint:errorlint // only one error can be wrapped
+		}
+
+		if t.Negate {
+			matchers = append(matchers, q.Not(m))
+			continue
+		}
+
+		if _, ok := groups[name]; !ok {
+			order = append(order, name)
+		}
+
+		groups[name] = append(groups[name], m)
+	}
+
+	for _, name := range order {
+		matchers = append(matchers, q.Or(groups[name]...))
+	}
+
+	return q.And(matchers...), nil
+}
+
+func (s Schema) resolve(t Term) (string, error) {
+	name := t.Field
+
+	if name == "" {
+		name = s.BareText
+
+		if !t.IsRange && s.isBareInt(t.Value) {
+			name = s.BareInt
+		}
+	}
+
+	if _, ok := s.Fields[name]; !ok || name == "" {
+		return "", fmt.Errorf("%w: %s", ErrUnknownField, t)
+	}
+
+	return name, nil
+}
+
+func (s Schema) isBareInt(v string) bool {
+	if s.IsBareInt != nil {
+		return s.IsBareInt(v)
+	}
+
+	_, err := strconv.Atoi(v)
+
+	return err == nil
+}
+
+func anyField(fields []string, fm q.FieldMatcher) q.Matcher {
+	if len(fields) == 1 {
+		return q.NewFieldMatcher(fields[0], fm)
+	}
+
+	mm := make([]q.Matcher, 0, len(fields))
+	for _, f := range fields {
+		mm = append(mm, q.NewFieldMatcher(f, fm))
+	}
+
+	return q.Or(mm...)
+}
+
+func (f Field) matcher(t Term) (q.Matcher, error) {
+	if f.Build != nil {
+		return f.Build(t)
+	}
+
+	fm, err := f.fieldMatcher(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return anyField(f.Fields, fm), nil
+}
+
+func (f Field) fieldMatcher(t Term) (q.FieldMatcher, error) {
+	if f.Matcher != nil {
+		return f.Matcher(t)
+	}
+
+	switch f.Kind {
+	case Int:
+		return newIntMatcher(t)
+	case String:
+		if t.IsRange {
+			return nil, errors.New("ranges are not supported")
+		}
+
+		return stringMatcher(func(v string) bool { return strings.EqualFold(v, t.Value) }), nil
+	case Text:
+		if t.IsRange {
+			return nil, errors.New("ranges are not supported")
+		}
+
+		want := strings.ToLower(t.Value)
+
+		return stringMatcher(func(v string) bool { return strings.Contains(strings.ToLower(v), want) }), nil
+	case Bool:
+		return newBoolMatcher(t)
+	case Date:
+		return newDateMatcher(t)
+	default:
+		return nil, fmt.Errorf("unsupported field kind %d", f.Kind)
+	}
+}
+
+// stringMatcher matches string fields with a predicate.
+type stringMatcher func(string) bool
+
+func (m stringMatcher) MatchField(v interface{}) (bool, error) {
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	}
+
+	return m(s), nil
+}
+
+type intMatcher struct {
+	min, max       int
+	hasMin, hasMax bool
+}
+
+func newIntMatcher(t Term) (q.FieldMatcher, error) {
+	var (
+		m   intMatcher
+		err error
+	)
+
+	if !t.IsRange {
+		m.min, err = strconv.Atoi(t.Value)
+		if err != nil {
+			return nil, fmt.Errorf("not a number: %s", t.Value)
+		}
+
+		m.max, m.hasMin, m.hasMax = m.min, true, true
+
+		return m, nil
+	}
+
+	if t.Min != "" {
+		if m.min, err = strconv.Atoi(t.Min); err != nil {
+			return nil, fmt.Errorf("not a number: %s", t.Min)
+		}
+
+		m.hasMin = true
+	}
+
+	if t.Max != "" {
+		if m.max, err = strconv.Atoi(t.Max); err != nil {
+			return nil, fmt.Errorf("not a number: %s", t.Max)
+		}
+
+		m.hasMax = true
+	}
+
+	return m, nil
+}
+
+func (m intMatcher) MatchField(v interface{}) (bool, error) {
+	rv := reflect.ValueOf(v)
+
+	var n int
+
+	switch rv.Kind() { //nolint:exhaustive // only integer fields can be matched
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = int(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = int(rv.Uint())
+	default:
+		return false, fmt.Errorf("field of type %T is not a number", v)
+	}
+
+	return (!m.hasMin || n >= m.min) && (!m.hasMax || n <= m.max), nil
+}
+
+type boolMatcher bool
+
+func newBoolMatcher(t Term) (q.FieldMatcher, error) {
+	if t.IsRange {
+		return nil, errors.New("ranges are not supported")
+	}
+
+	switch strings.ToLower(t.Value) {
+	case "true", "yes", "y", "1":
+		return boolMatcher(true), nil
+	case "false", "no", "n", "0":
+		return boolMatcher(false), nil
+	default:
+		return nil, fmt.Errorf("not a boolean: %s", t.Value)
+	}
+}
+
+func (m boolMatcher) MatchField(v interface{}) (bool, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("field of type %T is not a boolean", v)
+	}
+
+	return b == bool(m), nil
+}
+
+type dateMatcher struct {
+	value    string
+	min, max string
+	isRange  bool
+}
+
+func newDateMatcher(t Term) (q.FieldMatcher, error) {
+	for _, d := range []string{t.Value, t.Min, t.Max} {
+		if d != "" && !isDatePrefix(d) {
+			return nil, fmt.Errorf("date must be 'yyyy', 'yyyy-mm', or 'yyyy-mm-dd': %s", d)
+		}
+	}
+
+	return dateMatcher{value: t.Value, min: t.Min, max: t.Max, isRange: t.IsRange}, nil
+}
+
+func (m dateMatcher) MatchField(v interface{}) (bool, error) {
+	d := fmt.Sprint(v)
+
+	if !m.isRange {
+		return strings.HasPrefix(d, m.value), nil
+	}
+
+	// a partial upper bound includes every date it is a prefix of
+	afterMin := m.min == "" || d >= m.min
+	beforeMax := m.max == "" || d <= m.max || strings.HasPrefix(d, m.max)
+
+	return afterMin && beforeMax, nil
+}
+
+func isDatePrefix(s string) bool {
+	const layout = "0000-00-00"
+
+	if len(s) != 4 && len(s) != 7 && len(s) != 10 {
+		return false
+	}
+
+	for i, r := range s {
+		if layout[i] == '-' {
+			if r != '-' {
+				return false
+			}
+
+			continue
+		}
+
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func quote(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+
+	return s
+}
+
+func unquote(s string) string {
+	return strings.ReplaceAll(s, `"`, "")
+}
@@ -0,0 +1,243 @@
+package query_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/asdine/storm/v3/q"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/query"
+)
+
+type testRecord struct {
+	Number int
+	Other  int
+	Name   string
+	Body   string
+	Active bool
+	Date   string
+}
+
+var testSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"number": {Fields: []string{"Number"}, Kind: query.Int},
+		"either": {Fields: []string{"Number", "Other"}, Kind: query.Int},
+		"name":   {Fields: []string{"Name"}, Kind: query.String},
+		"body":   {Fields: []string{"Body"}, Kind: query.Text},
+		"active": {Fields: []string{"Active"}, Kind: query.Bool},
+		"date":   {Fields: []string{"Date"}, Kind: query.Date},
+	},
+	BareInt:  "number",
+	BareText: "body",
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		expr      string
+		want      []query.Term
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "empty",
+			expr:      "  ",
+			want:      nil,
+			assertion: assert.Error,
+		},
+		{
+			name: "bare values",
+			expr: "1234 poetry",
+			want: []query.Term{
+				{Value: "1234"},
+				{Value: "poetry"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "fields, quotes, ranges, and negation",
+			expr: `category:"Art & Photography" year:1990..1995 International:true -flag:true`,
+			want: []query.Term{
+				{Field: "category", Value: "Art & Photography"},
+				{Field: "year", Min: "1990", Max: "1995", IsRange: true},
+				{Field: "international", Value: "true"},
+				{Field: "flag", Value: "true", Negate: true},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "open ranges",
+			expr: "year:1990.. page:..4",
+			want: []query.Term{
+				{Field: "year", Min: "1990", IsRange: true},
+				{Field: "page", Max: "4", IsRange: true},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "quoted range bounds",
+			expr: `name:"a b".."c d"`,
+			want: []query.Term{
+				{Field: "name", Min: "a b", Max: "c d", IsRange: true},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "dots inside quotes are not a range",
+			expr: `body:"wait..what"`,
+			want: []query.Term{
+				{Field: "body", Value: "wait..what"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "unterminated quote",
+			expr:      `name:"Art`,
+			want:      nil,
+			assertion: assert.Error,
+		},
+		{
+			name:      "missing value",
+			expr:      "year:",
+			want:      nil,
+			assertion: assert.Error,
+		},
+		{
+			name:      "range without bounds",
+			expr:      "year:..",
+			want:      nil,
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := query.Parse(tt.expr)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTermString(t *testing.T) {
+	terms, err := query.Parse(`-category:"Art & Photography" year:1990.. 1234`)
+	require.NoError(t, err)
+
+	assert.Equal(t, `-category:"Art & Photography"`, terms[0].String())
+	assert.Equal(t, "year:1990..", terms[1].String())
+	assert.Equal(t, "1234", terms[2].String())
+}
+
+func TestBuild(t *testing.T) {
+	r := testRecord{
+		Number: 1234,
+		Other:  55,
+		Name:   "Art & Photography",
+		Body:   "Seeking penpals for Poetry exchange.",
+		Active: true,
+		Date:   "2021-03-15",
+	}
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    bool
+		wantErr error
+	}{
+		{name: "bare int", expr: "1234", want: true},
+		{name: "bare int mismatch", expr: "42", want: false},
+		{name: "bare text", expr: "poetry", want: true},
+		{name: "int range", expr: "number:1000..2000", want: true},
+		{name: "open int range", expr: "number:..1000", want: false},
+		{name: "string ignores case", expr: `name:"art & photography"`, want: true},
+		{name: "string is whole value", expr: "name:Art", want: false},
+		{name: "any of several fields", expr: "either:55", want: true},
+		{name: "bool", expr: "active:yes", want: true},
+		{name: "negated bool", expr: "-active:true", want: false},
+		{name: "date prefix", expr: "date:2021-03", want: true},
+		{name: "date range with partial bound", expr: "date:2020..2021-03", want: true},
+		{name: "date outside range", expr: "date:2021-04..", want: false},
+		{name: "repeated field matches any", expr: "number:1 number:1234", want: true},
+		{name: "different fields must all match", expr: "number:1234 active:false", want: false},
+		{name: "unknown field", expr: "color:red", wantErr: query.ErrUnknownField},
+		{name: "bad int", expr: "number:lots", wantErr: query.ErrInvalidValue},
+		{name: "bad bool", expr: "active:maybe", wantErr: query.ErrInvalidValue},
+		{name: "bad date", expr: "date:March", wantErr: query.ErrInvalidValue},
+		{name: "range on text", expr: "body:a..b", wantErr: query.ErrInvalidValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terms, err := query.Parse(tt.expr)
+			require.NoError(t, err)
+
+			m, err := query.Build(terms, testSchema)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			got, err := m.Match(&r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBuildOverrides(t *testing.T) {
+	r := testRecord{Number: 1234, Name: "B", Body: "1234B is here"}
+
+	// "member" matches a number and letter spread over two fields, like "1234B"
+	s := query.Schema{
+		Fields: map[string]query.Field{
+			"member": {Build: func(t query.Term) (q.Matcher, error) {
+				n, err := strconv.Atoi(t.Value[:len(t.Value)-1])
+				if err != nil {
+					return nil, err
+				}
+
+				return q.And(q.Eq("Number", n), q.Eq("Name", t.Value[len(t.Value)-1:])), nil
+			}},
+			"body": {Fields: []string{"Body"}, Kind: query.Text},
+		},
+		BareInt:   "member",
+		BareText:  "body",
+		IsBareInt: func(v string) bool { return strings.HasSuffix(v, "B") },
+	}
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    bool
+		wantErr error
+	}{
+		{name: "bare value uses build", expr: "1234B", want: true},
+		{name: "negated build", expr: "-member:1234B", want: false},
+		{name: "build mismatch", expr: "member:1234C", want: false},
+		{name: "other bare value is text", expr: "here", want: true},
+		{name: "build error", expr: "member:xB", wantErr: query.ErrInvalidValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terms, err := query.Parse(tt.expr)
+			require.NoError(t, err)
+
+			m, err := query.Build(terms, s)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			got, err := m.Match(&r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
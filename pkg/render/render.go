@@ -0,0 +1,360 @@
+// Package render writes the results of a command as a table for the terminal, or in a format that other programs
+// and documents can use.
+//
+// A Report holds results twice: as the rows of a table, and as the records they were read from. Tables are shown
+// in the terminal and written as csv, markdown, or html. Records are written as json, json lines, or yaml, with the
+// same field names as export files.
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"gopkg.in/yaml.v3"
+)
+
+// A Format is a way of writing a report.
+type Format string
+
+// Formats reports can be written in.
+const (
+	Table    Format = "table"
+	JSON     Format = "json"
+	JSONL    Format = "jsonl"
+	CSV      Format = "csv"
+	YAML     Format = "yaml"
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+)
+
+// Formats are all the formats, in the order they are listed in help.
+var Formats = []Format{Table, JSON, JSONL, CSV, YAML, Markdown, HTML}
+
+// ErrUnknownFormat is returned for a format that isn't one of Formats.
+var ErrUnknownFormat = errors.New("unknown output format")
+
+// ParseFormat returns the format with a name, in any case.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w %q: must be one of %s", ErrUnknownFormat, s, FormatNames())
+}
+
+// FormatNames lists the names of all the formats, like "table, json, jsonl".
+func FormatNames() string {
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// Structured reports whether a format writes records rather than the rows of a table.
+func (f Format) Structured() bool {
+	return f == JSON || f == JSONL || f == YAML
+}
+
+// A Report is the result of a command, as a table and as records.
+type Report struct {
+	Title   string
+	Header  table.Row
+	Rows    []table.Row
+	Footer  table.Row
+	Columns []table.ColumnConfig
+
+	// Records are the values the rows were made from, usually a slice of them.
+	Records interface{}
+
+	// Empty is shown in place of a table without rows.
+	Empty string
+
+	// Note is shown under the table in the terminal, like a count or what to do next. Other formats leave it out.
+	Note string
+}
+
+// Table returns the report as a table for the terminal, in color when pretty, followed by its note.
+func (rep Report) Table(pretty bool) string {
+	out := rep.Empty
+
+	if len(rep.Rows) > 0 || rep.Empty == "" {
+		t := rep.writer(rep.Columns)
+
+		if pretty {
+			t.SetStyle(table.StyleColoredBright)
+		}
+
+		out = t.Render()
+	}
+
+	if rep.Note != "" {
+		out += "\n" + rep.Note
+	}
+
+	return out
+}
+
+// writer returns a table writer holding the report.
+func (rep Report) writer(cc []table.ColumnConfig) table.Writer {
+	t := table.NewWriter()
+
+	t.SetTitle(rep.Title)
+
+	if len(rep.Header) > 0 {
+		t.AppendHeader(rep.Header)
+	}
+
+	t.AppendRows(rep.Rows)
+
+	if len(rep.Footer) > 0 {
+		t.AppendFooter(rep.Footer)
+	}
+
+	t.SetColumnConfigs(cc)
+
+	return t
+}
+
+// documentColumns returns the column configuration without widths, which only suit a terminal.
+func (rep Report) documentColumns() []table.ColumnConfig {
+	cc := make([]table.ColumnConfig, len(rep.Columns))
+	for i, c := range rep.Columns {
+		c.WidthMax = 0
+		c.WidthMaxEnforcer = nil
+		cc[i] = c
+	}
+
+	return cc
+}
+
+// Truncate is a column width enforcer that shortens a cell to the column's width, marking where it was cut. Only the
+// terminal table is shortened, as documents leave out column widths and keep the full text.
+func Truncate(col string, maxLen int) string {
+	return text.Snip(col, maxLen, "…")
+}
+
+// A Renderer writes reports in a format.
+type Renderer struct {
+	Format Format
+
+	// Pretty colors tables for the terminal.
+	Pretty bool
+}
+
+// Render returns a report in the renderer's format. Nothing is added after the last line.
+func (r Renderer) Render(rep Report) (string, error) {
+	switch r.Format {
+	case Table, "":
+		return rep.Table(r.Pretty), nil
+	case Markdown:
+		return rep.writer(rep.documentColumns()).RenderMarkdown(), nil
+	case HTML:
+		return rep.writer(rep.documentColumns()).RenderHTML(), nil
+	case CSV:
+		return rep.csv()
+	case JSON:
+		return marshalJSON(records(rep.Records))
+	case JSONL:
+		return marshalLines(records(rep.Records))
+	case YAML:
+		out, err := marshalJSON(records(rep.Records))
+		if err != nil {
+			return "", err
+		}
+
+		return toYAML(out)
+	}
+
+	return "", fmt.Errorf("%w %q: must be one of %s", ErrUnknownFormat, r.Format, FormatNames())
+}
+
+// A Section is one of several reports written together, named for json and yaml.
+type Section struct {
+	Name   string
+	Report Report
+}
+
+// RenderSections returns several reports together. Json and yaml write one document with a field named for each
+// section, json lines write the records of every section in turn, and the other formats write each section after a
+// blank line.
+func (r Renderer) RenderSections(ss []Section) (string, error) {
+	switch r.Format {
+	case JSON, YAML:
+		var b strings.Builder
+
+		b.WriteString("{")
+
+		for i, s := range ss {
+			if i > 0 {
+				b.WriteString(",")
+			}
+
+			name, err := json.Marshal(s.Name)
+			if err != nil {
+				return "", fmt.Errorf("error writing %s: %w", s.Name, err)
+			}
+
+			v, err := json.MarshalIndent(records(s.Report.Records), "  ", "  ")
+			if err != nil {
+				return "", fmt.Errorf("error writing %s: %w", s.Name, err)
+			}
+
+			fmt.Fprintf(&b, "\n  %s: %s", name, v)
+		}
+
+		b.WriteString("\n}")
+
+		if r.Format == YAML {
+			return toYAML(b.String())
+		}
+
+		return b.String(), nil
+	}
+
+	out := make([]string, 0, len(ss))
+
+	for _, s := range ss {
+		o, err := r.Render(s.Report)
+		if err != nil {
+			return "", fmt.Errorf("error writing %s: %w", s.Name, err)
+		}
+
+		if o != "" {
+			out = append(out, o)
+		}
+	}
+
+	sep := "\n\n"
+	if r.Format == JSONL {
+		sep = "\n"
+	}
+
+	return strings.Join(out, sep), nil
+}
+
+// records returns the records of a report, with a missing slice written as an empty one.
+func records(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice && rv.IsNil() {
+		return reflect.MakeSlice(rv.Type(), 0, 0).Interface()
+	}
+
+	return v
+}
+
+// csv returns the rows of a report as comma separated values, under the header. Colors are taken out of cells.
+func (rep Report) csv() (string, error) {
+	var b bytes.Buffer
+
+	w := csv.NewWriter(&b)
+
+	rows := rep.Rows
+	if len(rep.Header) > 0 {
+		rows = append([]table.Row{rep.Header}, rows...)
+	}
+
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			cells[i] = text.StripEscape(fmt.Sprint(c))
+		}
+
+		if err := w.Write(cells); err != nil {
+			return "", fmt.Errorf("error writing csv: %w", err)
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("error writing csv: %w", err)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func marshalJSON(v interface{}) (string, error) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error writing json: %w", err)
+	}
+
+	return string(out), nil
+}
+
+// marshalLines returns each record of a slice as json on a line of its own.
+func marshalLines(v interface{}) (string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return marshalLine(v)
+	}
+
+	lines := make([]string, rv.Len())
+
+	for i := range lines {
+		l, err := marshalLine(rv.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+
+		lines[i] = l
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func marshalLine(v interface{}) (string, error) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("error writing json: %w", err)
+	}
+
+	return string(out), nil
+}
+
+// toYAML rewrites a json document as yaml, keeping the order of its fields.
+func toYAML(doc string) (string, error) {
+	var n yaml.Node
+
+	if err := yaml.Unmarshal([]byte(doc), &n); err != nil {
+		return "", fmt.Errorf("error writing yaml: %w", err)
+	}
+
+	blockStyle(&n)
+
+	var b bytes.Buffer
+
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2) //nolint:gomnd // two spaces, as in the config file
+
+	if err := enc.Encode(&n); err != nil {
+		return "", fmt.Errorf("error writing yaml: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("error writing yaml: %w", err)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// blockStyle clears the json styles of a yaml document, so it is written as block yaml with quotes only where
+// they are needed.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
@@ -0,0 +1,238 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/render"
+)
+
+type record struct {
+	Number string `json:"number"`
+	Text   string `json:"text"`
+	Count  int    `json:"count"`
+}
+
+func report(rr []record) render.Report {
+	rep := render.Report{
+		Title:   "Records:",
+		Header:  table.Row{"Number", "Text", "Count"},
+		Records: rr,
+		Empty:   "No records found.",
+	}
+
+	if len(rr) > 1 {
+		rep.Note = "Records are counted."
+	}
+
+	for _, r := range rr {
+		rep.Rows = append(rep.Rows, table.Row{r.Number, "\x1b[1m" + r.Text + "\x1b[0m", r.Count})
+	}
+
+	return rep
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    render.Format
+		wantErr string
+	}{
+		{name: "table", in: "table", want: render.Table},
+		{name: "any case", in: "JSONL", want: render.JSONL},
+		{
+			name:    "unknown",
+			in:      "xml",
+			wantErr: `unknown output format "xml": must be one of table, json, jsonl, csv, yaml, markdown, html`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render.ParseFormat(tt.in)
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, render.ErrUnknownFormat)
+				assert.EqualError(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	rr := []record{
+		{Number: "1234", Text: `Say "hi", friend`, Count: 2},
+		{Number: "55A", Text: "true", Count: 0},
+	}
+
+	tests := []struct {
+		name   string
+		format render.Format
+		rr     []record
+		want   string
+	}{
+		{
+			name:   "table",
+			format: render.Table,
+			rr:     rr[:1],
+			want: "+-----------------------------------+\n" +
+				"| Records:                          |\n" +
+				"+--------+------------------+-------+\n" +
+				"| NUMBER | TEXT             | COUNT |\n" +
+				"+--------+------------------+-------+\n" +
+				"| 1234   | \x1b[1mSay \"hi\", friend\x1b[0m |     2 |\n" +
+				"+--------+------------------+-------+",
+		},
+		{
+			name:   "table with note",
+			format: render.Table,
+			rr:     rr,
+			want: "+-----------------------------------+\n" +
+				"| Records:                          |\n" +
+				"+--------+------------------+-------+\n" +
+				"| NUMBER | TEXT             | COUNT |\n" +
+				"+--------+------------------+-------+\n" +
+				"| 1234   | \x1b[1mSay \"hi\", friend\x1b[0m |     2 |\n" +
+				"| 55A    | \x1b[1mtrue\x1b[0m             |     0 |\n" +
+				"+--------+------------------+-------+\n" +
+				"Records are counted.",
+		},
+		{
+			name:   "empty table",
+			format: render.Table,
+			rr:     nil,
+			want:   "No records found.",
+		},
+		{
+			name:   "json",
+			format: render.JSON,
+			rr:     rr[:1],
+			want:   "[\n  {\n    \"number\": \"1234\",\n    \"text\": \"Say \\\"hi\\\", friend\",\n    \"count\": 2\n  }\n]",
+		},
+		{
+			name:   "empty json",
+			format: render.JSON,
+			rr:     nil,
+			want:   "[]",
+		},
+		{
+			name:   "json lines",
+			format: render.JSONL,
+			rr:     rr,
+			want: `{"number":"1234","text":"Say \"hi\", friend","count":2}` + "\n" +
+				`{"number":"55A","text":"true","count":0}`,
+		},
+		{
+			name:   "empty json lines",
+			format: render.JSONL,
+			rr:     nil,
+			want:   "",
+		},
+		{
+			name:   "yaml",
+			format: render.YAML,
+			rr:     rr,
+			want: "- number: \"1234\"\n  text: Say \"hi\", friend\n  count: 2\n" +
+				"- number: 55A\n  text: \"true\"\n  count: 0",
+		},
+		{
+			name:   "csv",
+			format: render.CSV,
+			rr:     rr,
+			want:   "Number,Text,Count\n1234,\"Say \"\"hi\"\", friend\",2\n55A,true,0",
+		},
+		{
+			name:   "markdown",
+			format: render.Markdown,
+			rr:     rr[1:],
+			want:   "# Records:\n| Number | Text | Count |\n| --- | --- | ---:|\n| 55A | \x1b[1mtrue\x1b[0m | 0 |",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render.Renderer{Format: tt.format}.Render(report(tt.rr))
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	rep := render.Report{
+		Header:  table.Row{"Text"},
+		Rows:    []table.Row{{"Lorem ipsum dolor sit amet"}},
+		Columns: []table.ColumnConfig{{Name: "Text", WidthMax: 12, WidthMaxEnforcer: render.Truncate}},
+	}
+
+	assert.Equal(t, "+--------------+\n| TEXT         |\n+--------------+\n| Lorem ipsum… |\n+--------------+",
+		rep.Table(false))
+
+	got, err := render.Renderer{Format: render.CSV}.Render(rep)
+	require.NoError(t, err)
+	assert.Equal(t, "Text\nLorem ipsum dolor sit amet", got)
+
+	got, err = render.Renderer{Format: render.Markdown}.Render(rep)
+	require.NoError(t, err)
+	assert.Contains(t, got, "| Lorem ipsum dolor sit amet |")
+}
+
+func TestRenderSections(t *testing.T) {
+	ss := []render.Section{
+		{Name: "records", Report: report([]record{{Number: "1234", Text: "Hello", Count: 1}})},
+		{Name: "others", Report: report(nil)},
+	}
+
+	tests := []struct {
+		name   string
+		format render.Format
+		want   string
+	}{
+		{
+			name:   "table",
+			format: render.Table,
+			want: "+------------------------+\n" +
+				"| Records:               |\n" +
+				"+--------+-------+-------+\n" +
+				"| NUMBER | TEXT  | COUNT |\n" +
+				"+--------+-------+-------+\n" +
+				"| 1234   | \x1b[1mHello\x1b[0m |     1 |\n" +
+				"+--------+-------+-------+\n\n" +
+				"No records found.",
+		},
+		{
+			name:   "json",
+			format: render.JSON,
+			want: "{\n  \"records\": [\n    {\n      \"number\": \"1234\",\n      \"text\": \"Hello\",\n      \"count\": 1\n    }\n  ],\n" +
+				"  \"others\": []\n}",
+		},
+		{
+			name:   "yaml",
+			format: render.YAML,
+			want:   "records:\n  - number: \"1234\"\n    text: Hello\n    count: 1\nothers: []",
+		},
+		{
+			name:   "json lines",
+			format: render.JSONL,
+			want:   `{"number":"1234","text":"Hello","count":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render.Renderer{Format: tt.format}.RenderSections(ss)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package textindex
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Token is a word found in text. Start and End are byte offsets of the original word.
+type Token struct {
+	Word  string
+	Term  string
+	Start int
+	End   int
+}
+
+// stopWords are common english words that are left out of the index.
+var stopWords = map[string]bool{
+	"a": true, "about": true, "after": true, "all": true, "also": true, "am": true, "an": true, "and": true,
+	"any": true, "are": true, "as": true, "at": true, "be": true, "been": true, "but": true, "by": true,
+	"can": true, "did": true, "do": true, "does": true, "for": true, "from": true, "had": true, "has": true,
+	"have": true, "he": true, "her": true, "him": true, "his": true, "how": true, "i": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "its": true, "just": true, "me": true, "more": true,
+	"my": true, "no": true, "not": true, "of": true, "on": true, "or": true, "our": true, "out": true,
+	"over": true, "she": true, "so": true, "some": true, "than": true, "that": true, "the": true,
+	"their": true, "them": true, "then": true, "there": true, "these": true, "they": true, "this": true,
+	"to": true, "too": true, "up": true, "us": true, "very": true, "was": true, "we": true, "were": true,
+	"what": true, "when": true, "which": true, "who": true, "will": true, "with": true, "would": true,
+	"you": true, "your": true,
+}
+
+// IsStopWord reports whether a lowercase word is left out of the index.
+func IsStopWord(word string) bool {
+	return stopWords[word]
+}
+
+// Tokenize splits text into words and derives the index term for each. Words are runs of letters and digits;
+// an apostrophe inside a word is dropped so "writer's" and "writers" index the same. Stop-words are skipped.
+func Tokenize(s string) []Token {
+	tokens := []Token{}
+	start := -1
+
+	emit := func(end int) {
+		word := s[start:end]
+		start = -1
+
+		lw := strings.ToLower(strings.ReplaceAll(word, "'", ""))
+		if IsStopWord(lw) {
+			return
+		}
+
+		tokens = append(tokens, Token{Word: word, Term: Stem(lw), Start: end - len(word), End: end})
+	}
+
+	for i, r := range s {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+
+		// keep apostrophes between letters as part of the word
+		if r == '\'' && start >= 0 && i+1 < len(s) {
+			next, _ := utf8.DecodeRuneInString(s[i+1:])
+			isWordRune = unicode.IsLetter(next)
+		}
+
+		switch {
+		case isWordRune && start < 0:
+			start = i
+		case !isWordRune && start >= 0:
+			emit(i)
+		}
+	}
+
+	if start >= 0 {
+		emit(len(s))
+	}
+
+	return tokens
+}
+
+// Terms returns the distinct index terms in s, in the order they first appear.
+func Terms(s string) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+
+	for _, t := range Tokenize(s) {
+		if !seen[t.Term] {
+			seen[t.Term] = true
+			terms = append(terms, t.Term)
+		}
+	}
+
+	return terms
+}
+
+// Highlight returns s with every word whose term is in terms passed through mark.
+func Highlight(s string, terms []string, mark func(string) string) string {
+	if len(terms) == 0 {
+		return s
+	}
+
+	want := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		want[t] = true
+	}
+
+	var sb strings.Builder
+
+	last := 0
+
+	for _, t := range Tokenize(s) {
+		if !want[t.Term] {
+			continue
+		}
+
+		sb.WriteString(s[last:t.Start])
+		sb.WriteString(mark(t.Word))
+		last = t.End
+	}
+
+	sb.WriteString(s[last:])
+
+	return sb.String()
+}
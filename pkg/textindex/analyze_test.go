@@ -0,0 +1,71 @@
+package textindex_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/asphaltbuffet/ogma/pkg/textindex"
+)
+
+func TestTokenize(t *testing.T) {
+	got := textindex.Tokenize("Seeking the Writer's  zines, 1986!")
+
+	assert.Equal(t, []textindex.Token{
+		{Word: "Seeking", Term: "seek", Start: 0, End: 7},
+		{Word: "Writer's", Term: "writer", Start: 12, End: 20},
+		{Word: "zines", Term: "zine", Start: 22, End: 27},
+		{Word: "1986", Term: "1986", Start: 29, End: 33},
+	}, got)
+
+	assert.Empty(t, textindex.Tokenize("to the, of it!"))
+
+	// apostrophes are only kept before a letter, which may take more than one byte
+	assert.Equal(t, []textindex.Token{
+		{Word: "l'été", Term: "lété", Start: 0, End: 7},
+		{Word: "rock", Term: "rock", Start: 8, End: 12},
+		{Word: "roll", Term: "roll", Start: 16, End: 20},
+	}, textindex.Tokenize("l'été rock'—roll"))
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"vintag", "camera"}, textindex.Terms("Vintage cameras and a vintage camera"))
+	assert.Empty(t, textindex.Terms(""))
+}
+
+func TestHighlight(t *testing.T) {
+	upper := strings.ToUpper
+
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{
+			name:  "no terms",
+			text:  "Vintage cameras wanted.",
+			terms: nil,
+			want:  "Vintage cameras wanted.",
+		},
+		{
+			name:  "stemmed matches",
+			text:  "Vintage cameras wanted, any camera.",
+			terms: textindex.Terms("vintage camera"),
+			want:  "VINTAGE CAMERAS wanted, any CAMERA.",
+		},
+		{
+			name:  "stop-words are never highlighted",
+			text:  "The camera",
+			terms: textindex.Terms("the camera"),
+			want:  "The CAMERA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, textindex.Highlight(tt.text, tt.terms, upper))
+		})
+	}
+}
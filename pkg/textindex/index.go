@@ -0,0 +1,241 @@
+// Package textindex maintains a full-text inverted index of record text inside the datastore and ranks
+// matches with BM25.
+//
+// Text is split into words, common stop-words are dropped, and each remaining word is reduced to its stem so
+// "camera", "cameras", and "Camera's" all find the same records.
+package textindex
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/asdine/storm/v3"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BucketPrefix is prepended to the index name to form the datastore bucket holding an index.
+const BucketPrefix = "__ogma_textindex_"
+
+// BM25 tuning parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+const (
+	termsBucket = "terms"
+	docsBucket  = "docs"
+	statsBucket = "stats"
+
+	docCountKey    = "doc_count"
+	totalLengthKey = "total_length"
+)
+
+// An Index is a full-text index of documents identified by record ID.
+type Index struct {
+	parent storm.Node
+	bucket string
+	node   storm.Node
+}
+
+// A Result is a document matching a search and its BM25 score.
+type Result struct {
+	ID    int
+	Score float64
+}
+
+// A posting is the number of times a term occurs in a document. Each term keeps its postings in a bucket of its
+// own, one record per document, so indexing a document only writes the postings of its terms.
+type posting struct {
+	ID    int `storm:"id"`
+	Count int `json:"count"`
+}
+
+type document struct {
+	Length int            `json:"length"`
+	Terms  map[string]int `json:"terms"`
+}
+
+// New returns the named index stored under n. Pass a transaction to update the index along with its records.
+func New(n storm.Node, name string) *Index {
+	bucket := BucketPrefix + name
+
+	return &Index{parent: n, bucket: bucket, node: n.From(bucket)}
+}
+
+// Add indexes text for a document, replacing anything previously indexed for it.
+func (ix *Index) Add(id int, text string) error {
+	if err := ix.Remove(id); err != nil {
+		return err
+	}
+
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	doc := document{Length: len(tokens), Terms: map[string]int{}}
+	for _, t := range tokens {
+		doc.Terms[t.Term]++
+	}
+
+	for term, tf := range doc.Terms {
+		if err := ix.term(term).Save(&posting{ID: id, Count: tf}); err != nil {
+			return fmt.Errorf("error indexing term %q: %w", term, err)
+		}
+	}
+
+	if err := ix.node.Set(docsBucket, id, doc); err != nil {
+		return fmt.Errorf("error indexing document %d: %w", id, err)
+	}
+
+	return ix.updateStats(1, doc.Length)
+}
+
+// Remove deletes a document from the index. Removing a document that isn't indexed does nothing.
+func (ix *Index) Remove(id int) error {
+	var doc document
+
+	err := ix.node.Get(docsBucket, id, &doc)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("error reading indexed document %d: %w", id, err)
+	}
+
+	for term := range doc.Terms {
+		err = ix.term(term).DeleteStruct(&posting{ID: id})
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return fmt.Errorf("error removing term %q: %w", term, err)
+		}
+	}
+
+	if err = ix.node.Delete(docsBucket, id); err != nil {
+		return fmt.Errorf("error removing indexed document %d: %w", id, err)
+	}
+
+	return ix.updateStats(-1, -doc.Length)
+}
+
+// Clear removes every document from the index.
+func (ix *Index) Clear() error {
+	err := ix.parent.Drop(ix.bucket)
+	if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return fmt.Errorf("error clearing text index: %w", err)
+	}
+
+	return nil
+}
+
+// Search returns documents containing any of the query's terms, best match first. A limit of zero or less
+// returns every match.
+func (ix *Index) Search(text string, limit int) ([]Result, error) {
+	terms := Terms(text)
+
+	n, total, err := ix.stats()
+	if err != nil || n == 0 || len(terms) == 0 {
+		return []Result{}, err
+	}
+
+	avgLength := float64(total) / float64(n)
+	scores := map[int]float64{}
+
+	for _, term := range terms {
+		p, err := ix.postings(term)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(p) == 0 {
+			continue
+		}
+
+		idf := math.Log(1 + (float64(n)-float64(len(p))+0.5)/(float64(len(p))+0.5))
+
+		for _, tf := range p {
+			var doc document
+			if err = ix.node.Get(docsBucket, tf.ID, &doc); err != nil {
+				return nil, fmt.Errorf("error reading indexed document %d: %w", tf.ID, err)
+			}
+
+			norm := k1 * (1 - b + b*float64(doc.Length)/avgLength)
+			scores[tf.ID] += idf * float64(tf.Count) * (k1 + 1) / (float64(tf.Count) + norm)
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, Result{ID: id, Score: score})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		return results[i].ID < results[j].ID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// Len returns the number of indexed documents.
+func (ix *Index) Len() (int, error) {
+	n, _, err := ix.stats()
+
+	return n, err
+}
+
+// term returns the node holding a term's postings.
+func (ix *Index) term(term string) storm.Node {
+	return ix.node.From(termsBucket, term)
+}
+
+func (ix *Index) postings(term string) ([]posting, error) {
+	p := []posting{}
+
+	err := ix.term(term).All(&p)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, fmt.Errorf("error reading term %q: %w", term, err)
+	}
+
+	return p, nil
+}
+
+func (ix *Index) stats() (int, int, error) {
+	var n, total int
+
+	for key, v := range map[string]*int{docCountKey: &n, totalLengthKey: &total} {
+		err := ix.node.Get(statsBucket, key, v)
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return 0, 0, fmt.Errorf("error reading text index stats: %w", err)
+		}
+	}
+
+	return n, total, nil
+}
+
+func (ix *Index) updateStats(docs int, length int) error {
+	n, total, err := ix.stats()
+	if err != nil {
+		return err
+	}
+
+	if err = ix.node.Set(statsBucket, docCountKey, n+docs); err != nil {
+		return fmt.Errorf("error updating text index stats: %w", err)
+	}
+
+	if err = ix.node.Set(statsBucket, totalLengthKey, total+length); err != nil {
+		return fmt.Errorf("error updating text index stats: %w", err)
+	}
+
+	return nil
+}
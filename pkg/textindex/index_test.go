@@ -0,0 +1,128 @@
+package textindex_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/asphaltbuffet/ogma/pkg/textindex"
+)
+
+func openIndex(t *testing.T) (*storm.DB, *textindex.Index) {
+	t.Helper()
+
+	db, err := storm.Open(filepath.Join(t.TempDir(), "index.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	ix := textindex.New(db, "Test")
+
+	docs := map[int]string{
+		1: "Vintage cameras and film wanted. Will trade stamps.",
+		2: "Collector of vintage postcards and vintage stamps.",
+		3: "Poetry exchange with other writers.",
+		4: "Camera repair manuals, camera straps, any camera brand.",
+	}
+
+	for id, text := range docs {
+		require.NoError(t, ix.Add(id, text))
+	}
+
+	return db, ix
+}
+
+func ids(rr []textindex.Result) []int {
+	got := []int{}
+	for _, r := range rr {
+		got = append(got, r.ID)
+	}
+
+	return got
+}
+
+func TestIndexSearch(t *testing.T) {
+	_, ix := openIndex(t)
+
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []int
+	}{
+		{name: "single term", text: "poetry", want: []int{3}},
+		{name: "stemmed term", text: "writer", want: []int{3}},
+		{name: "ranked by relevance", text: "vintage cameras", want: []int{1, 4, 2}},
+		{name: "limited", text: "vintage cameras", limit: 1, want: []int{1}},
+		{name: "no match", text: "knitting", want: []int{}},
+		{name: "only stop-words", text: "the and of", want: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ix.Search(tt.text, tt.limit)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(got))
+		})
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	db, ix := openIndex(t)
+
+	n, err := ix.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	// each document has a posting of its own under the term
+	term := db.From(textindex.BucketPrefix+"Test", "terms", textindex.Terms("camera")[0])
+	for id, want := range map[int]bool{1: true, 2: false, 4: true} {
+		got, err := term.KeyExists("posting", id)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, id)
+	}
+
+	// replacing a document drops its old terms
+	require.NoError(t, ix.Add(3, "Knitting patterns."))
+
+	got, err := ix.Search("poetry", 0)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	got, err = ix.Search("knit", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3}, ids(got))
+
+	require.NoError(t, ix.Remove(3))
+	require.NoError(t, ix.Remove(42))
+
+	got, err = ix.Search("knit", 0)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	n, err = ix.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	// updates in a rolled back transaction are discarded
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	require.NoError(t, textindex.New(tx, "Test").Add(5, "Poetry zines."))
+	require.NoError(t, tx.Rollback())
+
+	got, err = ix.Search("poetry", 0)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	require.NoError(t, ix.Clear())
+	require.NoError(t, ix.Clear())
+
+	n, err = ix.Len()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
@@ -0,0 +1,275 @@
+package textindex
+
+import "strings"
+
+// Stem reduces an english word to its stem using the Porter stemming algorithm. Words are expected to be
+// lowercase.
+func Stem(word string) string {
+	if len(word) <= 2 || !isASCIILetters(word) {
+		return word
+	}
+
+	w := []byte(word)
+
+	w = step1a(w)
+	w = step1b(w)
+	w = step1c(w)
+	w = replaceSuffix(w, step2Suffixes, 0)
+	w = replaceSuffix(w, step3Suffixes, 0)
+	w = step4(w)
+	w = step5(w)
+
+	return string(w)
+}
+
+func isASCIILetters(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'a' || s[i] > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isConsonant reports whether w[i] is a consonant. 'y' is a consonant unless it follows a consonant.
+func isConsonant(w []byte, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(w, i-1)
+	default:
+		return true
+	}
+}
+
+// measure counts the vowel-consonant sequences in w.
+func measure(w []byte) int {
+	m := 0
+	i := 0
+
+	// skip leading consonants
+	for i < len(w) && isConsonant(w, i) {
+		i++
+	}
+
+	for i < len(w) {
+		for i < len(w) && !isConsonant(w, i) {
+			i++
+		}
+
+		if i == len(w) {
+			break
+		}
+
+		for i < len(w) && isConsonant(w, i) {
+			i++
+		}
+
+		m++
+	}
+
+	return m
+}
+
+func hasVowel(w []byte) bool {
+	for i := range w {
+		if !isConsonant(w, i) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func endsDoubleConsonant(w []byte) bool {
+	n := len(w)
+
+	return n >= 2 && w[n-1] == w[n-2] && isConsonant(w, n-1)
+}
+
+// endsCVC reports whether w ends consonant-vowel-consonant where the last consonant is not w, x, or y.
+func endsCVC(w []byte) bool {
+	n := len(w)
+	if n < 3 || !isConsonant(w, n-3) || isConsonant(w, n-2) || !isConsonant(w, n-1) {
+		return false
+	}
+
+	switch w[n-1] {
+	case 'w', 'x', 'y':
+		return false
+	default:
+		return true
+	}
+}
+
+func hasSuffix(w []byte, s string) bool {
+	return strings.HasSuffix(string(w), s)
+}
+
+func step1a(w []byte) []byte {
+	switch {
+	case hasSuffix(w, "sses"), hasSuffix(w, "ies"):
+		return w[:len(w)-2]
+	case hasSuffix(w, "ss"):
+		return w
+	case hasSuffix(w, "s"):
+		return w[:len(w)-1]
+	default:
+		return w
+	}
+}
+
+func step1b(w []byte) []byte {
+	if hasSuffix(w, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			return w[:len(w)-1]
+		}
+
+		return w
+	}
+
+	var stem []byte
+
+	switch {
+	case hasSuffix(w, "ed") && hasVowel(w[:len(w)-2]):
+		stem = w[:len(w)-2]
+	case hasSuffix(w, "ing") && hasVowel(w[:len(w)-3]):
+		stem = w[:len(w)-3]
+	default:
+		return w
+	}
+
+	switch {
+	case hasSuffix(stem, "at"), hasSuffix(stem, "bl"), hasSuffix(stem, "iz"):
+		return append(stem, 'e')
+	case endsDoubleConsonant(stem):
+		switch stem[len(stem)-1] {
+		case 'l', 's', 'z':
+			return stem
+		default:
+			return stem[:len(stem)-1]
+		}
+	case measure(stem) == 1 && endsCVC(stem):
+		return append(stem, 'e')
+	default:
+		return stem
+	}
+}
+
+func step1c(w []byte) []byte {
+	if hasSuffix(w, "y") && hasVowel(w[:len(w)-1]) {
+		w[len(w)-1] = 'i'
+	}
+
+	return w
+}
+
+type suffixRule struct {
+	suffix      string
+	replacement string
+}
+
+// suffix rules are ordered so the longest matching suffix is found first.
+var step2Suffixes = []suffixRule{
+	{"ational", "ate"},
+	{"tional", "tion"},
+	{"enci", "ence"},
+	{"anci", "ance"},
+	{"izer", "ize"},
+	{"abli", "able"},
+	{"alli", "al"},
+	{"entli", "ent"},
+	{"eli", "e"},
+	{"ousli", "ous"},
+	{"ization", "ize"},
+	{"ation", "ate"},
+	{"ator", "ate"},
+	{"alism", "al"},
+	{"iveness", "ive"},
+	{"fulness", "ful"},
+	{"ousness", "ous"},
+	{"aliti", "al"},
+	{"iviti", "ive"},
+	{"biliti", "ble"},
+}
+
+var step3Suffixes = []suffixRule{
+	{"icate", "ic"},
+	{"ative", ""},
+	{"alize", "al"},
+	{"iciti", "ic"},
+	{"ical", "ic"},
+	{"ful", ""},
+	{"ness", ""},
+}
+
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+	"ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+// replaceSuffix applies the rule for the longest matching suffix if the remaining stem measures more than minMeasure.
+func replaceSuffix(w []byte, rules []suffixRule, minMeasure int) []byte {
+	var match *suffixRule
+
+	for i := range rules {
+		if hasSuffix(w, rules[i].suffix) && (match == nil || len(rules[i].suffix) > len(match.suffix)) {
+			match = &rules[i]
+		}
+	}
+
+	if match == nil {
+		return w
+	}
+
+	stem := w[:len(w)-len(match.suffix)]
+	if measure(stem) <= minMeasure {
+		return w
+	}
+
+	return append(stem, match.replacement...)
+}
+
+func step4(w []byte) []byte {
+	match := ""
+
+	for _, s := range step4Suffixes {
+		if hasSuffix(w, s) && len(s) > len(match) {
+			match = s
+		}
+	}
+
+	if match == "" {
+		return w
+	}
+
+	stem := w[:len(w)-len(match)]
+	if measure(stem) <= 1 {
+		return w
+	}
+
+	if match == "ion" && !hasSuffix(stem, "s") && !hasSuffix(stem, "t") {
+		return w
+	}
+
+	return stem
+}
+
+func step5(w []byte) []byte {
+	if hasSuffix(w, "e") {
+		stem := w[:len(w)-1]
+		m := measure(stem)
+
+		if m > 1 || (m == 1 && !endsCVC(stem)) {
+			w = stem
+		}
+	}
+
+	if measure(w) > 1 && endsDoubleConsonant(w) && hasSuffix(w, "l") {
+		w = w[:len(w)-1]
+	}
+
+	return w
+}
@@ -0,0 +1,45 @@
+package textindex_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/asphaltbuffet/ogma/pkg/textindex"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"sky":            "sky",
+		"relational":     "relat",
+		"generalization": "gener",
+		"cameras":        "camera",
+		"camera":         "camera",
+		"vintage":        "vintag",
+		"photography":    "photographi",
+		"writers":        "writer",
+		"controll":       "control",
+		"go":             "go",
+		"1986":           "1986",
+		"café":           "café",
+	}
+
+	for word, want := range tests {
+		t.Run(word, func(t *testing.T) {
+			assert.Equal(t, want, textindex.Stem(word))
+		})
+	}
+}